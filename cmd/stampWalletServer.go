@@ -1,22 +1,62 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"time"
+	_ "time/tzdata" // time zones of opening hours, the docker image has no zoneinfo
 
 	"github.com/urfave/cli/v2"
 
 	"github.com/StampWallet/backend/internal/api"
 	handlers "github.com/StampWallet/backend/internal/api/handlers"
 	"github.com/StampWallet/backend/internal/config"
-	"github.com/StampWallet/backend/internal/database"
 	accessors "github.com/StampWallet/backend/internal/database/accessors"
+	"github.com/StampWallet/backend/internal/database/migrations"
 	"github.com/StampWallet/backend/internal/managers"
 	"github.com/StampWallet/backend/internal/middleware"
 	"github.com/StampWallet/backend/internal/services"
+	"github.com/StampWallet/backend/internal/workers"
 )
 
+// Creates storage backend selected in config
+func createStorageBackend(config config.Config) (services.StorageBackend, error) {
+	var storageBackend services.StorageBackend
+	var err error
+	switch config.StorageBackend {
+	case "", "filesystem":
+		storageBackend, err = services.CreateFilesystemStorageBackend(config.StoragePath)
+	case "s3":
+		storageBackend, err = services.CreateS3StorageBackend(config.S3Config)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %s", config.StorageBackend)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage backend: %+v", err)
+	}
+	return storageBackend, nil
+}
+
+// Creates email service selected in config
+func createEmailService(config config.Config, logger *log.Logger) (services.EmailService, error) {
+	var emailService services.EmailService
+	var err error
+	switch config.EmailBackend {
+	case "", "smtp":
+		emailService, err = services.CreateEmailServiceImpl(config.SmtpConfig, logger)
+	case "file":
+		emailService, err = services.CreateFileEmailServiceImpl(config.EmailFilePath)
+	default:
+		return nil, fmt.Errorf("unknown email backend %s", config.EmailBackend)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create email service: %+v", err)
+	}
+	return emailService, nil
+}
+
 // Creates server from config
 func createServer(config config.Config) (*api.APIServer, error) {
 	db, err := services.GetDatabase(config)
@@ -32,29 +72,53 @@ func createServer(config config.Config) (*api.APIServer, error) {
 	}
 
 	tokenService := services.CreateTokenServiceImpl(baseServices.NewPrefix("TokenServiceImpl"))
-	emailService, err := services.CreateEmailServiceImpl(config.SmtpConfig,
-		services.NewPrefix(logger, "EmailServiceImpl"))
+	emailService, err := createEmailService(config, services.NewPrefix(logger, "EmailServiceImpl"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create emailService: %+v", err)
+		return nil, err
 	}
-	fileStorageService, err := services.CreateFileStorageServiceImpl(
-		baseServices.NewPrefix("FileStorageService"),
-		config.StoragePath)
+	emailOutbox := services.CreateEmailOutboxImpl(baseServices.NewPrefix("EmailOutboxImpl"))
+	emailTemplates, err := services.CreateEmailTemplateRegistry(config.EmailTemplatePath, config.DefaultLocale,
+		config.BackendURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load email templates: %+v", err)
+	}
+	storageBackend, err := createStorageBackend(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create fileStorageService: %+v", err)
+		return nil, err
 	}
+	fileStorageService := services.CreateFileStorageServiceImpl(
+		baseServices.NewPrefix("FileStorageService"),
+		storageBackend, config.FileQuota)
 
 	authMiddleware := middleware.CreateAuthMiddleware(services.NewPrefix(logger, "AuthMiddleware"), tokenService)
 	requireValidEmailMiddleware := middleware.CreateRequireValidEmailMiddleware(
 		services.NewPrefix(logger, "RequireValidEmailMiddleware"))
 
-	authManager := managers.CreateAuthManagerImpl(baseServices, emailService, tokenService,
-		config.VerificationEmailSubject, config.VerificationEmailBodyTemplate)
+	var loginAttemptStore services.LoginAttemptStore
+	switch config.LoginAttemptStore {
+	case "", "postgres":
+		loginAttemptStore = services.CreatePostgresLoginAttemptStore(baseServices.NewPrefix("PostgresLoginAttemptStore"))
+	case "memory":
+		loginAttemptStore = services.CreateInMemoryLoginAttemptStore()
+	default:
+		return nil, fmt.Errorf("unknown login attempt store %s", config.LoginAttemptStore)
+	}
+
+	authManager := managers.CreateAuthManagerImpl(baseServices, emailOutbox, emailTemplates, tokenService,
+		loginAttemptStore, managers.LoginThrottleConfig{
+			FreeAttempts:    config.LoginFreeAttempts,
+			BackoffBase:     config.LoginBackoffBase,
+			BackoffMax:      config.LoginBackoffMax,
+			LockoutAttempts: config.LoginLockoutAttempts,
+			LockoutDuration: config.LoginLockoutDuration,
+		}, config.TotpIssuer)
 	virtualCardManager := managers.CreateVirtualCardManagerImpl(baseServices)
 	itemDefinitionManager := managers.CreateItemDefinitionManagerImpl(baseServices, fileStorageService)
 	localCardManager := managers.CreateLocalCardManagerImpl(baseServices)
 	businessManager := managers.CreateBusinessManagerImpl(baseServices, fileStorageService)
-	transactionManager := managers.CreateTransactionManagerImpl(baseServices)
+	transactionManager := managers.CreateTransactionManagerImpl(baseServices, config.TransactionTTL)
+	pointsLedgerManager := managers.CreatePointsLedgerManagerImpl(baseServices, config.PointsExpiryWarningPeriod)
+	businessMemberManager := managers.CreateBusinessMemberManagerImpl(baseServices, emailOutbox, emailTemplates)
 
 	userAuthorizedAcessor := accessors.CreateUserAuthorizedAccessorImpl(baseServices.Database)
 	businessAuthorizedAccessor := accessors.CreateBusinessAuthorizedAccessorImpl(baseServices.Database)
@@ -67,6 +131,7 @@ func createServer(config config.Config) (*api.APIServer, error) {
 			businessManager,
 			transactionManager,
 			itemDefinitionManager,
+			businessMemberManager,
 
 			userAuthorizedAcessor,
 			businessAuthorizedAccessor,
@@ -80,6 +145,7 @@ func createServer(config config.Config) (*api.APIServer, error) {
 			businessManager,
 			transactionManager,
 			itemDefinitionManager,
+			pointsLedgerManager,
 			userAuthorizedAcessor,
 			authorizedTransactionAccessor,
 			services.NewPrefix(logger, "UserHandlers"),
@@ -90,14 +156,55 @@ func createServer(config config.Config) (*api.APIServer, error) {
 			services.NewPrefix(logger, "FileHandlers"),
 			userAuthorizedAcessor,
 		),
+		CategoryHandlers: handlers.CreateCategoryHandlers(
+			businessManager,
+			services.NewPrefix(logger, "CategoryHandlers"),
+		),
 	}
 
 	server := api.CreateAPIServer(authMiddleware, requireValidEmailMiddleware, &handlers,
 		services.NewPrefix(logger, "APIServer"), config)
 
+	// Background workers live as long as the process does
+	workers.CreateTransactionReaper(transactionManager, config.TransactionReaperInterval,
+		services.NewPrefix(logger, "TransactionReaper")).Start(context.Background())
+	workers.CreatePointsExpiryJob(pointsLedgerManager, config.PointsExpiryInterval,
+		services.NewPrefix(logger, "PointsExpiryJob")).Start(context.Background())
+	fileGCManager := managers.CreateFileGCManagerImpl(baseServices.NewPrefix("FileGCManager"),
+		fileStorageService, config.FileGCGracePeriod)
+	workers.CreateFileGCJob(fileGCManager, config.FileGCInterval,
+		services.NewPrefix(logger, "FileGCJob")).Start(context.Background())
+	emailOutboxManager := managers.CreateEmailOutboxManagerImpl(baseServices.NewPrefix("EmailOutboxManager"),
+		emailService, managers.EmailRetryConfig{
+			MaxAttempts: config.EmailMaxAttempts,
+			BackoffBase: config.EmailRetryBackoffBase,
+			BackoffMax:  config.EmailRetryBackoffMax,
+		})
+	workers.CreateEmailOutboxJob(emailOutboxManager, config.EmailOutboxInterval,
+		services.NewPrefix(logger, "EmailOutboxJob")).Start(context.Background())
+
 	return server, nil
 }
 
+// Creates migrator of embedded migrations from config
+func createMigrator(configPath string) (*migrations.Migrator, error) {
+	config, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %+v", err)
+	}
+
+	db, err := services.GetDatabase(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database: %+v", err)
+	}
+
+	embedded, err := migrations.EmbeddedMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %+v", err)
+	}
+	return migrations.CreateMigrator(db, embedded, log.Default()), nil
+}
+
 // Entrypoint of the backend app
 func main() {
 	// CLI framework configuration
@@ -127,8 +234,96 @@ func main() {
 				},
 			},
 			{
-				Name:  "automigrate",
-				Usage: "automatically migrates schema",
+				Name:  "migrate",
+				Usage: "manages database schema migrations",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "up",
+						Usage: "applies all pending migrations",
+						Action: func(ctx *cli.Context) error {
+							migrator, err := createMigrator(ctx.String("config"))
+							if err != nil {
+								return err
+							}
+							applied, err := migrator.Up()
+							if err != nil {
+								return fmt.Errorf("failed to migrate: %+v", err)
+							}
+							fmt.Printf("%d migrations applied\n", applied)
+							return nil
+						},
+					},
+					{
+						Name: "down",
+						Flags: []cli.Flag{
+							&cli.IntFlag{Name: "steps", Value: 1, Usage: "number of migrations to revert"},
+						},
+						Usage: "reverts the most recently applied migrations",
+						Action: func(ctx *cli.Context) error {
+							migrator, err := createMigrator(ctx.String("config"))
+							if err != nil {
+								return err
+							}
+							reverted, err := migrator.Down(ctx.Int("steps"))
+							if err != nil {
+								return fmt.Errorf("failed to revert migrations: %+v", err)
+							}
+							fmt.Printf("%d migrations reverted\n", reverted)
+							return nil
+						},
+					},
+					{
+						Name:  "status",
+						Usage: "lists migrations and whether they were applied",
+						Action: func(ctx *cli.Context) error {
+							migrator, err := createMigrator(ctx.String("config"))
+							if err != nil {
+								return err
+							}
+							statuses, err := migrator.Status()
+							if err != nil {
+								return fmt.Errorf("failed to get migration status: %+v", err)
+							}
+							for _, status := range statuses {
+								state := "pending"
+								if status.AppliedAt != nil {
+									state = "applied " + status.AppliedAt.Format(time.RFC3339)
+								}
+								if status.Unknown {
+									state += " (unknown to this version)"
+								}
+								fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "create",
+						ArgsUsage: "<name>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "dir", Value: migrations.DefaultDir},
+						},
+						Usage: "creates empty up and down files of a new migration",
+						Action: func(ctx *cli.Context) error {
+							if ctx.NArg() != 1 {
+								return fmt.Errorf("expected migration name")
+							}
+							upPath, downPath, err := migrations.CreateMigrationFiles(ctx.String("dir"), ctx.Args().First())
+							if err != nil {
+								return fmt.Errorf("failed to create migration: %+v", err)
+							}
+							fmt.Printf("created %s\ncreated %s\n", upPath, downPath)
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name: "reconcile-points",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "fix", Usage: "replaces mismatched balances with balances from the ledger"},
+				},
+				Usage: "compares balances of virtual cards with the points ledger",
 				Action: func(ctx *cli.Context) error {
 					config, err := config.LoadConfig(ctx.String("config"))
 					if err != nil {
@@ -139,11 +334,67 @@ func main() {
 					if err != nil {
 						return fmt.Errorf("failed to get database: %+v", err)
 					}
-					err = database.AutoMigrate(db)
 
+					pointsLedgerManager := managers.CreatePointsLedgerManagerImpl(services.BaseServices{
+						Logger:   log.Default(),
+						Database: db,
+					}, config.PointsExpiryWarningPeriod)
+					mismatches, err := pointsLedgerManager.Reconcile(ctx.Bool("fix"))
 					if err != nil {
-						return fmt.Errorf("failed to automigrate: %+v", err)
+						return fmt.Errorf("failed to reconcile points: %+v", err)
+					}
+					for _, mismatch := range mismatches {
+						fmt.Printf("virtual card %d: balance %d, ledger %d\n",
+							mismatch.VirtualCardId, mismatch.Points, mismatch.LedgerPoints)
 					}
+					fmt.Printf("%d mismatched balances\n", len(mismatches))
+					return nil
+				},
+			},
+			{
+				Name: "gc-files",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "dry-run", Usage: "only lists files that would be removed"},
+				},
+				Usage: "removes uploaded files that are not used anymore",
+				Action: func(ctx *cli.Context) error {
+					config, err := config.LoadConfig(ctx.String("config"))
+					if err != nil {
+						return fmt.Errorf("failed to load config: %+v", err)
+					}
+
+					db, err := services.GetDatabase(config)
+					if err != nil {
+						return fmt.Errorf("failed to get database: %+v", err)
+					}
+					storageBackend, err := createStorageBackend(config)
+					if err != nil {
+						return err
+					}
+
+					baseServices := services.BaseServices{
+						Logger:   log.Default(),
+						Database: db,
+					}
+					fileGCManager := managers.CreateFileGCManagerImpl(baseServices,
+						services.CreateFileStorageServiceImpl(baseServices, storageBackend, config.FileQuota),
+						config.FileGCGracePeriod)
+					report, err := fileGCManager.CollectGarbage(ctx.Bool("dry-run"))
+					if err != nil {
+						return fmt.Errorf("failed to collect garbage: %+v", err)
+					}
+					for _, publicId := range report.AbandonedStubs {
+						fmt.Printf("abandoned stub %s\n", publicId)
+					}
+					for _, publicId := range report.UnreferencedFiles {
+						fmt.Printf("unreferenced file %s\n", publicId)
+					}
+					for _, publicId := range report.OrphanedFiles {
+						fmt.Printf("orphaned file %s\n", publicId)
+					}
+					fmt.Printf("%d abandoned stubs, %d unreferenced files, %d orphaned files, %d failed\n",
+						len(report.AbandonedStubs), len(report.UnreferencedFiles), len(report.OrphanedFiles),
+						report.Failed)
 					return nil
 				},
 			},
@@ -159,16 +410,24 @@ func main() {
 				Flags: []cli.Flag{
 					&cli.StringFlag{Name: "to"},
 					&cli.StringFlag{Name: "subject"},
-					&cli.StringFlag{Name: "body"},
+					&cli.StringFlag{Name: "body", Usage: "HTML body"},
+					&cli.StringFlag{Name: "text", Usage: "plaintext alternative of the body"},
 				},
-				Usage: "sends an email",
+				Usage: "sends an email right away, without the outbox",
 				Action: func(ctx *cli.Context) error {
 					config, err := config.LoadConfig(ctx.String("config"))
-					emailService, err := services.CreateEmailServiceImpl(config.SmtpConfig, log.Default())
 					if err != nil {
-						return fmt.Errorf("failed to create email service %+v", err)
+						return fmt.Errorf("failed to load config: %+v", err)
+					}
+					emailService, err := createEmailService(config, log.Default())
+					if err != nil {
+						return err
 					}
-					return emailService.Send(ctx.String("to"), ctx.String("subject"), ctx.String("body"))
+					return emailService.Send(ctx.String("to"), services.EmailMessage{
+						Subject:  ctx.String("subject"),
+						HTMLBody: ctx.String("body"),
+						TextBody: ctx.String("text"),
+					})
 				},
 			},
 		},
@@ -12,6 +12,7 @@ type APIHandlers struct {
 	BusinessHandlers *BusinessHandlers
 	UserHandlers     *UserHandlers
 	FileHandlers     *FileHandlers
+	CategoryHandlers *CategoryHandlers
 }
 
 func (handlers *APIHandlers) Connect(rg *gin.RouterGroup, authMiddleware *AuthMiddleware,
@@ -28,4 +29,7 @@ func (handlers *APIHandlers) Connect(rg *gin.RouterGroup, authMiddleware *AuthMi
 
 	file := rg.Group("/file", authMiddleware.Handle, requireValidEmailMiddleware.Handle)
 	handlers.FileHandlers.Connect(file)
+
+	categories := rg.Group("/categories")
+	handlers.CategoryHandlers.Connect(categories)
 }
@@ -3,13 +3,18 @@ package api
 import (
 	"errors"
 	"log"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	api "github.com/StampWallet/backend/internal/api/models"
+	apiUtils "github.com/StampWallet/backend/internal/api/utils"
+	"github.com/StampWallet/backend/internal/database"
 	"github.com/StampWallet/backend/internal/managers"
 	"github.com/StampWallet/backend/internal/middleware"
+	"github.com/StampWallet/backend/internal/services"
 )
 
 type AuthHandlers struct {
@@ -58,6 +63,7 @@ func (handler *AuthHandlers) postAccount(c *gin.Context) {
 	_, token, secret, err := handler.authManager.Create(managers.UserDetails{
 		Email:    req.Email,
 		Password: req.Password,
+		Locale:   req.Locale,
 	})
 	if err != nil {
 		handler.logger.Printf("failed to authManager.Create in postAccount %+v", err)
@@ -67,6 +73,8 @@ func (handler *AuthHandlers) postAccount(c *gin.Context) {
 			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_EMAIL"})
 		} else if err == managers.ErrPasswordTooWeak {
 			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "PASSWORD_TOO_WEAK"})
+		} else if err == managers.ErrInvalidLocale {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_LOCALE"})
 		} else {
 			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
 		}
@@ -87,18 +95,78 @@ func (handler *AuthHandlers) postSession(c *gin.Context) {
 	}
 
 	// Pass login data to authManager, handle errors
-	_, token, tokenSecret, err := handler.authManager.Login(req.Email, req.Password)
+	_, token, tokenSecret, err := handler.authManager.Login(req.Email, req.Password, services.SessionMetadata{
+		UserAgent: c.Request.UserAgent(),
+		IpAddress: c.ClientIP(),
+	})
 	if err != nil {
 		handler.logger.Printf("failed to authManager.Login in postSession %+v", err)
+		var throttledErr *managers.LoginThrottledError
 		if err == managers.ErrInvalidLogin {
 			c.JSON(401, api.DefaultResponse{Status: api.UNAUTHORIZED})
+		} else if errors.As(err, &throttledErr) {
+			sendThrottled(c, throttledErr.RetryAfter)
+		} else {
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		}
+		return
+	}
+
+	// With TOTP enabled the token has to be exchanged for a session token in postSessionTotp
+	c.JSON(200, api.PostAccountSessionResponse{
+		Token:        token.TokenId + ":" + tokenSecret,
+		TotpRequired: token.TokenPurpose == database.TokenPurposeTotpPending,
+	})
+}
+
+// Sends 429 Too Many Requests with Retry-After header
+func sendThrottled(c *gin.Context, retryAfter time.Duration) {
+	// Retry-After is in whole seconds, round up so that the client does not retry too early
+	seconds := (retryAfter + time.Second - 1) / time.Second
+	c.Header("Retry-After", strconv.FormatInt(int64(seconds), 10))
+	c.JSON(429, api.DefaultResponse{Status: api.TOO_MANY_REQUESTS})
+}
+
+// Handles second step of login of users with TOTP enabled
+func (handler *AuthHandlers) postSessionTotp(c *gin.Context) {
+	// Parse request body
+	req := api.PostAccountSessionTotpRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		handler.logger.Printf("failed to parse in postSessionTotp %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	// Parse token from request
+	tokenId, tokenSecret, err := splitToken(req.Token)
+	if err != nil {
+		handler.logger.Printf("failed to splitToken in postSessionTotp %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	// Pass data to authManager, handle errors
+	_, token, sessionSecret, err := handler.authManager.VerifyTotpLogin(tokenId, tokenSecret, req.Code,
+		services.SessionMetadata{
+			UserAgent: c.Request.UserAgent(),
+			IpAddress: c.ClientIP(),
+		})
+	if err != nil {
+		handler.logger.Printf("failed to authManager.VerifyTotpLogin in postSessionTotp %+v", err)
+		var throttledErr *managers.LoginThrottledError
+		if err == managers.ErrInvalidToken || err == managers.ErrInvalidTokenPurpose {
+			c.JSON(401, api.DefaultResponse{Status: api.UNAUTHORIZED})
+		} else if err == managers.ErrInvalidTotpCode {
+			c.JSON(401, api.DefaultResponse{Status: api.UNAUTHORIZED, Message: "INVALID_CODE"})
+		} else if errors.As(err, &throttledErr) {
+			sendThrottled(c, throttledErr.RetryAfter)
 		} else {
 			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
 		}
 		return
 	}
 
-	c.JSON(200, api.PostAccountSessionResponse{Token: token.TokenId + ":" + tokenSecret})
+	c.JSON(200, api.PostAccountSessionResponse{Token: token.TokenId + ":" + sessionSecret})
 }
 
 // Handles logout request
@@ -126,6 +194,80 @@ func (handler *AuthHandlers) deleteSession(c *gin.Context) {
 	c.JSON(200, api.DefaultResponse{Status: api.OK})
 }
 
+// Handles session list request
+func (handler *AuthHandlers) getSessions(c *gin.Context) {
+	// Get user and current session from context
+	user := getUserFromContext(handler.logger, c)
+	if user == nil {
+		return
+	}
+	currentSession := getSessionFromContext(handler.logger, c)
+	if currentSession == nil {
+		return
+	}
+
+	// Get sessions from authManager
+	sessions, err := handler.authManager.GetSessions(user)
+	if err != nil {
+		handler.logger.Printf("failed to authManager.GetSessions in getSessions %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	resp := api.GetSessionsResponse{Sessions: []api.SessionApiModel{}}
+	for i := range sessions {
+		resp.Sessions = append(resp.Sessions,
+			apiUtils.ConvertSessionToApiModel(&sessions[i], sessions[i].TokenId == currentSession.TokenId))
+	}
+	c.JSON(200, resp)
+}
+
+// Handles request to log out a specific session
+func (handler *AuthHandlers) deleteSessionById(c *gin.Context) {
+	// Get user from context
+	user := getUserFromContext(handler.logger, c)
+	if user == nil {
+		return
+	}
+
+	// Pass data to authManager, handle errors
+	err := handler.authManager.RevokeSession(user, c.Param("tokenId"))
+	if err != nil {
+		handler.logger.Printf("failed to authManager.RevokeSession in deleteSessionById %+v", err)
+		if err == managers.ErrNoSuchSession {
+			c.JSON(404, api.DefaultResponse{Status: api.NOT_FOUND})
+		} else {
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		}
+		return
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
+// Handles request to log out all sessions except the current one
+func (handler *AuthHandlers) deleteOtherSessions(c *gin.Context) {
+	// Get current session from context
+	currentSession := getSessionFromContext(handler.logger, c)
+	if currentSession == nil {
+		return
+	}
+
+	// Pass data to authManager, handle errors
+	err := handler.authManager.RevokeOtherSessions(currentSession)
+	if err != nil {
+		handler.logger.Printf("failed to authManager.RevokeOtherSessions in deleteOtherSessions %+v", err)
+		if err == managers.ErrInvalidTokenPurpose {
+			c.JSON(401, api.DefaultResponse{Status: api.UNAUTHORIZED})
+		} else {
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		}
+		return
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
 // Handles email confirmation request
 func (handler *AuthHandlers) postAccountEmailConfirmation(c *gin.Context) {
 	// Parse request body
@@ -150,6 +292,69 @@ func (handler *AuthHandlers) postAccountEmailConfirmation(c *gin.Context) {
 		handler.logger.Printf("failed to authManager.ConfirmEmail in postAccountEmailConfirmation %+v", err)
 		if err == managers.ErrInvalidToken || err == managers.ErrInvalidTokenPurpose {
 			c.JSON(401, api.DefaultResponse{Status: api.UNAUTHORIZED})
+		} else if err == managers.ErrEmailExists {
+			c.JSON(409, api.DefaultResponse{Status: api.CONFLICT})
+		} else {
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		}
+		return
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
+// Handles request to resend the verification email
+func (handler *AuthHandlers) postAccountEmailConfirmationResend(c *gin.Context) {
+	// Get user from context
+	user := getUserFromContext(handler.logger, c)
+	if user == nil {
+		return
+	}
+
+	// Pass data to authManager, handle errors
+	err := handler.authManager.ResendEmailConfirmation(user)
+	if err != nil {
+		handler.logger.Printf("failed to authManager.ResendEmailConfirmation in postAccountEmailConfirmationResend %+v", err)
+		var throttledErr *managers.ResendThrottledError
+		if err == managers.ErrEmailVerified {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "EMAIL_ALREADY_VERIFIED"})
+		} else if errors.As(err, &throttledErr) {
+			sendThrottled(c, throttledErr.RetryAfter)
+		} else {
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		}
+		return
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
+// Handles email change revert request, sent from the link in the notice to the old email
+func (handler *AuthHandlers) postAccountEmailRevert(c *gin.Context) {
+	// Parse request body
+	req := api.PostAccountEmailRevertRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		handler.logger.Printf("failed to parse in postAccountEmailRevert %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	// Parse token from request
+	tokenId, tokenSecret, err := splitToken(req.Token)
+	if err != nil {
+		handler.logger.Printf("failed to splitToken in postAccountEmailRevert %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	// Pass data to authManager
+	_, err = handler.authManager.RevertEmailChange(tokenId, tokenSecret)
+	if err != nil {
+		handler.logger.Printf("failed to authManager.RevertEmailChange in postAccountEmailRevert %+v", err)
+		if err == managers.ErrInvalidToken || err == managers.ErrInvalidTokenPurpose {
+			c.JSON(401, api.DefaultResponse{Status: api.UNAUTHORIZED})
+		} else if err == managers.ErrEmailExists {
+			c.JSON(409, api.DefaultResponse{Status: api.CONFLICT})
 		} else {
 			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
 		}
@@ -176,8 +381,17 @@ func (handler *AuthHandlers) postAccountPassword(c *gin.Context) {
 		return
 	}
 
+	// Current session is kept when other sessions are logged out
+	var keepSession *database.Token
+	if req.LogoutOtherSessions {
+		keepSession = getSessionFromContext(handler.logger, c)
+		if keepSession == nil {
+			return
+		}
+	}
+
 	// Pass data to authManager, handle errors
-	_, err := handler.authManager.ChangePassword(user, req.OldPassword, req.Password)
+	_, err := handler.authManager.ChangePassword(user, req.OldPassword, req.Password, keepSession)
 	if err != nil {
 		handler.logger.Printf("failed to authManager.ChangePassword in postAccountPassword %+v", err)
 		if err == managers.ErrInvalidOldPassword {
@@ -209,8 +423,17 @@ func (handler *AuthHandlers) postAccountEmail(c *gin.Context) {
 		return
 	}
 
+	// Current session is kept when other sessions are logged out
+	var keepSession *database.Token
+	if req.LogoutOtherSessions {
+		keepSession = getSessionFromContext(handler.logger, c)
+		if keepSession == nil {
+			return
+		}
+	}
+
 	// Pass data to authManager, handle errors
-	_, err := handler.authManager.ChangeEmail(user, req.Email)
+	_, err := handler.authManager.ChangeEmail(user, req.Email, keepSession)
 	if err != nil {
 		handler.logger.Printf("failed to authManager.ChangeEmail in postAccountEmail %+v", err)
 		if err == managers.ErrEmailExists {
@@ -226,14 +449,214 @@ func (handler *AuthHandlers) postAccountEmail(c *gin.Context) {
 	c.JSON(200, api.DefaultResponse{Status: api.OK})
 }
 
+// Handles preferred language change request
+func (handler *AuthHandlers) postAccountLocale(c *gin.Context) {
+	// Parse request body
+	req := api.PostAccountLocaleRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		handler.logger.Printf("failed to parse in postAccountLocale %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	// Get user from context
+	user := getUserFromContext(handler.logger, c)
+	if user == nil {
+		return
+	}
+
+	// Pass data to authManager, handle errors
+	_, err := handler.authManager.ChangeLocale(user, req.Locale)
+	if err != nil {
+		handler.logger.Printf("failed to authManager.ChangeLocale in postAccountLocale %+v", err)
+		if err == managers.ErrInvalidLocale {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_LOCALE"})
+		} else {
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		}
+		return
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
+// Handles password reset request. Responds with OK whether the email exists or not.
+func (handler *AuthHandlers) postAccountPasswordReset(c *gin.Context) {
+	// Parse request body
+	req := api.PostAccountPasswordResetRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		handler.logger.Printf("failed to parse in postAccountPasswordReset %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	// Pass data to authManager. Errors are not reported to the client - they could tell
+	// that the email exists
+	err := handler.authManager.RequestPasswordReset(req.Email)
+	if err != nil {
+		handler.logger.Printf("failed to authManager.RequestPasswordReset in postAccountPasswordReset %+v", err)
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
+// Handles password reset confirmation request
+func (handler *AuthHandlers) postAccountPasswordResetConfirmation(c *gin.Context) {
+	// Parse request body
+	req := api.PostAccountPasswordResetConfirmationRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		handler.logger.Printf("failed to parse in postAccountPasswordResetConfirmation %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	// Parse token from request
+	tokenId, tokenSecret, err := splitToken(req.Token)
+	if err != nil {
+		handler.logger.Printf("failed to splitToken in postAccountPasswordResetConfirmation %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	// Pass data to authManager, handle errors
+	_, err = handler.authManager.ResetPassword(tokenId, tokenSecret, req.Password)
+	if err != nil {
+		handler.logger.Printf("failed to authManager.ResetPassword in postAccountPasswordResetConfirmation %+v", err)
+		if err == managers.ErrInvalidToken || err == managers.ErrInvalidTokenPurpose {
+			c.JSON(401, api.DefaultResponse{Status: api.UNAUTHORIZED})
+		} else if err == managers.ErrPasswordTooWeak {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "PASSWORD_TOO_WEAK"})
+		} else {
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		}
+		return
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
+// Handles TOTP enrollment request
+func (handler *AuthHandlers) postAccountTotp(c *gin.Context) {
+	// Get user from context
+	user := getUserFromContext(handler.logger, c)
+	if user == nil {
+		return
+	}
+
+	// Pass data to authManager, handle errors
+	secret, uri, err := handler.authManager.EnrollTotp(user)
+	if err != nil {
+		handler.logger.Printf("failed to authManager.EnrollTotp in postAccountTotp %+v", err)
+		if err == managers.ErrTotpAlreadyEnabled {
+			c.JSON(409, api.DefaultResponse{Status: api.CONFLICT})
+		} else {
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		}
+		return
+	}
+
+	c.JSON(200, api.PostAccountTotpResponse{Secret: secret, Uri: uri})
+}
+
+// Handles TOTP enrollment confirmation request
+func (handler *AuthHandlers) postAccountTotpConfirmation(c *gin.Context) {
+	// Parse request body
+	req := api.PostAccountTotpConfirmationRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		handler.logger.Printf("failed to parse in postAccountTotpConfirmation %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	// Get user from context
+	user := getUserFromContext(handler.logger, c)
+	if user == nil {
+		return
+	}
+
+	// Current session is kept when other sessions are logged out
+	var keepSession *database.Token
+	if req.LogoutOtherSessions {
+		keepSession = getSessionFromContext(handler.logger, c)
+		if keepSession == nil {
+			return
+		}
+	}
+
+	// Pass data to authManager, handle errors
+	recoveryCodes, err := handler.authManager.ConfirmTotp(user, req.Code, keepSession)
+	if err != nil {
+		handler.logger.Printf("failed to authManager.ConfirmTotp in postAccountTotpConfirmation %+v", err)
+		if err == managers.ErrTotpAlreadyEnabled {
+			c.JSON(409, api.DefaultResponse{Status: api.CONFLICT})
+		} else if err == managers.ErrTotpNotEnrolled {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "TOTP_NOT_ENROLLED"})
+		} else if err == managers.ErrInvalidTotpCode {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CODE"})
+		} else {
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		}
+		return
+	}
+
+	c.JSON(200, api.PostAccountTotpConfirmationResponse{RecoveryCodes: recoveryCodes})
+}
+
+// Handles request to disable TOTP
+func (handler *AuthHandlers) deleteAccountTotp(c *gin.Context) {
+	// Parse request body
+	req := api.DeleteAccountTotpRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		handler.logger.Printf("failed to parse in deleteAccountTotp %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	// Get user from context
+	user := getUserFromContext(handler.logger, c)
+	if user == nil {
+		return
+	}
+
+	// Pass data to authManager, handle errors
+	_, err := handler.authManager.DisableTotp(user, req.Code)
+	if err != nil {
+		handler.logger.Printf("failed to authManager.DisableTotp in deleteAccountTotp %+v", err)
+		if err == managers.ErrTotpNotEnabled {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "TOTP_NOT_ENABLED"})
+		} else if err == managers.ErrInvalidTotpCode {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CODE"})
+		} else if err == managers.ErrTotpRequired {
+			c.JSON(403, api.DefaultResponse{Status: api.FORBIDDEN, Message: "TOTP_REQUIRED"})
+		} else {
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		}
+		return
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
 func (handler *AuthHandlers) Connect(rg *gin.RouterGroup, authMiddleware *middleware.AuthMiddleware) {
 	account := rg.Group("/account")
 	{
 		account.POST("", handler.postAccount)
 		account.POST("/emailConfirmation", handler.postAccountEmailConfirmation)
+		account.POST("/emailConfirmation/resend", authMiddleware.Handle, handler.postAccountEmailConfirmationResend)
+		account.POST("/emailRevert", handler.postAccountEmailRevert)
 		account.POST("/email", authMiddleware.Handle, handler.postAccountEmail)
 		account.POST("/password", authMiddleware.Handle, handler.postAccountPassword)
+		account.POST("/locale", authMiddleware.Handle, handler.postAccountLocale)
+		account.POST("/passwordReset", handler.postAccountPasswordReset)
+		account.POST("/passwordResetConfirmation", handler.postAccountPasswordResetConfirmation)
+		account.POST("/totp", authMiddleware.Handle, handler.postAccountTotp)
+		account.DELETE("/totp", authMiddleware.Handle, handler.deleteAccountTotp)
+		account.POST("/totpConfirmation", authMiddleware.Handle, handler.postAccountTotpConfirmation)
 	}
 	rg.POST("/sessions", handler.postSession)
+	rg.POST("/sessions/totp", handler.postSessionTotp)
 	rg.DELETE("/sessions", authMiddleware.Handle, handler.deleteSession)
+	rg.GET("/sessions", authMiddleware.Handle, handler.getSessions)
+	rg.DELETE("/sessions/others", authMiddleware.Handle, handler.deleteOtherSessions)
+	rg.DELETE("/sessions/:tokenId", authMiddleware.Handle, handler.deleteSessionById)
 }
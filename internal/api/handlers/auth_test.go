@@ -12,6 +12,7 @@ import (
 	"github.com/StampWallet/backend/internal/database"
 	"github.com/StampWallet/backend/internal/managers"
 	. "github.com/StampWallet/backend/internal/managers/mocks"
+	"github.com/StampWallet/backend/internal/services"
 	. "github.com/StampWallet/backend/internal/testutils"
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
@@ -206,6 +207,7 @@ func TestAuthHandlersPostSessionOk(t *testing.T) {
 		Login(
 			gomock.Eq(testUser.Email),
 			gomock.Eq(testPassword),
+			gomock.Eq(services.SessionMetadata{}),
 		).
 		Return(
 			testUser,
@@ -275,6 +277,7 @@ func TestAuthHandlersPostSessionNok_InvLogin(t *testing.T) {
 		Login(
 			gomock.Eq(testUser.Email),
 			gomock.Eq(testPassword),
+			gomock.Eq(services.SessionMetadata{}),
 		).
 		Return(
 			nil,
@@ -295,6 +298,40 @@ func TestAuthHandlersPostSessionNok_InvLogin(t *testing.T) {
 	// TODO: MatchEntities and gomock.Eq
 }
 
+// Tests postSession when login attempts are throttled
+func TestAuthHandlersPostSessionNok_Throttled(t *testing.T) {
+	w, context, testUser, testPassword, _, _ := SetupAuthHandlersPostSession()
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		Login(
+			gomock.Eq(testUser.Email),
+			gomock.Eq(testPassword),
+			gomock.Eq(services.SessionMetadata{}),
+		).
+		Return(
+			nil,
+			nil,
+			"",
+			&managers.LoginThrottledError{RetryAfter: 1500 * time.Millisecond},
+		)
+
+	handler.postSession(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.TOO_MANY_REQUESTS}
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(429), respCode, "Response returned unexpected status code")
+	require.Equalf(t, "2", w.Header().Get("Retry-After"), "Response returned unexpected Retry-After header")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
 // deleteSession tests
 
 // Sets up tests for deleteSession
@@ -501,6 +538,226 @@ func TestAuthHandlersPostAccountEmailConfirmationNok_InvTok(t *testing.T) {
 	// TODO: MatchEntities and gomock.Eq
 }
 
+// Tests postAccountEmailConfirmation when another user has taken the pending email
+func TestAuthHandlersPostAccountEmailConfirmationNok_Taken(t *testing.T) {
+	w, context, _, tokenId, tokenSecret := SetupAuthHandlersPostAccountEmailConfirmation()
+
+	respBodyExpected := api.DefaultResponse{Status: api.CONFLICT}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		ConfirmEmail(
+			gomock.Eq(tokenId),
+			gomock.Eq(tokenSecret),
+		).
+		Return(
+			nil,
+			managers.ErrEmailExists,
+		)
+
+	handler.postAccountEmailConfirmation(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(409), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// postAccountEmailConfirmationResend tests
+
+// Sets up tests for postAccountEmailConfirmationResend
+func SetupAuthHandlersPostAccountEmailConfirmationResend() (
+	w *httptest.ResponseRecorder,
+	testUser *database.User,
+	context *gin.Context,
+) {
+	// data prep
+	gin.SetMode(gin.TestMode)
+	w = httptest.NewRecorder()
+
+	testUser = GetDefaultUser()
+
+	context = NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/auth/account/emailConfirmation/resend").
+		SetUser(testUser).
+		SetMethod("POST").
+		SetHeader("Accept", "application/json").
+		SetDefaultToken().
+		Context
+
+	return w, testUser, context
+}
+
+// Tests postAccountEmailConfirmationResend on the happy path
+func TestAuthHandlersPostAccountEmailConfirmationResendOk(t *testing.T) {
+	w, testUser, context := SetupAuthHandlersPostAccountEmailConfirmationResend()
+	respBodyExpected := api.DefaultResponse{Status: api.OK}
+
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		ResendEmailConfirmation(gomock.Eq(testUser)).
+		Return(nil)
+
+	handler.postAccountEmailConfirmationResend(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+// Tests postAccountEmailConfirmationResend when the email is already verified
+func TestAuthHandlersPostAccountEmailConfirmationResendNok_Verified(t *testing.T) {
+	w, testUser, context := SetupAuthHandlersPostAccountEmailConfirmationResend()
+	respBodyExpected := api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "EMAIL_ALREADY_VERIFIED"}
+
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		ResendEmailConfirmation(gomock.Eq(testUser)).
+		Return(managers.ErrEmailVerified)
+
+	handler.postAccountEmailConfirmationResend(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(400), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+// Tests postAccountEmailConfirmationResend when too many emails were sent recently
+func TestAuthHandlersPostAccountEmailConfirmationResendNok_Throttled(t *testing.T) {
+	w, testUser, context := SetupAuthHandlersPostAccountEmailConfirmationResend()
+	respBodyExpected := api.DefaultResponse{Status: api.TOO_MANY_REQUESTS}
+
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		ResendEmailConfirmation(gomock.Eq(testUser)).
+		Return(&managers.ResendThrottledError{RetryAfter: 10 * time.Minute})
+
+	handler.postAccountEmailConfirmationResend(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(429), respCode, "Response returned unexpected status code")
+	require.Equalf(t, "600", w.Header().Get("Retry-After"), "Response returned unexpected Retry-After header")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+// postAccountEmailRevert tests
+
+// Sets up tests for postAccountEmailRevert
+func SetupAuthHandlersPostAccountEmailRevert() (
+	w *httptest.ResponseRecorder,
+	context *gin.Context,
+	testUser *database.User,
+	tokenId string,
+	tokenSecret string,
+) {
+	// data prep
+	gin.SetMode(gin.TestMode)
+	w = httptest.NewRecorder()
+
+	tokenId = "0123456789"
+	tokenSecret = "ZWVnaDhhZWg4bGVpbDJhaXBlaW5nZWViNWFpU2hlaGUK"
+	exampleToken := tokenId + ":" + tokenSecret
+
+	testUser = GetDefaultUser()
+
+	payload := api.PostAccountEmailRevertRequest{
+		Token: exampleToken,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	context = NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/auth/account/emailRevert").
+		SetMethod("POST").
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json").
+		SetBody(payloadJson).
+		Context
+
+	return w, context, testUser, tokenId, tokenSecret
+}
+
+// Tests postAccountEmailRevert on the happy path
+func TestAuthHandlersPostAccountEmailRevertOk(t *testing.T) {
+	w, context, testUser, tokenId, tokenSecret := SetupAuthHandlersPostAccountEmailRevert()
+
+	respBodyExpected := api.DefaultResponse{Status: api.OK}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		RevertEmailChange(
+			gomock.Eq(tokenId),
+			gomock.Eq(tokenSecret),
+		).
+		Return(
+			testUser,
+			nil,
+		)
+
+	handler.postAccountEmailRevert(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// Tests postAccountEmailRevert when the token is invalid
+func TestAuthHandlersPostAccountEmailRevertNok_InvTok(t *testing.T) {
+	w, context, _, tokenId, tokenSecret := SetupAuthHandlersPostAccountEmailRevert()
+
+	respBodyExpected := api.DefaultResponse{Status: api.UNAUTHORIZED}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		RevertEmailChange(
+			gomock.Eq(tokenId),
+			gomock.Eq(tokenSecret),
+		).
+		Return(
+			nil,
+			managers.ErrInvalidToken,
+		)
+
+	handler.postAccountEmailRevert(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(401), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
 // postAccountPassword tests
 
 // Tests postAccountPassword on the happy path
@@ -548,6 +805,7 @@ func TestAuthHandlersPostAccountPasswordOk(t *testing.T) {
 			gomock.Eq(userOldPass),
 			gomock.Eq(oldPassword),
 			gomock.Eq(newPassword),
+			gomock.Nil(),
 		).
 		Return(
 			userNewPass,
@@ -652,6 +910,7 @@ func TestAuthHandlersPostAccountPasswordNok_OldPass(t *testing.T) {
 			gomock.Eq(user),
 			gomock.Eq(testPassword),
 			gomock.Eq(testPassword),
+			gomock.Nil(),
 		).
 		Return(
 			nil,
@@ -720,6 +979,7 @@ func TestAuthHandlersPostAccountEmailOk(t *testing.T) {
 		ChangeEmail(
 			gomock.Eq(testUser),
 			gomock.Eq(testNewEmailPtr),
+			gomock.Nil(),
 		).
 		Return(
 			testUserChangedEmail,
@@ -748,6 +1008,7 @@ func TestAuthHandlersPostAccountEmailNok_DupMail(t *testing.T) {
 		ChangeEmail(
 			gomock.Eq(testUser),
 			gomock.Eq(testNewEmailPtr),
+			gomock.Nil(),
 		).
 		Return(
 			nil,
@@ -762,3 +1023,668 @@ func TestAuthHandlersPostAccountEmailNok_DupMail(t *testing.T) {
 	require.Equalf(t, int(409), respCode, "Response returned unexpected status code")
 	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
 }
+
+// postAccountLocale tests
+
+// Sets up tests for postAccountLocale
+func SetupAuthHandlersPostAccountLocale(locale string) (
+	w *httptest.ResponseRecorder,
+	testUser *database.User,
+	context *gin.Context,
+) {
+	// data prep
+	gin.SetMode(gin.TestMode)
+	w = httptest.NewRecorder()
+
+	testUser = GetDefaultUser()
+	payload := api.PostAccountLocaleRequest{
+		Locale: locale,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	context = NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/auth/account/locale").
+		SetUser(testUser).
+		SetMethod("POST").
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		SetBody(payloadJson).
+		Context
+
+	return w, testUser, context
+}
+
+// Tests postAccountLocale on happy path
+func TestAuthHandlersPostAccountLocaleOk(t *testing.T) {
+	w, testUser, context := SetupAuthHandlersPostAccountLocale("pl-PL")
+	respBodyExpected := api.DefaultResponse{Status: api.OK}
+
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	changedUser := *testUser
+	changedUser.Locale = "pl-PL"
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		ChangeLocale(gomock.Eq(testUser), gomock.Eq("pl-PL")).
+		Return(&changedUser, nil)
+
+	handler.postAccountLocale(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+// Tests postAccountLocale with a locale that is not a language tag
+func TestAuthHandlersPostAccountLocaleInvalid(t *testing.T) {
+	w, testUser, context := SetupAuthHandlersPostAccountLocale("not a locale")
+	respBodyExpected := api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_LOCALE"}
+
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		ChangeLocale(gomock.Eq(testUser), gomock.Eq("not a locale")).
+		Return(nil, managers.ErrInvalidLocale)
+
+	handler.postAccountLocale(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(400), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+// postAccountPasswordReset tests
+
+// Sets up tests for postAccountPasswordReset
+func SetupAuthHandlersPostAccountPasswordReset(email string) (
+	w *httptest.ResponseRecorder,
+	context *gin.Context,
+) {
+	// data prep
+	gin.SetMode(gin.TestMode)
+	w = httptest.NewRecorder()
+
+	payload := api.PostAccountPasswordResetRequest{
+		Email: email,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	context = NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/auth/account/passwordReset").
+		SetMethod("POST").
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json").
+		SetBody(payloadJson).
+		Context
+
+	return w, context
+}
+
+// Tests postAccountPasswordReset on happy path
+func TestAuthHandlersPostAccountPasswordResetOk(t *testing.T) {
+	w, context := SetupAuthHandlersPostAccountPasswordReset("test@example.com")
+
+	respBodyExpected := api.DefaultResponse{Status: api.OK}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		RequestPasswordReset(gomock.Eq("test@example.com")).
+		Return(nil)
+
+	handler.postAccountPasswordReset(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// Tests that postAccountPasswordReset does not report errors
+func TestAuthHandlersPostAccountPasswordResetError(t *testing.T) {
+	w, context := SetupAuthHandlersPostAccountPasswordReset("test@example.com")
+
+	respBodyExpected := api.DefaultResponse{Status: api.OK}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		RequestPasswordReset(gomock.Eq("test@example.com")).
+		Return(managers.ErrUnknownError)
+
+	handler.postAccountPasswordReset(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// postAccountPasswordResetConfirmation tests
+
+// Sets up tests for postAccountPasswordResetConfirmation
+func SetupAuthHandlersPostAccountPasswordResetConfirmation(password string) (
+	w *httptest.ResponseRecorder,
+	context *gin.Context,
+	tokenId string,
+	tokenSecret string,
+) {
+	// data prep
+	gin.SetMode(gin.TestMode)
+	w = httptest.NewRecorder()
+
+	tokenId = "0123456789"
+	tokenSecret = "ZWVnaDhhZWg4bGVpbDJhaXBlaW5nZWViNWFpU2hlaGUK"
+
+	payload := api.PostAccountPasswordResetConfirmationRequest{
+		Token:    tokenId + ":" + tokenSecret,
+		Password: password,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	context = NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/auth/account/passwordResetConfirmation").
+		SetMethod("POST").
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json").
+		SetBody(payloadJson).
+		Context
+
+	return w, context, tokenId, tokenSecret
+}
+
+// Tests postAccountPasswordResetConfirmation on happy path
+func TestAuthHandlersPostAccountPasswordResetConfirmationOk(t *testing.T) {
+	w, context, tokenId, tokenSecret := SetupAuthHandlersPostAccountPasswordResetConfirmation("nu9AhYoo")
+
+	respBodyExpected := api.DefaultResponse{Status: api.OK}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		ResetPassword(
+			gomock.Eq(tokenId),
+			gomock.Eq(tokenSecret),
+			gomock.Eq("nu9AhYoo"),
+		).
+		Return(GetDefaultUser(), nil)
+
+	handler.postAccountPasswordResetConfirmation(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// Tests postAccountPasswordResetConfirmation when the token is invalid
+func TestAuthHandlersPostAccountPasswordResetConfirmationNok_InvTok(t *testing.T) {
+	w, context, tokenId, tokenSecret := SetupAuthHandlersPostAccountPasswordResetConfirmation("nu9AhYoo")
+
+	respBodyExpected := api.DefaultResponse{Status: api.UNAUTHORIZED}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		ResetPassword(
+			gomock.Eq(tokenId),
+			gomock.Eq(tokenSecret),
+			gomock.Eq("nu9AhYoo"),
+		).
+		Return(nil, managers.ErrInvalidToken)
+
+	handler.postAccountPasswordResetConfirmation(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(401), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// Tests postAccountPasswordResetConfirmation when the password is too weak
+func TestAuthHandlersPostAccountPasswordResetConfirmationNok_WeakPass(t *testing.T) {
+	w, context, tokenId, tokenSecret := SetupAuthHandlersPostAccountPasswordResetConfirmation("weak")
+
+	respBodyExpected := api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "PASSWORD_TOO_WEAK"}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		ResetPassword(
+			gomock.Eq(tokenId),
+			gomock.Eq(tokenSecret),
+			gomock.Eq("weak"),
+		).
+		Return(nil, managers.ErrPasswordTooWeak)
+
+	handler.postAccountPasswordResetConfirmation(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(400), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// getSessions tests
+
+// Sets up tests for session management handlers. Returns current session and another session of the user.
+func SetupAuthHandlersSessions(method string, endpoint string, body []byte) (
+	w *httptest.ResponseRecorder,
+	context *gin.Context,
+	testUser *database.User,
+	currentSession *database.Token,
+	otherSession *database.Token,
+) {
+	// data prep
+	gin.SetMode(gin.TestMode)
+	w = httptest.NewRecorder()
+
+	testUser = GetDefaultUser()
+	currentSession = &database.Token{
+		OwnerId:      testUser.ID,
+		TokenId:      "012346789",
+		Expires:      time.Now().Add(time.Hour * 24),
+		TokenPurpose: database.TokenPurposeSession,
+		Used:         true,
+		UserAgent:    "test agent",
+		IpAddress:    "192.0.2.1",
+	}
+	otherSession = &database.Token{
+		OwnerId:      testUser.ID,
+		TokenId:      "987643210",
+		Expires:      time.Now().Add(time.Hour * 24),
+		TokenPurpose: database.TokenPurposeSession,
+		Used:         false,
+	}
+
+	context = NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint(endpoint).
+		SetUser(testUser).
+		SetSession(currentSession).
+		SetMethod(method).
+		SetDefaultToken().
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json").
+		SetBody(body).
+		Context
+
+	return w, context, testUser, currentSession, otherSession
+}
+
+// Tests getSessions on the happy path
+func TestAuthHandlersGetSessionsOk(t *testing.T) {
+	w, context, testUser, currentSession, otherSession := SetupAuthHandlersSessions("GET", "/auth/sessions", nil)
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		GetSessions(gomock.Eq(testUser)).
+		Return([]database.Token{*currentSession, *otherSession}, nil)
+
+	handler.getSessions(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.GetSessionsResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Lenf(t, respBody.Sessions, 2, "Response returned unexpected number of sessions")
+	require.Equalf(t, currentSession.TokenId, respBody.Sessions[0].SessionId, "Response returned unexpected session")
+	require.Truef(t, respBody.Sessions[0].Current, "Current session should be marked as current")
+	require.Equalf(t, "test agent", respBody.Sessions[0].UserAgent, "Response returned unexpected user agent")
+	require.Equalf(t, "192.0.2.1", respBody.Sessions[0].IpAddress, "Response returned unexpected ip address")
+	require.Equalf(t, otherSession.TokenId, respBody.Sessions[1].SessionId, "Response returned unexpected session")
+	require.Falsef(t, respBody.Sessions[1].Current, "Other session should not be marked as current")
+}
+
+// deleteSessionById tests
+
+// Tests deleteSessionById on the happy path
+func TestAuthHandlersDeleteSessionByIdOk(t *testing.T) {
+	w, context, testUser, _, otherSession := SetupAuthHandlersSessions("DELETE", "/auth/sessions/987643210", nil)
+	context.AddParam("tokenId", otherSession.TokenId)
+
+	respBodyExpected := api.DefaultResponse{Status: api.OK}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		RevokeSession(gomock.Eq(testUser), gomock.Eq(otherSession.TokenId)).
+		Return(nil)
+
+	handler.deleteSessionById(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// Tests deleteSessionById when the session does not exist
+func TestAuthHandlersDeleteSessionByIdNotFound(t *testing.T) {
+	w, context, testUser, _, _ := SetupAuthHandlersSessions("DELETE", "/auth/sessions/unknown", nil)
+	context.AddParam("tokenId", "unknown")
+
+	respBodyExpected := api.DefaultResponse{Status: api.NOT_FOUND}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		RevokeSession(gomock.Eq(testUser), gomock.Eq("unknown")).
+		Return(managers.ErrNoSuchSession)
+
+	handler.deleteSessionById(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(404), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// deleteOtherSessions tests
+
+// Tests deleteOtherSessions on the happy path
+func TestAuthHandlersDeleteOtherSessionsOk(t *testing.T) {
+	w, context, _, currentSession, _ := SetupAuthHandlersSessions("DELETE", "/auth/sessions/others", nil)
+
+	respBodyExpected := api.DefaultResponse{Status: api.OK}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		RevokeOtherSessions(gomock.Eq(currentSession)).
+		Return(nil)
+
+	handler.deleteOtherSessions(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// Tests postAccountPassword when other sessions should be logged out
+func TestAuthHandlersPostAccountPasswordLogoutOtherSessions(t *testing.T) {
+	payload := api.PostAccountPasswordRequest{
+		OldPassword:         "zaq1@WSX",
+		Password:            "XSW@1qaz",
+		LogoutOtherSessions: true,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	w, context, testUser, currentSession, _ := SetupAuthHandlersSessions("POST", "/auth/account/password", payloadJson)
+
+	respBodyExpected := api.DefaultResponse{Status: api.OK}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		ChangePassword(
+			gomock.Eq(testUser),
+			gomock.Eq("zaq1@WSX"),
+			gomock.Eq("XSW@1qaz"),
+			gomock.Eq(currentSession),
+		).
+		Return(testUser, nil)
+
+	handler.postAccountPassword(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// TOTP tests
+
+// Tests postSession when user has TOTP enabled
+func TestAuthHandlersPostSessionOk_TotpRequired(t *testing.T) {
+	w, context, testUser, testPassword, testToken, testTokenSecret := SetupAuthHandlersPostSession()
+	testToken.TokenPurpose = database.TokenPurposeTotpPending
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		Login(
+			gomock.Eq(testUser.Email),
+			gomock.Eq(testPassword),
+			gomock.Eq(services.SessionMetadata{}),
+		).
+		Return(testUser, testToken, testTokenSecret, nil)
+
+	handler.postSession(context)
+
+	respBodyExpected := api.PostAccountSessionResponse{
+		Token:        testToken.TokenId + ":" + testTokenSecret,
+		TotpRequired: true,
+	}
+
+	respBody, respCode, respParseErr := ExtractResponse[api.PostAccountSessionResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// Sets up tests for postSessionTotp
+func SetupAuthHandlersPostSessionTotp(code string) (
+	w *httptest.ResponseRecorder,
+	context *gin.Context,
+) {
+	// data prep
+	gin.SetMode(gin.TestMode)
+	w = httptest.NewRecorder()
+
+	payload := api.PostAccountSessionTotpRequest{
+		Token: "pendingTokenId:pendingSecret",
+		Code:  code,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	context = NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/auth/sessions/totp").
+		SetMethod("POST").
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json").
+		SetBody(payloadJson).
+		Context
+
+	return w, context
+}
+
+// Tests postSessionTotp on the happy path
+func TestAuthHandlersPostSessionTotpOk(t *testing.T) {
+	w, context := SetupAuthHandlersPostSessionTotp("123456")
+	testUser := GetDefaultUser()
+	testToken := &database.Token{
+		OwnerId:      testUser.ID,
+		TokenId:      "sessionTokenId",
+		TokenPurpose: database.TokenPurposeSession,
+	}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		VerifyTotpLogin(
+			gomock.Eq("pendingTokenId"),
+			gomock.Eq("pendingSecret"),
+			gomock.Eq("123456"),
+			gomock.Eq(services.SessionMetadata{}),
+		).
+		Return(testUser, testToken, "sessionSecret", nil)
+
+	handler.postSessionTotp(context)
+
+	respBodyExpected := api.PostAccountSessionResponse{Token: "sessionTokenId:sessionSecret"}
+
+	respBody, respCode, respParseErr := ExtractResponse[api.PostAccountSessionResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// Tests postSessionTotp when the code is invalid
+func TestAuthHandlersPostSessionTotpNok_InvCode(t *testing.T) {
+	w, context := SetupAuthHandlersPostSessionTotp("000000")
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		VerifyTotpLogin(
+			gomock.Eq("pendingTokenId"),
+			gomock.Eq("pendingSecret"),
+			gomock.Eq("000000"),
+			gomock.Eq(services.SessionMetadata{}),
+		).
+		Return(nil, nil, "", managers.ErrInvalidTotpCode)
+
+	handler.postSessionTotp(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.UNAUTHORIZED, Message: "INVALID_CODE"}
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(401), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// Tests postAccountTotp on the happy path
+func TestAuthHandlersPostAccountTotpOk(t *testing.T) {
+	w, context, testUser, _, _ := SetupAuthHandlersSessions("POST", "/auth/account/totp", nil)
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		EnrollTotp(gomock.Eq(testUser)).
+		Return("SECRET", "otpauth://totp/test?secret=SECRET", nil)
+
+	handler.postAccountTotp(context)
+
+	respBodyExpected := api.PostAccountTotpResponse{Secret: "SECRET", Uri: "otpauth://totp/test?secret=SECRET"}
+
+	respBody, respCode, respParseErr := ExtractResponse[api.PostAccountTotpResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// Tests postAccountTotpConfirmation on the happy path
+func TestAuthHandlersPostAccountTotpConfirmationOk(t *testing.T) {
+	payload := api.PostAccountTotpConfirmationRequest{
+		Code:                "123456",
+		LogoutOtherSessions: true,
+	}
+	payloadJson, _ := json.Marshal(payload)
+	w, context, testUser, currentSession, _ := SetupAuthHandlersSessions("POST", "/auth/account/totpConfirmation",
+		payloadJson)
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		ConfirmTotp(gomock.Eq(testUser), gomock.Eq("123456"), gomock.Eq(currentSession)).
+		Return([]string{"abcde-fghij"}, nil)
+
+	handler.postAccountTotpConfirmation(context)
+
+	respBodyExpected := api.PostAccountTotpConfirmationResponse{RecoveryCodes: []string{"abcde-fghij"}}
+
+	respBody, respCode, respParseErr := ExtractResponse[api.PostAccountTotpConfirmationResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
+
+// Tests deleteAccountTotp when a business requires TOTP
+func TestAuthHandlersDeleteAccountTotpNok_Required(t *testing.T) {
+	payload := api.DeleteAccountTotpRequest{Code: "123456"}
+	payloadJson, _ := json.Marshal(payload)
+	w, context, testUser, _, _ := SetupAuthHandlersSessions("DELETE", "/auth/account/totp", payloadJson)
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := GetAuthHandlers(ctrl)
+
+	handler.authManager.(*MockAuthManager).
+		EXPECT().
+		DisableTotp(gomock.Eq(testUser), gomock.Eq("123456")).
+		Return(nil, managers.ErrTotpRequired)
+
+	handler.deleteAccountTotp(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.FORBIDDEN, Message: "TOTP_REQUIRED"}
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(403), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(*respBody, respBodyExpected), "Response returned unexpected body contents")
+}
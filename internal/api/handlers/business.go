@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"log"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +18,7 @@ type BusinessHandlers struct {
 	businessManager       BusinessManager
 	transactionManager    TransactionManager
 	itemDefinitionManager ItemDefinitionManager
+	businessMemberManager BusinessMemberManager
 
 	userAuthorizedAcessor         UserAuthorizedAccessor
 	businessAuthorizedAccessor    BusinessAuthorizedAccessor
@@ -29,7 +31,7 @@ type BusinessHandlers struct {
 
 func CreateBusinessHandlers(
 	businessManager BusinessManager, transactionManager TransactionManager,
-	itemDefinitionManager ItemDefinitionManager,
+	itemDefinitionManager ItemDefinitionManager, businessMemberManager BusinessMemberManager,
 	userAuthorizedAcessor UserAuthorizedAccessor, businessAuthorizedAccessor BusinessAuthorizedAccessor,
 	authorizedTransactionAccessor AuthorizedTransactionAccessor,
 	logger *log.Logger) *BusinessHandlers {
@@ -38,6 +40,7 @@ func CreateBusinessHandlers(
 		businessManager:       businessManager,
 		transactionManager:    transactionManager,
 		itemDefinitionManager: itemDefinitionManager,
+		businessMemberManager: businessMemberManager,
 
 		userAuthorizedAcessor:         userAuthorizedAcessor,
 		businessAuthorizedAccessor:    businessAuthorizedAccessor,
@@ -45,6 +48,7 @@ func CreateBusinessHandlers(
 
 		itemDefinitionHandlers: &ItemDefinitionHandlers{
 			itemDefinitionManager:      itemDefinitionManager,
+			businessMemberManager:      businessMemberManager,
 			userAuthorizedAcessor:      userAuthorizedAcessor,
 			businessAuthorizedAccessor: businessAuthorizedAccessor,
 			logger:                     services.NewPrefix(logger, "ItemDefinitionHandlers"),
@@ -54,26 +58,79 @@ func CreateBusinessHandlers(
 	}
 }
 
-// Gets business owned by user
-func (handler *BusinessHandlers) getBusinessOfUser(user *User, c *gin.Context) *Business {
+// Roles of business members, other than the owner, allowed to manage business details,
+// menu images and item definitions
+var businessManagerRoles = []BusinessMemberRoleEnum{BusinessMemberRoleManager}
+
+// Roles of business members, other than the owner, allowed to handle transactions
+var businessCashierRoles = []BusinessMemberRoleEnum{BusinessMemberRoleManager, BusinessMemberRoleCashier}
+
+// Gets business owned by user, or business user is a member of. Owner is always allowed,
+// other members only if their role is one of roles. If the business requires TOTP, user has to
+// have TOTP enabled. Sends HTTP errors and returns nil otherwise.
+func getBusinessOfUser(logger *log.Logger, userAuthorizedAcessor UserAuthorizedAccessor,
+	businessMemberManager BusinessMemberManager, user *User, c *gin.Context,
+	roles []BusinessMemberRoleEnum) *Business {
+
+	business := getBusinessOfUserByRole(logger, userAuthorizedAcessor, businessMemberManager, user, c, roles)
+	if business == nil {
+		return nil
+	}
+	if business.RequireTotp && !user.TotpEnabled {
+		c.JSON(403, api.DefaultResponse{Status: api.FORBIDDEN, Message: "TOTP_REQUIRED"})
+		return nil
+	}
+	return business
+}
+
+// See getBusinessOfUser, does not check TOTP requirement
+func getBusinessOfUserByRole(logger *log.Logger, userAuthorizedAcessor UserAuthorizedAccessor,
+	businessMemberManager BusinessMemberManager, user *User, c *gin.Context,
+	roles []BusinessMemberRoleEnum) *Business {
+
 	// Get user's business
-	businessTmp, err := handler.userAuthorizedAcessor.Get(user, &Business{})
+	businessTmp, err := userAuthorizedAcessor.Get(user, &Business{})
 
 	if err != nil && err != ErrNotFound {
-		handler.logger.Printf("failed to handler.userAuthorizedAcessor.Get in getAccountInfo %+v", err)
+		logger.Printf("failed to userAuthorizedAcessor.Get in getBusinessOfUser %+v", err)
 		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
 		return nil
-	} else if businessTmp == nil || err == ErrNotFound {
+	} else if businessTmp != nil && err == nil {
+		return businessTmp.(*Business)
+	}
+
+	// User does not own a business, check if they work for one
+	member, err := businessMemberManager.GetMembership(user)
+	if err == ErrNotMember {
 		c.JSON(404, api.DefaultResponse{Status: api.NOT_FOUND})
 		return nil
+	} else if err != nil {
+		logger.Printf("failed to businessMemberManager.GetMembership in getBusinessOfUser %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return nil
 	}
 
-	return businessTmp.(*Business)
+	for _, role := range roles {
+		if member.Role == role {
+			return member.Business
+		}
+	}
+	c.JSON(403, api.DefaultResponse{Status: api.FORBIDDEN})
+	return nil
+}
+
+// Gets business of user, see getBusinessOfUser
+func (handler *BusinessHandlers) getBusinessOfUser(user *User, c *gin.Context,
+	roles ...BusinessMemberRoleEnum) *Business {
+	return getBusinessOfUser(handler.logger, handler.userAuthorizedAcessor, handler.businessMemberManager,
+		user, c, roles)
 }
 
 // Gets user (usually inserted into the context by AuthMiddleware)
-// and business owned by user from request context.
-func (handler *BusinessHandlers) getUserAndBusiness(c *gin.Context) (*User, *Business) {
+// and business of user from request context. Members of the business other than the owner
+// are allowed only if their role is one of roles.
+func (handler *BusinessHandlers) getUserAndBusiness(c *gin.Context,
+	roles ...BusinessMemberRoleEnum) (*User, *Business) {
 	// Get user from context
 	user := getUserFromContext(handler.logger, c)
 	if user == nil {
@@ -81,7 +138,7 @@ func (handler *BusinessHandlers) getUserAndBusiness(c *gin.Context) (*User, *Bus
 	}
 
 	// Get user's business
-	business := handler.getBusinessOfUser(user, c)
+	business := handler.getBusinessOfUser(user, c, roles...)
 	if business == nil {
 		return nil, nil
 	}
@@ -123,14 +180,21 @@ func (handler *BusinessHandlers) postAccount(c *gin.Context) {
 		REGON:          req.Regon,
 		OwnerName:      req.OwnerName,
 		GPSCoordinates: coordinates,
+		Categories:     req.Categories,
 	})
 
 	// Handle errors, send response
 	if err != nil {
 		handler.logger.Printf("failed to businessManager.Create in postAccount %+v", err)
-		if err == ErrBusinessAlreadyExists {
+		if err == ErrBusinessAlreadyExists || err == ErrAlreadyMember {
 			c.JSON(409, api.DefaultResponse{Status: api.ALREADY_EXISTS})
 			return
+		} else if err == ErrInvalidCategory {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CATEGORY"})
+			return
+		} else if errors.Is(err, services.ErrFileQuotaExceeded) {
+			c.JSON(403, api.DefaultResponse{Status: api.FORBIDDEN, Message: "FILE_QUOTA_EXCEEDED"})
+			return
 		} else {
 			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
 			return
@@ -148,13 +212,13 @@ func (handler *BusinessHandlers) postAccount(c *gin.Context) {
 func (handler *BusinessHandlers) getAccountInfo(c *gin.Context) {
 	// Get user and business of user. getUserAndBusiness sends HTTP errors, so we can just quit
 	// if business or user is not available
-	user, business := handler.getUserAndBusiness(c)
+	user, business := handler.getUserAndBusiness(c, businessManagerRoles...)
 	if user == nil || business == nil {
 		return
 	}
 
 	// Get MenuItems of business
-	menuItems, err := handler.businessAuthorizedAccessor.GetAll(business, &MenuImage{})
+	menuItems, _, err := handler.businessAuthorizedAccessor.GetAll(business, &MenuImage{}, nil)
 	if err != nil {
 		handler.logger.Printf("failed to handler.businessAuthorizedAccessor.Get MenuItem in getAccountInfo %+v", err)
 		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
@@ -162,7 +226,7 @@ func (handler *BusinessHandlers) getAccountInfo(c *gin.Context) {
 	}
 
 	// Get ItemDefinitions of business
-	itemDefinitionsTmp, err := handler.businessAuthorizedAccessor.GetAll(business, &ItemDefinition{Withdrawn: false})
+	itemDefinitionsTmp, _, err := handler.businessAuthorizedAccessor.GetAll(business, &ItemDefinition{Withdrawn: false}, nil)
 	if err != nil {
 		handler.logger.Printf("failed to handler.businessAuthorizedAccessor.Get ItemDefinition in getAccountInfo %+v", err)
 		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
@@ -181,20 +245,72 @@ func (handler *BusinessHandlers) getAccountInfo(c *gin.Context) {
 		itemDefinitions = append(itemDefinitions, apiUtils.ConvertItemDefinitionToApiModel(v.(*ItemDefinition)))
 	}
 
+	// Get categories of business
+	categories, err := handler.businessManager.GetBusinessCategories(business)
+	if err != nil {
+		handler.logger.Printf("failed to handler.businessManager.GetBusinessCategories in getAccountInfo %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	// Get opening hours of business
+	openingHours, openingHoursExceptions, err := handler.businessManager.GetOpeningHours(business)
+	if err != nil {
+		handler.logger.Printf("failed to handler.businessManager.GetOpeningHours in getAccountInfo %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+	business.OpeningHours = openingHours
+	business.OpeningHoursExceptions = openingHoursExceptions
+
+	// Get locations of business
+	locations, err := handler.businessManager.GetLocations(business)
+	if err != nil {
+		handler.logger.Printf("failed to handler.businessManager.GetLocations in getAccountInfo %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	// File quota is managed by the owner
+	var fileUsage *api.FileUsageApiModel
+	if business.OwnerId == user.ID {
+		usage, err := handler.businessManager.GetFileUsage(business)
+		if err != nil {
+			handler.logger.Printf("failed to handler.businessManager.GetFileUsage in getAccountInfo %+v", err)
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+			return
+		}
+		fileUsage = &api.FileUsageApiModel{
+			Files:    int32(usage.Files),
+			Bytes:    usage.Bytes,
+			MaxFiles: int32(usage.MaxFiles),
+			MaxBytes: usage.MaxBytes,
+		}
+	}
+
 	c.JSON(200, api.GetBusinessAccountResponse{
-		PublicId:        business.PublicId,
-		Name:            business.Name,
-		Address:         business.Address,
-		GpsCoordinates:  business.GPSCoordinates.ToString(),
-		BannerImageId:   business.BannerImageId,
-		IconImageId:     business.IconImageId,
-		MenuImageIds:    menuImageIds,
-		ItemDefinitions: itemDefinitions,
-		Nip:             business.NIP,
-		Krs:             business.KRS,
-		Regon:           business.REGON,
-		OwnerName:       business.OwnerName,
-		Description:     business.Description,
+		PublicId:         business.PublicId,
+		Name:             business.Name,
+		Address:          business.Address,
+		GpsCoordinates:   business.GPSCoordinates.ToString(),
+		BannerImageId:    business.BannerImageId,
+		IconImageId:      business.IconImageId,
+		MenuImageIds:     menuImageIds,
+		ItemDefinitions:  itemDefinitions,
+		Nip:              business.NIP,
+		Krs:              business.KRS,
+		Regon:            business.REGON,
+		OwnerName:        business.OwnerName,
+		Description:      business.Description,
+		PointsExpiryDays: int32(business.PointsExpiryDays),
+		RequireTotp:      business.RequireTotp,
+		Categories:       apiUtils.ConvertCategoriesToSlugs(categories),
+		TimeZone:         business.TimeZone,
+		OpeningHours:     apiUtils.ConvertOpeningHoursToApiModel(openingHours),
+		OpeningHoursExceptions: apiUtils.ConvertOpeningHoursExceptionsToApiModel(
+			openingHoursExceptions),
+		Locations: apiUtils.ConvertBusinessLocationsToApiModel(business, locations),
+		FileUsage: fileUsage,
 	})
 }
 
@@ -210,6 +326,7 @@ func (handler *BusinessHandlers) patchAccountInfo(c *gin.Context) {
 
 	var nameToChange *string
 	var descriptionToChange *string
+	var pointsExpiryDaysToChange *uint
 
 	if req.Name != "" {
 		nameToChange = &req.Name
@@ -219,26 +336,85 @@ func (handler *BusinessHandlers) patchAccountInfo(c *gin.Context) {
 		descriptionToChange = &req.Description
 	}
 
-	// Make sure that the request is correct - either Name or Description has to be not empty
-	if nameToChange == nil && descriptionToChange == nil {
+	if req.PointsExpiryDays != nil {
+		if *req.PointsExpiryDays < 0 {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_POINTS_EXPIRY_DAYS"})
+			return
+		}
+		pointsExpiryDays := uint(*req.PointsExpiryDays)
+		pointsExpiryDaysToChange = &pointsExpiryDays
+	}
+
+	var openingHoursToChange *[]OpeningHours
+	var openingHoursExceptionsToChange *[]OpeningHoursException
+
+	if req.OpeningHours != nil {
+		openingHours, err := apiUtils.ConvertApiOpeningHours(*req.OpeningHours)
+		if err != nil {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPENING_HOURS"})
+			return
+		}
+		openingHoursToChange = &openingHours
+	}
+
+	if req.OpeningHoursExceptions != nil {
+		exceptions, err := apiUtils.ConvertApiOpeningHoursExceptions(*req.OpeningHoursExceptions)
+		if err != nil {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPENING_HOURS"})
+			return
+		}
+		openingHoursExceptionsToChange = &exceptions
+	}
+
+	// Make sure that the request is correct - at least one field has to be changed
+	if nameToChange == nil && descriptionToChange == nil && pointsExpiryDaysToChange == nil &&
+		req.RequireTotp == nil && req.Categories == nil && req.TimeZone == nil &&
+		openingHoursToChange == nil && openingHoursExceptionsToChange == nil {
 		c.JSON(401, api.DefaultResponse{Status: api.INVALID_REQUEST})
 		return
 	}
 
 	// Get user and business of user. getUserAndBusiness sends HTTP errors, so we can just quit
 	// if business or user is not available
-	user, business := handler.getUserAndBusiness(c)
+	user, business := handler.getUserAndBusiness(c, businessManagerRoles...)
 	if user == nil || business == nil {
 		return
 	}
 
+	// Only the owner can require TOTP, and has to enable it first - otherwise they would lock themselves out
+	if req.RequireTotp != nil {
+		if user.ID != business.OwnerId {
+			c.JSON(403, api.DefaultResponse{Status: api.FORBIDDEN})
+			return
+		}
+		if *req.RequireTotp && !user.TotpEnabled {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "TOTP_NOT_ENABLED"})
+			return
+		}
+	}
+
 	// Send to manager, handle errors, send response
 	_, err := handler.businessManager.ChangeDetails(business, &ChangeableBusinessDetails{
-		Name:        nameToChange,
-		Description: descriptionToChange,
+		Name:                   nameToChange,
+		Description:            descriptionToChange,
+		PointsExpiryDays:       pointsExpiryDaysToChange,
+		RequireTotp:            req.RequireTotp,
+		Categories:             req.Categories,
+		TimeZone:               req.TimeZone,
+		OpeningHours:           openingHoursToChange,
+		OpeningHoursExceptions: openingHoursExceptionsToChange,
 	})
 
-	if err != nil {
+	if err == ErrInvalidCategory {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CATEGORY"})
+		return
+	} else if err == ErrInvalidTimeZone {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_TIME_ZONE"})
+		return
+	} else if err == ErrInvalidOpeningHours {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPENING_HOURS"})
+		return
+	} else if err != nil {
 		handler.logger.Printf("failed to handler.businessManager.ChangeDetails in patchAccountInfo %+v", err)
 		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
 		return
@@ -254,7 +430,7 @@ func (handler *BusinessHandlers) getTransaction(c *gin.Context) {
 
 	// Get user and business of user. getUserAndBusiness sends HTTP errors, so we can just quit
 	// if business or user is not available
-	user, business := handler.getUserAndBusiness(c)
+	user, business := handler.getUserAndBusiness(c, businessCashierRoles...)
 	if user == nil || business == nil {
 		return
 	}
@@ -308,7 +484,7 @@ func (handler *BusinessHandlers) postTransaction(c *gin.Context) {
 
 	// Get user and business of user. getUserAndBusiness sends HTTP errors, so we can just quit
 	// if business or user is not available
-	user, business := handler.getUserAndBusiness(c)
+	user, business := handler.getUserAndBusiness(c, businessCashierRoles...)
 	if user == nil || business == nil {
 		return
 	}
@@ -346,13 +522,34 @@ func (handler *BusinessHandlers) postTransaction(c *gin.Context) {
 		})
 	}
 
+	// Get location, if present
+	var location *BusinessLocation
+	if req.LocationId != "" {
+		locationTmp, err := handler.businessAuthorizedAccessor.Get(business, &BusinessLocation{PublicId: req.LocationId})
+		if err == ErrNotFound || err == ErrNoAccess {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "UNKNOWN_LOCATION"})
+			return
+		} else if err != nil {
+			handler.logger.Printf("failed to handler.businessAuthorizedAccessor.Get in postTransaction %+v", err)
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+			return
+		}
+		location = locationTmp.(*BusinessLocation)
+	}
+
 	// Send data to manager, handle errors
-	_, err = handler.transactionManager.Finalize(transaction, itemActions, uint64(req.AddedPoints))
+	_, err = handler.transactionManager.Finalize(transaction, itemActions, uint64(req.AddedPoints), location)
 	if err != nil {
 		handler.logger.Printf("failed to handler.transactionManager.Finalize in postTransaction %+v", err)
 		if err == ErrInvalidItem {
 			c.JSON(400, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
 			return
+		} else if err == ErrInvalidLocationId {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "UNKNOWN_LOCATION"})
+			return
+		} else if err == ErrTransactionExpired {
+			c.JSON(410, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "TRANSACTION_EXPIRED"})
+			return
 		} else {
 			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
 			return
@@ -366,7 +563,7 @@ func (handler *BusinessHandlers) postTransaction(c *gin.Context) {
 func (handler *BusinessHandlers) postMenuImage(c *gin.Context) {
 	// Get user and business of user. getUserAndBusiness sends HTTP errors, so we can just quit
 	// if business or user is not available
-	user, business := handler.getUserAndBusiness(c)
+	user, business := handler.getUserAndBusiness(c, businessManagerRoles...)
 	if user == nil || business == nil {
 		return
 	}
@@ -378,6 +575,9 @@ func (handler *BusinessHandlers) postMenuImage(c *gin.Context) {
 			Message: "TOO_MANY_IMAGES",
 		})
 		return
+	} else if errors.Is(err, services.ErrFileQuotaExceeded) {
+		c.JSON(403, api.DefaultResponse{Status: api.FORBIDDEN, Message: "FILE_QUOTA_EXCEEDED"})
+		return
 	} else if err != nil {
 		handler.logger.Printf("failed to handler.businessManager.AddMenuImage in postMenuImage %+v", err)
 		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
@@ -396,7 +596,7 @@ func (handler *BusinessHandlers) deleteMenuImage(c *gin.Context) {
 
 	// Get user and business of user. getUserAndBusiness sends HTTP errors, so we can just quit
 	// if business or user is not available
-	user, business := handler.getUserAndBusiness(c)
+	user, business := handler.getUserAndBusiness(c, businessManagerRoles...)
 	if user == nil || business == nil {
 		return
 	}
@@ -426,6 +626,333 @@ func (handler *BusinessHandlers) deleteMenuImage(c *gin.Context) {
 	c.JSON(200, api.DefaultResponse{Status: api.OK})
 }
 
+// Handles business member invitation request. Only the owner can invite members.
+func (handler *BusinessHandlers) postMember(c *gin.Context) {
+	req := api.PostBusinessMemberRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		handler.logger.Printf("failed to parse in postMember %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+	if req.Role != api.MANAGER && req.Role != api.CASHIER {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_ROLE"})
+		return
+	}
+
+	user, business := handler.getUserAndBusiness(c)
+	if user == nil || business == nil {
+		return
+	}
+
+	invitation, err := handler.businessMemberManager.Invite(business, req.Email, apiUtils.ConvertApiBusinessMemberRole(req.Role))
+	if err == ErrInvalidEmail {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_EMAIL"})
+		return
+	} else if err == ErrAlreadyMember {
+		c.JSON(409, api.DefaultResponse{Status: api.ALREADY_EXISTS})
+		return
+	} else if err != nil {
+		handler.logger.Printf("failed to handler.businessMemberManager.Invite in postMember %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	c.JSON(201, api.PostBusinessMemberResponse{
+		InvitationId: invitation.PublicId,
+	})
+}
+
+// Handles business members list request. Only the owner can list members.
+func (handler *BusinessHandlers) getMembers(c *gin.Context) {
+	user, business := handler.getUserAndBusiness(c)
+	if user == nil || business == nil {
+		return
+	}
+
+	members, err := handler.businessMemberManager.GetMembers(business)
+	if err != nil {
+		handler.logger.Printf("failed to handler.businessMemberManager.GetMembers in getMembers %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	resp := api.GetBusinessMembersResponse{Members: []api.BusinessMemberApiModel{}}
+	for i := range members {
+		resp.Members = append(resp.Members, apiUtils.ConvertBusinessMemberToApiModel(&members[i]))
+	}
+	c.JSON(200, resp)
+}
+
+// Handles business member remove request. Only the owner can remove members.
+// Requires {memberId} URL path parameter
+func (handler *BusinessHandlers) deleteMember(c *gin.Context) {
+	memberId := c.Param("memberId")
+
+	user, business := handler.getUserAndBusiness(c)
+	if user == nil || business == nil {
+		return
+	}
+
+	memberTmp, err := handler.businessAuthorizedAccessor.Get(business, &BusinessMember{PublicId: memberId})
+	if err == ErrNoAccess {
+		c.JSON(403, api.DefaultResponse{Status: api.FORBIDDEN})
+		return
+	} else if err == ErrNotFound {
+		c.JSON(404, api.DefaultResponse{Status: api.NOT_FOUND})
+		return
+	} else if err != nil {
+		handler.logger.Printf("failed to handler.businessAuthorizedAccessor.Get in deleteMember %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	err = handler.businessMemberManager.RemoveMember(memberTmp.(*BusinessMember))
+	if err == ErrCannotRemoveOwner {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "CANNOT_REMOVE_OWNER"})
+		return
+	} else if err != nil {
+		handler.logger.Printf("failed to handler.businessMemberManager.RemoveMember in deleteMember %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
+// Handles request for invitations to businesses sent to email of the user
+func (handler *BusinessHandlers) getInvitations(c *gin.Context) {
+	user := getUserFromContext(handler.logger, c)
+	if user == nil {
+		return
+	}
+
+	invitations, err := handler.businessMemberManager.GetInvitations(user)
+	if err != nil {
+		handler.logger.Printf("failed to handler.businessMemberManager.GetInvitations in getInvitations %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	resp := api.GetBusinessInvitationsResponse{Invitations: []api.BusinessInvitationApiModel{}}
+	for i := range invitations {
+		resp.Invitations = append(resp.Invitations, apiUtils.ConvertBusinessInvitationToApiModel(&invitations[i]))
+	}
+	c.JSON(200, resp)
+}
+
+// Handles invitation accept request
+// Requires {invitationId} URL path parameter
+func (handler *BusinessHandlers) postInvitation(c *gin.Context) {
+	invitationId := c.Param("invitationId")
+
+	user := getUserFromContext(handler.logger, c)
+	if user == nil {
+		return
+	}
+
+	_, err := handler.businessMemberManager.AcceptInvitation(user, invitationId)
+	if err == ErrNoSuchInvitation {
+		c.JSON(404, api.DefaultResponse{Status: api.NOT_FOUND})
+		return
+	} else if err == ErrAlreadyMember {
+		c.JSON(409, api.DefaultResponse{Status: api.ALREADY_EXISTS})
+		return
+	} else if err != nil {
+		handler.logger.Printf("failed to handler.businessMemberManager.AcceptInvitation in postInvitation %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
+// Gets location of business with {locationId} URL path parameter. Sends HTTP errors and returns nil otherwise
+func (handler *BusinessHandlers) getLocation(c *gin.Context, business *Business) *BusinessLocation {
+	locationId := c.Param("locationId")
+	locationTmp, err := handler.businessAuthorizedAccessor.Get(business, &BusinessLocation{PublicId: locationId})
+	if err == ErrNoAccess {
+		c.JSON(403, api.DefaultResponse{Status: api.FORBIDDEN})
+		return nil
+	} else if err == ErrNotFound {
+		c.JSON(404, api.DefaultResponse{Status: api.NOT_FOUND})
+		return nil
+	} else if err != nil {
+		handler.logger.Printf("failed to handler.businessAuthorizedAccessor.Get in getLocation %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return nil
+	}
+	return locationTmp.(*BusinessLocation)
+}
+
+// Handles business locations list request
+func (handler *BusinessHandlers) getLocations(c *gin.Context) {
+	user, business := handler.getUserAndBusiness(c, businessCashierRoles...)
+	if user == nil || business == nil {
+		return
+	}
+
+	// Opening hours of the business are needed to tell if locations without their own are open
+	openingHours, openingHoursExceptions, err := handler.businessManager.GetOpeningHours(business)
+	if err != nil {
+		handler.logger.Printf("failed to handler.businessManager.GetOpeningHours in getLocations %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+	business.OpeningHours = openingHours
+	business.OpeningHoursExceptions = openingHoursExceptions
+
+	locations, err := handler.businessManager.GetLocations(business)
+	if err != nil {
+		handler.logger.Printf("failed to handler.businessManager.GetLocations in getLocations %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	resp := api.GetBusinessLocationsResponse{Locations: []api.BusinessLocationApiModel{}}
+	for i := range locations {
+		resp.Locations = append(resp.Locations, apiUtils.ConvertBusinessLocationToApiModel(business, &locations[i]))
+	}
+	c.JSON(200, resp)
+}
+
+// Handles business location add request
+func (handler *BusinessHandlers) postLocation(c *gin.Context) {
+	req := api.PostBusinessLocationRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		handler.logger.Printf("failed to parse in postLocation %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	coordinates, err := GPSCoordinatesFromString(req.GpsCoordinates)
+	if err != nil {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_GPS_COORDINATES"})
+		return
+	}
+	openingHours, err := apiUtils.ConvertApiOpeningHours(req.OpeningHours)
+	if err != nil {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPENING_HOURS"})
+		return
+	}
+	exceptions, err := apiUtils.ConvertApiOpeningHoursExceptions(req.OpeningHoursExceptions)
+	if err != nil {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPENING_HOURS"})
+		return
+	}
+
+	user, business := handler.getUserAndBusiness(c, businessManagerRoles...)
+	if user == nil || business == nil {
+		return
+	}
+
+	location, err := handler.businessManager.AddLocation(business, &BusinessLocationDetails{
+		Name:                   req.Name,
+		Address:                req.Address,
+		GPSCoordinates:         coordinates,
+		OpeningHours:           openingHours,
+		OpeningHoursExceptions: exceptions,
+	})
+	if err == ErrInvalidLocation {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_LOCATION"})
+		return
+	} else if err == ErrInvalidOpeningHours {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPENING_HOURS"})
+		return
+	} else if err != nil {
+		handler.logger.Printf("failed to handler.businessManager.AddLocation in postLocation %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	c.JSON(201, api.PostBusinessLocationResponse{PublicId: location.PublicId})
+}
+
+// Handles business location change request
+// Requires {locationId} URL path parameter
+func (handler *BusinessHandlers) patchLocation(c *gin.Context) {
+	req := api.PatchBusinessLocationRequest{}
+	if err := c.BindJSON(&req); err != nil {
+		handler.logger.Printf("failed to parse in patchLocation %+v", err)
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
+		return
+	}
+
+	details := ChangeableBusinessLocationDetails{Name: req.Name, Address: req.Address}
+	if req.GpsCoordinates != nil {
+		coordinates, err := GPSCoordinatesFromString(*req.GpsCoordinates)
+		if err != nil {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_GPS_COORDINATES"})
+			return
+		}
+		details.GPSCoordinates = &coordinates
+	}
+	if req.OpeningHours != nil {
+		openingHours, err := apiUtils.ConvertApiOpeningHours(*req.OpeningHours)
+		if err != nil {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPENING_HOURS"})
+			return
+		}
+		details.OpeningHours = &openingHours
+	}
+	if req.OpeningHoursExceptions != nil {
+		exceptions, err := apiUtils.ConvertApiOpeningHoursExceptions(*req.OpeningHoursExceptions)
+		if err != nil {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPENING_HOURS"})
+			return
+		}
+		details.OpeningHoursExceptions = &exceptions
+	}
+
+	user, business := handler.getUserAndBusiness(c, businessManagerRoles...)
+	if user == nil || business == nil {
+		return
+	}
+	location := handler.getLocation(c, business)
+	if location == nil {
+		return
+	}
+
+	_, err := handler.businessManager.ChangeLocation(location, &details)
+	if err == ErrInvalidLocation {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_LOCATION"})
+		return
+	} else if err == ErrInvalidOpeningHours {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPENING_HOURS"})
+		return
+	} else if err != nil {
+		handler.logger.Printf("failed to handler.businessManager.ChangeLocation in patchLocation %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
+// Handles business location remove request
+// Requires {locationId} URL path parameter
+func (handler *BusinessHandlers) deleteLocation(c *gin.Context) {
+	user, business := handler.getUserAndBusiness(c, businessManagerRoles...)
+	if user == nil || business == nil {
+		return
+	}
+	location := handler.getLocation(c, business)
+	if location == nil {
+		return
+	}
+
+	err := handler.businessManager.RemoveLocation(location)
+	if err == ErrLastLocation {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "LAST_LOCATION"})
+		return
+	} else if err != nil {
+		handler.logger.Printf("failed to handler.businessManager.RemoveLocation in deleteLocation %+v", err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	c.JSON(200, api.DefaultResponse{Status: api.OK})
+}
+
 func (handler *BusinessHandlers) Connect(rg *gin.RouterGroup) {
 	rg.POST("/account", handler.postAccount)
 	rg.GET("/info", handler.getAccountInfo)
@@ -443,6 +970,27 @@ func (handler *BusinessHandlers) Connect(rg *gin.RouterGroup) {
 		transactions.POST("/:transactionCode", handler.postTransaction)
 	}
 
+	locations := rg.Group("/locations")
+	{
+		locations.GET("", handler.getLocations)
+		locations.POST("", handler.postLocation)
+		locations.PATCH("/:locationId", handler.patchLocation)
+		locations.DELETE("/:locationId", handler.deleteLocation)
+	}
+
+	members := rg.Group("/members")
+	{
+		members.GET("", handler.getMembers)
+		members.POST("", handler.postMember)
+		members.DELETE("/:memberId", handler.deleteMember)
+	}
+
+	invitations := rg.Group("/invitations")
+	{
+		invitations.GET("", handler.getInvitations)
+		invitations.POST("/:invitationId", handler.postInvitation)
+	}
+
 	handler.itemDefinitionHandlers.Connect(rg.Group("/itemDefinitions"))
 }
 
@@ -450,31 +998,14 @@ func (handler *BusinessHandlers) Connect(rg *gin.RouterGroup) {
 
 type ItemDefinitionHandlers struct {
 	itemDefinitionManager      ItemDefinitionManager
+	businessMemberManager      BusinessMemberManager
 	userAuthorizedAcessor      UserAuthorizedAccessor
 	businessAuthorizedAccessor BusinessAuthorizedAccessor
 	logger                     *log.Logger
 }
 
-// Gets business owned by user
-// TODO refactor - duplicated from BusinessHandlers
-func (handler *ItemDefinitionHandlers) getBusinessOfUser(user *User, c *gin.Context) *Business {
-	// Get user's business
-	businessTmp, err := handler.userAuthorizedAcessor.Get(user, &Business{})
-
-	if err != nil && err != ErrNotFound {
-		handler.logger.Printf("failed to handler.userAuthorizedAcessor.Get in getAccountInfo %+v", err)
-		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
-		return nil
-	} else if businessTmp == nil || err == ErrNotFound {
-		c.JSON(404, api.DefaultResponse{Status: api.NOT_FOUND})
-		return nil
-	}
-
-	return businessTmp.(*Business)
-}
-
 // Gets user (usually inserted into the context by AuthMiddleware)
-// and business owned by user from request context.
+// and business of user from request context. Only the owner and managers are allowed.
 // TODO refactor - duplicated from BusinessHandlers
 func (handler *ItemDefinitionHandlers) getUserAndBusiness(c *gin.Context) (*User, *Business) {
 	// Get user from context
@@ -484,7 +1015,8 @@ func (handler *ItemDefinitionHandlers) getUserAndBusiness(c *gin.Context) (*User
 	}
 
 	// Get user's business
-	business := handler.getBusinessOfUser(user, c)
+	business := getBusinessOfUser(handler.logger, handler.userAuthorizedAcessor, handler.businessMemberManager,
+		user, c, businessManagerRoles)
 	if business == nil {
 		return nil, nil
 	}
@@ -500,8 +1032,18 @@ func (handler *ItemDefinitionHandlers) getItemDefinition(c *gin.Context) {
 		return
 	}
 
-	itemDefinitionsTmp, err := handler.businessAuthorizedAccessor.GetAll(business, &ItemDefinition{BusinessId: business.ID, Withdrawn: false})
-	if err != nil {
+	// Pagination - cursor and limit
+	page := getPageFromQuery(c)
+	if page == nil {
+		return
+	}
+
+	itemDefinitionsTmp, nextCursor, err := handler.businessAuthorizedAccessor.GetAll(business,
+		&ItemDefinition{BusinessId: business.ID, Withdrawn: false}, page)
+	if err == ErrInvalidCursor {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CURSOR"})
+		return
+	} else if err != nil {
 		handler.logger.Printf("failed to handler.businessAuthorizedAccessor.GetAll in getItemDefinition%+v", err)
 		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
 		return
@@ -514,6 +1056,7 @@ func (handler *ItemDefinitionHandlers) getItemDefinition(c *gin.Context) {
 
 	c.JSON(200, api.GetBusinessItemDefinitionsResponse{
 		ItemDefinitions: itemDefinitions,
+		NextCursor:      nextCursor,
 	})
 }
 
@@ -557,6 +1100,9 @@ func (handler *ItemDefinitionHandlers) postItemDefinition(c *gin.Context) {
 	if err == ErrInvalidItemDetails {
 		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
 		return
+	} else if errors.Is(err, services.ErrFileQuotaExceeded) {
+		c.JSON(403, api.DefaultResponse{Status: api.FORBIDDEN, Message: "FILE_QUOTA_EXCEEDED"})
+		return
 	} else if err != nil {
 		handler.logger.Printf("unknown error returned from itemDefinitionManager.AddItem: %+v", err)
 		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
@@ -620,6 +1166,9 @@ func (handler *ItemDefinitionHandlers) putItemDefinition(c *gin.Context) {
 	if err == ErrInvalidItemDetails {
 		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST})
 		return
+	} else if errors.Is(err, services.ErrFileQuotaExceeded) {
+		c.JSON(403, api.DefaultResponse{Status: api.FORBIDDEN, Message: "FILE_QUOTA_EXCEEDED"})
+		return
 	} else if err != nil {
 		handler.logger.Printf("unknown error returned from itemDefinitionManager.AddItem: %+v", err)
 		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
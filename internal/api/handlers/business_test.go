@@ -15,6 +15,7 @@ import (
 	. "github.com/StampWallet/backend/internal/database/accessors/mocks"
 	"github.com/StampWallet/backend/internal/managers"
 	. "github.com/StampWallet/backend/internal/managers/mocks"
+	"github.com/StampWallet/backend/internal/services"
 	. "github.com/StampWallet/backend/internal/testutils"
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
@@ -27,6 +28,7 @@ func getBusinessHandlers(ctrl *gomock.Controller) *BusinessHandlers {
 		businessManager:               NewMockBusinessManager(ctrl),
 		transactionManager:            NewMockTransactionManager(ctrl),
 		itemDefinitionManager:         NewMockItemDefinitionManager(ctrl),
+		businessMemberManager:         NewMockBusinessMemberManager(ctrl),
 		userAuthorizedAcessor:         NewMockUserAuthorizedAccessor(ctrl),
 		businessAuthorizedAccessor:    NewMockBusinessAuthorizedAccessor(ctrl),
 		authorizedTransactionAccessor: NewMockAuthorizedTransactionAccessor(ctrl),
@@ -409,9 +411,11 @@ func TestBusinessHandlersGetAccountInfoOk(t *testing.T) {
 		GetAll(
 			gomock.Eq(testBusiness),
 			gomock.Eq(&database.ItemDefinition{}),
+			gomock.Nil(),
 		).
 		Return(
 			[]acc.BusinessOwnedEntity{&testBusiness.ItemDefinitions[0]},
+			"",
 			nil,
 		)
 
@@ -420,12 +424,63 @@ func TestBusinessHandlersGetAccountInfoOk(t *testing.T) {
 		GetAll(
 			gomock.Eq(testBusiness),
 			gomock.Eq(&database.MenuImage{}),
+			gomock.Nil(),
 		).
 		Return(
 			[]acc.BusinessOwnedEntity{&testBusiness.MenuImages[0]},
+			"",
 			nil,
 		)
 
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		GetBusinessCategories(gomock.Eq(testBusiness)).
+		Return([]database.Category{{Slug: "bakery"}, {Slug: "cafe"}}, nil)
+	respBodyExpected.Categories = []string{"bakery", "cafe"}
+
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		GetOpeningHours(gomock.Eq(testBusiness)).
+		Return(
+			[]database.OpeningHours{{Weekday: time.Monday, OpensAt: 8 * 60, ClosesAt: 16*60 + 30}},
+			[]database.OpeningHoursException{{Date: time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC), Closed: true}},
+			nil,
+		)
+	respBodyExpected.OpeningHours = []api.OpeningHoursApiModel{{Weekday: 1, Opens: "08:00", Closes: "16:30"}}
+	respBodyExpected.OpeningHoursExceptions = []api.OpeningHoursExceptionApiModel{{Date: "2023-12-25", Closed: true}}
+
+	// Location open all day, every day, so that openNow does not depend on the time of the test
+	var allDay []database.OpeningHours
+	var allDayApi []api.OpeningHoursApiModel
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		allDay = append(allDay, database.OpeningHours{Weekday: weekday, OpensAt: 0, ClosesAt: database.MinutesInDay})
+		allDayApi = append(allDayApi, api.OpeningHoursApiModel{Weekday: int32(weekday), Opens: "00:00", Closes: "24:00"})
+	}
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		GetLocations(gomock.Eq(testBusiness)).
+		Return([]database.BusinessLocation{{
+			PublicId:       "location",
+			Name:           "Downtown",
+			Address:        "Main street 1",
+			GPSCoordinates: testBusiness.GPSCoordinates,
+			OpeningHours:   allDay,
+		}}, nil)
+	respBodyExpected.Locations = []api.BusinessLocationApiModel{{
+		PublicId:       "location",
+		Name:           "Downtown",
+		Address:        "Main street 1",
+		GpsCoordinates: testBusiness.GPSCoordinates.ToString(),
+		OpeningHours:   allDayApi,
+		OpenNow:        Ptr(true),
+	}}
+
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		GetFileUsage(gomock.Eq(testBusiness)).
+		Return(&services.FileUsage{Files: 3, Bytes: 1000, MaxFiles: 300, MaxBytes: 100_000_000}, nil)
+	respBodyExpected.FileUsage = &api.FileUsageApiModel{Files: 3, Bytes: 1000, MaxFiles: 300, MaxBytes: 100_000_000}
+
 	handler.getAccountInfo(context)
 
 	respBody, respCode, respParseErr := ExtractResponse[api.GetBusinessAccountResponse](w)
@@ -455,6 +510,10 @@ func TestBusinessHandlersGetAccountInfoNok_NoBiz(t *testing.T) {
 			nil,
 			acc.ErrNotFound,
 		)
+	handler.businessMemberManager.(*MockBusinessMemberManager).
+		EXPECT().
+		GetMembership(gomock.Eq(testBusinessUser)).
+		Return(nil, managers.ErrNotMember)
 
 	respBodyExpected := api.DefaultResponse{Status: api.NOT_FOUND}
 
@@ -538,6 +597,259 @@ func TestBusinessHandlersPatchAccountInfoOk(t *testing.T) {
 	// TODO: test MatchEntities and gomock.Eq
 }
 
+func TestBusinessHandlersPatchAccountInfoPointsExpiryOk(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+
+	pointsExpiryDays := int32(365)
+	payload := api.PatchBusinessAccountRequest{
+		PointsExpiryDays: &pointsExpiryDays,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	expectedPointsExpiryDays := uint(365)
+	newBusinessDetails := &managers.ChangeableBusinessDetails{
+		PointsExpiryDays: &expectedPointsExpiryDays,
+	}
+
+	testBusinessVal := *testBusiness
+	newBusiness := &testBusinessVal
+	newBusiness.PointsExpiryDays = expectedPointsExpiryDays
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/info").
+		SetUser(testBusinessUser).
+		SetMethod("PATCH").
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		SetBody(payloadJson).
+		Context
+
+	respBodyExpected := &api.DefaultResponse{Status: api.OK}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusinessUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			testBusiness,
+			nil,
+		)
+
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		ChangeDetails(
+			gomock.Eq(testBusiness),
+			gomock.Eq(newBusinessDetails),
+		).
+		Return(
+			newBusiness,
+			nil,
+		)
+
+	handler.patchAccountInfo(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersPatchAccountInfoOpeningHoursOk(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+
+	payload := api.PatchBusinessAccountRequest{
+		TimeZone: Ptr("Europe/Warsaw"),
+		OpeningHours: &[]api.OpeningHoursApiModel{
+			{Weekday: 5, Opens: "18:00", Closes: "24:00"},
+			{Weekday: 6, Opens: "00:00", Closes: "02:00"},
+		},
+		OpeningHoursExceptions: &[]api.OpeningHoursExceptionApiModel{
+			{Date: "2023-12-24", Opens: "10:00", Closes: "14:00"},
+			{Date: "2023-12-25", Closed: true, Opens: "10:00"},
+		},
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	newBusinessDetails := &managers.ChangeableBusinessDetails{
+		TimeZone: Ptr("Europe/Warsaw"),
+		OpeningHours: &[]database.OpeningHours{
+			{Weekday: time.Friday, OpensAt: 18 * 60, ClosesAt: 24 * 60},
+			{Weekday: time.Saturday, OpensAt: 0, ClosesAt: 2 * 60},
+		},
+		OpeningHoursExceptions: &[]database.OpeningHoursException{
+			{Date: time.Date(2023, 12, 24, 0, 0, 0, 0, time.UTC), OpensAt: 10 * 60, ClosesAt: 14 * 60},
+			{Date: time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC), Closed: true},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/info").
+		SetUser(testBusinessUser).
+		SetMethod("PATCH").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		SetBody(payloadJson).
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(gomock.Eq(testBusinessUser), gomock.Eq(&database.Business{})).
+		Return(testBusiness, nil)
+
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		ChangeDetails(gomock.Eq(testBusiness), gomock.Eq(newBusinessDetails)).
+		Return(testBusiness, nil)
+
+	handler.patchAccountInfo(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, 200, respCode, "Response returned unexpected status code")
+	require.Equalf(t, api.DefaultResponse{Status: api.OK}, *respBody, "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersPatchAccountInfoInvalidOpeningHours(t *testing.T) {
+	for _, payload := range []api.PatchBusinessAccountRequest{
+		{OpeningHours: &[]api.OpeningHoursApiModel{{Weekday: 1, Opens: "8:00", Closes: "16:00"}}},
+		{OpeningHours: &[]api.OpeningHoursApiModel{{Weekday: 1, Opens: "08:00", Closes: "24:30"}}},
+		{OpeningHoursExceptions: &[]api.OpeningHoursExceptionApiModel{{Date: "24.12.2023", Closed: true}}},
+		{OpeningHoursExceptions: &[]api.OpeningHoursExceptionApiModel{{Date: "2023-12-24", Opens: "10:00"}}},
+	} {
+		payloadJson, _ := json.Marshal(payload)
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+
+		context := NewTestContextBuilder(w).
+			SetDefaultUrl().
+			SetEndpoint("/business/info").
+			SetUser(GetDefaultUser()).
+			SetMethod("PATCH").
+			SetHeader("Content-Type", "application/json").
+			SetDefaultToken().
+			SetBody(payloadJson).
+			Context
+
+		// test env prep
+		ctrl := gomock.NewController(t)
+		handler := getBusinessHandlers(ctrl)
+
+		handler.patchAccountInfo(context)
+
+		respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+		require.Nilf(t, respParseErr, "Failed to parse JSON response")
+		require.Equalf(t, 400, respCode, "Response returned unexpected status code for %s", payloadJson)
+		require.Equalf(t, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPENING_HOURS"}, *respBody,
+			"Response returned unexpected body contents for %s", payloadJson)
+	}
+}
+
+func TestBusinessHandlersPatchAccountInfoInvalidTimeZone(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+
+	payloadJson, _ := json.Marshal(api.PatchBusinessAccountRequest{TimeZone: Ptr("Mars/Olympus_Mons")})
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/info").
+		SetUser(testBusinessUser).
+		SetMethod("PATCH").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		SetBody(payloadJson).
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(gomock.Eq(testBusinessUser), gomock.Eq(&database.Business{})).
+		Return(testBusiness, nil)
+
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		ChangeDetails(gomock.Eq(testBusiness), gomock.Any()).
+		Return(nil, managers.ErrInvalidTimeZone)
+
+	handler.patchAccountInfo(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, 400, respCode, "Response returned unexpected status code")
+	require.Equalf(t, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_TIME_ZONE"}, *respBody,
+		"Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersPatchAccountInfoNegativePointsExpiry(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+
+	pointsExpiryDays := int32(-1)
+	payload := api.PatchBusinessAccountRequest{
+		PointsExpiryDays: &pointsExpiryDays,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/info").
+		SetUser(testBusinessUser).
+		SetMethod("PATCH").
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		SetBody(payloadJson).
+		Context
+
+	respBodyExpected := &api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_POINTS_EXPIRY_DAYS"}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.patchAccountInfo(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(400), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, respBody), "Response returned unexpected body contents")
+}
+
 func TestBusinessHandlersPatchAccountInfoNok_InvBiz(t *testing.T) {
 	testBusinessUser := GetDefaultUser()
 	testBusiness := GetDefaultBusiness(testBusinessUser)
@@ -586,6 +898,10 @@ func TestBusinessHandlersPatchAccountInfoNok_InvBiz(t *testing.T) {
 			nil,
 			acc.ErrNotFound,
 		)
+	handler.businessMemberManager.(*MockBusinessMemberManager).
+		EXPECT().
+		GetMembership(gomock.Eq(testBusinessUser)).
+		Return(nil, managers.ErrNotMember)
 
 	handler.patchAccountInfo(context)
 
@@ -708,6 +1024,60 @@ func TestBusinessHandlersPostMenuImageTooManyImages(t *testing.T) {
 	require.Truef(t, reflect.DeepEqual(respBodyExpected, respBody), "Response returned unexpected body contents")
 }
 
+func TestBusinessHandlersPostMenuImageFileQuotaExceeded(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/menuImages").
+		SetUser(testBusinessUser).
+		SetMethod("POST").
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		Context
+
+	respBodyExpected := &api.DefaultResponse{Status: api.FORBIDDEN, Message: "FILE_QUOTA_EXCEEDED"}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusinessUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			testBusiness,
+			nil,
+		)
+
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		AddMenuImage(
+			gomock.Eq(testBusinessUser),
+			gomock.Eq(testBusiness),
+		).
+		Return(
+			nil,
+			fmt.Errorf("failed to create image stub: %w", services.ErrFileQuotaExceeded),
+		)
+
+	handler.postMenuImage(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(403), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, respBody), "Response returned unexpected body contents")
+}
+
 func TestBusinessHandlersDeleteMenuImage(t *testing.T) {
 	testBusinessUser := GetDefaultUser()
 	testBusiness := GetDefaultBusiness(testBusinessUser)
@@ -1056,6 +1426,7 @@ func TestBusinessHandlersPostTransactionOk(t *testing.T) {
 			gomock.Eq(testTransaction),
 			gomock.Eq(testItemsWithAction),
 			gomock.Eq(uint64(payload.AddedPoints)),
+			gomock.Nil(),
 		).
 		Return(
 			transactionFinalized,
@@ -1144,3 +1515,721 @@ func TestBusinessHandlersPostTransactionInvalidItem(t *testing.T) {
 	require.Equalf(t, int(400), respCode, "Response returned unexpected status code")
 	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
 }
+
+func TestBusinessHandlersPostTransactionExpired(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+	testVcard := GetTestVirtualCard(nil, testBusinessUser, testBusiness)
+	testTransaction, _ := GetTestTransaction(nil, testVcard, []database.OwnedItem{})
+
+	payload := api.PostBusinessTransactionRequest{
+		AddedPoints: 10,
+		ItemActions: []api.ItemActionApiModel{},
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/transaction/"+testTransaction.Code).
+		SetUser(testBusinessUser).
+		SetMethod("POST").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		SetBody(payloadJson).
+		SetParam("transactionCode", testTransaction.Code).
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusinessUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			testBusiness,
+			nil,
+		)
+
+	handler.authorizedTransactionAccessor.(*MockAuthorizedTransactionAccessor).
+		EXPECT().
+		GetForBusiness(
+			gomock.Eq(testBusiness),
+			gomock.Eq(testTransaction.Code),
+		).
+		Return(
+			testTransaction,
+			nil,
+		)
+
+	handler.transactionManager.(*MockTransactionManager).
+		EXPECT().
+		Finalize(
+			gomock.Eq(testTransaction),
+			gomock.Any(),
+			gomock.Eq(uint64(payload.AddedPoints)),
+			gomock.Nil(),
+		).
+		Return(
+			nil,
+			managers.ErrTransactionExpired,
+		)
+
+	handler.postTransaction(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "TRANSACTION_EXPIRED"}
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(410), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+// members
+
+func TestBusinessHandlersGetAccountInfoCashierForbidden(t *testing.T) {
+	w, context, testCashierUser, testBusiness, _ := setupBusinessHandlersGetAccountInfo()
+	testMember := GetTestBusinessMember(nil, testBusiness, testCashierUser, database.BusinessMemberRoleCashier)
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testCashierUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			nil,
+			acc.ErrNotFound,
+		)
+	handler.businessMemberManager.(*MockBusinessMemberManager).
+		EXPECT().
+		GetMembership(gomock.Eq(testCashierUser)).
+		Return(testMember, nil)
+
+	handler.getAccountInfo(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.FORBIDDEN}
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(403), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersGetAccountInfoTotpRequired(t *testing.T) {
+	w, context, testManagerUser, testBusiness, _ := setupBusinessHandlersGetAccountInfo()
+	testBusiness.RequireTotp = true
+	testMember := GetTestBusinessMember(nil, testBusiness, testManagerUser, database.BusinessMemberRoleManager)
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testManagerUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			nil,
+			acc.ErrNotFound,
+		)
+	handler.businessMemberManager.(*MockBusinessMemberManager).
+		EXPECT().
+		GetMembership(gomock.Eq(testManagerUser)).
+		Return(testMember, nil)
+
+	handler.getAccountInfo(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.FORBIDDEN, Message: "TOTP_REQUIRED"}
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(403), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersPatchAccountInfoRequireTotpNotEnabled(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+
+	requireTotp := true
+	payload := api.PatchBusinessAccountRequest{
+		RequireTotp: &requireTotp,
+	}
+	payloadJson, _ := json.Marshal(payload)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/info").
+		SetUser(testBusinessUser).
+		SetMethod("PATCH").
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		SetBody(payloadJson).
+		Context
+
+	respBodyExpected := &api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "TOTP_NOT_ENABLED"}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusinessUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			testBusiness,
+			nil,
+		)
+
+	handler.patchAccountInfo(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(400), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersGetTransactionCashierOk(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testCashierUser := GetDefaultUser()
+	testTransactionCode := "0123456789"
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+	testMember := GetTestBusinessMember(nil, testBusiness, testCashierUser, database.BusinessMemberRoleCashier)
+	testVcard := GetTestVirtualCard(nil, GetDefaultUser(), testBusiness)
+	testTransaction, details := GetTestTransaction(nil, testVcard, []database.OwnedItem{})
+	testTransaction.TransactionDetails = details
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/transaction/"+testTransactionCode).
+		SetUser(testCashierUser).
+		SetMethod("GET").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		SetParam("transactionCode", testTransactionCode).
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testCashierUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			nil,
+			acc.ErrNotFound,
+		)
+	handler.businessMemberManager.(*MockBusinessMemberManager).
+		EXPECT().
+		GetMembership(gomock.Eq(testCashierUser)).
+		Return(testMember, nil)
+	handler.authorizedTransactionAccessor.(*MockAuthorizedTransactionAccessor).
+		EXPECT().
+		GetForBusiness(
+			gomock.Eq(testBusiness),
+			gomock.Eq(testTransactionCode),
+		).
+		Return(
+			testTransaction,
+			nil,
+		)
+
+	handler.getTransaction(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.GetBusinessTransactionResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Equalf(t, testTransaction.PublicId, respBody.PublicId, "Response returned unexpected transaction")
+}
+
+func setupBusinessHandlersPostMember(user *database.User, payload api.PostBusinessMemberRequest) (
+	w *httptest.ResponseRecorder,
+	context *gin.Context,
+) {
+	payloadJson, _ := json.Marshal(payload)
+
+	gin.SetMode(gin.TestMode)
+	w = httptest.NewRecorder()
+
+	context = NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/members").
+		SetUser(user).
+		SetMethod("POST").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		SetBody(payloadJson).
+		Context
+
+	return w, context
+}
+
+func TestBusinessHandlersPostMemberOk(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+	testInvitation := GetTestBusinessInvitation(nil, testBusiness, "cashier@example.com",
+		database.BusinessMemberRoleCashier)
+	w, context := setupBusinessHandlersPostMember(testBusinessUser, api.PostBusinessMemberRequest{
+		Email: testInvitation.Email,
+		Role:  api.CASHIER,
+	})
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusinessUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			testBusiness,
+			nil,
+		)
+	handler.businessMemberManager.(*MockBusinessMemberManager).
+		EXPECT().
+		Invite(
+			gomock.Eq(testBusiness),
+			gomock.Eq(testInvitation.Email),
+			gomock.Eq(database.BusinessMemberRoleEnum(database.BusinessMemberRoleCashier)),
+		).
+		Return(testInvitation, nil)
+
+	handler.postMember(context)
+
+	respBodyExpected := api.PostBusinessMemberResponse{InvitationId: testInvitation.PublicId}
+	respBody, respCode, respParseErr := ExtractResponse[api.PostBusinessMemberResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(201), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersPostMemberInvalidRole(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	w, context := setupBusinessHandlersPostMember(testBusinessUser, api.PostBusinessMemberRequest{
+		Email: "owner@example.com",
+		Role:  api.OWNER,
+	})
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.postMember(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_ROLE"}
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(400), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersPostMemberManagerForbidden(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testManagerUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+	testMember := GetTestBusinessMember(nil, testBusiness, testManagerUser, database.BusinessMemberRoleManager)
+	w, context := setupBusinessHandlersPostMember(testManagerUser, api.PostBusinessMemberRequest{
+		Email: "cashier@example.com",
+		Role:  api.CASHIER,
+	})
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testManagerUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			nil,
+			acc.ErrNotFound,
+		)
+	handler.businessMemberManager.(*MockBusinessMemberManager).
+		EXPECT().
+		GetMembership(gomock.Eq(testManagerUser)).
+		Return(testMember, nil)
+
+	handler.postMember(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.FORBIDDEN}
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(403), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersDeleteMemberOk(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+	testMember := GetTestBusinessMember(nil, testBusiness, GetDefaultUser(), database.BusinessMemberRoleCashier)
+	testMember.PublicId = shortuuid.New()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/members/"+testMember.PublicId).
+		SetUser(testBusinessUser).
+		SetMethod("DELETE").
+		SetDefaultToken().
+		SetParam("memberId", testMember.PublicId).
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusinessUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			testBusiness,
+			nil,
+		)
+	handler.businessAuthorizedAccessor.(*MockBusinessAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusiness),
+			gomock.Eq(&database.BusinessMember{PublicId: testMember.PublicId}),
+		).
+		Return(testMember, nil)
+	handler.businessMemberManager.(*MockBusinessMemberManager).
+		EXPECT().
+		RemoveMember(gomock.Eq(testMember)).
+		Return(nil)
+
+	handler.deleteMember(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.OK}
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersPostInvitationOk(t *testing.T) {
+	testUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(GetDefaultUser())
+	testInvitation := GetTestBusinessInvitation(nil, testBusiness, testUser.Email,
+		database.BusinessMemberRoleManager)
+	testInvitation.PublicId = shortuuid.New()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/invitations/"+testInvitation.PublicId).
+		SetUser(testUser).
+		SetMethod("POST").
+		SetDefaultToken().
+		SetParam("invitationId", testInvitation.PublicId).
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.businessMemberManager.(*MockBusinessMemberManager).
+		EXPECT().
+		AcceptInvitation(gomock.Eq(testUser), gomock.Eq(testInvitation.PublicId)).
+		Return(GetTestBusinessMember(nil, testBusiness, testUser, database.BusinessMemberRoleManager), nil)
+
+	handler.postInvitation(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.OK}
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersPostInvitationNotFound(t *testing.T) {
+	testUser := GetDefaultUser()
+	invitationId := shortuuid.New()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/invitations/"+invitationId).
+		SetUser(testUser).
+		SetMethod("POST").
+		SetDefaultToken().
+		SetParam("invitationId", invitationId).
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.businessMemberManager.(*MockBusinessMemberManager).
+		EXPECT().
+		AcceptInvitation(gomock.Eq(testUser), gomock.Eq(invitationId)).
+		Return(nil, managers.ErrNoSuchInvitation)
+
+	handler.postInvitation(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.NOT_FOUND}
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(404), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersPostTransactionUnknownLocation(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+	testVcard := GetTestVirtualCard(nil, testBusinessUser, testBusiness)
+	testTransaction, _ := GetTestTransaction(nil, testVcard, []database.OwnedItem{})
+
+	payload := api.PostBusinessTransactionRequest{AddedPoints: 10, LocationId: "unknown"}
+	payloadJson, _ := json.Marshal(payload)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/transaction/"+testTransaction.Code).
+		SetUser(testBusinessUser).
+		SetMethod("POST").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		SetBody(payloadJson).
+		SetParam("transactionCode", testTransaction.Code).
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusinessUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			testBusiness,
+			nil,
+		)
+	handler.authorizedTransactionAccessor.(*MockAuthorizedTransactionAccessor).
+		EXPECT().
+		GetForBusiness(
+			gomock.Eq(testBusiness),
+			gomock.Eq(testTransaction.Code),
+		).
+		Return(
+			testTransaction,
+			nil,
+		)
+	handler.businessAuthorizedAccessor.(*MockBusinessAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusiness),
+			gomock.Eq(&database.BusinessLocation{PublicId: "unknown"}),
+		).
+		Return(nil, acc.ErrNotFound)
+
+	handler.postTransaction(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "UNKNOWN_LOCATION"}
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(400), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+func setupBusinessHandlersPostLocation(user *database.User, payload api.PostBusinessLocationRequest) (
+	w *httptest.ResponseRecorder,
+	context *gin.Context,
+) {
+	payloadJson, _ := json.Marshal(payload)
+
+	gin.SetMode(gin.TestMode)
+	w = httptest.NewRecorder()
+
+	context = NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/locations").
+		SetUser(user).
+		SetMethod("POST").
+		SetHeader("Content-Type", "application/json").
+		SetDefaultToken().
+		SetBody(payloadJson).
+		Context
+
+	return w, context
+}
+
+func TestBusinessHandlersPostLocationOk(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+	testLocation := GetTestBusinessLocation(nil, testBusiness, testBusiness.GPSCoordinates)
+	w, context := setupBusinessHandlersPostLocation(testBusinessUser, api.PostBusinessLocationRequest{
+		Name:           testLocation.Name,
+		Address:        testLocation.Address,
+		GpsCoordinates: testLocation.GPSCoordinates.ToString(),
+		OpeningHours:   []api.OpeningHoursApiModel{{Weekday: 1, Opens: "08:00", Closes: "16:00"}},
+	})
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusinessUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			testBusiness,
+			nil,
+		)
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		AddLocation(
+			gomock.Eq(testBusiness),
+			gomock.Eq(&managers.BusinessLocationDetails{
+				Name:                   testLocation.Name,
+				Address:                testLocation.Address,
+				GPSCoordinates:         testLocation.GPSCoordinates,
+				OpeningHours:           []database.OpeningHours{{Weekday: time.Monday, OpensAt: 8 * 60, ClosesAt: 16 * 60}},
+				OpeningHoursExceptions: []database.OpeningHoursException{},
+			}),
+		).
+		Return(testLocation, nil)
+
+	handler.postLocation(context)
+
+	respBodyExpected := api.PostBusinessLocationResponse{PublicId: testLocation.PublicId}
+	respBody, respCode, respParseErr := ExtractResponse[api.PostBusinessLocationResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(201), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersPostLocationInvalidGpsCoordinates(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	w, context := setupBusinessHandlersPostLocation(testBusinessUser, api.PostBusinessLocationRequest{
+		Name:           "location",
+		Address:        "address",
+		GpsCoordinates: "invalid",
+	})
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.postLocation(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_GPS_COORDINATES"}
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(400), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
+
+func TestBusinessHandlersDeleteLocationLastLocation(t *testing.T) {
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+	testLocation := GetTestBusinessLocation(nil, testBusiness, testBusiness.GPSCoordinates)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/business/locations/"+testLocation.PublicId).
+		SetUser(testBusinessUser).
+		SetMethod("DELETE").
+		SetDefaultToken().
+		SetParam("locationId", testLocation.PublicId).
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getBusinessHandlers(ctrl)
+
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusinessUser),
+			gomock.Eq(&database.Business{}),
+		).
+		Return(
+			testBusiness,
+			nil,
+		)
+	handler.businessAuthorizedAccessor.(*MockBusinessAuthorizedAccessor).
+		EXPECT().
+		Get(
+			gomock.Eq(testBusiness),
+			gomock.Eq(&database.BusinessLocation{PublicId: testLocation.PublicId}),
+		).
+		Return(testLocation, nil)
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		RemoveLocation(gomock.Eq(testLocation)).
+		Return(managers.ErrLastLocation)
+
+	handler.deleteLocation(context)
+
+	respBodyExpected := api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "LAST_LOCATION"}
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(400), respCode, "Response returned unexpected status code")
+	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
+}
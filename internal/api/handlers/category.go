@@ -0,0 +1,46 @@
+package api
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	api "github.com/StampWallet/backend/internal/api/models"
+	apiUtils "github.com/StampWallet/backend/internal/api/utils"
+	. "github.com/StampWallet/backend/internal/managers"
+	. "github.com/StampWallet/backend/internal/utils"
+)
+
+// CategoryHandlers implements handlers for operations under "/categories" URL path.
+// Categories are public - requests do not require authentication.
+type CategoryHandlers struct {
+	businessManager BusinessManager
+	logger          *log.Logger
+}
+
+// Handles category list request
+func (handler *CategoryHandlers) getCategories(c *gin.Context) {
+	categories, err := handler.businessManager.GetCategories()
+	if err != nil {
+		handler.logger.Printf("%s unknown error after businessManager.GetCategories %+v", CallerFilename(), err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	result := api.GetCategoriesResponse{Categories: []api.CategoryApiModel{}}
+	for _, v := range categories {
+		result.Categories = append(result.Categories, apiUtils.ConvertCategoryToApiModel(&v))
+	}
+	c.JSON(200, result)
+}
+
+func CreateCategoryHandlers(businessManager BusinessManager, logger *log.Logger) *CategoryHandlers {
+	return &CategoryHandlers{
+		businessManager: businessManager,
+		logger:          logger,
+	}
+}
+
+func (handler *CategoryHandlers) Connect(rg *gin.RouterGroup) {
+	rg.GET("", handler.getCategories)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"log"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	api "github.com/StampWallet/backend/internal/api/models"
+	"github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/managers/mocks"
+	. "github.com/StampWallet/backend/internal/testutils"
+)
+
+func getCategoryHandlers(ctrl *gomock.Controller) *CategoryHandlers {
+	return &CategoryHandlers{
+		businessManager: NewMockBusinessManager(ctrl),
+		logger:          log.Default(),
+	}
+}
+
+func TestCategoryHandlersGetCategoriesOk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/categories").
+		SetMethod("GET").
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getCategoryHandlers(ctrl)
+
+	// setup mocks
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		GetCategories().
+		Return([]database.Category{{Slug: "bakery", Name: "Bakery"}, {Slug: "cafe", Name: "Café"}}, nil)
+
+	handler.getCategories(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.GetCategoriesResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, 200, respCode, "Response returned unexpected status code")
+	require.Equalf(t, api.GetCategoriesResponse{Categories: []api.CategoryApiModel{
+		{Slug: "bakery", Name: "Bakery"},
+		{Slug: "cafe", Name: "Café"},
+	}}, *respBody, "Response returned unexpected body")
+}
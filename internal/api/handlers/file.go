@@ -3,6 +3,7 @@ package api
 import (
 	"io"
 	"log"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
@@ -43,11 +44,19 @@ func (handler *FileHandlers) getFileById(c *gin.Context, user *database.User, fi
 
 // Handles get file request
 // Requires fileId path parameter which will contain FileMetadata.PublicId
+// Optional variant query parameter selects the image variant - thumb, medium or original
 func (handler *FileHandlers) getFile(c *gin.Context) {
 	fileId := c.Param("fileId")
 
+	// Original is returned by default
+	variant := ImageVariant(c.DefaultQuery("variant", string(ImageVariantOriginal)))
+	if !Contains(ImageVariants, variant) {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_VARIANT"})
+		return
+	}
+
 	// Get file by id, handle errors
-	file, mimetype, err := handler.fileStorageService.GetData(fileId)
+	fileData, err := handler.fileStorageService.GetData(fileId, variant)
 	if err == ErrNoSuchFile || err == ErrFileNotUploaded {
 		c.JSON(404, api.DefaultResponse{Status: api.NOT_FOUND})
 		return
@@ -57,9 +66,29 @@ func (handler *FileHandlers) getFile(c *gin.Context) {
 		return
 	}
 
+	// Clients that have the data already don't have to download it again
+	if fileData.ETag != "" {
+		c.Header("ETag", fileData.ETag)
+		if etagMatches(c.GetHeader("If-None-Match"), fileData.ETag) {
+			if fileData.Reader != nil {
+				fileData.Reader.Close()
+			}
+			c.Status(304)
+			c.Writer.WriteHeaderNow()
+			return
+		}
+	}
+
+	// Storage backend serves the file itself
+	if fileData.RedirectUrl != "" {
+		c.Redirect(302, fileData.RedirectUrl)
+		return
+	}
+	defer fileData.Reader.Close()
+
 	// Write Content-Type and file
-	c.Header("Content-Type", mimetype)
-	_, err = io.Copy(c.Writer, file)
+	c.Header("Content-Type", fileData.ContentType)
+	_, err = io.Copy(c.Writer, fileData.Reader)
 	//content-type already set, cant just return a json now
 	if err != nil {
 		handler.logger.Printf("%s unknown error after io.Copy: %+v", CallerFilename(), err)
@@ -111,6 +140,15 @@ func (handler *FileHandlers) postFile(c *gin.Context) {
 	if err == ErrInvalidMimeType {
 		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CONTENT_TYPE"})
 		return
+	} else if err == ErrInvalidImage {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_IMAGE"})
+		return
+	} else if err == ErrImageTooLarge {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "IMAGE_TOO_LARGE"})
+		return
+	} else if err == ErrFileQuotaExceeded {
+		c.JSON(403, api.DefaultResponse{Status: api.FORBIDDEN, Message: "FILE_QUOTA_EXCEEDED"})
+		return
 	} else if err != nil {
 		handler.logger.Printf("%s unknown error after fileStorageService.Upload: %+v", CallerFilename(), err)
 		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
@@ -168,3 +206,17 @@ func (handler *FileHandlers) Connect(rg *gin.RouterGroup) {
 	rg.POST("/:fileId", handler.postFile)
 	rg.DELETE("/:fileId", handler.deleteFile)
 }
+
+// Returns true if If-None-Match header value matches etag. Weak comparison is used, as required
+// for If-None-Match.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -12,6 +12,7 @@ import (
 	api "github.com/StampWallet/backend/internal/api/models"
 	"github.com/StampWallet/backend/internal/database"
 	. "github.com/StampWallet/backend/internal/database/accessors/mocks"
+	. "github.com/StampWallet/backend/internal/services"
 	. "github.com/StampWallet/backend/internal/services/mocks"
 	. "github.com/StampWallet/backend/internal/testutils"
 	"github.com/gin-gonic/gin"
@@ -56,10 +57,9 @@ func TestFileHandlerGetFileOk(t *testing.T) {
 
 	handler.fileStorageService.(*MockFileStorageService).
 		EXPECT().
-		GetData(gomock.Eq(fileId)).
+		GetData(gomock.Eq(fileId), gomock.Eq(ImageVariantOriginal)).
 		Return(
-			testFileHandle, // Q: Should this change upon upload?
-			"image/png",
+			&FileData{Reader: testFileHandle, ContentType: "image/png", ETag: `"0d2f"`}, // Q: Should this change upon upload?
 			nil,
 		)
 
@@ -69,6 +69,114 @@ func TestFileHandlerGetFileOk(t *testing.T) {
 
 	require.Equalf(t, w.Result().StatusCode, int(200), "Response returned unexpected status code")
 	require.Truef(t, bytes.Compare(w.Body.Bytes(), expectedContents) == 0, "Response returned unexpected file data")
+	require.Equalf(t, `"0d2f"`, w.Result().Header.Get("ETag"), "Response should have the ETag")
+}
+
+func TestFileHandlerGetFileRedirect(t *testing.T) {
+	testUser := GetDefaultUser()
+	fileId := "abcdef123"
+	redirectUrl := "https://bucket.s3.example.com/abcdef123?X-Amz-Signature=abc"
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/file/"+fileId).
+		AddQueryParam("variant", "thumb").
+		SetUser(testUser).
+		SetMethod("GET").
+		SetParam("fileId", fileId).
+		SetDefaultToken().
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getFileHandlers(ctrl)
+
+	// setup mocks
+	handler.fileStorageService.(*MockFileStorageService).
+		EXPECT().
+		GetData(gomock.Eq(fileId), gomock.Eq(ImageVariantThumb)).
+		Return(&FileData{RedirectUrl: redirectUrl, ContentType: "image/png"}, nil)
+
+	handler.getFile(context)
+
+	require.Equalf(t, 302, w.Result().StatusCode, "Response returned unexpected status code")
+	require.Equalf(t, redirectUrl, w.Result().Header.Get("Location"), "Response should redirect to the file")
+}
+
+func TestFileHandlerGetFileNotModified(t *testing.T) {
+	testUser := GetDefaultUser()
+	fileId := "abcdef123"
+	etag := `"0d2f_thumb"`
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/file/"+fileId).
+		AddQueryParam("variant", "thumb").
+		SetUser(testUser).
+		SetMethod("GET").
+		SetHeader("If-None-Match", `"other", W/`+etag).
+		SetParam("fileId", fileId).
+		SetDefaultToken().
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getFileHandlers(ctrl)
+
+	// setup mocks
+	testFileHandle, err := os.Open("resources/test.png")
+	require.NoError(t, err)
+	handler.fileStorageService.(*MockFileStorageService).
+		EXPECT().
+		GetData(gomock.Eq(fileId), gomock.Eq(ImageVariantThumb)).
+		Return(&FileData{Reader: testFileHandle, ContentType: "image/png", ETag: etag}, nil)
+
+	handler.getFile(context)
+
+	require.Equalf(t, 304, w.Result().StatusCode, "Response returned unexpected status code")
+	require.Equalf(t, etag, w.Result().Header.Get("ETag"), "Response should have the ETag")
+	require.Equalf(t, 0, w.Body.Len(), "Response should not have a body")
+}
+
+func TestEtagMatches(t *testing.T) {
+	require.Truef(t, etagMatches(`"abc"`, `"abc"`), "same ETags should match")
+	require.Truef(t, etagMatches(`"x", W/"abc"`, `"abc"`), "weak ETags should match in lists")
+	require.Truef(t, etagMatches(`*`, `"abc"`), "* should match any ETag")
+	require.Falsef(t, etagMatches(`"abd"`, `"abc"`), "different ETags should not match")
+	require.Falsef(t, etagMatches(``, `"abc"`), "missing header should not match")
+}
+
+func TestFileHandlerGetFileInvalidVariant(t *testing.T) {
+	fileId := "abcdef123"
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/file/"+fileId).
+		AddQueryParam("variant", "huge").
+		SetMethod("GET").
+		SetParam("fileId", fileId).
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getFileHandlers(ctrl)
+
+	handler.getFile(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, 400, respCode, "Response returned unexpected status code")
+	require.Equalf(t, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_VARIANT"}, *respBody,
+		"Response returned unexpected body")
 }
 
 func TestFileHandlersPostFileOk(t *testing.T) {
@@ -24,6 +24,7 @@ import (
 func getItemHandlers(ctrl *gomock.Controller) *ItemDefinitionHandlers {
 	return &ItemDefinitionHandlers{
 		itemDefinitionManager:      NewMockItemDefinitionManager(ctrl),
+		businessMemberManager:      NewMockBusinessMemberManager(ctrl),
 		userAuthorizedAcessor:      NewMockUserAuthorizedAccessor(ctrl),
 		businessAuthorizedAccessor: NewMockBusinessAuthorizedAccessor(ctrl),
 		logger:                     log.Default(),
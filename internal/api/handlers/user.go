@@ -2,7 +2,10 @@ package api
 
 import (
 	"log"
+	"math"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -47,6 +50,7 @@ func CreateUserHandlers(
 	businessManager BusinessManager,
 	transactionManager TransactionManager,
 	itemDefinitionManager ItemDefinitionManager,
+	pointsLedgerManager PointsLedgerManager,
 	userAuthorizedAcessor UserAuthorizedAccessor,
 	authorizedTransactionAccessor AuthorizedTransactionAccessor,
 	logger *log.Logger,
@@ -64,6 +68,7 @@ func CreateUserHandlers(
 			virtualCardManager:            virtualCardManager,
 			transactionManager:            transactionManager,
 			itemDefinitionManager:         itemDefinitionManager,
+			pointsLedgerManager:           pointsLedgerManager,
 			userAuthorizedAcessor:         userAuthorizedAcessor,
 			authorizedTransactionAccessor: authorizedTransactionAccessor,
 			logger:                        services.NewPrefix(logger, "VirtualCardHandlers"),
@@ -79,6 +84,12 @@ func CreateUserHandlers(
 	}
 }
 
+// Lists in the cursor of getUserCards
+const (
+	cardListLocal   = "local"
+	cardListVirtual = "virtual"
+)
+
 // Handles local and virtual cards retrieval request
 func (handler *UserHandlers) getUserCards(c *gin.Context) {
 	// Get user object from middleware
@@ -87,47 +98,97 @@ func (handler *UserHandlers) getUserCards(c *gin.Context) {
 		return
 	}
 
+	// Pagination - cursor and limit
+	page := getPageFromQuery(c)
+	if page == nil {
+		return
+	}
+
+	// Local cards are listed before virtual cards. Cursor of the response stores the list
+	// the next page starts in, and the cursor returned by that list
+	list := cardListLocal
+	var listCursor string
+	if page.Cursor != "" {
+		err := database.DecodeCursor(page.Cursor, &list, &listCursor)
+		if err != nil || (list != cardListLocal && list != cardListVirtual) {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CURSOR"})
+			return
+		}
+	}
+
 	result := api.GetUserCardsResponse{}
+	remaining := page.Limit
+
+	// Get local cards of user
+	if list == cardListLocal {
+		localCards, nextCursor, err := handler.userAuthorizedAcessor.GetAll(user, &database.LocalCard{}, []string{},
+			&database.Page{Cursor: listCursor, Limit: remaining})
+		if err == database.ErrInvalidCursor {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CURSOR"})
+			return
+		} else if err != nil {
+			handler.logger.Printf("%s unknown error after userAuthorizedAcessor.GetAll for localCard: %+v",
+				CallerFilename(), err)
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+			return
+		}
 
-	// Get all local cards of user
-	localCards, err := handler.userAuthorizedAcessor.GetAll(user, &database.LocalCard{}, []string{})
-	if err != nil {
-		handler.logger.Printf("%s unknown error after userAuthorizedAcessor.GetAll for localCard: %+v",
-			CallerFilename(), err)
-		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
-		return
+		// Convert database.LocalCard to api model
+		for _, v := range localCards {
+			card := v.(*database.LocalCard)
+			result.LocalCards = append(result.LocalCards, api.LocalCardApiModel{
+				PublicId: card.PublicId,
+				Name:     card.Name,
+				Type:     card.Type,
+				Code:     card.Code,
+			})
+		}
+
+		remaining -= uint(len(localCards))
+		list, listCursor = cardListVirtual, ""
+		if nextCursor != "" {
+			list, listCursor = cardListLocal, nextCursor
+		}
 	}
 
-	// Convert database.LocalCard to api model
-	for _, v := range localCards {
-		card := v.(*database.LocalCard)
-		result.LocalCards = append(result.LocalCards, api.LocalCardApiModel{
-			PublicId: card.PublicId,
-			Name:     card.Name,
-			Type:     card.Type,
-			Code:     card.Code,
-		})
+	// Get virtual cards of user, if local cards did not fill the page
+	if list == cardListVirtual && remaining > 0 {
+		virtualCards, nextCursor, err := handler.userAuthorizedAcessor.GetAll(user, &database.VirtualCard{},
+			[]string{"Business"}, &database.Page{Cursor: listCursor, Limit: remaining})
+		if err == database.ErrInvalidCursor {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CURSOR"})
+			return
+		} else if err != nil {
+			handler.logger.Printf("%s unknown error after userAuthorizedAcessor.GetAll for virtualCard: %+v",
+				CallerFilename(), err)
+			c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+			return
+		}
+
+		// Convert database.VirtualCard to api model
+		for _, v := range virtualCards {
+			card := v.(*database.VirtualCard)
+			result.VirtualCards = append(result.VirtualCards, api.ShortVirtualCardApiModel{
+				BusinessDetails: apiUtils.ConvertBusinessToShortApiModel(card.Business),
+				Points:          int32(card.Points),
+			})
+		}
+
+		// Virtual cards are the last list
+		if nextCursor == "" {
+			c.JSON(200, result)
+			return
+		}
+		listCursor = nextCursor
 	}
 
-	// Get all virtual cards of user
-	virtualCards, err := handler.userAuthorizedAcessor.GetAll(user, &database.VirtualCard{},
-		[]string{"Business"})
+	nextCursor, err := database.EncodeCursor(list, listCursor)
 	if err != nil {
-		handler.logger.Printf("%s unknown error after userAuthorizedAcessor.GetAll for virtualCard: %+v",
-			CallerFilename(), err)
+		handler.logger.Printf("%s unknown error after database.EncodeCursor: %+v", CallerFilename(), err)
 		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
 		return
 	}
-
-	// Convert database.VirtualCard to api model
-	for _, v := range virtualCards {
-		card := v.(*database.VirtualCard)
-		result.VirtualCards = append(result.VirtualCards, api.ShortVirtualCardApiModel{
-			BusinessDetails: apiUtils.ConvertBusinessToShortApiModel(card.Business),
-			Points:          int32(card.Points),
-		})
-	}
-
+	result.NextCursor = nextCursor
 	c.JSON(200, result)
 }
 
@@ -139,10 +200,12 @@ func (handler *UserHandlers) getSearchBusinesses(c *gin.Context) {
 	locationQuery := c.Query("location")
 	// Filter by location - proximity in meters
 	proximityQuery, proximityExists := c.GetQuery("proximity")
-	// Pagination - offset
-	offsetQuery := c.Query("offset")
-	// Pagination - limit
-	limitQuery := c.Query("limit")
+	// Filter by categories (slugs), any of them has to match
+	categories := c.QueryArray("category")
+	// Sort mode - distance, relevance or newest
+	sortQuery := c.Query("sort")
+	// Filter by opening hours - only businesses open now
+	openNowQuery := c.Query("openNow")
 
 	// Parse text query if presetn
 	var text *string
@@ -175,46 +238,88 @@ func (handler *UserHandlers) getSearchBusinesses(c *gin.Context) {
 		proximity = uint(localProximity)
 	}
 
-	var offset uint = 0
-	var limit uint = 50
-
-	// Parse offset if exists
-	if offsetQuery != "" {
-		localOffset, err := strconv.ParseUint(offsetQuery, 10, 32)
+	// Parse open now filter if present
+	var openAt *time.Time
+	if openNowQuery != "" {
+		openNow, err := strconv.ParseBool(openNowQuery)
 		if err != nil {
-			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OFFSET"})
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPEN_NOW"})
 			return
 		}
-		offset = uint(localOffset)
+		if openNow {
+			now := time.Now()
+			openAt = &now
+		}
 	}
 
-	// Parse limit if exists
-	if limitQuery != "" {
-		localLimit, err := strconv.ParseUint(limitQuery, 10, 32)
-		if err != nil {
-			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OFFSET"})
-			return
-		}
-		offset = uint(localLimit)
+	// Pagination - cursor and limit
+	page := getPageFromQuery(c)
+	if page == nil {
+		return
 	}
 
-	if location == nil && text == nil {
+	// Parse sort mode if present, manager picks the default otherwise
+	sortMode := BusinessSearchSortEnum(sortQuery)
+	if sortMode != "" && sortMode != BusinessSearchSortDistance && sortMode != BusinessSearchSortRelevance &&
+		sortMode != BusinessSearchSortNewest {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_SORT"})
+		return
+	}
+
+	if location == nil && text == nil && len(categories) == 0 {
 		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "EMPTY_QUERY"})
 		return
 	}
 
 	// Execute the query, handle errors
-	businesses, err := handler.businessManager.Search(text, location, proximity, offset, limit)
-	if err != nil {
+	searchResult, err := handler.businessManager.Search(&BusinessSearchQuery{
+		Text:              text,
+		Location:          location,
+		ProximityInMeters: proximity,
+		Categories:        categories,
+		OpenAt:            openAt,
+		Sort:              sortMode,
+		Page:              *page,
+	})
+	if err == ErrInvalidCategory {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CATEGORY"})
+		return
+	} else if err == ErrInvalidSort {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_SORT"})
+		return
+	} else if err == database.ErrInvalidCursor {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CURSOR"})
+		return
+	} else if err != nil {
 		handler.logger.Printf("%s unknown error after businessManager.Search %+v", CallerFilename(), err)
 		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
 		return
 	}
 
 	// Convert results to api model
-	result := api.GetUserBusinessesSearchResponse{}
-	for _, v := range businesses {
-		result.Businesses = append(result.Businesses, apiUtils.ConvertBusinessToShortApiModel(&v))
+	result := api.GetUserBusinessesSearchResponse{NextCursor: searchResult.NextCursor}
+	for _, v := range searchResult.Businesses {
+		business := apiUtils.ConvertBusinessToShortApiModel(&v.Business)
+		if v.DistanceInMeters != nil {
+			distance := int32(math.Round(*v.DistanceInMeters))
+			business.Distance = &distance
+		}
+		if v.NearestLocation != nil {
+			location := apiUtils.ConvertBusinessLocationToShortApiModel(v.NearestLocation)
+			business.NearestLocation = &location
+		}
+		result.Businesses = append(result.Businesses, business)
+	}
+	slugs := make([]string, 0, len(searchResult.CategoryCounts))
+	for slug := range searchResult.CategoryCounts {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	for _, slug := range slugs {
+		result.CategoryCounts = append(result.CategoryCounts, api.CategoryCountApiModel{
+			Slug:  slug,
+			Count: int32(searchResult.CategoryCounts[slug]),
+		})
 	}
 
 	c.JSON(200, result)
@@ -260,6 +365,7 @@ type UserVirtualCardHandlers struct {
 	virtualCardManager            VirtualCardManager
 	transactionManager            TransactionManager
 	itemDefinitionManager         ItemDefinitionManager
+	pointsLedgerManager           PointsLedgerManager
 	userAuthorizedAcessor         UserAuthorizedAccessor
 	authorizedTransactionAccessor AuthorizedTransactionAccessor
 	logger                        *log.Logger
@@ -368,7 +474,23 @@ func (handler *UserVirtualCardHandlers) getCard(c *gin.Context) {
 		return
 	}
 
+	// Get points that expire soon
+	expiringPoints, err := handler.pointsLedgerManager.GetExpiringPoints(virtualCard)
+	if err != nil {
+		handler.logger.Printf("%s unknown error after pointsLedgerManager.GetExpiringPoints: %+v", CallerFilename(), err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
 	// Convert data, return response
+	var apiExpiringPoints []api.ExpiringPointsApiModel
+	for _, v := range expiringPoints {
+		apiExpiringPoints = append(apiExpiringPoints, api.ExpiringPointsApiModel{
+			Points:  int32(v.Points),
+			Expires: v.Expires,
+		})
+	}
+
 	var ownedItems []api.OwnedItemApiModel
 	for _, v := range virtualCard.OwnedItems {
 		ownedItems = append(ownedItems, api.OwnedItemApiModel{
@@ -383,7 +505,8 @@ func (handler *UserVirtualCardHandlers) getCard(c *gin.Context) {
 			virtualCard.Business,
 			virtualCard.Business.ItemDefinitions,
 			virtualCard.Business.MenuImages),
-		OwnedItems: ownedItems,
+		OwnedItems:     ownedItems,
+		ExpiringPoints: apiExpiringPoints,
 	}
 	c.JSON(200, response)
 }
@@ -585,12 +708,56 @@ func (handler *UserVirtualCardHandlers) getTransaction(c *gin.Context) {
 	})
 }
 
+// Handles get points history request
+// Requires businessId path parameter
+func (handler *UserVirtualCardHandlers) getPointsHistory(c *gin.Context) {
+	businessId := c.Param("businessId")
+
+	// Get user from context (should be inserted by authMiddleware)
+	user := getUserFromContext(handler.logger, c)
+	if user == nil {
+		return
+	}
+
+	// Get virtual card of user
+	virtualCard := handler.getVirtualCardOfUser(c, user, businessId)
+	if virtualCard == nil {
+		return
+	}
+
+	// Pagination - cursor and limit
+	page := getPageFromQuery(c)
+	if page == nil {
+		return
+	}
+
+	// Get ledger entries, handle errors
+	entries, nextCursor, err := handler.pointsLedgerManager.GetHistory(virtualCard, page)
+	if err == database.ErrInvalidCursor {
+		c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CURSOR"})
+		return
+	} else if err != nil {
+		handler.logger.Printf("%s unknown error after pointsLedgerManager.GetHistory: %+v", CallerFilename(), err)
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return
+	}
+
+	// Convert data, return response
+	apiEntries := []api.PointsLedgerEntryApiModel{}
+	for _, v := range entries {
+		apiEntries = append(apiEntries, apiUtils.ConvertPointsLedgerEntryToApiModel(&v))
+	}
+
+	c.JSON(200, api.GetUserVirtualCardPointsHistoryResponse{Entries: apiEntries, NextCursor: nextCursor})
+}
+
 func (handler *UserVirtualCardHandlers) Connect(rg *gin.RouterGroup) {
 	card := rg.Group("/:businessId")
 	{
 		card.POST("", handler.postCard)
 		card.DELETE("", handler.deleteCard)
 		card.GET("", handler.getCard)
+		card.GET("/points", handler.getPointsHistory)
 
 		card.POST("/itemsDefinitions/:itemDefinitionId", handler.postItemDefinition)
 
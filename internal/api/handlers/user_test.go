@@ -12,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 
 	api "github.com/StampWallet/backend/internal/api/models"
 	apiUtils "github.com/StampWallet/backend/internal/api/utils"
@@ -56,6 +57,7 @@ func getVirtualCardHandlers(ctrl *gomock.Controller) *UserVirtualCardHandlers {
 		virtualCardManager:            NewMockVirtualCardManager(ctrl),
 		transactionManager:            NewMockTransactionManager(ctrl),
 		itemDefinitionManager:         NewMockItemDefinitionManager(ctrl),
+		pointsLedgerManager:           NewMockPointsLedgerManager(ctrl),
 		userAuthorizedAcessor:         NewMockUserAuthorizedAccessor(ctrl),
 		authorizedTransactionAccessor: NewMockAuthorizedTransactionAccessor(ctrl),
 		logger:                        log.Default(),
@@ -138,13 +140,15 @@ func TestUserHandlersGetUserCardsOk(t *testing.T) {
 
 	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
 		EXPECT().
-		GetAll(gomock.Eq(testUser), &database.LocalCard{}, []string{}).
-		Return(([]accessors.UserOwnedEntity{testLocalCard}), nil)
+		GetAll(gomock.Eq(testUser), &database.LocalCard{}, []string{},
+			gomock.Eq(&database.Page{Limit: defaultPageLimit})).
+		Return([]accessors.UserOwnedEntity{testLocalCard}, "", nil)
 
 	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
 		EXPECT().
-		GetAll(gomock.Eq(testUser), &database.VirtualCard{}, []string{"Business"}).
-		Return([]accessors.UserOwnedEntity{testVirtualCard}, nil)
+		GetAll(gomock.Eq(testUser), &database.VirtualCard{}, []string{"Business"},
+			gomock.Eq(&database.Page{Limit: defaultPageLimit - 1})).
+		Return([]accessors.UserOwnedEntity{testVirtualCard}, "", nil)
 
 	handler.getUserCards(context)
 
@@ -155,6 +159,80 @@ func TestUserHandlersGetUserCardsOk(t *testing.T) {
 	require.Truef(t, reflect.DeepEqual(*respBodyExpected, *respBody), "Response returned unexpected body contents")
 }
 
+func TestUserHandlersGetUserCardsPages(t *testing.T) {
+	testUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(GetDefaultUser())
+	testLocalCard := GetTestLocalCard(nil, testUser)
+	testVirtualCard := GetTestVirtualCard(nil, testUser, testBusiness)
+	testVirtualCard.Business = testBusiness
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getUserHandlers(ctrl)
+
+	getCards := func(cursor string) *api.GetUserCardsResponse {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+
+		builder := NewTestContextBuilder(w).
+			SetDefaultUrl().
+			SetEndpoint("/user/cards").
+			AddQueryParam("limit", "1").
+			SetUser(testUser).
+			SetMethod("GET").
+			SetDefaultToken()
+		if cursor != "" {
+			builder = builder.AddQueryParam("cursor", cursor)
+		}
+
+		handler.getUserCards(builder.Context)
+
+		respBody, respCode, respParseErr := ExtractResponse[api.GetUserCardsResponse](w)
+		require.Nilf(t, respParseErr, "Failed to parse JSON response")
+		require.Equalf(t, 200, respCode, "Response returned unexpected status code")
+		return respBody
+	}
+
+	// first page - local card fills the page, there are more local cards
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		GetAll(gomock.Eq(testUser), &database.LocalCard{}, []string{}, gomock.Eq(&database.Page{Limit: 1})).
+		Return([]accessors.UserOwnedEntity{testLocalCard}, "localCursor", nil)
+
+	page := getCards("")
+	require.Lenf(t, page.LocalCards, 1, "First page should have the local card")
+	require.Emptyf(t, page.VirtualCards, "First page should not have virtual cards")
+	require.NotEmptyf(t, page.NextCursor, "First page should have cursor of the next page")
+
+	// second page - no more local cards, page is filled with virtual cards
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		GetAll(gomock.Eq(testUser), &database.LocalCard{}, []string{},
+			gomock.Eq(&database.Page{Cursor: "localCursor", Limit: 1})).
+		Return([]accessors.UserOwnedEntity{}, "", nil)
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		GetAll(gomock.Eq(testUser), &database.VirtualCard{}, []string{"Business"}, gomock.Eq(&database.Page{Limit: 1})).
+		Return([]accessors.UserOwnedEntity{testVirtualCard}, "virtualCursor", nil)
+
+	page = getCards(page.NextCursor)
+	require.Emptyf(t, page.LocalCards, "Second page should not have local cards")
+	require.Lenf(t, page.VirtualCards, 1, "Second page should have the virtual card")
+	require.NotEmptyf(t, page.NextCursor, "Second page should have cursor of the next page")
+
+	// last page - only virtual cards are left
+	handler.userAuthorizedAcessor.(*MockUserAuthorizedAccessor).
+		EXPECT().
+		GetAll(gomock.Eq(testUser), &database.VirtualCard{}, []string{"Business"},
+			gomock.Eq(&database.Page{Cursor: "virtualCursor", Limit: 1})).
+		Return([]accessors.UserOwnedEntity{}, "", nil)
+
+	page = getCards(page.NextCursor)
+	require.Emptyf(t, page.LocalCards, "Last page should not have local cards")
+	require.Emptyf(t, page.VirtualCards, "Last page should not have virtual cards")
+	require.Emptyf(t, page.NextCursor, "Last page should not have cursor of the next page")
+}
+
 func TestUserHandlersGetSearchBusinessesOk(t *testing.T) {
 	// TODO caly test case do napisania
 	testUser := GetDefaultUser()
@@ -194,14 +272,12 @@ func TestUserHandlersGetSearchBusinessesOk(t *testing.T) {
 	// setup mocks
 	handler.businessManager.(*MockBusinessManager).
 		EXPECT().
-		Search(
-			&pointerMatcher[string]{"example business search"},
-			gomock.Any(),
-			gomock.Any(),
-			gomock.Any(),
-			gomock.Any(),
-		).
-		Return([]database.Business{*testBusiness}, nil)
+		Search(gomock.Eq(&managers.BusinessSearchQuery{
+			Text:              Ptr("example business search"),
+			ProximityInMeters: 1000,
+			Page:              database.Page{Limit: 50},
+		})).
+		Return(&managers.BusinessSearchResult{Businesses: []managers.FoundBusiness{{Business: *testBusiness}}}, nil)
 
 	handler.getSearchBusinesses(context)
 
@@ -212,6 +288,392 @@ func TestUserHandlersGetSearchBusinessesOk(t *testing.T) {
 	require.Truef(t, reflect.DeepEqual(respBodyExpected, *respBody), "Response returned unexpected body contents")
 }
 
+func TestUserHandlersGetSearchBusinessesCategories(t *testing.T) {
+	testUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(GetDefaultUser())
+	testBusiness.Categories = []database.Category{{Slug: "bakery"}, {Slug: "cafe"}}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/user/businesses").
+		AddQueryParam("category", "cafe").
+		AddQueryParam("category", "bakery").
+		AddQueryParam("limit", "10").
+		SetUser(testUser).
+		SetMethod("GET").
+		SetDefaultToken().
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getUserHandlers(ctrl)
+
+	// setup mocks
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		Search(gomock.Eq(&managers.BusinessSearchQuery{
+			ProximityInMeters: 1000,
+			Categories:        []string{"cafe", "bakery"},
+			Page:              database.Page{Limit: 10},
+		})).
+		Return(&managers.BusinessSearchResult{
+			Businesses:     []managers.FoundBusiness{{Business: *testBusiness}},
+			CategoryCounts: map[string]uint{"cafe": 4, "bakery": 1, "barber": 2},
+		}, nil)
+
+	handler.getSearchBusinesses(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.GetUserBusinessesSearchResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, 200, respCode, "Response returned unexpected status code")
+	require.Lenf(t, respBody.Businesses, 1, "Response should have the business")
+	require.Equalf(t, []string{"bakery", "cafe"}, respBody.Businesses[0].Categories,
+		"Response should have categories of the business")
+	require.Equalf(t, []api.CategoryCountApiModel{
+		{Slug: "bakery", Count: 1},
+		{Slug: "barber", Count: 2},
+		{Slug: "cafe", Count: 4},
+	}, respBody.CategoryCounts, "Response returned unexpected category counts")
+}
+
+func TestUserHandlersGetSearchBusinessesDistance(t *testing.T) {
+	testBusiness := GetDefaultBusiness(GetDefaultUser())
+	location := database.FromCoords(27.5916, 086.5640)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/user/businesses").
+		AddQueryParam("location", location.ToString()).
+		AddQueryParam("proximity", "500").
+		AddQueryParam("sort", "distance").
+		SetUser(GetDefaultUser()).
+		SetMethod("GET").
+		SetDefaultToken().
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getUserHandlers(ctrl)
+
+	// setup mocks
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		Search(gomock.Eq(&managers.BusinessSearchQuery{
+			Location:          &location,
+			ProximityInMeters: 500,
+			Sort:              managers.BusinessSearchSortDistance,
+			Page:              database.Page{Limit: 50},
+		})).
+		Return(&managers.BusinessSearchResult{
+			Businesses: []managers.FoundBusiness{{Business: *testBusiness, DistanceInMeters: Ptr(349.6)}},
+		}, nil)
+
+	handler.getSearchBusinesses(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.GetUserBusinessesSearchResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, 200, respCode, "Response returned unexpected status code")
+	require.Lenf(t, respBody.Businesses, 1, "Response should have the business")
+	require.Equalf(t, Ptr(int32(350)), respBody.Businesses[0].Distance, "Response should have the distance in meters")
+}
+
+func TestUserHandlersGetSearchBusinessesInvalidSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/user/businesses").
+		AddQueryParam("text", "cafe").
+		AddQueryParam("sort", "alphabetical").
+		SetUser(GetDefaultUser()).
+		SetMethod("GET").
+		SetDefaultToken().
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getUserHandlers(ctrl)
+
+	handler.getSearchBusinesses(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, 400, respCode, "Response returned unexpected status code")
+	require.Equalf(t, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_SORT"}, *respBody,
+		"Response returned unexpected body")
+}
+
+func TestUserHandlersGetSearchBusinessesPage(t *testing.T) {
+	testBusiness := GetDefaultBusiness(GetDefaultUser())
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/user/businesses").
+		AddQueryParam("text", "cafe").
+		AddQueryParam("cursor", "cursorOfPage2").
+		AddQueryParam("limit", "1").
+		SetUser(GetDefaultUser()).
+		SetMethod("GET").
+		SetDefaultToken().
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getUserHandlers(ctrl)
+
+	// setup mocks
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		Search(gomock.Eq(&managers.BusinessSearchQuery{
+			Text:              Ptr("cafe"),
+			ProximityInMeters: 1000,
+			Page:              database.Page{Cursor: "cursorOfPage2", Limit: 1},
+		})).
+		Return(&managers.BusinessSearchResult{
+			Businesses: []managers.FoundBusiness{{Business: *testBusiness}},
+			NextCursor: "cursorOfPage3",
+		}, nil)
+
+	handler.getSearchBusinesses(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.GetUserBusinessesSearchResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, 200, respCode, "Response returned unexpected status code")
+	require.Lenf(t, respBody.Businesses, 1, "Response should have the business")
+	require.Equalf(t, "cursorOfPage3", respBody.NextCursor, "Response should have cursor of the next page")
+}
+
+func TestUserHandlersGetSearchBusinessesInvalidLimit(t *testing.T) {
+	for _, limit := range []string{"0", "101", "many"} {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+
+		context := NewTestContextBuilder(w).
+			SetDefaultUrl().
+			SetEndpoint("/user/businesses").
+			AddQueryParam("limit", limit).
+			SetUser(GetDefaultUser()).
+			SetMethod("GET").
+			SetDefaultToken().
+			Context
+
+		// test env prep
+		ctrl := gomock.NewController(t)
+		handler := getUserHandlers(ctrl)
+
+		handler.getSearchBusinesses(context)
+
+		respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+		require.Nilf(t, respParseErr, "Failed to parse JSON response")
+		require.Equalf(t, 400, respCode, "Response returned unexpected status code for limit %s", limit)
+		require.Equalf(t, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_LIMIT"}, *respBody,
+			"Response returned unexpected body for limit %s", limit)
+	}
+}
+
+func TestUserHandlersGetSearchBusinessesInvalidCursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/user/businesses").
+		AddQueryParam("text", "cafe").
+		AddQueryParam("cursor", "invalid").
+		SetUser(GetDefaultUser()).
+		SetMethod("GET").
+		SetDefaultToken().
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getUserHandlers(ctrl)
+
+	// setup mocks
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		Search(gomock.Any()).
+		Return(nil, database.ErrInvalidCursor)
+
+	handler.getSearchBusinesses(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, 400, respCode, "Response returned unexpected status code")
+	require.Equalf(t, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CURSOR"}, *respBody,
+		"Response returned unexpected body")
+}
+
+func TestUserHandlersGetSearchBusinessesOpenNow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/user/businesses").
+		AddQueryParam("text", "cafe").
+		AddQueryParam("openNow", "true").
+		SetUser(GetDefaultUser()).
+		SetMethod("GET").
+		SetDefaultToken().
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getUserHandlers(ctrl)
+
+	// setup mocks
+	before := time.Now()
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		Search(gomock.Any()).
+		DoAndReturn(func(query *managers.BusinessSearchQuery) (*managers.BusinessSearchResult, error) {
+			require.NotNilf(t, query.OpenAt, "Search query should filter by opening hours")
+			require.Falsef(t, query.OpenAt.Before(before) || query.OpenAt.After(time.Now()),
+				"Search query should filter businesses open now")
+			return &managers.BusinessSearchResult{}, nil
+		})
+
+	handler.getSearchBusinesses(context)
+
+	require.Equalf(t, 200, w.Result().StatusCode, "Response returned unexpected status code")
+}
+
+func TestUserHandlersGetSearchBusinessesInvalidOpenNow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/user/businesses").
+		AddQueryParam("text", "cafe").
+		AddQueryParam("openNow", "sometimes").
+		SetUser(GetDefaultUser()).
+		SetMethod("GET").
+		SetDefaultToken().
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getUserHandlers(ctrl)
+
+	handler.getSearchBusinesses(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, 400, respCode, "Response returned unexpected status code")
+	require.Equalf(t, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_OPEN_NOW"}, *respBody,
+		"Response returned unexpected body")
+}
+
+func TestUserHandlersGetSearchBusinessesInvalidCategory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/user/businesses").
+		AddQueryParam("category", "spaceport").
+		SetUser(GetDefaultUser()).
+		SetMethod("GET").
+		SetDefaultToken().
+		Context
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getUserHandlers(ctrl)
+
+	// setup mocks
+	handler.businessManager.(*MockBusinessManager).
+		EXPECT().
+		Search(gomock.Any()).
+		Return(nil, managers.ErrInvalidCategory)
+
+	handler.getSearchBusinesses(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.DefaultResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, 400, respCode, "Response returned unexpected status code")
+	require.Equalf(t, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_CATEGORY"}, *respBody,
+		"Response returned unexpected body")
+}
+
+func TestUserHandlersGetBusinessOpenNow(t *testing.T) {
+	testBusiness := GetDefaultBusiness(GetDefaultUser())
+	testBusiness.TimeZone = "Europe/Warsaw"
+	today := time.Now().In(testBusiness.Location())
+
+	gin.SetMode(gin.TestMode)
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getUserHandlers(ctrl)
+
+	getBusiness := func() *api.PublicBusinessDetailsApiModel {
+		w := httptest.NewRecorder()
+		context := NewTestContextBuilder(w).
+			SetDefaultUrl().
+			SetEndpoint("/user/businesses/"+testBusiness.PublicId).
+			SetUser(GetDefaultUser()).
+			SetMethod("GET").
+			SetDefaultToken().
+			SetParam("businessId", testBusiness.PublicId).
+			Context
+
+		handler.businessManager.(*MockBusinessManager).
+			EXPECT().
+			GetById(gomock.Eq(testBusiness.PublicId), gomock.Eq(true)).
+			Return(testBusiness, nil)
+
+		handler.getBusiness(context)
+
+		respBody, respCode, respParseErr := ExtractResponse[api.PublicBusinessDetailsApiModel](w)
+		require.Nilf(t, respParseErr, "Failed to parse JSON response")
+		require.Equalf(t, 200, respCode, "Response returned unexpected status code")
+		return respBody
+	}
+
+	// no opening hours
+	respBody := getBusiness()
+	require.Nilf(t, respBody.OpenNow, "openNow should not be set without opening hours")
+
+	// open the whole day
+	testBusiness.OpeningHours = []database.OpeningHours{{Weekday: today.Weekday(), OpensAt: 0, ClosesAt: 24 * 60}}
+	respBody = getBusiness()
+	require.Equalf(t, "Europe/Warsaw", respBody.TimeZone, "Response should have the time zone")
+	require.Equalf(t, []api.OpeningHoursApiModel{{Weekday: int32(today.Weekday()), Opens: "00:00", Closes: "24:00"}},
+		respBody.OpeningHours, "Response returned unexpected opening hours")
+	require.Equalf(t, Ptr(true), respBody.OpenNow, "business should be open")
+
+	// closed today by an exception
+	testBusiness.OpeningHoursExceptions = []database.OpeningHoursException{{
+		Date:   time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC),
+		Closed: true,
+	}}
+	respBody = getBusiness()
+	require.Equalf(t, []api.OpeningHoursExceptionApiModel{{Date: today.Format(time.DateOnly), Closed: true}},
+		respBody.OpeningHoursExceptions, "Response returned unexpected opening hours exceptions")
+	require.Equalf(t, Ptr(false), respBody.OpenNow, "business should be closed by the exception")
+}
+
 func TestUserHandlersGetBusinessesOk(t *testing.T) {
 	testUser := GetDefaultUser()
 	testBusinessUser := GetDefaultUser()
@@ -477,9 +939,11 @@ func TestUserVirtualCardHandlersGetCardOk(t *testing.T) {
 			gomock.Eq(&database.VirtualCard{Business: &database.Business{PublicId: testBusiness.PublicId}}),
 			gomock.Eq([]string{"Business", "Business.ItemDefinitions", "Business.MenuImages",
 				"OwnedItems", "OwnedItems.ItemDefinition"}),
+			gomock.Nil(),
 		).
 		Return(
 			[]accessors.UserOwnedEntity{testCard},
+			"",
 			nil,
 		)
 
@@ -493,6 +957,93 @@ func TestUserVirtualCardHandlersGetCardOk(t *testing.T) {
 	require.Truef(t, EqualStructs(*respBodyExpected, *respBody), "Response returned unexpected body contents")
 }
 
+func TestUserVirtualCardHandlersGetPointsHistoryOk(t *testing.T) {
+	testUser := GetDefaultUser()
+	testBusinessUser := GetDefaultUser()
+	testBusiness := GetDefaultBusiness(testBusinessUser)
+	testCard := GetTestVirtualCard(nil, testUser, testBusiness)
+	testItemDef := GetDefaultItem(testBusiness)
+	testOwnedItem := GetDefaultOwnedItem(testItemDef, testCard)
+	testOwnedItem.ItemDefinition = testItemDef
+	testTransaction := &database.Transaction{PublicId: "transaction"}
+	now := time.Now()
+
+	testEntries := []database.PointsLedgerEntry{
+		{
+			Model:       gorm.Model{CreatedAt: now},
+			Delta:       -10,
+			Reason:      database.PointsLedgerReasonItemBought,
+			OwnedItem:   testOwnedItem,
+			VirtualCard: testCard,
+		},
+		{
+			Model:       gorm.Model{CreatedAt: now.Add(-time.Hour)},
+			Delta:       20,
+			Reason:      database.PointsLedgerReasonTransaction,
+			Transaction: testTransaction,
+			VirtualCard: testCard,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/user/cards/virtual/"+testBusiness.PublicId+"/points").
+		SetUser(testUser).
+		SetMethod("GET").
+		SetDefaultToken().
+		SetParam("businessId", testBusiness.PublicId).
+		Context
+
+	respBodyExpected := &api.GetUserVirtualCardPointsHistoryResponse{
+		Entries: []api.PointsLedgerEntryApiModel{
+			{
+				Delta:            -10,
+				Reason:           api.ITEM_BOUGHT,
+				ItemId:           testOwnedItem.PublicId,
+				ItemDefinitionId: testItemDef.PublicId,
+				Created:          now,
+			},
+			{
+				Delta:         20,
+				Reason:        api.TRANSACTION,
+				TransactionId: testTransaction.PublicId,
+				Created:       now.Add(-time.Hour),
+			},
+		},
+	}
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	handler := getVirtualCardHandlers(ctrl)
+
+	handler.virtualCardManager.(*MockVirtualCardManager).
+		EXPECT().
+		GetForUser(gomock.Eq(testUser), gomock.Eq(testBusiness.PublicId)).
+		Return(testCard, nil)
+
+	handler.pointsLedgerManager.(*MockPointsLedgerManager).
+		EXPECT().
+		GetHistory(gomock.Eq(testCard), gomock.Eq(&database.Page{Limit: defaultPageLimit})).
+		Return(testEntries, "", nil)
+
+	handler.getPointsHistory(context)
+
+	respBody, respCode, respParseErr := ExtractResponse[api.GetUserVirtualCardPointsHistoryResponse](w)
+
+	require.Nilf(t, respParseErr, "Failed to parse JSON response")
+	require.Equalf(t, int(200), respCode, "Response returned unexpected status code")
+	require.Lenf(t, respBody.Entries, 2, "Response returned unexpected number of entries")
+	for i := range respBody.Entries {
+		require.Truef(t, respBodyExpected.Entries[i].Created.Equal(respBody.Entries[i].Created),
+			"Response returned unexpected entry date")
+		respBody.Entries[i].Created = respBodyExpected.Entries[i].Created
+	}
+	require.Equalf(t, respBodyExpected, respBody, "Response returned unexpected body contents")
+}
+
 func TestUserVirtualCardHandlersPostItemOk(t *testing.T) {
 	testUser := GetDefaultUser()
 	testBusinessUser := GetDefaultUser()
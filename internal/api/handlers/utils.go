@@ -2,6 +2,7 @@ package api
 
 import (
 	"log"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
@@ -18,3 +19,34 @@ func getUserFromContext(logger *log.Logger, c *gin.Context) *database.User {
 	}
 	return userAny.(*database.User)
 }
+
+// Returns session token inserted into the context by AuthMiddleware
+func getSessionFromContext(logger *log.Logger, c *gin.Context) *database.Token {
+	tokenAny, exists := c.Get("token")
+	if !exists {
+		logger.Printf("token not available context")
+		c.JSON(500, api.DefaultResponse{Status: api.UNKNOWN_ERROR})
+		return nil
+	}
+	return tokenAny.(*database.Token)
+}
+
+const (
+	defaultPageLimit = 50  // Items on a page of a list if the request does not specify the limit
+	maxPageLimit     = 100 // Max items on a page of a list
+)
+
+// Parses page of a list from "cursor" and "limit" query parameters.
+// On error, responds with appropriate HTTP data and returns nil
+func getPageFromQuery(c *gin.Context) *database.Page {
+	page := database.Page{Cursor: c.Query("cursor"), Limit: defaultPageLimit}
+	if limitQuery := c.Query("limit"); limitQuery != "" {
+		limit, err := strconv.ParseUint(limitQuery, 10, 32)
+		if err != nil || limit == 0 || limit > maxPageLimit {
+			c.JSON(400, api.DefaultResponse{Status: api.INVALID_REQUEST, Message: "INVALID_LIMIT"})
+			return nil
+		}
+		page.Limit = uint(limit)
+	}
+	return &page
+}
@@ -0,0 +1,24 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+package api
+
+import (
+	"time"
+)
+
+type BusinessInvitationApiModel struct {
+	InvitationId string `json:"invitationId,omitempty"`
+
+	BusinessDetails ShortBusinessDetailsApiModel `json:"businessDetails,omitempty"`
+
+	Role BusinessMemberRoleEnum `json:"role,omitempty"`
+
+	Created time.Time `json:"created,omitempty"`
+}
@@ -0,0 +1,29 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type BusinessLocationApiModel struct {
+	PublicId string `json:"publicId,omitempty"`
+
+	Name string `json:"name,omitempty"`
+
+	Address string `json:"address,omitempty"`
+
+	GpsCoordinates string `json:"gpsCoordinates,omitempty"`
+
+	// Own opening hours of the location. If both are empty, opening hours of the business apply
+	OpeningHours []OpeningHoursApiModel `json:"openingHours,omitempty"`
+
+	OpeningHoursExceptions []OpeningHoursExceptionApiModel `json:"openingHoursExceptions,omitempty"`
+
+	// Location is open at the moment, not set if neither the location nor the business has opening hours
+	OpenNow *bool `json:"openNow,omitempty"`
+}
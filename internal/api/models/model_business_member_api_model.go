@@ -0,0 +1,24 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+package api
+
+import (
+	"time"
+)
+
+type BusinessMemberApiModel struct {
+	PublicId string `json:"publicId,omitempty"`
+
+	Email string `json:"email,omitempty"`
+
+	Role BusinessMemberRoleEnum `json:"role,omitempty"`
+
+	Joined time.Time `json:"joined,omitempty"`
+}
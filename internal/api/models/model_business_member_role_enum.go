@@ -0,0 +1,19 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+package api
+
+type BusinessMemberRoleEnum string
+
+// List of BusinessMemberRoleEnum
+const (
+	OWNER   BusinessMemberRoleEnum = "OWNER"
+	MANAGER BusinessMemberRoleEnum = "MANAGER"
+	CASHIER BusinessMemberRoleEnum = "CASHIER"
+)
@@ -0,0 +1,18 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type CategoryApiModel struct {
+	// Identifier used in requests, ex. cafe
+	Slug string `json:"slug"`
+
+	Name string `json:"name"`
+}
@@ -0,0 +1,18 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type CategoryCountApiModel struct {
+	Slug string `json:"slug"`
+
+	// Number of businesses in the category matching the query, regardless of selected categories
+	Count int32 `json:"count"`
+}
@@ -14,13 +14,14 @@ type DefaultResponseStatusEnum string
 
 // List of DefaultResponseStatusEnum
 const (
-	OK              DefaultResponseStatusEnum = "OK"
-	CREATED         DefaultResponseStatusEnum = "CREATED"
-	NOT_FOUND       DefaultResponseStatusEnum = "NOT_FOUND"
-	FORBIDDEN       DefaultResponseStatusEnum = "FORBIDDEN"
-	ALREADY_EXISTS  DefaultResponseStatusEnum = "ALREADY_EXISTS"
-	UNAUTHORIZED    DefaultResponseStatusEnum = "UNAUTHORIZED"
-	INVALID_REQUEST DefaultResponseStatusEnum = "INVALID_REQUEST"
-	UNKNOWN_ERROR   DefaultResponseStatusEnum = "UNKNOWN_ERROR"
-	CONFLICT        DefaultResponseStatusEnum = "CONFLICT"
+	OK                DefaultResponseStatusEnum = "OK"
+	CREATED           DefaultResponseStatusEnum = "CREATED"
+	NOT_FOUND         DefaultResponseStatusEnum = "NOT_FOUND"
+	FORBIDDEN         DefaultResponseStatusEnum = "FORBIDDEN"
+	ALREADY_EXISTS    DefaultResponseStatusEnum = "ALREADY_EXISTS"
+	UNAUTHORIZED      DefaultResponseStatusEnum = "UNAUTHORIZED"
+	INVALID_REQUEST   DefaultResponseStatusEnum = "INVALID_REQUEST"
+	UNKNOWN_ERROR     DefaultResponseStatusEnum = "UNKNOWN_ERROR"
+	CONFLICT          DefaultResponseStatusEnum = "CONFLICT"
+	TOO_MANY_REQUESTS DefaultResponseStatusEnum = "TOO_MANY_REQUESTS"
 )
@@ -0,0 +1,21 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+import (
+	"time"
+)
+
+type ExpiringPointsApiModel struct {
+	Points int32 `json:"points"`
+
+	Expires time.Time `json:"expires,omitempty"`
+}
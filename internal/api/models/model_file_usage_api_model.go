@@ -0,0 +1,23 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type FileUsageApiModel struct {
+	Files int32 `json:"files"`
+
+	Bytes int64 `json:"bytes"`
+
+	// 0 if there is no limit
+	MaxFiles int32 `json:"maxFiles"`
+
+	// 0 if there is no limit
+	MaxBytes int64 `json:"maxBytes"`
+}
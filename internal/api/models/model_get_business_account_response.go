@@ -36,4 +36,24 @@ type GetBusinessAccountResponse struct {
 	Regon string `json:"regon,omitempty"`
 
 	OwnerName string `json:"ownerName,omitempty"`
+
+	PointsExpiryDays int32 `json:"pointsExpiryDays"`
+
+	// Owner and members have to enable TOTP to manage the business
+	RequireTotp bool `json:"requireTotp"`
+
+	// Category slugs
+	Categories []string `json:"categories,omitempty"`
+
+	// IANA time zone of opening hours, ex. Europe/Warsaw
+	TimeZone string `json:"timeZone,omitempty"`
+
+	OpeningHours []OpeningHoursApiModel `json:"openingHours,omitempty"`
+
+	OpeningHoursExceptions []OpeningHoursExceptionApiModel `json:"openingHoursExceptions,omitempty"`
+
+	Locations []BusinessLocationApiModel `json:"locations,omitempty"`
+
+	// Files of the business and their limits, only shown to the owner
+	FileUsage *FileUsageApiModel `json:"fileUsage,omitempty"`
 }
@@ -12,4 +12,7 @@ package api
 
 type GetBusinessItemDefinitionsResponse struct {
 	ItemDefinitions []ItemDefinitionApiModel `json:"itemDefinitions,omitempty"`
+
+	// Cursor of the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
 }
@@ -0,0 +1,15 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type GetBusinessLocationsResponse struct {
+	Locations []BusinessLocationApiModel `json:"locations"`
+}
@@ -12,4 +12,9 @@ package api
 
 type GetUserBusinessesSearchResponse struct {
 	Businesses []ShortBusinessDetailsApiModel `json:"businesses,omitempty"`
+
+	CategoryCounts []CategoryCountApiModel `json:"categoryCounts,omitempty"`
+
+	// Cursor of the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
 }
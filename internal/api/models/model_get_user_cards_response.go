@@ -14,4 +14,7 @@ type GetUserCardsResponse struct {
 	LocalCards []LocalCardApiModel `json:"localCards,omitempty"`
 
 	VirtualCards []ShortVirtualCardApiModel `json:"virtualCards,omitempty"`
+
+	// Cursor of the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
 }
@@ -16,4 +16,6 @@ type GetUserVirtualCardResponse struct {
 	OwnedItems []OwnedItemApiModel `json:"ownedItems,omitempty"`
 
 	BusinessDetails PublicBusinessDetailsApiModel `json:"businessDetails,omitempty"`
+
+	ExpiringPoints []ExpiringPointsApiModel `json:"expiringPoints,omitempty"`
 }
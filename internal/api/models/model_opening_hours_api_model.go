@@ -0,0 +1,22 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type OpeningHoursApiModel struct {
+	// Day of the week, 0 - Sunday, 6 - Saturday
+	Weekday int32 `json:"weekday"`
+
+	// Local time in HH:MM format
+	Opens string `json:"opens"`
+
+	// Local time in HH:MM format, up to 24:00. Hours past midnight are a separate entry of the next day
+	Closes string `json:"closes"`
+}
@@ -0,0 +1,25 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type OpeningHoursExceptionApiModel struct {
+	// Date in YYYY-MM-DD format. Exceptions replace regular opening hours of the date
+	Date string `json:"date"`
+
+	// Business is closed the whole day, opens and closes are ignored
+	Closed bool `json:"closed"`
+
+	// Local time in HH:MM format
+	Opens string `json:"opens,omitempty"`
+
+	// Local time in HH:MM format, up to 24:00
+	Closes string `json:"closes,omitempty"`
+}
@@ -14,4 +14,21 @@ type PatchBusinessAccountRequest struct {
 	Name string `json:"name,omitempty"`
 
 	Description string `json:"description,omitempty"`
+
+	PointsExpiryDays *int32 `json:"pointsExpiryDays,omitempty"`
+
+	// Require TOTP from the owner and all members. Can only be changed by the owner
+	RequireTotp *bool `json:"requireTotp,omitempty"`
+
+	// Category slugs, replace all current categories. See GET /categories
+	Categories *[]string `json:"categories,omitempty"`
+
+	// IANA time zone of opening hours, ex. Europe/Warsaw
+	TimeZone *string `json:"timeZone,omitempty"`
+
+	// Replace all current opening hours
+	OpeningHours *[]OpeningHoursApiModel `json:"openingHours,omitempty"`
+
+	// Replace all current opening hours exceptions
+	OpeningHoursExceptions *[]OpeningHoursExceptionApiModel `json:"openingHoursExceptions,omitempty"`
 }
@@ -0,0 +1,25 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type PatchBusinessLocationRequest struct {
+	Name *string `json:"name,omitempty"`
+
+	Address *string `json:"address,omitempty"`
+
+	GpsCoordinates *string `json:"gpsCoordinates,omitempty"`
+
+	// Replace all own opening hours of the location
+	OpeningHours *[]OpeningHoursApiModel `json:"openingHours,omitempty"`
+
+	// Replace all own opening hours exceptions of the location
+	OpeningHoursExceptions *[]OpeningHoursExceptionApiModel `json:"openingHoursExceptions,omitempty"`
+}
@@ -0,0 +1,29 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+import (
+	"time"
+)
+
+type PointsLedgerEntryApiModel struct {
+	Delta int32 `json:"delta"`
+
+	Reason PointsLedgerReasonEnum `json:"reason,omitempty"`
+
+	TransactionId string `json:"transactionId,omitempty"`
+
+	ItemId string `json:"itemId,omitempty"`
+
+	ItemDefinitionId string `json:"itemDefinitionId,omitempty"`
+
+	Created time.Time `json:"created,omitempty"`
+}
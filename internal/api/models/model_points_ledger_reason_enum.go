@@ -0,0 +1,24 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type PointsLedgerReasonEnum string
+
+// List of PointsLedgerReasonEnum
+const (
+	OPENING_BALANCE PointsLedgerReasonEnum = "OPENING_BALANCE"
+	TRANSACTION     PointsLedgerReasonEnum = "TRANSACTION"
+	ITEM_BOUGHT     PointsLedgerReasonEnum = "ITEM_BOUGHT"
+	ITEM_RETURNED   PointsLedgerReasonEnum = "ITEM_RETURNED"
+	ITEM_RECALLED   PointsLedgerReasonEnum = "ITEM_RECALLED"
+	ITEM_WITHDRAWN  PointsLedgerReasonEnum = "ITEM_WITHDRAWN"
+	POINTS_EXPIRED  PointsLedgerReasonEnum = "POINTS_EXPIRED"
+)
@@ -12,4 +12,7 @@ package api
 
 type PostAccountEmailRequest struct {
 	Email string `json:"email,omitempty" binding:"required"`
+
+	// Log out all other sessions of the user
+	LogoutOtherSessions bool `json:"logoutOtherSessions,omitempty"`
 }
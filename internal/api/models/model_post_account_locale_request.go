@@ -0,0 +1,16 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type PostAccountLocaleRequest struct {
+	// Preferred language of emails, like \"en\" or \"pl-PL\". Empty resets it to the default
+	Locale string `json:"locale"`
+}
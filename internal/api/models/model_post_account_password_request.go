@@ -14,4 +14,7 @@ type PostAccountPasswordRequest struct {
 	Password string `json:"password,omitempty" binding:"required"`
 
 	OldPassword string `json:"oldPassword,omitempty" binding:"required"`
+
+	// Log out all other sessions of the user
+	LogoutOtherSessions bool `json:"logoutOtherSessions,omitempty"`
 }
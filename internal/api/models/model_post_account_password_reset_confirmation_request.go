@@ -0,0 +1,16 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+package api
+
+type PostAccountPasswordResetConfirmationRequest struct {
+	Token string `json:"token,omitempty" binding:"required"`
+
+	Password string `json:"password,omitempty" binding:"required"`
+}
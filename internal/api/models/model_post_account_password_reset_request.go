@@ -0,0 +1,14 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+package api
+
+type PostAccountPasswordResetRequest struct {
+	Email string `json:"email,omitempty" binding:"required"`
+}
@@ -14,4 +14,7 @@ type PostAccountRequest struct {
 	Email string `json:"email,omitempty" binding:"required"`
 
 	Password string `json:"password,omitempty" binding:"required"`
+
+	// Preferred language of emails, like \"en\" or \"pl-PL\"
+	Locale string `json:"locale,omitempty"`
 }
@@ -12,4 +12,7 @@ package api
 
 type PostAccountSessionResponse struct {
 	Token string `json:"token,omitempty"`
+
+	// Token is not a session token yet, it has to be exchanged for one with a TOTP code
+	TotpRequired bool `json:"totpRequired,omitempty"`
 }
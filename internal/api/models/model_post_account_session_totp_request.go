@@ -0,0 +1,19 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type PostAccountSessionTotpRequest struct {
+	// Token returned by login
+	Token string `json:"token,omitempty" binding:"required"`
+
+	// TOTP code or recovery code
+	Code string `json:"code,omitempty" binding:"required"`
+}
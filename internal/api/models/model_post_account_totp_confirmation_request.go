@@ -0,0 +1,19 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type PostAccountTotpConfirmationRequest struct {
+	// TOTP code generated from the secret
+	Code string `json:"code,omitempty" binding:"required"`
+
+	// Log out all other sessions of the user
+	LogoutOtherSessions bool `json:"logoutOtherSessions,omitempty"`
+}
@@ -0,0 +1,16 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type PostAccountTotpConfirmationResponse struct {
+	// Single use codes that can be used instead of TOTP codes. Shown only once
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
@@ -0,0 +1,19 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type PostAccountTotpResponse struct {
+	// Base32 encoded TOTP secret
+	Secret string `json:"secret,omitempty"`
+
+	// otpauth URI of the secret, usually shown as a QR code
+	Uri string `json:"uri,omitempty"`
+}
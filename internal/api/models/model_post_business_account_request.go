@@ -26,4 +26,7 @@ type PostBusinessAccountRequest struct {
 	Regon string `json:"regon,omitempty" binding:"required"`
 
 	OwnerName string `json:"ownerName,omitempty" binding:"required"`
+
+	// Category slugs, see GET /categories
+	Categories []string `json:"categories,omitempty"`
 }
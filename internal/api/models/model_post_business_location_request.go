@@ -0,0 +1,24 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package api
+
+type PostBusinessLocationRequest struct {
+	Name string `json:"name"`
+
+	Address string `json:"address"`
+
+	GpsCoordinates string `json:"gpsCoordinates"`
+
+	// Own opening hours of the location. If both are empty, opening hours of the business apply
+	OpeningHours []OpeningHoursApiModel `json:"openingHours,omitempty"`
+
+	OpeningHoursExceptions []OpeningHoursExceptionApiModel `json:"openingHoursExceptions,omitempty"`
+}
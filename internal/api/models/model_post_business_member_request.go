@@ -0,0 +1,16 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+package api
+
+type PostBusinessMemberRequest struct {
+	Email string `json:"email,omitempty" binding:"required"`
+
+	Role BusinessMemberRoleEnum `json:"role,omitempty" binding:"required"`
+}
@@ -14,4 +14,7 @@ type PostBusinessTransactionRequest struct {
 	AddedPoints int32 `json:"addedPoints,omitempty"`
 
 	ItemActions []ItemActionApiModel `json:"itemActions,omitempty"`
+
+	// Location of the business where the transaction took place, optional
+	LocationId string `json:"locationId,omitempty"`
 }
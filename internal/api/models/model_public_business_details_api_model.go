@@ -28,4 +28,21 @@ type PublicBusinessDetailsApiModel struct {
 	MenuImageIds []string `json:"menuImageIds,omitempty"`
 
 	ItemDefinitions []ItemDefinitionApiModel `json:"itemDefinitions,omitempty"`
+
+	PointsExpiryDays int32 `json:"pointsExpiryDays"`
+
+	// Category slugs
+	Categories []string `json:"categories,omitempty"`
+
+	// IANA time zone of opening hours, ex. Europe/Warsaw
+	TimeZone string `json:"timeZone,omitempty"`
+
+	OpeningHours []OpeningHoursApiModel `json:"openingHours,omitempty"`
+
+	OpeningHoursExceptions []OpeningHoursExceptionApiModel `json:"openingHoursExceptions,omitempty"`
+
+	Locations []BusinessLocationApiModel `json:"locations,omitempty"`
+
+	// Any location of the business is open at the moment, not set if the business has no opening hours
+	OpenNow *bool `json:"openNow,omitempty"`
 }
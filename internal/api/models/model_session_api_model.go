@@ -0,0 +1,29 @@
+/*
+ * StampWallet API Server
+ *
+ * StampWallet API Server REST Specification
+ *
+ * API version: 0.1.0
+ * Contact: fbstachura@gmail.com
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+package api
+
+import (
+	"time"
+)
+
+type SessionApiModel struct {
+	SessionId string `json:"sessionId,omitempty"`
+
+	Created time.Time `json:"created,omitempty"`
+
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+
+	UserAgent string `json:"userAgent,omitempty"`
+
+	IpAddress string `json:"ipAddress,omitempty"`
+
+	// True if this is the session used to make the request
+	Current bool `json:"current"`
+}
@@ -22,4 +22,14 @@ type ShortBusinessDetailsApiModel struct {
 	BannerImageId string `json:"bannerImageId,omitempty"`
 
 	IconImageId string `json:"iconImageId,omitempty"`
+
+	// Category slugs
+	Categories []string `json:"categories,omitempty"`
+
+	// Distance from the searched location to the nearest location of the business in meters,
+	// only present if the location was given
+	Distance *int32 `json:"distance,omitempty"`
+
+	// Location of the business nearest to the searched location, only present if the location was given
+	NearestLocation *BusinessLocationApiModel `json:"nearestLocation,omitempty"`
 }
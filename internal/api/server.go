@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"log"
 
 	. "github.com/StampWallet/backend/internal/config"
@@ -34,6 +35,11 @@ func CreateAPIServer(
 		config:                      config,
 	}
 
+	// Client IP addresses are used to throttle logins, X-Forwarded-For is only accepted from known proxies
+	if err := server.router.SetTrustedProxies(config.TrustedProxies); err != nil {
+		panic(fmt.Errorf("invalid TrustedProxies: %w", err))
+	}
+
 	server.router.Use(func(c *gin.Context) {
 		c.Next()
 		var uid uint
@@ -1,6 +1,7 @@
 package apiUtils
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -67,6 +68,113 @@ func ConvertDbItemAction(arg database.ActionTypeEnum) api.ItemActionTypeEnum {
 	}
 }
 
+func ConvertDbPointsLedgerReason(arg database.PointsLedgerReasonEnum) api.PointsLedgerReasonEnum {
+	if arg == database.PointsLedgerReasonOpeningBalance {
+		return api.OPENING_BALANCE
+	} else if arg == database.PointsLedgerReasonTransaction {
+		return api.TRANSACTION
+	} else if arg == database.PointsLedgerReasonItemBought {
+		return api.ITEM_BOUGHT
+	} else if arg == database.PointsLedgerReasonItemReturned {
+		return api.ITEM_RETURNED
+	} else if arg == database.PointsLedgerReasonItemRecalled {
+		return api.ITEM_RECALLED
+	} else if arg == database.PointsLedgerReasonItemWithdrawn {
+		return api.ITEM_WITHDRAWN
+	} else if arg == database.PointsLedgerReasonPointsExpired {
+		return api.POINTS_EXPIRED
+	} else {
+		panic(fmt.Errorf("unkown database.PointsLedgerReasonEnum enum valule - cannot map to api.PointsLedgerReasonEnum %+v", arg))
+	}
+}
+
+func ConvertApiBusinessMemberRole(arg api.BusinessMemberRoleEnum) database.BusinessMemberRoleEnum {
+	if arg == api.OWNER {
+		return database.BusinessMemberRoleOwner
+	} else if arg == api.MANAGER {
+		return database.BusinessMemberRoleManager
+	} else if arg == api.CASHIER {
+		return database.BusinessMemberRoleCashier
+	} else {
+		panic(fmt.Errorf("unkown api.BusinessMemberRoleEnum enum valule - cannot map to database.BusinessMemberRoleEnum %+v", arg))
+	}
+}
+
+func ConvertDbBusinessMemberRole(arg database.BusinessMemberRoleEnum) api.BusinessMemberRoleEnum {
+	if arg == database.BusinessMemberRoleOwner {
+		return api.OWNER
+	} else if arg == database.BusinessMemberRoleManager {
+		return api.MANAGER
+	} else if arg == database.BusinessMemberRoleCashier {
+		return api.CASHIER
+	} else {
+		panic(fmt.Errorf("unkown database.BusinessMemberRoleEnum enum valule - cannot map to api.BusinessMemberRoleEnum %+v", arg))
+	}
+}
+
+// Converts BusinessMember from database model to api model
+// User relation should be loaded
+func ConvertBusinessMemberToApiModel(member *database.BusinessMember) api.BusinessMemberApiModel {
+	model := api.BusinessMemberApiModel{
+		PublicId: member.PublicId,
+		Role:     ConvertDbBusinessMemberRole(member.Role),
+		Joined:   member.CreatedAt,
+	}
+	if member.User != nil {
+		model.Email = member.User.Email
+	}
+	return model
+}
+
+// Converts BusinessInvitation from database model to api model
+// Business relation should be loaded
+func ConvertBusinessInvitationToApiModel(invitation *database.BusinessInvitation) api.BusinessInvitationApiModel {
+	model := api.BusinessInvitationApiModel{
+		InvitationId: invitation.PublicId,
+		Role:         ConvertDbBusinessMemberRole(invitation.Role),
+		Created:      invitation.CreatedAt,
+	}
+	if invitation.Business != nil {
+		model.BusinessDetails = ConvertBusinessToShortApiModel(invitation.Business)
+	}
+	return model
+}
+
+// Converts session token from database model to api model
+func ConvertSessionToApiModel(session *database.Token, current bool) api.SessionApiModel {
+	model := api.SessionApiModel{
+		SessionId: session.TokenId,
+		Created:   session.CreatedAt,
+		UserAgent: session.UserAgent,
+		IpAddress: session.IpAddress,
+		Current:   current,
+	}
+	if session.LastUsed.Valid {
+		model.LastUsed = &session.LastUsed.Time
+	}
+	return model
+}
+
+// Converts PointsLedgerEntry from database model to api model
+// Transaction, OwnedItem and OwnedItem.ItemDefinition relations should be loaded
+func ConvertPointsLedgerEntryToApiModel(entry *database.PointsLedgerEntry) api.PointsLedgerEntryApiModel {
+	model := api.PointsLedgerEntryApiModel{
+		Delta:   int32(entry.Delta),
+		Reason:  ConvertDbPointsLedgerReason(entry.Reason),
+		Created: entry.CreatedAt,
+	}
+	if entry.Transaction != nil {
+		model.TransactionId = entry.Transaction.PublicId
+	}
+	if entry.OwnedItem != nil {
+		model.ItemId = entry.OwnedItem.PublicId
+		if entry.OwnedItem.ItemDefinition != nil {
+			model.ItemDefinitionId = entry.OwnedItem.ItemDefinition.PublicId
+		}
+	}
+	return model
+}
+
 // Converts ItemDefinition from database model to api model
 func ConvertItemDefinitionToApiModel(itd *database.ItemDefinition) api.ItemDefinitionApiModel {
 	var sd *time.Time
@@ -95,6 +203,23 @@ func ConvertItemDefinitionToApiModel(itd *database.ItemDefinition) api.ItemDefin
 	}
 }
 
+// Converts database.Category to api.CategoryApiModel
+func ConvertCategoryToApiModel(category *database.Category) api.CategoryApiModel {
+	return api.CategoryApiModel{
+		Slug: category.Slug,
+		Name: category.Name,
+	}
+}
+
+// Converts categories to their slugs
+func ConvertCategoriesToSlugs(categories []database.Category) []string {
+	var slugs []string
+	for _, v := range categories {
+		slugs = append(slugs, v.Slug)
+	}
+	return slugs
+}
+
 // Converts database.Business to api.ShortBusinessDetailsApiModel
 // Most data is lost in conversion - api.ShortBusinessDetailsApiModel does not contain all
 // data from model
@@ -106,6 +231,7 @@ func ConvertBusinessToShortApiModel(business *database.Business) api.ShortBusine
 		GpsCoordinates: business.GPSCoordinates.ToString(),
 		BannerImageId:  business.BannerImageId,
 		IconImageId:    business.IconImageId,
+		Categories:     ConvertCategoriesToSlugs(business.Categories),
 	}
 }
 
@@ -126,15 +252,169 @@ func ConvertBusinessToApiModel(business *database.Business,
 		itemDefinitionsApi = append(itemDefinitionsApi, ConvertItemDefinitionToApiModel(&v))
 	}
 
+	// Opening hours have to be preloaded
+	var openNow *bool
+	if business.HasOpeningHours() {
+		open := business.IsOpenAt(time.Now())
+		openNow = &open
+	}
+
 	return api.PublicBusinessDetailsApiModel{
-		PublicId:        business.PublicId,
-		Name:            business.Name,
-		GpsCoordinates:  business.GPSCoordinates.ToString(),
-		BannerImageId:   business.BannerImageId,
-		Description:     business.Description,
-		IconImageId:     business.IconImageId,
-		MenuImageIds:    menuImageIds,
-		Address:         business.Address,
-		ItemDefinitions: itemDefinitionsApi,
+		PublicId:         business.PublicId,
+		Name:             business.Name,
+		GpsCoordinates:   business.GPSCoordinates.ToString(),
+		BannerImageId:    business.BannerImageId,
+		Description:      business.Description,
+		IconImageId:      business.IconImageId,
+		MenuImageIds:     menuImageIds,
+		Address:          business.Address,
+		ItemDefinitions:  itemDefinitionsApi,
+		PointsExpiryDays: int32(business.PointsExpiryDays),
+		Categories:       ConvertCategoriesToSlugs(business.Categories),
+		TimeZone:         business.TimeZone,
+		OpeningHours:     ConvertOpeningHoursToApiModel(business.OpeningHours),
+		OpeningHoursExceptions: ConvertOpeningHoursExceptionsToApiModel(
+			business.OpeningHoursExceptions),
+		Locations: ConvertBusinessLocationsToApiModel(business, business.Locations),
+		OpenNow:   openNow,
+	}
+}
+
+// Converts database.BusinessLocation to api.BusinessLocationApiModel, without opening hours
+func ConvertBusinessLocationToShortApiModel(location *database.BusinessLocation) api.BusinessLocationApiModel {
+	return api.BusinessLocationApiModel{
+		PublicId:       location.PublicId,
+		Name:           location.Name,
+		Address:        location.Address,
+		GpsCoordinates: location.GPSCoordinates.ToString(),
+	}
+}
+
+// Converts location of business to api.BusinessLocationApiModel.
+// Opening hours of the business and of the location have to be preloaded
+func ConvertBusinessLocationToApiModel(business *database.Business,
+	location *database.BusinessLocation) api.BusinessLocationApiModel {
+
+	result := ConvertBusinessLocationToShortApiModel(location)
+	result.OpeningHours = ConvertOpeningHoursToApiModel(location.OpeningHours)
+	result.OpeningHoursExceptions = ConvertOpeningHoursExceptionsToApiModel(location.OpeningHoursExceptions)
+	if location.HasOwnOpeningHours() || len(business.OpeningHours) > 0 || len(business.OpeningHoursExceptions) > 0 {
+		open := business.IsLocationOpenAt(location, time.Now())
+		result.OpenNow = &open
+	}
+	return result
+}
+
+// Converts locations of business to []api.BusinessLocationApiModel, see ConvertBusinessLocationToApiModel
+func ConvertBusinessLocationsToApiModel(business *database.Business,
+	locations []database.BusinessLocation) []api.BusinessLocationApiModel {
+
+	var result []api.BusinessLocationApiModel
+	for i := range locations {
+		result = append(result, ConvertBusinessLocationToApiModel(business, &locations[i]))
+	}
+	return result
+}
+
+var ErrInvalidTimeOfDay = errors.New("Invalid time of day")
+var ErrInvalidDate = errors.New("Invalid date")
+
+// Formats minutes since midnight as HH:MM
+func FormatTimeOfDay(minutes uint) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// Parses HH:MM into minutes since midnight. 24:00 is the end of the day
+func ParseTimeOfDay(value string) (uint, error) {
+	if len(value) != 5 || value[2] != ':' {
+		return 0, ErrInvalidTimeOfDay
+	}
+	var digits [4]uint
+	for i, c := range value[:2] + value[3:] {
+		if c < '0' || c > '9' {
+			return 0, ErrInvalidTimeOfDay
+		}
+		digits[i] = uint(c - '0')
+	}
+	hours := digits[0]*10 + digits[1]
+	minutes := digits[2]*10 + digits[3]
+	if minutes >= 60 || hours*60+minutes > database.MinutesInDay {
+		return 0, ErrInvalidTimeOfDay
+	}
+	return hours*60 + minutes, nil
+}
+
+// Converts []database.OpeningHours to []api.OpeningHoursApiModel
+func ConvertOpeningHoursToApiModel(hours []database.OpeningHours) []api.OpeningHoursApiModel {
+	var result []api.OpeningHoursApiModel
+	for _, v := range hours {
+		result = append(result, api.OpeningHoursApiModel{
+			Weekday: int32(v.Weekday),
+			Opens:   FormatTimeOfDay(v.OpensAt),
+			Closes:  FormatTimeOfDay(v.ClosesAt),
+		})
+	}
+	return result
+}
+
+// Converts []database.OpeningHoursException to []api.OpeningHoursExceptionApiModel
+func ConvertOpeningHoursExceptionsToApiModel(exceptions []database.OpeningHoursException) []api.OpeningHoursExceptionApiModel {
+	var result []api.OpeningHoursExceptionApiModel
+	for _, v := range exceptions {
+		exception := api.OpeningHoursExceptionApiModel{
+			Date:   v.Date.Format(time.DateOnly),
+			Closed: v.Closed,
+		}
+		if !v.Closed {
+			exception.Opens = FormatTimeOfDay(v.OpensAt)
+			exception.Closes = FormatTimeOfDay(v.ClosesAt)
+		}
+		result = append(result, exception)
+	}
+	return result
+}
+
+// Converts []api.OpeningHoursApiModel to []database.OpeningHours.
+// Returns ErrInvalidTimeOfDay if times are not in HH:MM format
+func ConvertApiOpeningHours(hours []api.OpeningHoursApiModel) ([]database.OpeningHours, error) {
+	result := []database.OpeningHours{}
+	for _, v := range hours {
+		opensAt, err := ParseTimeOfDay(v.Opens)
+		if err != nil {
+			return nil, err
+		}
+		closesAt, err := ParseTimeOfDay(v.Closes)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, database.OpeningHours{
+			Weekday:  time.Weekday(v.Weekday),
+			OpensAt:  opensAt,
+			ClosesAt: closesAt,
+		})
+	}
+	return result, nil
+}
+
+// Converts []api.OpeningHoursExceptionApiModel to []database.OpeningHoursException.
+// Returns ErrInvalidDate or ErrInvalidTimeOfDay if dates or times have invalid format
+func ConvertApiOpeningHoursExceptions(exceptions []api.OpeningHoursExceptionApiModel) ([]database.OpeningHoursException, error) {
+	result := []database.OpeningHoursException{}
+	for _, v := range exceptions {
+		date, err := time.Parse(time.DateOnly, v.Date)
+		if err != nil {
+			return nil, ErrInvalidDate
+		}
+		exception := database.OpeningHoursException{Date: date, Closed: v.Closed}
+		if !v.Closed {
+			if exception.OpensAt, err = ParseTimeOfDay(v.Opens); err != nil {
+				return nil, err
+			}
+			if exception.ClosesAt, err = ParseTimeOfDay(v.Closes); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, exception)
 	}
+	return result, nil
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env"
@@ -16,22 +17,69 @@ import (
 
 // SMTP client config
 type SMTPConfig struct {
-	ServerHostname string // SMTP server hostname
-	ServerPort     uint16 // SMTP server port
-	Username       string // Authorization username
-	Password       string // Authorization password
-	SenderEmail    string // Email to use in the "From" field
+	ServerHostname string        // SMTP server hostname
+	ServerPort     uint16        // SMTP server port
+	TLSMode        string        // "implicit" (usually port 465), "starttls" (usually port 587) or "none"
+	TLSSkipVerify  bool          // Don't verify the server certificate, for test and internal relays only
+	AuthMechanism  string        // "plain", "login", "cram-md5" or "none"
+	Username       string        // Authorization username
+	Password       string        // Authorization password
+	SenderEmail    string        // Email to use in the "From" field
+	Timeout        time.Duration // Timeout of connecting and of every SMTP command
+	KeepAlive      time.Duration // How long an idle connection is kept open for the next email. 0 connects for every email
+}
+
+// S3-compatible object storage config
+type S3Config struct {
+	Endpoint        string        // Endpoint URL, ex. https://s3.eu-central-1.amazonaws.com or http://localhost:9000
+	Region          string        // Region of the bucket
+	Bucket          string        // Bucket name
+	AccessKeyId     string        // Access key ID
+	SecretAccessKey string        // Secret access key
+	PathStyle       bool          // Use endpoint/bucket/key URLs instead of bucket.endpoint/key, required by MinIO
+	PresignTTL      time.Duration // How long presigned download URLs are valid. 0 streams files through the server
+}
+
+// Limits of uploaded files. 0 means no limit.
+type QuotaConfig struct {
+	UserMaxFiles     uint  // Max number of files created by a single user, uploaded or not
+	UserMaxBytes     int64 // Max bytes of files uploaded by a single user
+	BusinessMaxFiles uint  // Max number of files of a single business, uploaded or not
+	BusinessMaxBytes int64 // Max bytes of files uploaded to a single business
 }
 
 type Config struct {
-	DatabaseUrl                   string     // Database URL
-	SmtpConfig                    SMTPConfig // SMTP Client config
-	ListenIP                      string     // Hostname:port this server will listen on
-	StoragePath                   string     // File storage path
-	BackendURL                    string     // Public DNS domain this server is reachable from
-	VerificationEmailSubject      string     // String with verification email subject
-	VerificationEmailBodyTemplate string     // Template that receives the email verification token
-	StaticPath                    string     // Static file path
+	DatabaseUrl               string        // Database URL
+	SmtpConfig                SMTPConfig    // SMTP Client config
+	EmailBackend              string        // How emails are sent, "smtp" or "file"
+	EmailFilePath             string        // File the file email backend appends to, "-" for stdout
+	EmailOutboxInterval       time.Duration // How often queued emails are delivered
+	EmailMaxAttempts          uint          // Delivery attempts after which a queued email is dead-lettered
+	EmailRetryBackoffBase     time.Duration // Wait time after the first failed delivery, doubled after each next one
+	EmailRetryBackoffMax      time.Duration // Max wait time between delivery attempts
+	EmailTemplatePath         string        // Directory with email templates of every locale, empty for built-in templates
+	DefaultLocale             string        // Language of emails sent to users without a preferred language
+	ListenIP                  string        // Hostname:port this server will listen on
+	TrustedProxies            []string      // IPs or CIDRs of reverse proxies allowed to set X-Forwarded-For
+	StorageBackend            string        // Where uploaded files are stored, "filesystem" or "s3"
+	StoragePath               string        // File storage path of the filesystem storage backend
+	S3Config                  S3Config      // Config of the s3 storage backend
+	FileQuota                 QuotaConfig   // Limits of uploaded files
+	BackendURL                string        // Public DNS domain this server is reachable from
+	StaticPath                string        // Static file path
+	TransactionTTL            time.Duration // How long a started transaction can wait for finalization
+	TransactionReaperInterval time.Duration // How often expired transactions are looked up
+	PointsExpiryInterval      time.Duration // How often expired points are looked up
+	PointsExpiryWarningPeriod time.Duration // Points that expire within this period are shown as expiring soon
+	FileGCInterval            time.Duration // How often unreferenced files are removed
+	FileGCGracePeriod         time.Duration // Files younger than this are never removed by file GC
+	LoginAttemptStore         string        // Where failed login attempts are stored, "postgres" or "memory"
+	LoginFreeAttempts         uint          // Failed login attempts of an email or IP address that are not throttled
+	LoginBackoffBase          time.Duration // Wait time after the first throttled login attempt, doubled after each next one
+	LoginBackoffMax           time.Duration // Max wait time between throttled login attempts
+	LoginLockoutAttempts      uint          // Failed login attempts after which the email or IP address is locked out
+	LoginLockoutDuration      time.Duration // How long the lockout lasts and failed login attempts are remembered
+	TotpIssuer                string        // Issuer shown in authenticator apps next to TOTP codes
 }
 
 // Returns config with default values
@@ -41,20 +89,56 @@ func GetDefaultConfig() Config {
 		SmtpConfig: SMTPConfig{
 			ServerHostname: "localhost",
 			ServerPort:     465,
+			TLSMode:        "implicit",
+			AuthMechanism:  "login",
 			Username:       "test",
 			Password:       "test",
 			SenderEmail:    "test@localhost",
+			Timeout:        15 * time.Second,
+			KeepAlive:      30 * time.Second,
+		},
+		EmailBackend:              "smtp",
+		EmailFilePath:             "-",
+		EmailOutboxInterval:       10 * time.Second,
+		EmailMaxAttempts:          8,
+		EmailRetryBackoffBase:     time.Minute,
+		EmailRetryBackoffMax:      6 * time.Hour,
+		DefaultLocale:             "en",
+		ListenIP:                  "localhost:8080",
+		StorageBackend:            "filesystem",
+		StoragePath:               "/tmp/",
+		StaticPath:                "static",
+		BackendURL:                "http://localhost:8080/",
+		TransactionTTL:            15 * time.Minute,
+		TransactionReaperInterval: time.Minute,
+		PointsExpiryInterval:      time.Hour,
+		PointsExpiryWarningPeriod: 30 * 24 * time.Hour,
+		FileGCInterval:            24 * time.Hour,
+		FileGCGracePeriod:         24 * time.Hour,
+		LoginAttemptStore:         "postgres",
+		LoginFreeAttempts:         3,
+		LoginBackoffBase:          time.Second,
+		LoginBackoffMax:           time.Minute,
+		LoginLockoutAttempts:      10,
+		LoginLockoutDuration:      15 * time.Minute,
+		TotpIssuer:                "StampWallet",
+		S3Config: S3Config{
+			Endpoint:   "http://localhost:9000",
+			Region:     "us-east-1",
+			Bucket:     "stampwallet",
+			PathStyle:  true,
+			PresignTTL: 15 * time.Minute,
+		},
+		FileQuota: QuotaConfig{
+			UserMaxFiles:     500,
+			UserMaxBytes:     200_000_000,
+			BusinessMaxFiles: 300,
+			BusinessMaxBytes: 100_000_000,
 		},
-		ListenIP:                      "localhost:8080",
-		StoragePath:                   "/tmp/",
-		StaticPath:                    "static",
-		BackendURL:                    "http://localhost:8080/",
-		VerificationEmailSubject:      "email subject",
-		VerificationEmailBodyTemplate: "http://localhost:8080/static/emailVerification.html?token={{ .Token}}",
 	}
 }
 
-// Loads config from file under path
+// Loads config from file under path and STAMPWALLET_ env variables, on top of GetDefaultConfig
 func LoadConfig(path string) (Config, error) {
 	k := koanf.New(".")
 	fileSuccess := true
@@ -82,8 +166,11 @@ func LoadConfig(path string) (Config, error) {
 		return Config{}, errors.New("failed to load config both from env and file")
 	}
 
-	var config Config
-	k.UnmarshalWithConf("", &config, koanf.UnmarshalConf{})
+	// Keys missing in both the file and env keep their default values
+	config := GetDefaultConfig()
+	if err := k.UnmarshalWithConf("", &config, koanf.UnmarshalConf{}); err != nil {
+		return Config{}, err
+	}
 	return config, nil
 }
 
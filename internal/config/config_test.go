@@ -0,0 +1,34 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigFileDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("ListenIP: 0.0.0.0:9000\n"), 0644)
+	require.Nilf(t, err, "os.WriteFile returned an error")
+
+	config, err := LoadConfig(path)
+	require.Nilf(t, err, "LoadConfig returned an error")
+	expected := GetDefaultConfig()
+	expected.ListenIP = "0.0.0.0:9000"
+	require.Equalf(t, expected, config, "keys missing in the file should have default values")
+}
+
+func TestLoadConfigEnvDefaults(t *testing.T) {
+	t.Setenv("STAMPWALLET_EMAILOUTBOXINTERVAL", "5s")
+	t.Setenv("STAMPWALLET_SMTPCONFIG_SERVERHOSTNAME", "smtp.example.com")
+
+	config, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Nilf(t, err, "LoadConfig returned an error")
+	expected := GetDefaultConfig()
+	expected.EmailOutboxInterval = 5 * time.Second
+	expected.SmtpConfig.ServerHostname = "smtp.example.com"
+	require.Equalf(t, expected, config, "keys missing in env should have default values")
+}
@@ -45,11 +45,28 @@ func checkEq[T authModel](el T, expectedId uint, id uint, err error) (T, error)
 	}
 }
 
+// Orders tx by id and limits it to page, if page is not nil
+func paginate(tx GormDB, page *Page) (GormDB, error) {
+	if page == nil {
+		return tx.Order("id"), nil
+	}
+	return PaginateById(tx, "id", page)
+}
+
+// Returns cursor of the page after entities in dest fetched with paginate, see NextIdCursor
+func nextCursor(dest interface{}, page *Page) (string, error) {
+	if page == nil {
+		return "", nil
+	}
+	return NextIdCursor(dest, page)
+}
+
 // BusinessAuthorizedAccessor
 
 type BusinessAuthorizedAccessor interface {
 	Get(business *Business, cond BusinessOwnedEntity) (BusinessOwnedEntity, error)
-	GetAll(business *Business, cond BusinessOwnedEntity) ([]BusinessOwnedEntity, error)
+	// Returns entities on page, ordered by id, and cursor of the next page. nil page returns all entities
+	GetAll(business *Business, cond BusinessOwnedEntity, page *Page) ([]BusinessOwnedEntity, string, error)
 }
 
 type BusinessAuthorizedAccessorImpl struct {
@@ -73,8 +90,8 @@ func (accessor *BusinessAuthorizedAccessorImpl) Get(business *Business, conds Bu
 	return checkEq(result, business.ID, id, err)
 }
 
-// NOTE shouldnt be used for huge amounts of data
-func (accessor *BusinessAuthorizedAccessorImpl) GetAll(business *Business, conds BusinessOwnedEntity) ([]BusinessOwnedEntity, error) {
+// NOTE shouldnt be used for huge amounts of data without page
+func (accessor *BusinessAuthorizedAccessorImpl) GetAll(business *Business, conds BusinessOwnedEntity, page *Page) ([]BusinessOwnedEntity, string, error) {
 	// Find BusinessId in conds object and set it to id of business
 	// All objects "owned" by a business are required to store the business id in a field named "BusinessId".
 	condsValue := reflect.ValueOf(conds)
@@ -89,12 +106,20 @@ func (accessor *BusinessAuthorizedAccessorImpl) GetAll(business *Business, conds
 	dbResult := reflect.New(reflect.SliceOf(reflect.TypeOf(conds).Elem()))
 
 	// Execute query, handle errors
-	tx := accessor.database.Find(dbResult.Interface(), condsValue.Interface())
+	tx, err := paginate(accessor.database, page)
+	if err != nil {
+		return nil, "", err
+	}
+	tx = tx.Find(dbResult.Interface(), condsValue.Interface())
 	if err := tx.GetError(); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return []BusinessOwnedEntity{}, nil
+			return []BusinessOwnedEntity{}, "", nil
 		}
-		return nil, err
+		return nil, "", err
+	}
+	nextCursor, err := nextCursor(dbResult.Interface(), page)
+	if err != nil {
+		return nil, "", err
 	}
 
 	// Convert results (reflect.Value) to BusinessOwnedEntity
@@ -103,14 +128,15 @@ func (accessor *BusinessAuthorizedAccessorImpl) GetAll(business *Business, conds
 		result = append(result, dbResult.Elem().Index(i).Addr().Interface().(BusinessOwnedEntity))
 	}
 
-	return result, nil
+	return result, nextCursor, nil
 }
 
 // UserAuthorizedAccessor
 
 type UserAuthorizedAccessor interface {
 	Get(user *User, cond UserOwnedEntity) (UserOwnedEntity, error)
-	GetAll(user *User, cond UserOwnedEntity, preloads []string) ([]UserOwnedEntity, error)
+	// Returns entities on page, ordered by id, and cursor of the next page. nil page returns all entities
+	GetAll(user *User, cond UserOwnedEntity, preloads []string, page *Page) ([]UserOwnedEntity, string, error)
 }
 
 type UserAuthorizedAccessorImpl struct {
@@ -148,7 +174,7 @@ func (accessor *UserAuthorizedAccessorImpl) Get(user *User, conds UserOwnedEntit
 // This is a problem with both managers and accessors. Without preloads, there is no guarantee that relation properties will be loaded in the object.
 // Perhaps forbidding use of relation properties in the whole codebase, except for code that directly interacts with the database
 // would be a good idea.
-func (accessor *UserAuthorizedAccessorImpl) GetAll(user *User, conds UserOwnedEntity, preloads []string) ([]UserOwnedEntity, error) {
+func (accessor *UserAuthorizedAccessorImpl) GetAll(user *User, conds UserOwnedEntity, preloads []string, page *Page) ([]UserOwnedEntity, string, error) {
 	// Find OwnerId in conds object and set it to id of user
 	// All objects "owned" by a user are required to store the user id in a field named "OwnerId".
 	condsValue := reflect.ValueOf(conds)
@@ -163,7 +189,10 @@ func (accessor *UserAuthorizedAccessorImpl) GetAll(user *User, conds UserOwnedEn
 	dbResult := reflect.New(reflect.SliceOf(reflect.TypeOf(conds).Elem()))
 
 	// Create db object with configured preloads
-	tx := accessor.database
+	tx, err := paginate(accessor.database, page)
+	if err != nil {
+		return nil, "", err
+	}
 	for _, v := range preloads {
 		tx = tx.Preload(v)
 	}
@@ -172,9 +201,13 @@ func (accessor *UserAuthorizedAccessorImpl) GetAll(user *User, conds UserOwnedEn
 	tx = tx.Find(dbResult.Interface(), condsValue.Interface())
 	if err := tx.GetError(); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return []UserOwnedEntity{}, nil
+			return []UserOwnedEntity{}, "", nil
 		}
-		return nil, err
+		return nil, "", err
+	}
+	nextCursor, err := nextCursor(dbResult.Interface(), page)
+	if err != nil {
+		return nil, "", err
 	}
 
 	// Convert results (reflect.Value) to UserOwnedEntity
@@ -183,7 +216,7 @@ func (accessor *UserAuthorizedAccessorImpl) GetAll(user *User, conds UserOwnedEn
 		result = append(result, dbResult.Elem().Index(i).Addr().Interface().(UserOwnedEntity))
 	}
 
-	return result, nil
+	return result, nextCursor, nil
 }
 
 // AuthorizedTransactionAccessor
@@ -215,10 +248,10 @@ func (accessor *AuthorizedTransactionAccessorImpl) GetForBusiness(business *Busi
 		Preload("TransactionDetails").
 		Preload("TransactionDetails.OwnedItem").
 		Preload("TransactionDetails.OwnedItem.ItemDefinition").
-		First(&transaction, Transaction{
-			Code:        transactionCode,
-			VirtualCard: &VirtualCard{Business: business},
-		})
+		// Codes are only unique among active transactions of a business - prefer the newest one
+		Where("business_id = ? AND code = ?", business.ID, transactionCode).
+		Order("created_at desc").
+		First(&transaction)
 	if err := checkErr(tx); err != nil {
 		return nil, err
 	}
@@ -229,10 +262,11 @@ func (accessor *AuthorizedTransactionAccessorImpl) GetForBusiness(business *Busi
 
 func (accessor *AuthorizedTransactionAccessorImpl) GetForUser(user *User, transactionCode string) (*Transaction, error) {
 	var transaction Transaction
-	tx := accessor.database.Preload("TransactionDetails").First(&transaction, Transaction{
-		Code:        transactionCode,
-		VirtualCard: &VirtualCard{User: user},
-	})
+	tx := accessor.database.Preload("TransactionDetails").
+		Joins("JOIN virtual_cards ON virtual_cards.id = transactions.virtual_card_id").
+		Where("virtual_cards.owner_id = ? AND transactions.code = ?", user.ID, transactionCode).
+		Order("transactions.created_at desc").
+		First(&transaction)
 	if err := checkErr(tx); err != nil {
 		return nil, err
 	}
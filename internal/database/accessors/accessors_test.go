@@ -82,7 +82,8 @@ func TestBusinessAuthorizedAccessorValidAccessAll(t *testing.T) {
 	_ = GetTestItemDefinition(accessor.database, business2,
 		*GetTestFileMetadata(accessor.database, user2))
 
-	result, err := accessor.GetAll(business, &ItemDefinition{})
+	result, nextCursor, err := accessor.GetAll(business, &ItemDefinition{}, nil)
+	require.Emptyf(t, nextCursor, "accessor returned cursor without page")
 	require.Nilf(t, err, "accessor returned non nil error")
 	require.NotNilf(t, result, "accessor returned nil")
 	var obtainedItemDefinitions []ItemDefinition
@@ -142,7 +143,8 @@ func TestUserAuthorizedAccessorValidAccessAll(t *testing.T) {
 	user2 := GetTestUser(accessor.database)
 	_ = GetTestLocalCard(accessor.database, user2)
 
-	result, err := accessor.GetAll(user, &LocalCard{}, []string{})
+	result, nextCursor, err := accessor.GetAll(user, &LocalCard{}, []string{}, nil)
+	require.Emptyf(t, nextCursor, "accessor returned cursor without page")
 	require.Nilf(t, err, "accessor returned non nil error")
 	require.NotNilf(t, result, "accessor returned nil")
 	var obtainedLocalCards []LocalCard
@@ -154,6 +156,28 @@ func TestUserAuthorizedAccessorValidAccessAll(t *testing.T) {
 	require.Equal(t, localCard2.PublicId, obtainedLocalCards[1].PublicId, "accessor returned a different second card")
 }
 
+func TestUserAuthorizedAccessorGetAllPaginated(t *testing.T) {
+	_, accessor, user, localCard := setupUserAccessorTest(t)
+	localCard2 := GetTestLocalCard(accessor.database, user)
+	localCard3 := GetTestLocalCard(accessor.database, user)
+
+	result, nextCursor, err := accessor.GetAll(user, &LocalCard{}, []string{}, &Page{Limit: 2})
+	require.Nilf(t, err, "accessor returned non nil error")
+	require.Lenf(t, result, 2, "accessor returned unexpected number of cards")
+	require.Equal(t, localCard.PublicId, result[0].(*LocalCard).PublicId, "accessor returned a different first card")
+	require.Equal(t, localCard2.PublicId, result[1].(*LocalCard).PublicId, "accessor returned a different second card")
+	require.NotEmptyf(t, nextCursor, "accessor did not return cursor of the next page")
+
+	result, nextCursor, err = accessor.GetAll(user, &LocalCard{}, []string{}, &Page{Cursor: nextCursor, Limit: 2})
+	require.Nilf(t, err, "accessor returned non nil error")
+	require.Lenf(t, result, 1, "accessor returned unexpected number of cards")
+	require.Equal(t, localCard3.PublicId, result[0].(*LocalCard).PublicId, "accessor returned a different card")
+	require.Emptyf(t, nextCursor, "accessor returned cursor on the last page")
+
+	_, _, err = accessor.GetAll(user, &LocalCard{}, []string{}, &Page{Cursor: "invalid", Limit: 2})
+	require.Equalf(t, ErrInvalidCursor, err, "accessor accepted invalid cursor")
+}
+
 // TransactionAuthorizedAccessor
 
 func setupAuthorizedTransactionAccessorTest(t *testing.T) (*gomock.Controller, *AuthorizedTransactionAccessorImpl, *User, *User, *Business, *VirtualCard, *Transaction) {
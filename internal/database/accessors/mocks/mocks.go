@@ -51,18 +51,19 @@ func (mr *MockBusinessAuthorizedAccessorMockRecorder) Get(arg0, arg1 interface{}
 }
 
 // GetAll mocks base method.
-func (m *MockBusinessAuthorizedAccessor) GetAll(arg0 *database.Business, arg1 database0.BusinessOwnedEntity) ([]database0.BusinessOwnedEntity, error) {
+func (m *MockBusinessAuthorizedAccessor) GetAll(arg0 *database.Business, arg1 database0.BusinessOwnedEntity, arg2 *database.Page) ([]database0.BusinessOwnedEntity, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetAll", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]database0.BusinessOwnedEntity)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // GetAll indicates an expected call of GetAll.
-func (mr *MockBusinessAuthorizedAccessorMockRecorder) GetAll(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockBusinessAuthorizedAccessorMockRecorder) GetAll(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockBusinessAuthorizedAccessor)(nil).GetAll), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockBusinessAuthorizedAccessor)(nil).GetAll), arg0, arg1, arg2)
 }
 
 // MockUserAuthorizedAccessor is a mock of UserAuthorizedAccessor interface.
@@ -104,18 +105,19 @@ func (mr *MockUserAuthorizedAccessorMockRecorder) Get(arg0, arg1 interface{}) *g
 }
 
 // GetAll mocks base method.
-func (m *MockUserAuthorizedAccessor) GetAll(arg0 *database.User, arg1 database0.UserOwnedEntity, arg2 []string) ([]database0.UserOwnedEntity, error) {
+func (m *MockUserAuthorizedAccessor) GetAll(arg0 *database.User, arg1 database0.UserOwnedEntity, arg2 []string, arg3 *database.Page) ([]database0.UserOwnedEntity, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "GetAll", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].([]database0.UserOwnedEntity)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // GetAll indicates an expected call of GetAll.
-func (mr *MockUserAuthorizedAccessorMockRecorder) GetAll(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockUserAuthorizedAccessorMockRecorder) GetAll(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockUserAuthorizedAccessor)(nil).GetAll), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockUserAuthorizedAccessor)(nil).GetAll), arg0, arg1, arg2, arg3)
 }
 
 // MockAuthorizedTransactionAccessor is a mock of AuthorizedTransactionAccessor interface.
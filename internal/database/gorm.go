@@ -245,7 +245,7 @@ func (self *GormDBImpl) InstanceSet(key string, value interface{}) GormDB {
 }
 
 func (self *GormDBImpl) Joins(query string, args ...interface{}) (tx GormDB) {
-	return &GormDBImpl{self.Db.Joins(query, args)}
+	return &GormDBImpl{self.Db.Joins(query, args...)}
 }
 
 func (self *GormDBImpl) Last(dest interface{}, conds ...interface{}) (tx GormDB) {
@@ -0,0 +1,331 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	. "github.com/StampWallet/backend/internal/database"
+)
+
+// Migrations shipped with the binary. Files are named NNNN_name.up.sql and NNNN_name.down.sql,
+// versions are applied in ascending order.
+//
+//go:embed sql/*.sql
+var embeddedFiles embed.FS
+
+// Default directory of migration files, relative to the repository root
+const DefaultDir = "internal/database/migrations/sql"
+
+// Key of the postgres advisory lock held while migrating, so that replicas starting at the same
+// time don't apply the same migration twice. Arbitrary, but has to be the same for all instances.
+const migrationLockKey = 0x5354414d50
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+var ErrInvalidMigrationFile = errors.New("Invalid migration file")
+var ErrMissingMigrationFile = errors.New("Missing up or down migration file")
+var ErrDuplicateMigration = errors.New("Duplicate migration version")
+var ErrInvalidMigrationName = errors.New("Invalid migration name")
+
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migration as seen by Migrator.Status
+type MigrationStatus struct {
+	Version uint64
+	Name    string
+	// Nil if the migration was not applied yet
+	AppliedAt *time.Time
+	// True if the migration was applied, but is not known to this binary
+	Unknown bool
+}
+
+// Row of schema_migrations
+type appliedMigration struct {
+	Version   uint64
+	Name      string
+	AppliedAt time.Time
+}
+
+// Loads migrations from fsys. Every version needs both an up and a down file.
+// Returned migrations are sorted by version.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("fs.ReadDir returned an error: %w", err)
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigrationFile, entry.Name())
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigrationFile, entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("fs.ReadFile returned an error: %w", err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateMigration, version)
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("%w: %d_%s", ErrMissingMigrationFile, migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Loads migrations embedded in the binary
+func EmbeddedMigrations() ([]Migration, error) {
+	fsys, err := fs.Sub(embeddedFiles, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return LoadMigrations(fsys)
+}
+
+// Creates empty up and down files of a new migration in dir, versioned after the last migration
+// in dir. Returns paths of the created files.
+func CreateMigrationFiles(dir string, name string) (string, string, error) {
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return "", "", ErrInvalidMigrationName
+	}
+	migrations, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	version := uint64(1)
+	if len(migrations) != 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	prefix := filepath.Join(dir, fmt.Sprintf("%04d_%s", version, name))
+	upPath, downPath := prefix+".up.sql", prefix+".down.sql"
+	if err := os.WriteFile(upPath, []byte("-- "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(downPath, []byte("-- revert "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	return upPath, downPath, nil
+}
+
+// A Migrator applies and reverts migrations, recording applied versions in schema_migrations.
+type Migrator struct {
+	db         GormDB
+	migrations []Migration
+	logger     *log.Logger
+}
+
+func CreateMigrator(db GormDB, migrations []Migration, logger *log.Logger) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		logger:     logger,
+	}
+}
+
+// Runs fc on a single connection holding the migration lock, after making sure that
+// schema_migrations exists.
+func (migrator *Migrator) withLock(fc func(db GormDB) error) error {
+	return migrator.db.Connection(func(db GormDB) error {
+		// Session level lock - transactions of single migrations don't release it
+		if err := db.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).GetError(); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			if err := db.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey).GetError(); err != nil {
+				migrator.logger.Printf("failed to release migration lock: %+v", err)
+			}
+		}()
+
+		tx := db.Exec(`
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint PRIMARY KEY,
+	name text NOT NULL,
+	applied_at timestamptz NOT NULL DEFAULT now()
+)`)
+		if err := tx.GetError(); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+
+		if err := migrator.adoptLegacySchema(db); err != nil {
+			return err
+		}
+		return fc(db)
+	})
+}
+
+// Databases created by the former automigrate command already have the initial schema.
+// The first migration is recorded as applied without running it. Migrations after it that
+// automigrate also did skip what already exists.
+func (migrator *Migrator) adoptLegacySchema(db GormDB) error {
+	if len(migrator.migrations) == 0 || migrator.migrations[0].Version != 1 {
+		return nil
+	}
+	tx := db.Exec(`
+INSERT INTO schema_migrations (version, name)
+	SELECT ?, ?
+	WHERE NOT EXISTS (SELECT 1 FROM schema_migrations) AND to_regclass('public.users') IS NOT NULL`,
+		migrator.migrations[0].Version, migrator.migrations[0].Name)
+	if err := tx.GetError(); err != nil {
+		return fmt.Errorf("failed to adopt legacy schema: %w", err)
+	}
+	if tx.GetRowsAffected() != 0 {
+		migrator.logger.Printf("existing schema found, marked migration %04d_%s as applied",
+			migrator.migrations[0].Version, migrator.migrations[0].Name)
+	}
+	return nil
+}
+
+func (migrator *Migrator) applied(db GormDB) (map[uint64]appliedMigration, error) {
+	var rows []appliedMigration
+	tx := db.Raw("SELECT version, name, applied_at FROM schema_migrations ORDER BY version").Scan(&rows)
+	if err := tx.GetError(); err != nil {
+		return nil, fmt.Errorf("db.Scan(schema_migrations) returned an error: %w", err)
+	}
+	applied := make(map[uint64]appliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// Applies all pending migrations in order. Each migration runs in its own transaction.
+// Returns the number of applied migrations.
+func (migrator *Migrator) Up() (int, error) {
+	count := 0
+	err := migrator.withLock(func(db GormDB) error {
+		applied, err := migrator.applied(db)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrator.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			migration := migration
+			err := db.Transaction(func(db GormDB) error {
+				if err := db.Exec(migration.Up).GetError(); err != nil {
+					return err
+				}
+				return db.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)",
+					migration.Version, migration.Name).GetError()
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			migrator.logger.Printf("applied migration %04d_%s", migration.Version, migration.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Reverts up to steps most recently applied migrations, newest first.
+// Returns the number of reverted migrations.
+func (migrator *Migrator) Down(steps int) (int, error) {
+	count := 0
+	err := migrator.withLock(func(db GormDB) error {
+		applied, err := migrator.applied(db)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrator.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := migrator.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			err := db.Transaction(func(db GormDB) error {
+				if err := db.Exec(migration.Down).GetError(); err != nil {
+					return err
+				}
+				return db.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version).GetError()
+			})
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			migrator.logger.Printf("reverted migration %04d_%s", migration.Version, migration.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Returns all known and applied migrations, sorted by version
+func (migrator *Migrator) Status() ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := migrator.withLock(func(db GormDB) error {
+		applied, err := migrator.applied(db)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrator.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if row, ok := applied[migration.Version]; ok {
+				appliedAt := row.AppliedAt
+				status.AppliedAt = &appliedAt
+				delete(applied, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		// Applied by a newer version of the server
+		for _, row := range applied {
+			appliedAt := row.AppliedAt
+			statuses = append(statuses, MigrationStatus{
+				Version:   row.Version,
+				Name:      row.Name,
+				AppliedAt: &appliedAt,
+				Unknown:   true,
+			})
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, err
+}
@@ -0,0 +1,124 @@
+package migrations_test
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/StampWallet/backend/internal/database/migrations"
+	. "github.com/StampWallet/backend/internal/testutils"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.up.sql":   {Data: []byte("CREATE TABLE b ()")},
+		"0002_second.down.sql": {Data: []byte("DROP TABLE b")},
+		"0001_first.up.sql":    {Data: []byte("CREATE TABLE a ()")},
+		"0001_first.down.sql":  {Data: []byte("DROP TABLE a")},
+		"README":               {Data: []byte("not a migration")},
+	}
+	migrations, err := LoadMigrations(fsys)
+	require.Nilf(t, err, "LoadMigrations should return nil error")
+	require.Equalf(t, []Migration{
+		{Version: 1, Name: "first", Up: "CREATE TABLE a ()", Down: "DROP TABLE a"},
+		{Version: 2, Name: "second", Up: "CREATE TABLE b ()", Down: "DROP TABLE b"},
+	}, migrations, "LoadMigrations should return migrations sorted by version")
+
+	_, err = LoadMigrations(fstest.MapFS{
+		"0001_first.up.sql": {Data: []byte("CREATE TABLE a ()")},
+	})
+	require.Truef(t, errors.Is(err, ErrMissingMigrationFile), "LoadMigrations should require down files, got %+v", err)
+
+	_, err = LoadMigrations(fstest.MapFS{
+		"0001_first.up.sql":   {Data: []byte("CREATE TABLE a ()")},
+		"0001_first.down.sql": {Data: []byte("DROP TABLE a")},
+		"0001_other.up.sql":   {Data: []byte("CREATE TABLE b ()")},
+		"0001_other.down.sql": {Data: []byte("DROP TABLE b")},
+	})
+	require.Truef(t, errors.Is(err, ErrDuplicateMigration), "LoadMigrations should reject duplicate versions, got %+v", err)
+
+	_, err = LoadMigrations(fstest.MapFS{
+		"first.up.sql": {Data: []byte("CREATE TABLE a ()")},
+	})
+	require.Truef(t, errors.Is(err, ErrInvalidMigrationFile), "LoadMigrations should reject unversioned files, got %+v", err)
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := EmbeddedMigrations()
+	require.Nilf(t, err, "EmbeddedMigrations should return nil error")
+	require.NotEmptyf(t, migrations, "EmbeddedMigrations should return migrations")
+	for i, migration := range migrations {
+		require.Equalf(t, uint64(i+1), migration.Version, "migration versions should have no gaps")
+	}
+}
+
+// Legacy databases skip the first migration, so tables created later must not be in it
+func TestEmbeddedMigrationsInitialIsBaseline(t *testing.T) {
+	migrations, err := EmbeddedMigrations()
+	require.Nilf(t, err, "EmbeddedMigrations should return nil error")
+	createTable := regexp.MustCompile(`CREATE TABLE (?:IF NOT EXISTS )?([a-z_]+)`)
+	for _, migration := range migrations[1:] {
+		for _, match := range createTable.FindAllStringSubmatch(migration.Up, -1) {
+			require.NotRegexpf(t, `CREATE TABLE `+match[1]+` \(`, migrations[0].Up,
+				"table %s of migration %04d_%s should not be created by the initial migration",
+				match[1], migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestCreateMigrationFiles(t *testing.T) {
+	dir := t.TempDir()
+	upPath, downPath, err := CreateMigrationFiles(dir, "first")
+	require.Nilf(t, err, "CreateMigrationFiles should return nil error")
+	require.Equalf(t, filepath.Join(dir, "0001_first.up.sql"), upPath, "CreateMigrationFiles should start at version 1")
+	require.Equalf(t, filepath.Join(dir, "0001_first.down.sql"), downPath, "CreateMigrationFiles should create a down file")
+
+	upPath, _, err = CreateMigrationFiles(dir, "second")
+	require.Nilf(t, err, "CreateMigrationFiles should return nil error")
+	require.Equalf(t, filepath.Join(dir, "0002_second.up.sql"), upPath, "CreateMigrationFiles should use the next version")
+	_, err = os.Stat(upPath)
+	require.Nilf(t, err, "CreateMigrationFiles should create the up file")
+
+	_, _, err = CreateMigrationFiles(dir, "Not Valid")
+	require.Equalf(t, ErrInvalidMigrationName, err, "CreateMigrationFiles should reject invalid names")
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	db := GetTestDatabase()
+	migrations := []Migration{
+		{Version: 1000001, Name: "test_table", Up: "CREATE TABLE migrator_test (id bigint)", Down: "DROP TABLE migrator_test"},
+	}
+	migrator := CreateMigrator(db, migrations, log.Default())
+
+	applied, err := migrator.Up()
+	require.Nilf(t, err, "Migrator.Up should return nil error")
+	require.Equalf(t, 1, applied, "Migrator.Up should apply pending migrations")
+	applied, err = migrator.Up()
+	require.Nilf(t, err, "Migrator.Up should return nil error")
+	require.Equalf(t, 0, applied, "Migrator.Up should not apply migrations twice")
+
+	statuses, err := migrator.Status()
+	require.Nilf(t, err, "Migrator.Status should return nil error")
+	var found bool
+	for _, status := range statuses {
+		if status.Version == 1000001 {
+			found = true
+			require.NotNilf(t, status.AppliedAt, "Migrator.Status should return when migration was applied")
+		}
+	}
+	require.Truef(t, found, "Migrator.Status should return applied migration")
+
+	reverted, err := migrator.Down(1)
+	require.Nilf(t, err, "Migrator.Down should return nil error")
+	require.Equalf(t, 1, reverted, "Migrator.Down should revert the last migration")
+	var exists bool
+	require.Nilf(t, db.Raw("SELECT to_regclass('migrator_test') IS NOT NULL").Scan(&exists).GetError(),
+		"db.Scan should return nil error")
+	require.Falsef(t, exists, "Migrator.Down should drop the table")
+}
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS transaction_details;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS owned_items;
+DROP TABLE IF EXISTS virtual_cards;
+DROP TABLE IF EXISTS menu_images;
+DROP TABLE IF EXISTS item_definitions;
+DROP TABLE IF EXISTS file_metadata;
+DROP TABLE IF EXISTS businesses;
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS local_cards;
+DROP TABLE IF EXISTS users;
+
+DROP FUNCTION IF EXISTS f_concat_ws(text, VARIADIC text[]);
@@ -0,0 +1,205 @@
+-- Schema created by GORM AutoMigrate before any of the later schema changes.
+-- Databases created with the former automigrate command are marked as migrated to this version
+-- without running it, see migrations.Migrator. Migrations up to 0009 were also done by automigrate,
+-- so they don't fail on databases that already have their changes.
+
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+CREATE TABLE users (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	public_id text NOT NULL,
+	email text NOT NULL,
+	password_hash text NOT NULL,
+	email_verified boolean NOT NULL DEFAULT false,
+	PRIMARY KEY (id)
+);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+CREATE UNIQUE INDEX idx_users_public_id ON users (public_id);
+
+CREATE TABLE local_cards (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	public_id text NOT NULL,
+	owner_id bigint,
+	type text,
+	code text,
+	name text,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_users_local_cards FOREIGN KEY (owner_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX idx_local_cards_public_id ON local_cards (public_id);
+CREATE INDEX idx_local_cards_deleted_at ON local_cards (deleted_at);
+
+CREATE TABLE tokens (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	owner_id bigint,
+	token_id text NOT NULL,
+	token_hash text NOT NULL,
+	expires timestamptz NOT NULL,
+	token_purpose text NOT NULL,
+	used boolean NOT NULL DEFAULT false,
+	recalled boolean NOT NULL DEFAULT false,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_users_tokens FOREIGN KEY (owner_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX idx_tokens_token_id ON tokens (token_id);
+CREATE INDEX idx_tokens_deleted_at ON tokens (deleted_at);
+
+CREATE TABLE businesses (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	public_id text NOT NULL,
+	owner_id bigint NOT NULL,
+	name text NOT NULL,
+	description text NOT NULL,
+	address text NOT NULL,
+	gps_coordinates geography(POINT,4326),
+	n_ip text NOT NULL UNIQUE,
+	krs text UNIQUE,
+	regon text UNIQUE,
+	owner_name text NOT NULL,
+	banner_image_id text NOT NULL UNIQUE,
+	icon_image_id text NOT NULL UNIQUE,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_users_business FOREIGN KEY (owner_id) REFERENCES users (id)
+);
+CREATE INDEX idx_businesses_gps_coordinates ON businesses USING gist (gps_coordinates);
+CREATE UNIQUE INDEX idx_businesses_public_id ON businesses (public_id);
+CREATE INDEX idx_businesses_deleted_at ON businesses (deleted_at);
+
+CREATE TABLE file_metadata (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	public_id text NOT NULL,
+	owner_id bigint NOT NULL,
+	content_type text,
+	uploaded timestamptz,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_users_files_metadata FOREIGN KEY (owner_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX idx_file_metadata_public_id ON file_metadata (public_id);
+CREATE INDEX idx_file_metadata_deleted_at ON file_metadata (deleted_at);
+
+CREATE TABLE item_definitions (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	public_id text NOT NULL,
+	business_id bigint NOT NULL,
+	name text NOT NULL,
+	price bigint NOT NULL,
+	description text NOT NULL,
+	image_id text UNIQUE,
+	start_date timestamptz,
+	end_date timestamptz,
+	max_amount bigint,
+	available boolean NOT NULL,
+	withdrawn boolean NOT NULL,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_businesses_item_definitions FOREIGN KEY (business_id) REFERENCES businesses (id)
+);
+CREATE UNIQUE INDEX idx_item_definitions_public_id ON item_definitions (public_id);
+CREATE INDEX idx_item_definitions_deleted_at ON item_definitions (deleted_at);
+
+CREATE TABLE menu_images (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	business_id bigint NOT NULL,
+	file_id text NOT NULL,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_businesses_menu_images FOREIGN KEY (business_id) REFERENCES businesses (id)
+);
+CREATE INDEX idx_menu_images_deleted_at ON menu_images (deleted_at);
+CREATE UNIQUE INDEX menu_item_idx ON menu_images (business_id, file_id);
+
+CREATE TABLE virtual_cards (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	public_id text NOT NULL,
+	owner_id bigint NOT NULL,
+	business_id bigint NOT NULL,
+	points bigint NOT NULL,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_businesses_virtual_cards FOREIGN KEY (business_id) REFERENCES businesses (id),
+	CONSTRAINT fk_users_virtual_cards FOREIGN KEY (owner_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX idx_virtual_cards_public_id ON virtual_cards (public_id);
+CREATE INDEX idx_virtual_cards_deleted_at ON virtual_cards (deleted_at);
+
+CREATE TABLE owned_items (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	public_id text NOT NULL,
+	definition_id bigint NOT NULL,
+	virtual_card_id bigint NOT NULL,
+	used timestamptz,
+	status text NOT NULL DEFAULT 'OWNED',
+	PRIMARY KEY (id),
+	CONSTRAINT fk_virtual_cards_owned_items FOREIGN KEY (virtual_card_id) REFERENCES virtual_cards (id),
+	CONSTRAINT fk_item_definitions_owned_items FOREIGN KEY (definition_id) REFERENCES item_definitions (id)
+);
+CREATE UNIQUE INDEX idx_owned_items_public_id ON owned_items (public_id);
+CREATE INDEX idx_owned_items_deleted_at ON owned_items (deleted_at);
+
+CREATE TABLE transactions (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	public_id text NOT NULL,
+	virtual_card_id bigint NOT NULL,
+	code text NOT NULL,
+	state text NOT NULL DEFAULT 'STARTED',
+	added_points bigint,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_virtual_cards_transactions FOREIGN KEY (virtual_card_id) REFERENCES virtual_cards (id)
+);
+CREATE INDEX idx_transactions_deleted_at ON transactions (deleted_at);
+CREATE UNIQUE INDEX code ON transactions (virtual_card_id, code);
+CREATE UNIQUE INDEX idx_transactions_public_id ON transactions (public_id);
+
+CREATE TABLE transaction_details (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	transaction_id bigint NOT NULL,
+	item_id bigint NOT NULL,
+	action text NOT NULL DEFAULT 'NO_ACTION',
+	PRIMARY KEY (id),
+	CONSTRAINT fk_transaction_details_owned_item FOREIGN KEY (item_id) REFERENCES owned_items (id),
+	CONSTRAINT fk_transactions_transaction_details FOREIGN KEY (transaction_id) REFERENCES transactions (id)
+);
+CREATE UNIQUE INDEX transaction_detail ON transaction_details (transaction_id, item_id);
+CREATE INDEX idx_transaction_details_deleted_at ON transaction_details (deleted_at);
+
+-- https://dba.stackexchange.com/a/164081
+CREATE OR REPLACE FUNCTION f_concat_ws(text, VARIADIC text[])
+	RETURNS text
+	LANGUAGE sql IMMUTABLE PARALLEL SAFE AS
+	'SELECT array_to_string($2, $1)';
+
+CREATE INDEX business_fulltext_idx ON businesses
+	USING GIN (
+		to_tsvector('simple', f_concat_ws(' ', name, description, address))
+	);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transactions_expires_at;
+ALTER TABLE transactions DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS transaction_active_code_idx;
+DROP INDEX IF EXISTS idx_transactions_business_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS business_id;
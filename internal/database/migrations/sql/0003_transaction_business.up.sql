@@ -0,0 +1,10 @@
+-- business of existing transactions, from their virtual cards
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS business_id bigint;
+UPDATE transactions AS t SET business_id = vc.business_id
+	FROM virtual_cards AS vc
+	WHERE vc.id = t.virtual_card_id AND t.business_id IS NULL;
+ALTER TABLE transactions ALTER COLUMN business_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_business_id ON transactions (business_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_active_code_idx ON transactions (business_id, code)
+	WHERE state IN ('STARTED', 'PROCESSING') AND deleted_at IS NULL;
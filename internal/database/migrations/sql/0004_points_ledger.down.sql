@@ -0,0 +1 @@
+DROP TABLE IF EXISTS points_ledger_entries;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS points_ledger_entries (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	virtual_card_id bigint NOT NULL,
+	delta bigint NOT NULL,
+	reason text NOT NULL,
+	transaction_id bigint,
+	owned_item_id bigint,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_points_ledger_entries_virtual_card FOREIGN KEY (virtual_card_id) REFERENCES virtual_cards (id),
+	CONSTRAINT fk_points_ledger_entries_transaction FOREIGN KEY (transaction_id) REFERENCES transactions (id),
+	CONSTRAINT fk_points_ledger_entries_owned_item FOREIGN KEY (owned_item_id) REFERENCES owned_items (id)
+);
+CREATE INDEX IF NOT EXISTS idx_points_ledger_entries_virtual_card_id ON points_ledger_entries (virtual_card_id);
+CREATE INDEX IF NOT EXISTS idx_points_ledger_entries_deleted_at ON points_ledger_entries (deleted_at);
+
+-- cards created before the ledger existed start with a single entry with their balance
+INSERT INTO points_ledger_entries (created_at, updated_at, virtual_card_id, delta, reason)
+	SELECT now(), now(), vc.id, vc.points, 'OPENING_BALANCE'
+	FROM virtual_cards AS vc
+	WHERE vc.points <> 0 AND NOT EXISTS (
+		SELECT 1 FROM points_ledger_entries AS ple WHERE ple.virtual_card_id = vc.id
+	);
@@ -0,0 +1,4 @@
+ALTER TABLE points_ledger_entries DROP COLUMN IF EXISTS points_lot_id;
+DROP TABLE IF EXISTS points_lot_spendings;
+DROP TABLE IF EXISTS points_lots;
+ALTER TABLE businesses DROP COLUMN IF EXISTS points_expiry_days;
@@ -0,0 +1,42 @@
+ALTER TABLE businesses ADD COLUMN IF NOT EXISTS points_expiry_days bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS points_lots (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	virtual_card_id bigint NOT NULL,
+	points bigint NOT NULL,
+	remaining bigint NOT NULL,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_points_lots_virtual_card FOREIGN KEY (virtual_card_id) REFERENCES virtual_cards (id)
+);
+CREATE INDEX IF NOT EXISTS idx_points_lots_virtual_card_id ON points_lots (virtual_card_id);
+CREATE INDEX IF NOT EXISTS idx_points_lots_deleted_at ON points_lots (deleted_at);
+
+CREATE TABLE IF NOT EXISTS points_lot_spendings (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	points_lot_id bigint NOT NULL,
+	owned_item_id bigint NOT NULL,
+	points bigint NOT NULL,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_points_lot_spendings_points_lot FOREIGN KEY (points_lot_id) REFERENCES points_lots (id),
+	CONSTRAINT fk_points_lot_spendings_owned_item FOREIGN KEY (owned_item_id) REFERENCES owned_items (id)
+);
+CREATE INDEX IF NOT EXISTS idx_points_lot_spendings_owned_item_id ON points_lot_spendings (owned_item_id);
+CREATE INDEX IF NOT EXISTS idx_points_lot_spendings_points_lot_id ON points_lot_spendings (points_lot_id);
+CREATE INDEX IF NOT EXISTS idx_points_lot_spendings_deleted_at ON points_lot_spendings (deleted_at);
+
+ALTER TABLE points_ledger_entries ADD COLUMN IF NOT EXISTS points_lot_id bigint
+	CONSTRAINT fk_points_ledger_entries_points_lot REFERENCES points_lots (id);
+
+-- same as the ledger - points from before lots existed expire as if they were added during migration
+INSERT INTO points_lots (created_at, updated_at, virtual_card_id, points, remaining)
+	SELECT now(), now(), vc.id, vc.points, vc.points
+	FROM virtual_cards AS vc
+	WHERE vc.points <> 0 AND NOT EXISTS (
+		SELECT 1 FROM points_lots AS pl WHERE pl.virtual_card_id = vc.id
+	);
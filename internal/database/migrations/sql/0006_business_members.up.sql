@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS business_members (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	public_id text NOT NULL,
+	business_id bigint NOT NULL,
+	owner_id bigint NOT NULL,
+	role text NOT NULL,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_business_members_business FOREIGN KEY (business_id) REFERENCES businesses (id),
+	CONSTRAINT fk_business_members_user FOREIGN KEY (owner_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_business_members_deleted_at ON business_members (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_business_members_owner_id ON business_members (owner_id);
+CREATE INDEX IF NOT EXISTS idx_business_members_business_id ON business_members (business_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_business_members_public_id ON business_members (public_id);
+
+CREATE TABLE IF NOT EXISTS business_invitations (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	public_id text NOT NULL,
+	business_id bigint NOT NULL,
+	email text NOT NULL,
+	role text NOT NULL,
+	accepted timestamptz,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_business_invitations_business FOREIGN KEY (business_id) REFERENCES businesses (id)
+);
+CREATE INDEX IF NOT EXISTS idx_business_invitations_email ON business_invitations (email);
+CREATE INDEX IF NOT EXISTS idx_business_invitations_business_id ON business_invitations (business_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_business_invitations_public_id ON business_invitations (public_id);
+CREATE INDEX IF NOT EXISTS idx_business_invitations_deleted_at ON business_invitations (deleted_at);
+
+-- owners of businesses created before members existed
+INSERT INTO business_members (created_at, updated_at, public_id, business_id, owner_id, role)
+	SELECT now(), now(), md5(random()::text), b.id, b.owner_id, 'OWNER'
+	FROM businesses AS b
+	WHERE b.deleted_at IS NULL AND NOT EXISTS (
+		SELECT 1 FROM business_members AS bm WHERE bm.owner_id = b.owner_id
+	);
@@ -0,0 +1,3 @@
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS last_used timestamptz;
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS user_agent text;
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS ip_address text;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	key text NOT NULL,
+	failures bigint NOT NULL,
+	last_failure timestamptz NOT NULL,
+	locked_until timestamptz,
+	PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_login_attempts_key ON login_attempts (key);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_deleted_at ON login_attempts (deleted_at);
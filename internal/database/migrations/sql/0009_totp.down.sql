@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS totp_recovery_codes;
+ALTER TABLE businesses DROP COLUMN IF EXISTS require_totp;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_step;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_enabled;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret;
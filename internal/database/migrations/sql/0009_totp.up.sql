@@ -0,0 +1,18 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled boolean NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step bigint NOT NULL DEFAULT 0;
+ALTER TABLE businesses ADD COLUMN IF NOT EXISTS require_totp boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	owner_id bigint NOT NULL,
+	code_hash text NOT NULL,
+	used boolean NOT NULL DEFAULT false,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_totp_recovery_codes_user FOREIGN KEY (owner_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_totp_recovery_codes_owner_id ON totp_recovery_codes (owner_id);
+CREATE INDEX IF NOT EXISTS idx_totp_recovery_codes_deleted_at ON totp_recovery_codes (deleted_at);
@@ -0,0 +1,2 @@
+ALTER TABLE file_metadata DROP COLUMN processed;
+ALTER TABLE file_metadata DROP COLUMN purpose;
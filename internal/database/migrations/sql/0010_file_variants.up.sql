@@ -0,0 +1,12 @@
+ALTER TABLE file_metadata ADD COLUMN purpose text NOT NULL DEFAULT '';
+ALTER TABLE file_metadata ADD COLUMN processed boolean NOT NULL DEFAULT false;
+
+-- purposes of existing files, from what they are attached to
+UPDATE file_metadata SET purpose = 'BUSINESS_BANNER'
+	WHERE public_id IN (SELECT banner_image_id FROM businesses);
+UPDATE file_metadata SET purpose = 'BUSINESS_ICON'
+	WHERE public_id IN (SELECT icon_image_id FROM businesses);
+UPDATE file_metadata SET purpose = 'MENU_IMAGE'
+	WHERE public_id IN (SELECT file_id FROM menu_images);
+UPDATE file_metadata SET purpose = 'ITEM_IMAGE'
+	WHERE public_id IN (SELECT image_id FROM item_definitions);
@@ -0,0 +1,5 @@
+DROP INDEX idx_file_metadata_business_id;
+ALTER TABLE file_metadata DROP CONSTRAINT fk_file_metadata_business;
+ALTER TABLE file_metadata DROP COLUMN checksum;
+ALTER TABLE file_metadata DROP COLUMN size;
+ALTER TABLE file_metadata DROP COLUMN business_id;
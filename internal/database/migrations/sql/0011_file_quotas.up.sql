@@ -0,0 +1,14 @@
+ALTER TABLE file_metadata ADD COLUMN business_id bigint;
+ALTER TABLE file_metadata ADD COLUMN size bigint NOT NULL DEFAULT 0;
+ALTER TABLE file_metadata ADD COLUMN checksum text;
+ALTER TABLE file_metadata ADD CONSTRAINT fk_file_metadata_business
+	FOREIGN KEY (business_id) REFERENCES businesses (id);
+CREATE INDEX idx_file_metadata_business_id ON file_metadata (business_id);
+
+-- businesses of existing files, from what they are attached to. Sizes of existing files are unknown.
+UPDATE file_metadata SET business_id = businesses.id FROM businesses
+	WHERE file_metadata.public_id IN (businesses.banner_image_id, businesses.icon_image_id);
+UPDATE file_metadata SET business_id = menu_images.business_id FROM menu_images
+	WHERE file_metadata.public_id = menu_images.file_id;
+UPDATE file_metadata SET business_id = item_definitions.business_id FROM item_definitions
+	WHERE file_metadata.public_id = item_definitions.image_id;
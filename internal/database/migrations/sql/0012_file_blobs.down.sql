@@ -0,0 +1,4 @@
+DROP INDEX idx_file_metadata_blob_hash;
+ALTER TABLE file_metadata DROP COLUMN blob_hash;
+DROP TABLE IF EXISTS file_blob_variants;
+DROP TABLE IF EXISTS file_blobs;
@@ -0,0 +1,31 @@
+CREATE TABLE file_blobs (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	hash text NOT NULL,
+	content_type text NOT NULL,
+	size bigint NOT NULL,
+	ref_count bigint NOT NULL,
+	PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_file_blobs_hash ON file_blobs (hash);
+CREATE INDEX idx_file_blobs_deleted_at ON file_blobs (deleted_at);
+
+CREATE TABLE file_blob_variants (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	hash text NOT NULL,
+	purpose text NOT NULL,
+	size bigint NOT NULL,
+	ref_count bigint NOT NULL,
+	PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX blob_variant ON file_blob_variants (hash, purpose);
+CREATE INDEX idx_file_blob_variants_deleted_at ON file_blob_variants (deleted_at);
+
+-- existing files stay stored under their public_id
+ALTER TABLE file_metadata ADD COLUMN blob_hash text;
+CREATE INDEX idx_file_metadata_blob_hash ON file_metadata (blob_hash);
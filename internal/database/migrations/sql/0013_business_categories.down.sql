@@ -0,0 +1,8 @@
+DROP INDEX business_fulltext_idx;
+CREATE INDEX business_fulltext_idx ON businesses
+	USING GIN (
+		to_tsvector('simple', f_concat_ws(' ', name, description, address))
+	);
+
+DROP TABLE IF EXISTS business_categories;
+DROP TABLE IF EXISTS categories;
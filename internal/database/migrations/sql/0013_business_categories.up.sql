@@ -0,0 +1,42 @@
+CREATE TABLE categories (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	slug text NOT NULL,
+	name text NOT NULL,
+	PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_categories_slug ON categories (slug);
+CREATE INDEX idx_categories_deleted_at ON categories (deleted_at);
+
+INSERT INTO categories (created_at, updated_at, slug, name) VALUES
+	(now(), now(), 'cafe', 'Café'),
+	(now(), now(), 'bakery', 'Bakery'),
+	(now(), now(), 'barber', 'Barber'),
+	(now(), now(), 'beauty', 'Beauty salon'),
+	(now(), now(), 'restaurant', 'Restaurant'),
+	(now(), now(), 'bar', 'Bar'),
+	(now(), now(), 'grocery', 'Grocery'),
+	(now(), now(), 'pharmacy', 'Pharmacy'),
+	(now(), now(), 'fitness', 'Fitness'),
+	(now(), now(), 'clothing', 'Clothing'),
+	(now(), now(), 'bookstore', 'Bookstore'),
+	(now(), now(), 'other', 'Other');
+
+CREATE TABLE business_categories (
+	business_id bigint,
+	category_id bigint,
+	PRIMARY KEY (business_id, category_id),
+	CONSTRAINT fk_business_categories_business FOREIGN KEY (business_id) REFERENCES businesses (id),
+	CONSTRAINT fk_business_categories_category FOREIGN KEY (category_id) REFERENCES categories (id)
+);
+CREATE INDEX idx_business_categories_category_id ON business_categories (category_id);
+
+-- search filters out deleted businesses, the full-text index should not cover them
+DROP INDEX business_fulltext_idx;
+CREATE INDEX business_fulltext_idx ON businesses
+	USING GIN (
+		to_tsvector('simple', f_concat_ws(' ', name, description, address))
+	)
+	WHERE deleted_at IS NULL;
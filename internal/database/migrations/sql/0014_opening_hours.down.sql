@@ -0,0 +1,4 @@
+DROP FUNCTION IF EXISTS business_open_at(bigint, timestamp);
+DROP TABLE IF EXISTS opening_hours_exceptions;
+DROP TABLE IF EXISTS opening_hours;
+ALTER TABLE businesses DROP COLUMN IF EXISTS time_zone;
@@ -0,0 +1,53 @@
+ALTER TABLE businesses ADD COLUMN time_zone text NOT NULL DEFAULT 'UTC';
+
+CREATE TABLE opening_hours (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	business_id bigint NOT NULL,
+	weekday bigint NOT NULL,
+	opens_at bigint NOT NULL,
+	closes_at bigint NOT NULL,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_businesses_opening_hours FOREIGN KEY (business_id) REFERENCES businesses (id)
+);
+CREATE INDEX idx_opening_hours_deleted_at ON opening_hours (deleted_at);
+CREATE INDEX idx_opening_hours_business_id ON opening_hours (business_id);
+
+CREATE TABLE opening_hours_exceptions (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	business_id bigint NOT NULL,
+	date date NOT NULL,
+	closed boolean NOT NULL DEFAULT false,
+	opens_at bigint NOT NULL,
+	closes_at bigint NOT NULL,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_businesses_opening_hours_exceptions FOREIGN KEY (business_id) REFERENCES businesses (id)
+);
+CREATE INDEX idx_opening_hours_exceptions_deleted_at ON opening_hours_exceptions (deleted_at);
+CREATE INDEX idx_opening_hours_exceptions_business_id ON opening_hours_exceptions (business_id);
+
+-- Checks if business is open at local_time, in the time zone of the business.
+-- Exceptions of the date replace regular opening hours of that day.
+-- Keep in sync with Business.IsOpenAt
+CREATE OR REPLACE FUNCTION business_open_at(business bigint, local_time timestamp)
+	RETURNS boolean
+	LANGUAGE sql STABLE PARALLEL SAFE AS
+$$
+	SELECT CASE
+		WHEN EXISTS (SELECT 1 FROM opening_hours_exceptions
+			WHERE business_id = business AND deleted_at IS NULL AND date = local_time::date)
+		THEN EXISTS (SELECT 1 FROM opening_hours_exceptions
+			WHERE business_id = business AND deleted_at IS NULL AND date = local_time::date AND NOT closed
+				AND opens_at <= extract(hour FROM local_time) * 60 + extract(minute FROM local_time)
+				AND extract(hour FROM local_time) * 60 + extract(minute FROM local_time) < closes_at)
+		ELSE EXISTS (SELECT 1 FROM opening_hours
+			WHERE business_id = business AND deleted_at IS NULL AND weekday = extract(dow FROM local_time)
+				AND opens_at <= extract(hour FROM local_time) * 60 + extract(minute FROM local_time)
+				AND extract(hour FROM local_time) * 60 + extract(minute FROM local_time) < closes_at)
+	END
+$$;
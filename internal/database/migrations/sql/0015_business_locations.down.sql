@@ -0,0 +1,30 @@
+DROP FUNCTION IF EXISTS location_open_at(bigint, timestamp);
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS business_location_id;
+-- opening hours of locations would become opening hours of their businesses
+DELETE FROM opening_hours_exceptions WHERE business_location_id IS NOT NULL;
+DELETE FROM opening_hours WHERE business_location_id IS NOT NULL;
+ALTER TABLE opening_hours_exceptions DROP COLUMN IF EXISTS business_location_id;
+ALTER TABLE opening_hours DROP COLUMN IF EXISTS business_location_id;
+DROP TABLE IF EXISTS business_locations;
+
+-- Checks if business is open at local_time, in the time zone of the business.
+-- Exceptions of the date replace regular opening hours of that day.
+-- Keep in sync with Business.IsOpenAt
+CREATE OR REPLACE FUNCTION business_open_at(business bigint, local_time timestamp)
+	RETURNS boolean
+	LANGUAGE sql STABLE PARALLEL SAFE AS
+$$
+	SELECT CASE
+		WHEN EXISTS (SELECT 1 FROM opening_hours_exceptions
+			WHERE business_id = business AND deleted_at IS NULL AND date = local_time::date)
+		THEN EXISTS (SELECT 1 FROM opening_hours_exceptions
+			WHERE business_id = business AND deleted_at IS NULL AND date = local_time::date AND NOT closed
+				AND opens_at <= extract(hour FROM local_time) * 60 + extract(minute FROM local_time)
+				AND extract(hour FROM local_time) * 60 + extract(minute FROM local_time) < closes_at)
+		ELSE EXISTS (SELECT 1 FROM opening_hours
+			WHERE business_id = business AND deleted_at IS NULL AND weekday = extract(dow FROM local_time)
+				AND opens_at <= extract(hour FROM local_time) * 60 + extract(minute FROM local_time)
+				AND extract(hour FROM local_time) * 60 + extract(minute FROM local_time) < closes_at)
+	END
+$$;
@@ -0,0 +1,74 @@
+CREATE TABLE business_locations (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	public_id text NOT NULL,
+	business_id bigint NOT NULL,
+	name text NOT NULL,
+	address text NOT NULL,
+	gps_coordinates geography(POINT,4326),
+	PRIMARY KEY (id),
+	CONSTRAINT fk_businesses_locations FOREIGN KEY (business_id) REFERENCES businesses (id)
+);
+CREATE UNIQUE INDEX idx_business_locations_public_id ON business_locations (public_id);
+CREATE INDEX idx_business_locations_deleted_at ON business_locations (deleted_at);
+CREATE INDEX idx_business_locations_business_id ON business_locations (business_id);
+CREATE INDEX idx_business_locations_gps_coordinates ON business_locations USING gist (gps_coordinates);
+
+-- every business has at least one location, existing businesses get one at their address.
+-- Public id of the business is unique, so it is reused as the public id of its first location
+INSERT INTO business_locations (created_at, updated_at, public_id, business_id, name, address, gps_coordinates)
+	SELECT now(), now(), public_id, id, name, address, gps_coordinates FROM businesses WHERE deleted_at IS NULL;
+
+ALTER TABLE opening_hours ADD COLUMN business_location_id bigint
+	CONSTRAINT fk_business_locations_opening_hours REFERENCES business_locations (id);
+CREATE INDEX idx_opening_hours_business_location_id ON opening_hours (business_location_id);
+ALTER TABLE opening_hours_exceptions ADD COLUMN business_location_id bigint
+	CONSTRAINT fk_business_locations_opening_hours_exceptions REFERENCES business_locations (id);
+CREATE INDEX idx_opening_hours_exceptions_business_location_id ON opening_hours_exceptions (business_location_id);
+
+ALTER TABLE transactions ADD COLUMN business_location_id bigint
+	CONSTRAINT fk_transactions_business_location REFERENCES business_locations (id);
+CREATE INDEX idx_transactions_business_location_id ON transactions (business_location_id);
+
+-- search filters locations, not businesses
+DROP FUNCTION IF EXISTS business_open_at(bigint, timestamp);
+
+-- Checks if location is open at local_time, in the time zone of its business. Locations without
+-- their own opening hours use opening hours of the business. Exceptions of the date replace
+-- regular opening hours of that day.
+-- Keep in sync with Business.IsLocationOpenAt
+CREATE OR REPLACE FUNCTION location_open_at(location bigint, local_time timestamp)
+	RETURNS boolean
+	LANGUAGE sql STABLE PARALLEL SAFE AS
+$$
+	WITH own AS (
+		SELECT EXISTS (SELECT 1 FROM opening_hours
+				WHERE business_location_id = location AND deleted_at IS NULL)
+			OR EXISTS (SELECT 1 FROM opening_hours_exceptions
+				WHERE business_location_id = location AND deleted_at IS NULL) AS has_hours
+	), hours AS (
+		SELECT opening_hours.* FROM opening_hours, own, business_locations
+		WHERE business_locations.id = location AND opening_hours.business_id = business_locations.business_id
+			AND opening_hours.deleted_at IS NULL
+			AND CASE WHEN own.has_hours THEN opening_hours.business_location_id = location
+				ELSE opening_hours.business_location_id IS NULL END
+	), exceptions AS (
+		SELECT opening_hours_exceptions.* FROM opening_hours_exceptions, own, business_locations
+		WHERE business_locations.id = location
+			AND opening_hours_exceptions.business_id = business_locations.business_id
+			AND opening_hours_exceptions.deleted_at IS NULL AND opening_hours_exceptions.date = local_time::date
+			AND CASE WHEN own.has_hours THEN opening_hours_exceptions.business_location_id = location
+				ELSE opening_hours_exceptions.business_location_id IS NULL END
+	), minute AS (
+		SELECT extract(hour FROM local_time) * 60 + extract(minute FROM local_time) AS value
+	)
+	SELECT CASE
+		WHEN EXISTS (SELECT 1 FROM exceptions)
+		THEN EXISTS (SELECT 1 FROM exceptions, minute
+			WHERE NOT closed AND opens_at <= minute.value AND minute.value < closes_at)
+		ELSE EXISTS (SELECT 1 FROM hours, minute
+			WHERE weekday = extract(dow FROM local_time) AND opens_at <= minute.value AND minute.value < closes_at)
+	END
+$$;
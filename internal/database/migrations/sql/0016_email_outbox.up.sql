@@ -0,0 +1,19 @@
+CREATE TABLE email_outbox_entries (
+	id bigserial,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	recipient text NOT NULL,
+	subject text NOT NULL,
+	body text NOT NULL,
+	state text NOT NULL DEFAULT 'PENDING',
+	attempts bigint NOT NULL DEFAULT 0,
+	next_attempt_at timestamptz NOT NULL,
+	last_error text,
+	sent_at timestamptz,
+	PRIMARY KEY (id)
+);
+CREATE INDEX idx_email_outbox_entries_deleted_at ON email_outbox_entries (deleted_at);
+-- the worker only looks at pending entries that are due
+CREATE INDEX idx_email_outbox_entries_pending ON email_outbox_entries (next_attempt_at)
+	WHERE state = 'PENDING';
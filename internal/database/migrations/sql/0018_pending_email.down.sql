@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS previous_email;
+ALTER TABLE users DROP COLUMN IF EXISTS pending_email;
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN pending_email text;
+ALTER TABLE users ADD COLUMN previous_email text;
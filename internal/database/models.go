@@ -49,6 +49,14 @@ type TokenPurposeEnum string
 const (
 	TokenPurposeSession TokenPurposeEnum = "SESSION"
 	TokenPurposeEmail   TokenPurposeEnum = "EMAIL"
+	// Single use, like TokenPurposeEmail
+	TokenPurposePasswordReset TokenPurposeEnum = "PASSWORD_RESET"
+	// Returned by login when the user has TOTP enabled, exchanged for a session after the code is verified
+	TokenPurposeTotpPending TokenPurposeEnum = "TOTP_PENDING"
+	// Single use, sent to User.PendingEmail, switches the user to it when confirmed
+	TokenPurposeEmailChange TokenPurposeEnum = "EMAIL_CHANGE"
+	// Single use, sent to the old email when email change is requested, cancels or undoes the change
+	TokenPurposeEmailRevert TokenPurposeEnum = "EMAIL_REVERT"
 )
 
 type OwnedItemStatusEnum string
@@ -60,6 +68,34 @@ const (
 	OwnedItemStatusReturned                      = "RETURNED"
 )
 
+type PointsLedgerReasonEnum string
+
+const (
+	PointsLedgerReasonOpeningBalance PointsLedgerReasonEnum = "OPENING_BALANCE" // balance from before the ledger existed
+	PointsLedgerReasonTransaction                           = "TRANSACTION"     // points added by business in a transaction
+	PointsLedgerReasonItemBought                            = "ITEM_BOUGHT"
+	PointsLedgerReasonItemReturned                          = "ITEM_RETURNED"  // returned by user
+	PointsLedgerReasonItemRecalled                          = "ITEM_RECALLED"  // recalled by business in a transaction
+	PointsLedgerReasonItemWithdrawn                         = "ITEM_WITHDRAWN" // item definition was withdrawn
+	PointsLedgerReasonPointsExpired                         = "POINTS_EXPIRED" // see Business.PointsExpiryDays
+)
+
+type BusinessMemberRoleEnum string
+
+const (
+	BusinessMemberRoleOwner   BusinessMemberRoleEnum = "OWNER"   // Business.OwnerId, can do everything
+	BusinessMemberRoleManager                        = "MANAGER" // everything except managing members
+	BusinessMemberRoleCashier                        = "CASHIER" // transactions only
+)
+
+type EmailOutboxStateEnum string
+
+const (
+	EmailOutboxStatePending EmailOutboxStateEnum = "PENDING"
+	EmailOutboxStateSent                         = "SENT"
+	EmailOutboxStateDead                         = "DEAD" // permanent failure or out of attempts, will not be retried
+)
+
 // MODELS
 
 // LocalCard
@@ -90,6 +126,10 @@ type Token struct {
 	TokenPurpose TokenPurposeEnum `gorm:"not null"`
 	Used         bool             `gorm:"default:false;not null"`
 	Recalled     bool             `gorm:"default:false;not null"`
+	// Session metadata, only set for TokenPurposeSession
+	LastUsed  sql.NullTime
+	UserAgent string
+	IpAddress string
 
 	User *User `gorm:"foreignkey:OwnerId"`
 }
@@ -100,20 +140,64 @@ func (entity *Token) GetUserId(_ GormDB) (uint, error) {
 
 // FileMetadata
 
+type FilePurposeEnum string
+
+// What a file is attached to. Decides dimensions of image variants.
+const (
+	FilePurposeBusinessBanner FilePurposeEnum = "BUSINESS_BANNER"
+	FilePurposeBusinessIcon   FilePurposeEnum = "BUSINESS_ICON"
+	FilePurposeMenuImage      FilePurposeEnum = "MENU_IMAGE"
+	FilePurposeItemImage      FilePurposeEnum = "ITEM_IMAGE"
+)
+
 type FileMetadata struct {
 	gorm.Model
-	PublicId    string `gorm:"uniqueIndex;not null"`
-	OwnerId     uint   `gorm:"not null"`
+	PublicId    string          `gorm:"uniqueIndex;not null"`
+	OwnerId     uint            `gorm:"not null"`
+	Purpose     FilePurposeEnum `gorm:"not null;default:''"`
 	ContentType sql.NullString
 	Uploaded    sql.NullTime
+	// Thumbnail and medium variants were stored. Files uploaded before variants existed only have the original.
+	Processed bool `gorm:"not null;default:false"`
+	// Business whose quota the file counts against, nil for files that don't belong to a business
+	BusinessId *uint `gorm:"index"`
+	// Bytes taken in storage by all variants, shared ones included. Files uploaded before sizes were
+	// recorded have 0.
+	Size int64 `gorm:"not null;default:0"`
+	// Hex encoded SHA-256 of the uploaded data
+	Checksum sql.NullString
+	// FileBlob with the stored data. Files uploaded before deduplication are stored under PublicId.
+	BlobHash sql.NullString `gorm:"index"`
 
-	User *User `gorm:"foreignkey:OwnerId"`
+	User     *User     `gorm:"foreignkey:OwnerId"`
+	Business *Business `gorm:"foreignkey:BusinessId"`
 }
 
 func (entity *FileMetadata) GetUserId(_ GormDB) (uint, error) {
 	return entity.OwnerId, nil
 }
 
+// Stored data shared by all files uploaded with the same contents. The original is stored under Hash
+// instead of FileMetadata.PublicId, other variants depend on the purpose, see FileBlobVariant.
+// Blobs are removed when the last file is removed.
+type FileBlob struct {
+	gorm.Model
+	Hash        string `gorm:"uniqueIndex;not null"` // FileMetadata.Checksum of the files
+	ContentType string `gorm:"not null"`
+	Size        int64  `gorm:"not null"` // bytes taken in storage by the original
+	RefCount    uint   `gorm:"not null"` // number of FileMetadata using the blob
+}
+
+// Thumbnail and medium variants of a FileBlob, for files with Purpose. Removed when the last file
+// with the purpose is removed.
+type FileBlobVariant struct {
+	gorm.Model
+	Hash     string          `gorm:"uniqueIndex:blob_variant;not null"` // FileBlob.Hash
+	Purpose  FilePurposeEnum `gorm:"uniqueIndex:blob_variant;not null"`
+	Size     int64           `gorm:"not null"` // bytes taken in storage by the variants
+	RefCount uint            `gorm:"not null"` // number of FileMetadata with Purpose using the blob
+}
+
 // User
 
 type User struct {
@@ -124,6 +208,16 @@ type User struct {
 	Email         string `gorm:"uniqueIndex;not null"`
 	PasswordHash  string `gorm:"not null"`
 	EmailVerified bool   `gorm:"default:false;not null"`
+	Locale        string `gorm:"default:'';not null"` // preferred language of emails, empty for the default
+	// Email change waiting for confirmation, Email is not changed until then. PreviousEmail is the email
+	// before the last confirmed change, restored if the change is reverted.
+	PendingEmail  sql.NullString
+	PreviousEmail sql.NullString
+	// TOTP two-factor authentication. TotpSecret is set on enrollment, TotpEnabled after the first
+	// code is confirmed. TotpLastStep is the time step of the last accepted code, codes are single use.
+	TotpSecret   sql.NullString
+	TotpEnabled  bool  `gorm:"default:false;not null"`
+	TotpLastStep int64 `gorm:"default:0;not null"`
 
 	Tokens        []Token        `gorm:"foreignkey:OwnerId"`
 	FilesMetadata []FileMetadata `gorm:"foreignkey:OwnerId"`
@@ -203,7 +297,7 @@ func FromCoords(longitude float64, latitude float64) GPSCoordinates {
 	return GPSCoordinates(*geom.NewPointFlat(geom.XY, geom.Coord{longitude, latitude}))
 }
 
-// NOTE index is created in automigrate. couldn't figure out how to create a gin fulltext on many columns from tags alone.
+// NOTE index is created in migrations. couldn't figure out how to create a gin fulltext on many columns from tags alone.
 // INDEX NAME is business_fulltext_idx
 type Business struct {
 	gorm.Model
@@ -211,7 +305,7 @@ type Business struct {
 	OwnerId        uint           `gorm:"not null"`
 	Name           string         `gorm:"not null"`
 	Description    string         `gorm:"not null"`
-	Address        string         `gorm:"not null"`
+	Address        string         `gorm:"not null"` // Registered address, see Locations for addresses of branches
 	GPSCoordinates GPSCoordinates `gorm:"type:geography(POINT,4326);index:,type:gist"`
 	NIP            string         `gorm:"unique;not null"`
 	KRS            string         `gorm:"unique"`
@@ -219,10 +313,23 @@ type Business struct {
 	OwnerName      string         `gorm:"not null"`
 	BannerImageId  string         `gorm:"unique;not null"`
 	IconImageId    string         `gorm:"unique;not null"`
-
-	ItemDefinitions []ItemDefinition `gorm:"foreignkey:BusinessId"`
-	MenuImages      []MenuImage      `gorm:"foreignkey:BusinessId"`
-	VirtualCards    []VirtualCard    `gorm:"foreignkey:BusinessId"`
+	// Points expire this many days after they were added to a card. 0 - points never expire
+	PointsExpiryDays uint `gorm:"default:0;not null"`
+	// Owner and members have to enable TOTP to manage the business
+	RequireTotp bool `gorm:"default:false;not null"`
+	// IANA time zone of opening hours, ex. Europe/Warsaw
+	TimeZone string `gorm:"default:UTC;not null"`
+
+	ItemDefinitions []ItemDefinition   `gorm:"foreignkey:BusinessId"`
+	MenuImages      []MenuImage        `gorm:"foreignkey:BusinessId"`
+	VirtualCards    []VirtualCard      `gorm:"foreignkey:BusinessId"`
+	Categories      []Category         `gorm:"many2many:business_categories"`
+	Locations       []BusinessLocation `gorm:"foreignkey:BusinessId"`
+
+	// Opening hours of all locations, including location-specific ones.
+	// Preload with "business_location_id IS NULL" to get only opening hours of the business
+	OpeningHours           []OpeningHours          `gorm:"foreignkey:BusinessId"`
+	OpeningHoursExceptions []OpeningHoursException `gorm:"foreignkey:BusinessId"`
 
 	User *User `gorm:"foreignkey:OwnerId"`
 }
@@ -231,6 +338,104 @@ func (entity *Business) GetUserId(_ GormDB) (uint, error) {
 	return entity.OwnerId, nil
 }
 
+// BusinessLocation
+
+// Branch of a business, like a café of a chain. All locations share the loyalty program of the business.
+// Every business has at least one location. Locations without their own opening hours are open
+// in the opening hours of the business.
+type BusinessLocation struct {
+	gorm.Model
+	PublicId       string         `gorm:"uniqueIndex;not null"`
+	BusinessId     uint           `gorm:"index;not null"`
+	Name           string         `gorm:"not null"`
+	Address        string         `gorm:"not null"`
+	GPSCoordinates GPSCoordinates `gorm:"type:geography(POINT,4326);index:,type:gist"`
+
+	OpeningHours           []OpeningHours          `gorm:"foreignkey:BusinessLocationId"`
+	OpeningHoursExceptions []OpeningHoursException `gorm:"foreignkey:BusinessLocationId"`
+
+	Business *Business `gorm:"foreignkey:BusinessId"`
+}
+
+func (entity *BusinessLocation) GetBusinessId(_ GormDB) (uint, error) {
+	return entity.BusinessId, nil
+}
+
+// Category
+
+// Kind of business, like cafe or bakery. Categories are predefined in migrations.
+type Category struct {
+	gorm.Model
+	Slug string `gorm:"uniqueIndex;not null"`
+	Name string `gorm:"not null"`
+}
+
+// OpeningHours
+
+// Regular opening hours of a business on a day of the week, in the time zone of the business.
+// Times are minutes since midnight. Hours past midnight are a separate interval of the next day.
+type OpeningHours struct {
+	gorm.Model
+	BusinessId         uint         `gorm:"not null;index"`
+	BusinessLocationId *uint        `gorm:"index"`    // nil - opening hours of the business
+	Weekday            time.Weekday `gorm:"not null"` // 0 - Sunday
+	OpensAt            uint         `gorm:"not null"`
+	ClosesAt           uint         `gorm:"not null"` // Up to 24:00, after OpensAt
+}
+
+// Opening hours on a specific date, like a holiday. Exceptions of a date replace regular
+// opening hours of that day. If Closed is set, the business is closed the whole day and times are ignored.
+type OpeningHoursException struct {
+	gorm.Model
+	BusinessId         uint      `gorm:"not null;index"`
+	BusinessLocationId *uint     `gorm:"index"`              // nil - exception of the business
+	Date               time.Time `gorm:"type:date;not null"` // Date in the time zone of the business, time is ignored
+	Closed             bool      `gorm:"default:false;not null"`
+	OpensAt            uint      `gorm:"not null"`
+	ClosesAt           uint      `gorm:"not null"`
+}
+
+// BusinessMember
+
+// User working for a business. A user can be a member of only one business.
+// Owner of the business is a member with BusinessMemberRoleOwner.
+type BusinessMember struct {
+	gorm.Model
+	PublicId   string                 `gorm:"uniqueIndex;not null"`
+	BusinessId uint                   `gorm:"index;not null"`
+	OwnerId    uint                   `gorm:"uniqueIndex;not null"`
+	Role       BusinessMemberRoleEnum `gorm:"not null"`
+
+	Business *Business `gorm:"foreignkey:BusinessId"`
+	User     *User     `gorm:"foreignkey:OwnerId"`
+}
+
+func (entity *BusinessMember) GetUserId(_ GormDB) (uint, error) {
+	return entity.OwnerId, nil
+}
+
+func (entity *BusinessMember) GetBusinessId(_ GormDB) (uint, error) {
+	return entity.BusinessId, nil
+}
+
+// BusinessInvitation
+
+// Invitation to become a member of a business, sent to Email. Can be accepted only by the user with that email.
+type BusinessInvitation struct {
+	gorm.Model
+	PublicId   string                 `gorm:"uniqueIndex;not null"`
+	BusinessId uint                   `gorm:"index;not null"`
+	Email      string                 `gorm:"index;not null"`
+	Role       BusinessMemberRoleEnum `gorm:"not null"`
+	Accepted   sql.NullTime
+
+	Business *Business `gorm:"foreignkey:BusinessId"`
+}
+
+func (entity *BusinessInvitation) GetBusinessId(_ GormDB) (uint, error) {
+	return entity.BusinessId, nil
+}
+
 // ItemDefinition
 
 type ItemDefinition struct {
@@ -328,17 +533,24 @@ func (entity *VirtualCard) GetBusinessId(_ GormDB) (uint, error) {
 
 // Transaction
 
+// NOTE (business_id, code) of active transactions is unique. Index is created in migrations.
+// INDEX NAME is transaction_active_code_idx
 type Transaction struct {
 	gorm.Model
 	PublicId      string               `gorm:"uniqueIndex;not null"`
 	VirtualCardId uint                 `gorm:"index:code,unique,priority:1;not null"`
+	BusinessId    uint                 `gorm:"index;not null"`
 	Code          string               `gorm:"index:code,unique,priority:2;not null"`
 	State         TransactionStateEnum `gorm:"default:STARTED;not null"`
 	AddedPoints   uint
+	ExpiresAt     sql.NullTime `gorm:"index"`
+	// Location the transaction was finalized at, nil if it was not given
+	BusinessLocationId *uint `gorm:"index"`
 
 	TransactionDetails []TransactionDetail `gorm:"foreignkey:TransactionId"`
 
-	VirtualCard *VirtualCard `gorm:"foreignkey:VirtualCardId"`
+	VirtualCard      *VirtualCard      `gorm:"foreignkey:VirtualCardId"`
+	BusinessLocation *BusinessLocation `gorm:"foreignkey:BusinessLocationId"`
 }
 
 func (entity *Transaction) GetUserId(db GormDB) (uint, error) {
@@ -350,13 +562,8 @@ func (entity *Transaction) GetUserId(db GormDB) (uint, error) {
 	return virtualCard.OwnerId, nil
 }
 
-func (entity *Transaction) GetBusinessId(db GormDB) (uint, error) {
-	var virtualCard VirtualCard
-	tx := db.First(&virtualCard, VirtualCard{Model: gorm.Model{ID: entity.VirtualCardId}})
-	if err := tx.GetError(); err != nil {
-		return 0, err
-	}
-	return virtualCard.BusinessId, nil
+func (entity *Transaction) GetBusinessId(_ GormDB) (uint, error) {
+	return entity.BusinessId, nil
 }
 
 // TransactionDetail
@@ -370,3 +577,121 @@ type TransactionDetail struct {
 	Transaction *Transaction `gorm:"foreignkey:TransactionId"`
 	OwnedItem   *OwnedItem   `gorm:"foreignkey:ItemId"`
 }
+
+// PointsLedgerEntry
+
+// Every change of VirtualCard.Points has a matching PointsLedgerEntry, created in the same database transaction.
+// Sum of Delta of all entries of a card is equal to VirtualCard.Points.
+type PointsLedgerEntry struct {
+	gorm.Model
+	VirtualCardId uint                   `gorm:"index;not null"`
+	Delta         int64                  `gorm:"not null"`
+	Reason        PointsLedgerReasonEnum `gorm:"not null"`
+	TransactionId *uint
+	OwnedItemId   *uint
+	PointsLotId   *uint
+
+	VirtualCard *VirtualCard `gorm:"foreignkey:VirtualCardId"`
+	Transaction *Transaction `gorm:"foreignkey:TransactionId"`
+	OwnedItem   *OwnedItem   `gorm:"foreignkey:OwnedItemId"`
+	PointsLot   *PointsLot   `gorm:"foreignkey:PointsLotId"`
+}
+
+func (entity *PointsLedgerEntry) GetUserId(db GormDB) (uint, error) {
+	var virtualCard VirtualCard
+	tx := db.First(&virtualCard, VirtualCard{Model: gorm.Model{ID: entity.VirtualCardId}})
+	if err := tx.GetError(); err != nil {
+		return 0, err
+	}
+	return virtualCard.OwnerId, nil
+}
+
+func (entity *PointsLedgerEntry) GetBusinessId(db GormDB) (uint, error) {
+	var virtualCard VirtualCard
+	tx := db.First(&virtualCard, VirtualCard{Model: gorm.Model{ID: entity.VirtualCardId}})
+	if err := tx.GetError(); err != nil {
+		return 0, err
+	}
+	return virtualCard.BusinessId, nil
+}
+
+// PointsLot
+
+// Points added to a virtual card at once. Points are spent from the oldest lots first and expire
+// with their lot, Business.PointsExpiryDays after the lot was created.
+// Sum of Remaining of all lots of a card is equal to VirtualCard.Points.
+type PointsLot struct {
+	gorm.Model
+	VirtualCardId uint `gorm:"index;not null"`
+	Points        uint `gorm:"not null"` // points added to the card
+	Remaining     uint `gorm:"not null"` // points not spent and not expired yet
+
+	VirtualCard *VirtualCard `gorm:"foreignkey:VirtualCardId"`
+}
+
+func (entity *PointsLot) GetUserId(db GormDB) (uint, error) {
+	var virtualCard VirtualCard
+	tx := db.First(&virtualCard, VirtualCard{Model: gorm.Model{ID: entity.VirtualCardId}})
+	if err := tx.GetError(); err != nil {
+		return 0, err
+	}
+	return virtualCard.OwnerId, nil
+}
+
+// PointsLotSpending
+
+// Points of a lot spent on an owned item. Used to give the points back to the same lots when
+// the item is refunded.
+type PointsLotSpending struct {
+	gorm.Model
+	PointsLotId uint `gorm:"index;not null"`
+	OwnedItemId uint `gorm:"index;not null"`
+	Points      uint `gorm:"not null"`
+
+	PointsLot *PointsLot `gorm:"foreignkey:PointsLotId"`
+	OwnedItem *OwnedItem `gorm:"foreignkey:OwnedItemId"`
+}
+
+// LoginAttempt
+
+// Failed login attempts of a single email or IP address, see services.LoginAttemptStore
+type LoginAttempt struct {
+	gorm.Model
+	Key         string    `gorm:"uniqueIndex;not null"` // "email:" or "ip:" followed by the email or IP address
+	Failures    uint      `gorm:"not null"`             // failed attempts since the last reset or lockout
+	LastFailure time.Time `gorm:"not null"`
+	LockedUntil sql.NullTime
+}
+
+// TotpRecoveryCode
+
+// Single use code that can be used instead of a TOTP code, when user lost their authenticator
+type TotpRecoveryCode struct {
+	gorm.Model
+	OwnerId  uint   `gorm:"index;not null"`
+	CodeHash string `gorm:"not null"` // hex encoded SHA-256 of the code
+	Used     bool   `gorm:"default:false;not null"`
+
+	User *User `gorm:"foreignkey:OwnerId"`
+}
+
+func (entity *TotpRecoveryCode) GetUserId(_ GormDB) (uint, error) {
+	return entity.OwnerId, nil
+}
+
+// EmailOutboxEntry
+
+// Email waiting to be delivered, written in the same transaction as the change that caused it.
+// Delivered by workers.EmailOutboxJob, see managers.EmailOutboxManager
+type EmailOutboxEntry struct {
+	gorm.Model
+	Recipient     string               `gorm:"not null"`
+	Subject       string               `gorm:"not null"`
+	Body          string               `gorm:"not null"` // HTML
+	TextBody      string               `gorm:"not null"` // plaintext alternative, may be empty
+	State         EmailOutboxStateEnum `gorm:"default:PENDING;not null"`
+	Attempts      uint                 `gorm:"not null"`
+	NextAttemptAt time.Time            `gorm:"not null"`
+	LastError     string               // error of the last failed attempt
+	SentAt        sql.NullTime
+}
@@ -0,0 +1,87 @@
+package database
+
+import "time"
+
+// Opening hours can close at 24:00
+const MinutesInDay = 24 * 60
+
+// Returns location of the business time zone, UTC if the time zone is not valid
+func (business *Business) Location() *time.Location {
+	location, err := time.LoadLocation(business.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Returns true if business or any of its locations has regular opening hours or exceptions.
+// Requires preloaded opening hours of the business and of its locations
+func (business *Business) HasOpeningHours() bool {
+	if len(business.OpeningHours) > 0 || len(business.OpeningHoursExceptions) > 0 {
+		return true
+	}
+	for i := range business.Locations {
+		if business.Locations[i].HasOwnOpeningHours() {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if location has opening hours or exceptions other than those of the business.
+// Requires preloaded OpeningHours and OpeningHoursExceptions of the location
+func (location *BusinessLocation) HasOwnOpeningHours() bool {
+	return len(location.OpeningHours) > 0 || len(location.OpeningHoursExceptions) > 0
+}
+
+// Checks if business is open at t - if any of its locations is open. Without preloaded locations,
+// only opening hours of the business are checked.
+// Requires preloaded opening hours of the business (only business_location_id IS NULL) and of its locations
+func (business *Business) IsOpenAt(t time.Time) bool {
+	if len(business.Locations) == 0 {
+		return isOpenAt(business.OpeningHours, business.OpeningHoursExceptions, t.In(business.Location()))
+	}
+	for i := range business.Locations {
+		if business.IsLocationOpenAt(&business.Locations[i], t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if location of business is open at t, in its own opening hours or in opening hours of the business.
+// Requires preloaded opening hours of the business (only business_location_id IS NULL) and of the location.
+// Keep in sync with location_open_at SQL function used by search
+func (business *Business) IsLocationOpenAt(location *BusinessLocation, t time.Time) bool {
+	if location.HasOwnOpeningHours() {
+		return isOpenAt(location.OpeningHours, location.OpeningHoursExceptions, t.In(business.Location()))
+	}
+	return isOpenAt(business.OpeningHours, business.OpeningHoursExceptions, t.In(business.Location()))
+}
+
+// Exceptions of the local date replace regular opening hours of that day
+func isOpenAt(hours []OpeningHours, exceptions []OpeningHoursException, local time.Time) bool {
+	minute := uint(local.Hour()*60 + local.Minute())
+	date := local.Format(time.DateOnly)
+
+	hasException := false
+	for _, exception := range exceptions {
+		if exception.Date.Format(time.DateOnly) != date {
+			continue
+		}
+		hasException = true
+		if !exception.Closed && exception.OpensAt <= minute && minute < exception.ClosesAt {
+			return true
+		}
+	}
+	if hasException {
+		return false
+	}
+
+	for _, v := range hours {
+		if v.Weekday == local.Weekday() && v.OpensAt <= minute && minute < v.ClosesAt {
+			return true
+		}
+	}
+	return false
+}
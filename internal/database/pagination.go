@@ -0,0 +1,74 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// Page of a list ordered by unique keys (keyset pagination). Cursor points just after the last
+// item of the previous page, so items added or removed in the meantime do not shift pages.
+type Page struct {
+	Cursor string // Cursor returned with the previous page, empty for the first page
+	Limit  uint
+}
+
+// Encodes keys of the last item of a page into a cursor. Cursors are opaque to clients
+func EncodeCursor(keys ...interface{}) (string, error) {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Decodes keys encoded by EncodeCursor into pointers in keys, in the same order.
+// Returns ErrInvalidCursor if the cursor is malformed or has a different number of keys
+func DecodeCursor(cursor string, keys ...interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	var rawKeys []json.RawMessage
+	if err := json.Unmarshal(data, &rawKeys); err != nil || len(rawKeys) != len(keys) {
+		return ErrInvalidCursor
+	}
+	for i, rawKey := range rawKeys {
+		if err := json.Unmarshal(rawKey, keys[i]); err != nil {
+			return ErrInvalidCursor
+		}
+	}
+	return nil
+}
+
+// Limits tx to the page of a list ordered by idColumn, ascending. One more row than
+// page.Limit is fetched, NextIdCursor uses it to check if there is a next page
+func PaginateById(tx GormDB, idColumn string, page *Page) (GormDB, error) {
+	if page.Cursor != "" {
+		var id uint
+		if err := DecodeCursor(page.Cursor, &id); err != nil {
+			return nil, err
+		}
+		tx = tx.Where(idColumn+" > ?", id)
+	}
+	return tx.Order(idColumn).Limit(int(page.Limit) + 1), nil
+}
+
+// Trims items (pointer to a slice of models) fetched with PaginateById to page.Limit and
+// returns cursor of the next page. Returns an empty cursor if this is the last page
+func NextIdCursor(items interface{}, page *Page) (string, error) {
+	slice := reflect.ValueOf(items).Elem()
+	if slice.Len() <= int(page.Limit) {
+		return "", nil
+	}
+	slice.Set(slice.Slice(0, int(page.Limit)))
+	if page.Limit == 0 {
+		return "", nil
+	}
+	last := reflect.Indirect(slice.Index(slice.Len() - 1))
+	return EncodeCursor(last.FieldByName("ID").Interface())
+}
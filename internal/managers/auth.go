@@ -1,12 +1,12 @@
 package managers
 
 import (
-	"bytes"
+	"database/sql"
 	"errors"
 	"fmt"
-	"html/template"
 	"net/mail"
 	"regexp"
+	"strings"
 	"time"
 	"unicode"
 
@@ -14,6 +14,7 @@ import (
 	"github.com/lithammer/shortuuid/v4"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	. "github.com/StampWallet/backend/internal/database"
 	. "github.com/StampWallet/backend/internal/services"
@@ -28,31 +29,164 @@ var (
 	ErrEmailExists         = errors.New("Email exists")          // Another user has the same email
 	ErrInvalidToken        = errors.New("Invalid token")         // Invalid/unknown token
 	ErrPasswordTooWeak     = errors.New("Password too weak")
+	ErrNoSuchSession       = errors.New("Session not found")
+	ErrTooManyAttempts     = errors.New("Too many login attempts")
+	ErrTotpAlreadyEnabled  = errors.New("TOTP already enabled")
+	ErrTotpNotEnabled      = errors.New("TOTP not enabled")
+	ErrTotpNotEnrolled     = errors.New("TOTP enrollment not started")
+	ErrTotpRequired        = errors.New("TOTP required by business")
+	ErrInvalidTotpCode     = errors.New("Invalid TOTP code")
+	ErrInvalidLocale       = errors.New("Invalid locale")
+	ErrEmailVerified       = errors.New("Email already verified")
+	ErrTooManyResends      = errors.New("Too many verification emails")
 	ErrUnknownError        = errors.New("Unknown error") // Unexpected error returned by external services
 )
 
+// Returned by Login when the email or IP address has to wait before trying again.
+// Wraps ErrTooManyAttempts.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (err *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, err.RetryAfter)
+}
+
+func (err *LoginThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// Returned by ResendEmailConfirmation when the user has to wait before requesting another email.
+// Wraps ErrTooManyResends.
+type ResendThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (err *ResendThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyResends, err.RetryAfter)
+}
+
+func (err *ResendThrottledError) Unwrap() error {
+	return ErrTooManyResends
+}
+
 type AuthManager interface {
 	// Creates a new user account from UserDetails struct. Returns a database object and, session token and
-	// matching token secret for the user. Sends a verification email.
+	// matching token secret for the user. Queues a verification email in the same transaction, see EmailOutbox.
 	Create(userDetails UserDetails) (*User, *Token, string, error)
 
 	// Checks if email and password match any user. If yes, returns the database object and serssion token
-	// for that user.
-	Login(email string, password string) (*User, *Token, string, error)
+	// for that user. metadata is stored with the session token.
+	// Failed attempts are counted per email and per IP address, see LoginThrottleConfig. Returns
+	// *LoginThrottledError if too many attempts failed recently.
+	// If user has TOTP enabled, returns a TokenPurposeTotpPending token instead of a session token,
+	// which has to be exchanged for a session token with VerifyTotpLogin.
+	Login(email string, password string, metadata SessionMetadata) (*User, *Token, string, error)
 
 	// Checks if token id and secret match any session token. If yes, invalidates the token.
 	Logout(tokenId string, tokenSecret string) (*User, *Token, error)
 
 	// Checks if token id and secret match any email token. If yes, invalidates the token and changes
 	// EmailVerified in user's database object to true.
+	// If the token was sent by ChangeEmail, also switches the user to their pending email. Returns
+	// ErrEmailExists if another user has taken that email in the meantime.
 	ConfirmEmail(tokenId string, tokenSecret string) (*User, error)
 
+	// Sends a new verification email to user and invalidates tokens of the previous ones.
+	// Returns ErrEmailVerified if the email is already verified, and *ResendThrottledError if user
+	// got too many verification emails recently, see emailVerificationLimit.
+	ResendEmailConfirmation(user *User) error
+
 	// Changes password of user, if oldPassword matches user.PasswordHash.
-	ChangePassword(user *User, oldPassword string, newPassword string) (*User, error)
+	// If keepSession is not nil, all other sessions of user are invalidated.
+	ChangePassword(user *User, oldPassword string, newPassword string, keepSession *Token) (*User, error)
+
+	// Stores newEmail as user.PendingEmail, if no other user has the same email. Sends a confirmation
+	// token to newEmail, which has to be passed to ConfirmEmail before user.Email is changed, and a notice
+	// with a RevertEmailChange token to the current email. Replaces earlier pending changes.
+	// If keepSession is not nil, all other sessions of user are invalidated.
+	ChangeEmail(user *User, newEmail string, keepSession *Token) (*User, error)
+
+	// Checks if token id and secret match a token sent by ChangeEmail to the old email. If yes, cancels
+	// the pending email change, or restores the old email if the change was already confirmed.
+	// All sessions of the user are invalidated, whoever requested the change should not stay logged in.
+	RevertEmailChange(tokenId string, tokenSecret string) (*User, error)
 
-	// Changes email of user, if no other user has the same email. Changes user.EmailVerified to false,
-	// sends a new verification email.
-	ChangeEmail(user *User, newEmail string) (*User, error)
+	// Changes preferred language of emails sent to user. Empty locale resets it to the default.
+	// Returns ErrInvalidLocale if locale is not a language tag.
+	ChangeLocale(user *User, locale string) (*User, error)
+
+	// Returns active session tokens of user, most recently used first.
+	GetSessions(user *User) ([]Token, error)
+
+	// Invalidates session token of user with tokenId. Returns ErrNoSuchSession if user has no
+	// active session with that id.
+	RevokeSession(user *User, tokenId string) error
+
+	// Invalidates all sessions of currentSession owner, except currentSession.
+	RevokeOtherSessions(currentSession *Token) error
+
+	// Sends an email with a password reset token to user with email. Does nothing if there is no such user,
+	// or if the user got too many password reset emails recently, see passwordResetLimit. The caller should
+	// not be able to tell if the email exists.
+	RequestPasswordReset(email string) error
+
+	// Checks if token id and secret match any password reset token. If yes, invalidates the token,
+	// changes password of the token owner to newPassword and invalidates all their sessions.
+	ResetPassword(tokenId string, tokenSecret string, newPassword string) (*User, error)
+
+	// Checks if token id and secret match a pending TOTP token returned by Login, and code is a TOTP code
+	// or unused recovery code of the token owner. If yes, invalidates the pending token and returns
+	// a session token with metadata. Failed attempts are throttled like in Login.
+	VerifyTotpLogin(tokenId string, tokenSecret string, code string, metadata SessionMetadata) (*User, *Token, string, error)
+
+	// Generates a new TOTP secret for user. Returns the secret and otpauth URI of the secret.
+	// TOTP is not enabled until ConfirmTotp is called. Returns ErrTotpAlreadyEnabled if user has TOTP enabled.
+	EnrollTotp(user *User) (string, string, error)
+
+	// Enables TOTP of user if code matches the secret from EnrollTotp. Returns recovery codes, which
+	// can be used instead of TOTP codes, once each. Only hashes of recovery codes are stored.
+	// If keepSession is not nil, all other sessions of user are invalidated.
+	ConfirmTotp(user *User, code string, keepSession *Token) ([]string, error)
+
+	// Disables TOTP of user if code is a TOTP code or unused recovery code of user. Returns ErrTotpRequired
+	// if user owns or is a member of a business that requires TOTP.
+	DisableTotp(user *User, code string) (*User, error)
+}
+
+// How long a password reset token is valid
+const passwordResetTokenTTL = time.Hour
+
+// At most passwordResetLimit password reset emails are sent to a user within passwordResetWindow
+const (
+	passwordResetLimit  = 3
+	passwordResetWindow = time.Hour
+)
+
+// How long an email verification token is valid
+const emailVerificationTokenTTL = 24 * time.Hour
+
+// At most emailVerificationLimit verification emails are sent to a user within emailVerificationWindow,
+// the one sent on registration included
+const (
+	emailVerificationLimit  = 4
+	emailVerificationWindow = time.Hour
+)
+
+// How long a pending email change can be confirmed, and how long it can be reverted from the old email
+const (
+	emailChangeTokenTTL = 24 * time.Hour
+	emailRevertTokenTTL = 7 * 24 * time.Hour
+)
+
+// Controls how failed login attempts are throttled. Attempts are counted separately for the email
+// and for the IP address of the client.
+type LoginThrottleConfig struct {
+	FreeAttempts    uint          // failed attempts that are not throttled
+	BackoffBase     time.Duration // wait time after the first throttled attempt, doubled after each next one
+	BackoffMax      time.Duration // max wait time between attempts
+	LockoutAttempts uint          // failed attempts after which the email or IP address is locked out, 0 disables lockout
+	LockoutDuration time.Duration // how long the lockout lasts, also how long failed attempts are remembered
 }
 
 type UserDetails struct {
@@ -60,31 +194,136 @@ type UserDetails struct {
 	//LastName  string
 	Email    string
 	Password string
+	Locale   string // optional, see User.Locale
 }
 
 type AuthManagerImpl struct {
-	baseServices BaseServices
-	emailService EmailService
-	tokenService TokenService
-	emailSubject string
-	emailBody    *template.Template
+	baseServices      BaseServices
+	emailOutbox       EmailOutbox
+	emailTemplates    EmailTemplates
+	tokenService      TokenService
+	loginAttemptStore LoginAttemptStore
+	loginThrottle     LoginThrottleConfig
+	totpIssuer        string
 }
 
+// LockoutDuration of LoginThrottleConfig if the configured one is not positive. Failed attempts that
+// are forgotten right away would never be throttled.
+const defaultLoginLockoutDuration = 15 * time.Minute
+
 func CreateAuthManagerImpl(baseServices BaseServices,
-	emailService EmailService, tokenService TokenService,
-	emailSubject string, emailBodyTemplate string) *AuthManagerImpl {
+	emailOutbox EmailOutbox, emailTemplates EmailTemplates, tokenService TokenService,
+	loginAttemptStore LoginAttemptStore, loginThrottle LoginThrottleConfig, totpIssuer string) *AuthManagerImpl {
+	if loginThrottle.LockoutDuration <= 0 {
+		baseServices.Logger.Printf("login lockout duration %s is not positive, using %s",
+			loginThrottle.LockoutDuration, defaultLoginLockoutDuration)
+		loginThrottle.LockoutDuration = defaultLoginLockoutDuration
+	}
+	return &AuthManagerImpl{
+		baseServices:      baseServices,
+		emailOutbox:       emailOutbox,
+		emailTemplates:    emailTemplates,
+		tokenService:      tokenService,
+		loginAttemptStore: loginAttemptStore,
+		loginThrottle:     loginThrottle,
+		totpIssuer:        totpIssuer,
+	}
+}
+
+// Returns LoginAttemptStore keys of email and ipAddress. Emails are compared case insensitively here,
+// so that changing the case does not reset the counter.
+func loginAttemptKeys(email string, ipAddress string) []string {
+	keys := []string{"email:" + strings.ToLower(email)}
+	if ipAddress != "" {
+		keys = append(keys, "ip:"+ipAddress)
+	}
+	return keys
+}
 
-	tmpl, err := template.New("email_verification_body").Parse(emailBodyTemplate)
+// Returns how long the caller has to wait before the next login attempt of key is allowed.
+// Returns 0 if the attempt is allowed now.
+func (manager *AuthManagerImpl) loginRetryAfter(key string, now time.Time) (time.Duration, error) {
+	attempts, err := manager.loginAttemptStore.Get(key)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
-	return &AuthManagerImpl{
-		baseServices: baseServices,
-		emailService: emailService,
-		tokenService: tokenService,
-		emailSubject: emailSubject,
-		emailBody:    tmpl,
+	if attempts.LockedUntil.After(now) {
+		return attempts.LockedUntil.Sub(now), nil
+	}
+
+	config := manager.loginThrottle
+	if attempts.Failures <= config.FreeAttempts || now.Sub(attempts.LastFailure) > config.LockoutDuration {
+		return 0, nil
 	}
+	delay := config.BackoffBase
+	for i := config.FreeAttempts + 1; i < attempts.Failures && delay < config.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > config.BackoffMax {
+		delay = config.BackoffMax
+	}
+	if next := attempts.LastFailure.Add(delay); next.After(now) {
+		return next.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// Returns *LoginThrottledError if any of keys has to wait before the next login attempt
+func (manager *AuthManagerImpl) checkLoginThrottle(keys []string) error {
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		keyRetryAfter, err := manager.loginRetryAfter(key, now)
+		if err != nil {
+			return fmt.Errorf("%s failed to get login attempts: %+v", CallerFilename(), err)
+		}
+		if keyRetryAfter > retryAfter {
+			retryAfter = keyRetryAfter
+		}
+	}
+	if retryAfter > 0 {
+		return &LoginThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Records a failed login attempt of all keys and locks out keys that failed too many times.
+// If user is not nil, they are notified when their email gets locked out.
+func (manager *AuthManagerImpl) recordLoginFailure(keys []string, user *User) error {
+	config := manager.loginThrottle
+	for _, key := range keys {
+		attempts, err := manager.loginAttemptStore.RecordFailure(key, config.LockoutDuration)
+		if err != nil {
+			return err
+		}
+		if config.LockoutAttempts == 0 || attempts.Failures < config.LockoutAttempts {
+			continue
+		}
+
+		until := time.Now().Add(config.LockoutDuration)
+		if err := manager.loginAttemptStore.Lock(key, until); err != nil {
+			return err
+		}
+		if user == nil || !strings.HasPrefix(key, "email:") {
+			continue
+		}
+
+		// The lockout is already in place, failing to notify the user does not fail the attempt
+		message, err := manager.emailTemplates.Render(EmailKindLockout, user.Locale, struct {
+			Until time.Time
+		}{
+			Until: until,
+		})
+		if err != nil {
+			manager.baseServices.Logger.Printf("%s failed to render lockout email: %+v", CallerFilename(), err)
+			continue
+		}
+		err = manager.emailOutbox.Enqueue(user.Email, *message)
+		if err != nil {
+			manager.baseServices.Logger.Printf("%s failed to queue lockout email: %+v", CallerFilename(), err)
+		}
+	}
+	return nil
 }
 
 func checkPassword(password string) bool {
@@ -109,6 +348,14 @@ func (manager *AuthManagerImpl) Create(userDetails UserDetails) (*User, *Token,
 		return nil, nil, "", ErrInvalidEmail
 	}
 
+	locale := userDetails.Locale
+	if locale != "" {
+		var ok bool
+		if locale, ok = NormalizeLocale(locale); !ok {
+			return nil, nil, "", ErrInvalidLocale
+		}
+	}
+
 	var existingUser User
 	tx := manager.baseServices.Database.Begin()
 
@@ -149,6 +396,7 @@ func (manager *AuthManagerImpl) Create(userDetails UserDetails) (*User, *Token,
 		PublicId:     shortuuid.New(),
 		Email:        userDetails.Email,
 		PasswordHash: string(hash),
+		Locale:       locale,
 		//FirstName:     userDetails.FirstName,
 		//LastName:      userDetails.LastName,
 		EmailVerified: false,
@@ -177,7 +425,7 @@ func (manager *AuthManagerImpl) Create(userDetails UserDetails) (*User, *Token,
 	}
 
 	// Create token for email verification
-	emailToken, emailSecret, err := tokenTx.Create(&user, TokenPurposeEmail, time.Now().Add(24*time.Hour))
+	emailToken, emailSecret, err := tokenTx.Create(&user, TokenPurposeEmail, time.Now().Add(emailVerificationTokenTTL))
 	if err != nil {
 		tx.Rollback()
 		// perhaps delete the user instead? the account will work, its created and shit. but user will have to
@@ -194,21 +442,26 @@ func (manager *AuthManagerImpl) Create(userDetails UserDetails) (*User, *Token,
 	}
 
 	// Send email verification token
-	buf := new(bytes.Buffer)
-	templateErr := manager.emailBody.Execute(buf, struct {
+	message, err := manager.emailTemplates.Render(EmailKindVerification, user.Locale, struct {
 		Token string
 	}{
 		Token: emailToken.TokenId + ":" + emailSecret,
 	})
 	if err != nil {
 		tx.Rollback()
-		return nil, nil, "", fmt.Errorf("%s failed to get email body: %+v", CallerFilename(), templateErr)
+		return nil, nil, "", fmt.Errorf("%s failed to render email: %+v", CallerFilename(), err)
 	}
-	println(buf.String())
-	mailErr := manager.emailService.Send(userDetails.Email, manager.emailSubject, buf.String())
+	// The email is only sent after the transaction is committed, see EmailOutboxManager
+	outboxTx, err := manager.emailOutbox.WithTransaction(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, "", fmt.Errorf("%s failed to call EmailOutbox.WithTransaction %+v",
+			CallerFilename(), err)
+	}
+	mailErr := outboxTx.Enqueue(userDetails.Email, *message)
 	if mailErr != nil {
 		tx.Rollback()
-		return nil, nil, "", fmt.Errorf("%s failed to send email, emailservice error: %+v", CallerFilename(), mailErr)
+		return nil, nil, "", fmt.Errorf("%s failed to queue email, emailoutbox error: %+v", CallerFilename(), mailErr)
 	}
 
 	// Commit transaction
@@ -220,13 +473,23 @@ func (manager *AuthManagerImpl) Create(userDetails UserDetails) (*User, *Token,
 	return &user, sessionToken, sessionSecret, nil
 }
 
-func (manager *AuthManagerImpl) Login(email string, password string) (*User, *Token, string, error) {
+func (manager *AuthManagerImpl) Login(email string, password string,
+	metadata SessionMetadata) (*User, *Token, string, error) {
 	var user User
 
+	// Check if the email or IP address is throttled, before spending time on bcrypt
+	keys := loginAttemptKeys(email, metadata.IpAddress)
+	if err := manager.checkLoginThrottle(keys); err != nil {
+		return nil, nil, "", err
+	}
+
 	// Find user
 	tx := manager.baseServices.Database.First(&user, User{Email: email})
 	err := tx.GetError()
 	if err == gorm.ErrRecordNotFound {
+		if err := manager.recordLoginFailure(keys, nil); err != nil {
+			return nil, nil, "", fmt.Errorf("%s failed to record login attempt: %+v", CallerFilename(), err)
+		}
 		return nil, nil, "", ErrInvalidLogin
 	} else if err != nil {
 		return nil, nil, "", err
@@ -235,13 +498,30 @@ func (manager *AuthManagerImpl) Login(email string, password string) (*User, *To
 	// Check password
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 	if err == bcrypt.ErrMismatchedHashAndPassword {
+		if err := manager.recordLoginFailure(keys, &user); err != nil {
+			return nil, nil, "", fmt.Errorf("%s failed to record login attempt: %+v", CallerFilename(), err)
+		}
 		return nil, nil, "", ErrInvalidLogin
 	} else if err != nil {
 		return nil, nil, "", err
 	}
 
-	// Create session token
-	sessionToken, sessionSecret, err := manager.tokenService.Create(&user, TokenPurposeSession, time.Now().Add(time.Hour))
+	// Second factor is required, failed attempts are not reset until it is verified
+	if user.TotpEnabled {
+		pendingToken, pendingSecret, err := manager.tokenService.Create(&user, TokenPurposeTotpPending,
+			time.Now().Add(totpPendingTokenTTL))
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return &user, pendingToken, pendingSecret, nil
+	}
+
+	// Failures of the IP address are not reset, knowing one password should not help guessing others
+	if err := manager.loginAttemptStore.Reset(keys[0]); err != nil {
+		return nil, nil, "", fmt.Errorf("%s failed to reset login attempts: %+v", CallerFilename(), err)
+	}
+
+	sessionToken, sessionSecret, err := manager.createSession(&user, metadata)
 	if err != nil {
 		return nil, nil, "", err
 	}
@@ -249,6 +529,19 @@ func (manager *AuthManagerImpl) Login(email string, password string) (*User, *To
 	return &user, sessionToken, sessionSecret, nil
 }
 
+// Creates session token of user with metadata
+func (manager *AuthManagerImpl) createSession(user *User, metadata SessionMetadata) (*Token, string, error) {
+	sessionToken, sessionSecret, err := manager.tokenService.Create(user, TokenPurposeSession, time.Now().Add(time.Hour))
+	if err != nil {
+		return nil, "", err
+	}
+	sessionToken, err = manager.tokenService.SetSessionMetadata(sessionToken, metadata)
+	if err != nil {
+		return nil, "", err
+	}
+	return sessionToken, sessionSecret, nil
+}
+
 func (manager *AuthManagerImpl) Logout(tokenId string, tokenSecret string) (*User, *Token, error) {
 	// Find token
 	token, err := manager.tokenService.Check(tokenId, tokenSecret)
@@ -283,7 +576,7 @@ func (manager *AuthManagerImpl) ConfirmEmail(tokenId string, tokenSecret string)
 	}
 
 	// Check if token is valid
-	if token.TokenPurpose != TokenPurposeEmail {
+	if token.TokenPurpose != TokenPurposeEmail && token.TokenPurpose != TokenPurposeEmailChange {
 		tx.Rollback()
 		return nil, ErrInvalidTokenPurpose
 	}
@@ -292,10 +585,27 @@ func (manager *AuthManagerImpl) ConfirmEmail(tokenId string, tokenSecret string)
 		return nil, ErrInvalidToken
 	}
 
+	// Switch to the pending email, the token proves the user owns it
+	if token.TokenPurpose == TokenPurposeEmailChange {
+		if !token.User.PendingEmail.Valid {
+			tx.Rollback()
+			return nil, ErrInvalidToken
+		}
+		token.User.PreviousEmail = sql.NullString{String: token.User.Email, Valid: true}
+		token.User.Email = token.User.PendingEmail.String
+		token.User.PendingEmail = sql.NullString{}
+	}
+
 	// Change email verification status
 	token.User.EmailVerified = true
 	txSave := tx.Save(token.User)
-	if err = txSave.GetError(); err != nil {
+	err = txSave.GetError()
+	// Another user took the pending email
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		tx.Rollback()
+		return nil, ErrEmailExists
+	} else if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
@@ -316,7 +626,69 @@ func (manager *AuthManagerImpl) ConfirmEmail(tokenId string, tokenSecret string)
 	return token.User, nil
 }
 
-func (manager *AuthManagerImpl) ChangePassword(user *User, oldPassword string, newPassword string) (*User, error) {
+func (manager *AuthManagerImpl) ResendEmailConfirmation(user *User) error {
+	return manager.baseServices.Database.Transaction(func(db GormDB) error {
+		// Lock the user, so that concurrent resends are all counted
+		var lockedUser User
+		tx := db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&lockedUser, user.ID)
+		if err := tx.GetError(); err != nil {
+			return fmt.Errorf("%s failed to find user, database error: %+v", CallerFilename(), err)
+		}
+		if lockedUser.EmailVerified {
+			return ErrEmailVerified
+		}
+
+		tokenTx, err := manager.tokenService.WithTransaction(db)
+		if err != nil {
+			return fmt.Errorf("%s failed to call TokenService.WithTransaction %+v", CallerFilename(), err)
+		}
+
+		// Recalled tokens count too, every one of them was sent
+		now := time.Now()
+		sent, err := tokenTx.GetCreatedSince(&lockedUser, TokenPurposeEmail, now.Add(-emailVerificationWindow))
+		if err != nil {
+			return fmt.Errorf("%s failed to get verification tokens: %+v", CallerFilename(), err)
+		}
+		if len(sent) >= emailVerificationLimit {
+			// Another email can be sent when the oldest one counted towards the limit leaves the window
+			oldest := sent[len(sent)-emailVerificationLimit]
+			return &ResendThrottledError{RetryAfter: oldest.CreatedAt.Add(emailVerificationWindow).Sub(now)}
+		}
+
+		// Only the newest verification token can be used
+		if err := tokenTx.InvalidateAll(&lockedUser, TokenPurposeEmail); err != nil {
+			return fmt.Errorf("%s failed to invalidate verification tokens: %+v", CallerFilename(), err)
+		}
+		emailToken, emailSecret, err := tokenTx.Create(&lockedUser, TokenPurposeEmail,
+			now.Add(emailVerificationTokenTTL))
+		if err != nil {
+			return fmt.Errorf("%s failed to create verification token, tokenservice error: %+v",
+				CallerFilename(), err)
+		}
+
+		// Send email verification token
+		message, err := manager.emailTemplates.Render(EmailKindVerification, lockedUser.Locale, struct {
+			Token string
+		}{
+			Token: emailToken.TokenId + ":" + emailSecret,
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed to render email: %+v", CallerFilename(), err)
+		}
+		outboxTx, err := manager.emailOutbox.WithTransaction(db)
+		if err != nil {
+			return fmt.Errorf("%s failed to call EmailOutbox.WithTransaction %+v", CallerFilename(), err)
+		}
+		err = outboxTx.Enqueue(lockedUser.Email, *message)
+		if err != nil {
+			return fmt.Errorf("%s failed to queue email, emailoutbox error: %+v", CallerFilename(), err)
+		}
+		return nil
+	})
+}
+
+func (manager *AuthManagerImpl) ChangePassword(user *User, oldPassword string, newPassword string,
+	keepSession *Token) (*User, error) {
 	// Check if old password matches
 	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword))
 	if err == bcrypt.ErrMismatchedHashAndPassword {
@@ -342,60 +714,361 @@ func (manager *AuthManagerImpl) ChangePassword(user *User, oldPassword string, n
 		return nil, err
 	}
 
+	// Log out everywhere else
+	if keepSession != nil {
+		if err := manager.tokenService.InvalidateOthers(keepSession); err != nil {
+			return nil, err
+		}
+	}
+
 	return user, nil
 }
 
-func (manager *AuthManagerImpl) ChangeEmail(user *User, newEmail string) (*User, error) {
+func (manager *AuthManagerImpl) ChangeEmail(user *User, newEmail string, keepSession *Token) (*User, error) {
 	// Check if email is valid
 	_, err := mail.ParseAddress(newEmail)
 	if err != nil {
 		return nil, ErrInvalidEmail
 	}
 
-	// Update user in the db
-	user.Email = newEmail
-	user.EmailVerified = false
-
-	tx := manager.baseServices.Database.Begin()
-	txSave := tx.Save(&user)
-	dbErr := txSave.GetError()
-	// Another user has the same email
-	var pgErr *pgconn.PgError
-	if errors.As(dbErr, &pgErr) && pgErr.Code == "23505" {
-		tx.Rollback()
-		manager.baseServices.Logger.Printf("gorm error when changing email %s\n", dbErr)
+	// Check if email is taken. The unique index is checked again when the change is confirmed
+	if newEmail == user.Email {
 		return nil, ErrEmailExists
-	} else if dbErr != nil {
-		tx.Rollback()
-		return nil, dbErr
+	}
+	var existingUser User
+	err = manager.baseServices.Database.First(&existingUser, User{Email: newEmail}).GetError()
+	if err == nil {
+		return nil, ErrEmailExists
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("%s failed to find user, database error: %+v", CallerFilename(), err)
 	}
 
-	// Get TokenService that executes queries within a transaction
-	tokenTx, err := manager.tokenService.WithTransaction(tx)
+	pendingEmail := sql.NullString{String: newEmail, Valid: true}
+	err = manager.baseServices.Database.Transaction(func(db GormDB) error {
+		tx := db.Model(user).Update("pending_email", pendingEmail)
+		if err := tx.GetError(); err != nil {
+			return fmt.Errorf("%s failed to update user, database error: %+v", CallerFilename(), err)
+		}
+
+		tokenTx, err := manager.tokenService.WithTransaction(db)
+		if err != nil {
+			return fmt.Errorf("%s failed to call TokenService.WithTransaction %+v", CallerFilename(), err)
+		}
+
+		// Only tokens of the newest change can be used
+		if err := tokenTx.InvalidateAll(user, TokenPurposeEmailChange); err != nil {
+			return fmt.Errorf("%s failed to invalidate email change tokens: %+v", CallerFilename(), err)
+		}
+		if err := tokenTx.InvalidateAll(user, TokenPurposeEmailRevert); err != nil {
+			return fmt.Errorf("%s failed to invalidate email revert tokens: %+v", CallerFilename(), err)
+		}
+		changeToken, changeSecret, err := tokenTx.Create(user, TokenPurposeEmailChange,
+			time.Now().Add(emailChangeTokenTTL))
+		if err != nil {
+			return fmt.Errorf("%s failed to create email change token, tokenservice error: %+v",
+				CallerFilename(), err)
+		}
+		revertToken, revertSecret, err := tokenTx.Create(user, TokenPurposeEmailRevert,
+			time.Now().Add(emailRevertTokenTTL))
+		if err != nil {
+			return fmt.Errorf("%s failed to create email revert token, tokenservice error: %+v",
+				CallerFilename(), err)
+		}
+
+		// Log out everywhere else
+		if keepSession != nil {
+			if err := tokenTx.InvalidateOthers(keepSession); err != nil {
+				return fmt.Errorf("%s failed to invalidate sessions: %+v", CallerFilename(), err)
+			}
+		}
+
+		// Send confirmation token to the new email and revert token to the old one
+		confirmation, err := manager.emailTemplates.Render(EmailKindEmailChange, user.Locale, struct {
+			Token string
+		}{
+			Token: changeToken.TokenId + ":" + changeSecret,
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed to render email: %+v", CallerFilename(), err)
+		}
+		notice, err := manager.emailTemplates.Render(EmailKindEmailChangeNotice, user.Locale, struct {
+			Token    string
+			NewEmail string
+		}{
+			Token:    revertToken.TokenId + ":" + revertSecret,
+			NewEmail: newEmail,
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed to render email: %+v", CallerFilename(), err)
+		}
+		outboxTx, err := manager.emailOutbox.WithTransaction(db)
+		if err != nil {
+			return fmt.Errorf("%s failed to call EmailOutbox.WithTransaction %+v", CallerFilename(), err)
+		}
+		if err := outboxTx.Enqueue(newEmail, *confirmation); err != nil {
+			return fmt.Errorf("%s failed to queue email, emailoutbox error: %+v", CallerFilename(), err)
+		}
+		if err := outboxTx.Enqueue(user.Email, *notice); err != nil {
+			return fmt.Errorf("%s failed to queue email, emailoutbox error: %+v", CallerFilename(), err)
+		}
+		return nil
+	})
 	if err != nil {
-		tx.Rollback()
 		return nil, err
 	}
 
-	// Create email verification token
-	emailToken, emailSecret, err := tokenTx.Create(user, TokenPurposeEmail, time.Now().Add(24*time.Hour))
+	user.PendingEmail = pendingEmail
+	return user, nil
+}
+
+func (manager *AuthManagerImpl) RevertEmailChange(tokenId string, tokenSecret string) (*User, error) {
+	var user *User
+	err := manager.baseServices.Database.Transaction(func(db GormDB) error {
+		tokenTx, err := manager.tokenService.WithTransaction(db)
+		if err != nil {
+			return fmt.Errorf("%s failed to call TokenService.WithTransaction %+v", CallerFilename(), err)
+		}
+
+		// Find email revert token
+		token, err := tokenTx.Check(tokenId, tokenSecret)
+		if err == ErrUnknownToken || err == ErrTokenUsed || err == ErrTokenExpired {
+			return ErrInvalidToken
+		} else if err != nil {
+			return err
+		}
+		if token.TokenPurpose != TokenPurposeEmailRevert {
+			return ErrInvalidTokenPurpose
+		}
+		user = token.User
+
+		// Cancel the pending change, or undo the confirmed one
+		var changes map[string]any
+		reverted := *user
+		if user.PendingEmail.Valid {
+			changes = map[string]any{"pending_email": sql.NullString{}}
+			reverted.PendingEmail = sql.NullString{}
+		} else if user.PreviousEmail.Valid {
+			changes = map[string]any{
+				"email":          user.PreviousEmail.String,
+				"email_verified": true,
+				"previous_email": sql.NullString{},
+			}
+			reverted.Email = user.PreviousEmail.String
+			reverted.EmailVerified = true
+			reverted.PreviousEmail = sql.NullString{}
+		} else {
+			return ErrInvalidToken
+		}
+		tx := db.Model(user).Updates(changes)
+		err = tx.GetError()
+		// Another user took the old email
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrEmailExists
+		} else if err != nil {
+			return fmt.Errorf("%s failed to update user, database error: %+v", CallerFilename(), err)
+		}
+		*user = reverted
+
+		// Whoever requested the change should not stay logged in or finish it
+		for _, purpose := range []TokenPurposeEnum{
+			TokenPurposeSession, TokenPurposePasswordReset, TokenPurposeEmailChange, TokenPurposeEmailRevert,
+		} {
+			if err := tokenTx.InvalidateAll(user, purpose); err != nil {
+				return fmt.Errorf("%s failed to invalidate tokens: %+v", CallerFilename(), err)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		tx.Rollback()
 		return nil, err
 	}
+	return user, nil
+}
 
-	// Send email verification token
-	mailError := manager.emailService.Send(user.Email, "test", "test "+emailToken.TokenId+":"+emailSecret)
-	if mailError != nil {
-		tx.Rollback()
-		return nil, err
+func (manager *AuthManagerImpl) ChangeLocale(user *User, locale string) (*User, error) {
+	if locale != "" {
+		var ok bool
+		if locale, ok = NormalizeLocale(locale); !ok {
+			return nil, ErrInvalidLocale
+		}
 	}
 
-	// Commit transaction
-	if err = tx.Commit().GetError(); err != nil {
-		tx.Rollback()
+	user.Locale = locale
+	tx := manager.baseServices.Database.Model(user).Update("locale", locale)
+	if err := tx.GetError(); err != nil {
+		return nil, fmt.Errorf("%s failed to update user, database error: %+v", CallerFilename(), err)
+	}
+	return user, nil
+}
+
+func (manager *AuthManagerImpl) GetSessions(user *User) ([]Token, error) {
+	sessions, err := manager.tokenService.GetActive(user, TokenPurposeSession)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to get sessions, tokenservice error: %+v", CallerFilename(), err)
+	}
+	return sessions, nil
+}
+
+func (manager *AuthManagerImpl) RevokeSession(user *User, tokenId string) error {
+	sessions, err := manager.GetSessions(user)
+	if err != nil {
+		return err
+	}
+
+	for i := range sessions {
+		if sessions[i].TokenId != tokenId {
+			continue
+		}
+		if _, err := manager.tokenService.Invalidate(&sessions[i]); err != nil {
+			return fmt.Errorf("%s failed to invalidate session, tokenservice error: %+v", CallerFilename(), err)
+		}
+		return nil
+	}
+	return ErrNoSuchSession
+}
+
+func (manager *AuthManagerImpl) RevokeOtherSessions(currentSession *Token) error {
+	if currentSession.TokenPurpose != TokenPurposeSession {
+		return ErrInvalidTokenPurpose
+	}
+	if err := manager.tokenService.InvalidateOthers(currentSession); err != nil {
+		return fmt.Errorf("%s failed to invalidate sessions, tokenservice error: %+v", CallerFilename(), err)
+	}
+	return nil
+}
+
+func (manager *AuthManagerImpl) RequestPasswordReset(email string) error {
+	// Check if email is valid. Unknown and invalid emails are not reported to the caller
+	if _, err := mail.ParseAddress(email); err != nil {
+		return nil
+	}
+
+	// Find user
+	var user User
+	tx := manager.baseServices.Database.First(&user, User{Email: email})
+	err := tx.GetError()
+	if err == gorm.ErrRecordNotFound {
+		manager.baseServices.Logger.Printf("password reset requested for unknown email")
+		return hashDummyTokenSecret()
+	} else if err != nil {
+		return fmt.Errorf("%s failed to find user, database error: %+v", CallerFilename(), err)
+	}
+
+	return manager.baseServices.Database.Transaction(func(db GormDB) error {
+		// Lock the user, so that concurrent requests are all counted
+		var lockedUser User
+		tx := db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&lockedUser, user.ID)
+		if err := tx.GetError(); err != nil {
+			return fmt.Errorf("%s failed to find user, database error: %+v", CallerFilename(), err)
+		}
+
+		tokenTx, err := manager.tokenService.WithTransaction(db)
+		if err != nil {
+			return fmt.Errorf("%s failed to call TokenService.WithTransaction %+v", CallerFilename(), err)
+		}
+
+		// Recalled tokens count too, every one of them was sent
+		now := time.Now()
+		sent, err := tokenTx.GetCreatedSince(&lockedUser, TokenPurposePasswordReset, now.Add(-passwordResetWindow))
+		if err != nil {
+			return fmt.Errorf("%s failed to get password reset tokens: %+v", CallerFilename(), err)
+		}
+		if len(sent) >= passwordResetLimit {
+			// Not reported either, otherwise the caller could tell that the email exists
+			manager.baseServices.Logger.Printf("password reset throttled for user %d", lockedUser.ID)
+			return hashDummyTokenSecret()
+		}
+
+		// Only the newest password reset token can be used
+		if err := tokenTx.InvalidateAll(&lockedUser, TokenPurposePasswordReset); err != nil {
+			return fmt.Errorf("%s failed to invalidate password reset tokens: %+v", CallerFilename(), err)
+		}
+		resetToken, resetSecret, err := tokenTx.Create(&lockedUser, TokenPurposePasswordReset,
+			now.Add(passwordResetTokenTTL))
+		if err != nil {
+			return fmt.Errorf("%s failed to create password reset token, tokenservice error: %+v",
+				CallerFilename(), err)
+		}
+
+		// Queue password reset token, sending the email does not affect the response time
+		message, err := manager.emailTemplates.Render(EmailKindPasswordReset, lockedUser.Locale, struct {
+			Token string
+		}{
+			Token: resetToken.TokenId + ":" + resetSecret,
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed to render email: %+v", CallerFilename(), err)
+		}
+		outboxTx, err := manager.emailOutbox.WithTransaction(db)
+		if err != nil {
+			return fmt.Errorf("%s failed to call EmailOutbox.WithTransaction %+v", CallerFilename(), err)
+		}
+		err = outboxTx.Enqueue(lockedUser.Email, *message)
+		if err != nil {
+			return fmt.Errorf("%s failed to queue email, emailoutbox error: %+v", CallerFilename(), err)
+		}
+		return nil
+	})
+}
+
+// Hashes a random secret the same way TokenService.Create does. Used where no token is created,
+// so that the response takes about as long as when it is.
+func hashDummyTokenSecret() error {
+	if _, err := bcrypt.GenerateFromPassword([]byte(shortuuid.New()), 10); err != nil {
+		return fmt.Errorf("%s bcrypt failed to generate password: %+v", CallerFilename(), err)
+	}
+	return nil
+}
+
+func (manager *AuthManagerImpl) ResetPassword(tokenId string, tokenSecret string, newPassword string) (*User, error) {
+	// Check the password first, so that the token is not used up by a weak password
+	if !checkPassword(newPassword) {
+		return nil, ErrPasswordTooWeak
+	}
+
+	newHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), 10)
+	if err != nil {
 		return nil, err
 	}
 
+	var user *User
+	err = manager.baseServices.Database.Transaction(func(db GormDB) error {
+		tokenTx, err := manager.tokenService.WithTransaction(db)
+		if err != nil {
+			return fmt.Errorf("%s failed to call TokenService.WithTransaction %+v", CallerFilename(), err)
+		}
+
+		// Find password reset token
+		token, err := tokenTx.Check(tokenId, tokenSecret)
+		if err == ErrUnknownToken || err == ErrTokenUsed || err == ErrTokenExpired {
+			return ErrInvalidToken
+		} else if err != nil {
+			return err
+		}
+		if token.TokenPurpose != TokenPurposePasswordReset {
+			return ErrInvalidTokenPurpose
+		}
+		user = token.User
+
+		// Update password in the database
+		user.PasswordHash = string(newHash)
+		tx := db.Model(user).Update("password_hash", user.PasswordHash)
+		if err := tx.GetError(); err != nil {
+			return fmt.Errorf("%s failed to update password, database error: %+v", CallerFilename(), err)
+		}
+
+		// Whoever knew the old password should not stay logged in
+		if err := tokenTx.InvalidateAll(user, TokenPurposeSession); err != nil {
+			return fmt.Errorf("%s failed to invalidate sessions: %+v", CallerFilename(), err)
+		}
+		if err := tokenTx.InvalidateAll(user, TokenPurposePasswordReset); err != nil {
+			return fmt.Errorf("%s failed to invalidate password reset tokens: %+v", CallerFilename(), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return user, nil
 }
@@ -1,11 +1,15 @@
 package managers
 
 import (
+	"database/sql"
+	"errors"
 	"log"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
@@ -42,15 +46,62 @@ type tokenMatcher struct {
 	Recalled     *bool
 }
 
+// Subset of services.EmailMessage that allows to check if some keys match using StructMatcher
+// nil == ignore key
+type emailMessageMatcher struct {
+	Subject  *string
+	HTMLBody *string
+	TextBody *string
+}
+
+// Returns EmailTemplates with simple templates of every email kind, in english and polish.
+// Only verification and password reset emails have plaintext alternatives.
+func getTestEmailTemplates() *EmailTemplateRegistry {
+	templates, err := LoadEmailTemplateRegistry(fstest.MapFS{
+		"en/verification.subject.txt":        {Data: []byte("verification")},
+		"en/verification.html":               {Data: []byte("{{ .Token }}")},
+		"en/verification.txt":                {Data: []byte("text {{ .Token }}")},
+		"en/password_reset.subject.txt":      {Data: []byte("password reset")},
+		"en/password_reset.html":             {Data: []byte("{{ .Token }}")},
+		"en/password_reset.txt":              {Data: []byte("text {{ .Token }}")},
+		"en/lockout.subject.txt":             {Data: []byte("lockout")},
+		"en/lockout.html":                    {Data: []byte("{{ .Until }}")},
+		"en/business_invitation.subject.txt": {Data: []byte("invitation")},
+		"en/business_invitation.html":        {Data: []byte("invited to {{ .BusinessName }} as {{ .Role }}")},
+		"en/email_change.subject.txt":        {Data: []byte("email change")},
+		"en/email_change.html":               {Data: []byte("{{ .Token }}")},
+		"en/email_change_notice.subject.txt": {Data: []byte("email change notice")},
+		"en/email_change_notice.html":        {Data: []byte("{{ .NewEmail }} {{ .Token }}")},
+		"pl/verification.subject.txt":        {Data: []byte("weryfikacja")},
+		"pl/verification.html":               {Data: []byte("{{ .Token }}")},
+		"pl/business_invitation.subject.txt": {Data: []byte("zaproszenie")},
+		"pl/business_invitation.html":        {Data: []byte("zaproszenie do {{ .BusinessName }} jako {{ .Role }}")},
+	}, "en", "http://localhost:8080/")
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}
+
 func getAuthManager(ctrl *gomock.Controller) (*AuthManagerImpl, error) {
-	return &AuthManagerImpl{
+	return CreateAuthManagerImpl(
 		BaseServices{
 			Logger:   log.Default(),
 			Database: NewMockGormDB(ctrl),
 		},
-		NewMockEmailService(ctrl),
+		NewMockEmailOutbox(ctrl),
+		getTestEmailTemplates(),
 		NewMockTokenService(ctrl),
-	}, nil
+		CreateInMemoryLoginAttemptStore(),
+		LoginThrottleConfig{
+			FreeAttempts:    2,
+			BackoffBase:     time.Minute,
+			BackoffMax:      time.Hour,
+			LockoutAttempts: 4,
+			LockoutDuration: time.Hour,
+		},
+		"StampWallet",
+	), nil
 }
 
 // Returns example user model
@@ -297,12 +348,20 @@ func TestAuthManagerCreate(t *testing.T) {
 		}, "sessionSecret", nil)
 
 	//TODO subject and body probably should be tested too
-	manager.emailService.(*MockEmailService).
+	manager.emailOutbox.(*MockEmailOutbox).
+		EXPECT().
+		WithTransaction(db).
+		Return(manager.emailOutbox, nil)
+
+	manager.emailOutbox.(*MockEmailOutbox).
 		EXPECT().
-		Send(
+		Enqueue(
 			gomock.Eq("test@example.com"),
-			gomock.Any(),
-			gomock.Any())
+			&StructMatcher{emailMessageMatcher{
+				Subject:  Ptr("verification"),
+				HTMLBody: Ptr(":emailSecret"),
+				TextBody: Ptr("text :emailSecret"),
+			}})
 
 	user, token, secret, err := manager.Create(
 		UserDetails{
@@ -345,6 +404,24 @@ func TestAuthManagerCreateWithInvalidEmail(t *testing.T) {
 	require.Nilf(t, user, "manager.Create should return nil user")
 }
 
+// Tests if AuthManagerImpl.Create rejects locales that are not language tags
+func TestAuthManagerCreateWithInvalidLocale(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	user, _, _, err := manager.Create(
+		UserDetails{
+			Email:    "test@example.com",
+			Password: "zaq1@WSX",
+			Locale:   "polish please",
+		},
+	)
+
+	require.ErrorIsf(t, err, ErrInvalidLocale, "manager.Create should return InvalidLocale error")
+	require.Nilf(t, user, "manager.Create should return nil user")
+}
+
 // Tests if AuthManagerImpl.Create works correctly if user with the same email exists
 func TestAuthManagerCreateWithExistingEmail(t *testing.T) {
 	ctrl := gomock.NewController(t)
@@ -398,8 +475,16 @@ func TestAuthManagerLogin(t *testing.T) {
 		DoAndReturn(func(user *User, arg1 interface{}, arg2 interface{}) (*Token, string, error) {
 			return &Token{OwnerId: user.ID, TokenId: "test", TokenHash: "test", TokenPurpose: TokenPurposeSession}, "sessionSecret", nil
 		})
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		SetSessionMetadata(gomock.Any(), SessionMetadata{UserAgent: "test agent", IpAddress: "192.0.2.1"}).
+		DoAndReturn(func(token *Token, metadata SessionMetadata) (*Token, error) {
+			token.UserAgent = metadata.UserAgent
+			token.IpAddress = metadata.IpAddress
+			return token, nil
+		})
 
-	user, token, sessionSecret, err := manager.Login("test@example.com", "zaq1@WSX")
+	user, token, sessionSecret, err := manager.Login("test@example.com", "zaq1@WSX", SessionMetadata{UserAgent: "test agent", IpAddress: "192.0.2.1"})
 	require.Nilf(t, err, "manager.Login should return a nil error")
 	require.NotNilf(t, user, "manager.Login should not return a nil user")
 	require.NotNilf(t, token, "manager.Login should not return a nil token")
@@ -410,6 +495,8 @@ func TestAuthManagerLogin(t *testing.T) {
 	assert.Equal(t, user.ID, token.OwnerId, "Invalid token owner id")
 	assert.Equal(t, TokenPurposeSession, token.TokenPurpose, "Invalid token purpose")
 	assert.Equal(t, "sessionSecret", sessionSecret, "Invalid session secret")
+	assert.Equal(t, "test agent", token.UserAgent, "Invalid token user agent")
+	assert.Equal(t, "192.0.2.1", token.IpAddress, "Invalid token ip address")
 }
 
 // Asserts that user, token and sesessionSecret are nil, error is InvalidLogin - user failed to login
@@ -437,7 +524,7 @@ func TestAuthManagerLoginInvalidPassword(t *testing.T) {
 
 	mockExampleUser(db.(*MockGormDB))
 
-	user, token, sessionSecret, err := manager.Login("test@example.com", "invalid_password")
+	user, token, sessionSecret, err := manager.Login("test@example.com", "invalid_password", SessionMetadata{})
 	assertInvalidLogin(t, user, token, sessionSecret, err)
 }
 
@@ -455,8 +542,104 @@ func TestAuthManagerLoginInvalidEmail(t *testing.T) {
 		}}).
 		DoAndReturn(returnError2(db, gorm.ErrRecordNotFound))
 
-	user, token, sessionSecret, err := manager.Login("unknown@example.com", "invalid_password")
+	user, token, sessionSecret, err := manager.Login("unknown@example.com", "invalid_password", SessionMetadata{})
+	assertInvalidLogin(t, user, token, sessionSecret, err)
+}
+
+// Tests if AuthManagerImpl.Login throttles attempts after FreeAttempts failed attempts
+func TestAuthManagerLoginThrottled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+	metadata := SessionMetadata{IpAddress: "192.0.2.1"}
+
+	for i := 0; i != 3; i++ {
+		mockExampleUser(db.(*MockGormDB))
+		user, token, sessionSecret, err := manager.Login("test@example.com", "invalid_password", metadata)
+		assertInvalidLogin(t, user, token, sessionSecret, err)
+	}
+
+	_, _, _, err := manager.Login("test@example.com", "zaq1@WSX", metadata)
+	var throttledErr *LoginThrottledError
+	require.Truef(t, errors.As(err, &throttledErr), "manager.Login should return LoginThrottledError")
+	require.ErrorIsf(t, err, ErrTooManyAttempts, "LoginThrottledError should wrap ErrTooManyAttempts")
+	require.Truef(t, throttledErr.RetryAfter > 0 && throttledErr.RetryAfter <= time.Minute,
+		"manager.Login returned unexpected RetryAfter %s", throttledErr.RetryAfter)
+
+	// Other IP addresses are still throttled by the email
+	_, _, _, err = manager.Login("TEST@example.com", "zaq1@WSX", SessionMetadata{IpAddress: "192.0.2.2"})
+	require.ErrorIsf(t, err, ErrTooManyAttempts, "manager.Login should throttle the email")
+}
+
+// Tests if AuthManagerImpl.Login locks out the email after LockoutAttempts failed attempts and notifies the user
+func TestAuthManagerLoginLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	manager.loginThrottle.BackoffBase = 0
+	db := manager.baseServices.Database
+	metadata := SessionMetadata{IpAddress: "192.0.2.1"}
+
+	manager.emailOutbox.(*MockEmailOutbox).
+		EXPECT().
+		Enqueue(gomock.Eq("test@example.com"), &StructMatcher{emailMessageMatcher{Subject: Ptr("lockout")}}).
+		Return(nil)
+
+	for i := 0; i != 4; i++ {
+		mockExampleUser(db.(*MockGormDB))
+		user, token, sessionSecret, err := manager.Login("test@example.com", "invalid_password", metadata)
+		assertInvalidLogin(t, user, token, sessionSecret, err)
+	}
+
+	_, _, _, err := manager.Login("test@example.com", "zaq1@WSX", metadata)
+	var throttledErr *LoginThrottledError
+	require.Truef(t, errors.As(err, &throttledErr), "manager.Login should return LoginThrottledError")
+	require.Truef(t, throttledErr.RetryAfter > 59*time.Minute,
+		"manager.Login returned unexpected RetryAfter %s", throttledErr.RetryAfter)
+
+	// The IP address is locked out too
+	_, _, _, err = manager.Login("other@example.com", "zaq1@WSX", metadata)
+	require.ErrorIsf(t, err, ErrTooManyAttempts, "manager.Login should lock out the IP address")
+}
+
+// Tests if AuthManagerImpl.Login resets failed attempts of the email, but not of the IP address, after login
+func TestCreateAuthManagerImplDefaultLockoutDuration(t *testing.T) {
+	manager := CreateAuthManagerImpl(BaseServices{Logger: log.Default()}, nil, nil, nil, nil,
+		LoginThrottleConfig{FreeAttempts: 3}, "StampWallet")
+	require.Equalf(t, 15*time.Minute, manager.loginThrottle.LockoutDuration,
+		"zero lockout duration should be replaced with the default")
+}
+
+func TestAuthManagerLoginResetsAttempts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+	metadata := SessionMetadata{IpAddress: "192.0.2.1"}
+
+	mockExampleUser(db.(*MockGormDB))
+	user, token, sessionSecret, err := manager.Login("test@example.com", "invalid_password", metadata)
 	assertInvalidLogin(t, user, token, sessionSecret, err)
+
+	mockExampleUser(db.(*MockGormDB))
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Create(gomock.Any(), TokenPurposeSession, gomock.Any()).
+		Return(&Token{TokenId: "test", TokenPurpose: TokenPurposeSession}, "sessionSecret", nil)
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		SetSessionMetadata(gomock.Any(), metadata).
+		DoAndReturn(func(token *Token, metadata SessionMetadata) (*Token, error) {
+			return token, nil
+		})
+	_, _, _, err = manager.Login("test@example.com", "zaq1@WSX", metadata)
+	require.Nilf(t, err, "manager.Login should return a nil error")
+
+	attempts, _ := manager.loginAttemptStore.Get("email:test@example.com")
+	require.Equalf(t, uint(0), attempts.Failures, "failed attempts of the email should be reset")
+	attempts, _ = manager.loginAttemptStore.Get("ip:192.0.2.1")
+	require.Equalf(t, uint(1), attempts.Failures, "failed attempts of the IP address should not be reset")
 }
 
 // Tests if AuthManagerImpl.Logiut works correctly on the happy path
@@ -619,6 +802,121 @@ func TestAuthManagerConfirmEmailInvalidHash(t *testing.T) {
 	}
 }
 
+// Mocks the part of AuthManagerImpl.ResendEmailConfirmation before the token is created. sent is returned
+// as verification tokens created within the last hour.
+func mockResendEmailConfirmation(manager *AuthManagerImpl, user User, sent []Token) {
+	db := manager.baseServices.Database
+
+	mockTransaction(db)
+
+	db.(*MockGormDB).
+		EXPECT().
+		Clauses(gomock.Any()).
+		Return(db)
+
+	db.(*MockGormDB).
+		EXPECT().
+		First(gomock.Any(), user.ID).
+		DoAndReturn(func(arg *User, conds ...interface{}) GormDB {
+			*arg = user
+			return returnError0(db, nil)()
+		})
+
+	if user.EmailVerified {
+		return
+	}
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		WithTransaction(db).
+		Return(manager.tokenService, nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		GetCreatedSince(&StructMatcher{userMatcher{ID: Ptr(user.ID)}}, TokenPurposeEmail,
+			&TimeGreaterThanNow{time.Now().Add(-time.Hour)}).
+		Return(sent, nil)
+}
+
+// Tests if AuthManagerImpl.ResendEmailConfirmation sends a new token on the happy path
+func TestAuthManagerResendEmailConfirmation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleUser()
+	mockResendEmailConfirmation(manager, user, []Token{createExampleToken("test_email", TokenPurposeEmail)})
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		InvalidateAll(&StructMatcher{userMatcher{ID: Ptr(user.ID)}}, TokenPurposeEmail).
+		Return(nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Create(
+			&StructMatcher{userMatcher{ID: Ptr(user.ID)}},
+			TokenPurposeEmail,
+			&TimeGreaterThanNow{time.Now().Add(24 * time.Hour)},
+		).
+		Return(&Token{TokenId: "email_id", TokenPurpose: TokenPurposeEmail}, "email_secret", nil)
+
+	manager.emailOutbox.(*MockEmailOutbox).
+		EXPECT().
+		WithTransaction(db).
+		Return(manager.emailOutbox, nil)
+
+	manager.emailOutbox.(*MockEmailOutbox).
+		EXPECT().
+		Enqueue("test@example.com", EmailMessage{
+			Subject:  "verification",
+			HTMLBody: "email_id:email_secret",
+			TextBody: "text email_id:email_secret",
+		}).
+		Return(nil)
+
+	err := manager.ResendEmailConfirmation(&user)
+	require.Nilf(t, err, "ResendEmailConfirmation should return a nil error")
+}
+
+// Tests if AuthManagerImpl.ResendEmailConfirmation does not send emails to verified users
+func TestAuthManagerResendEmailConfirmationVerified(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	user := getExampleUser()
+	user.EmailVerified = true
+	mockResendEmailConfirmation(manager, user, nil)
+
+	err := manager.ResendEmailConfirmation(&user)
+	require.ErrorIsf(t, err, ErrEmailVerified, "ResendEmailConfirmation should return ErrEmailVerified")
+}
+
+// Tests if AuthManagerImpl.ResendEmailConfirmation is throttled after too many emails
+func TestAuthManagerResendEmailConfirmationThrottled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	user := getExampleUser()
+	var sent []Token
+	for i := 5; i > 1; i-- {
+		token := createExampleToken("test_email", TokenPurposeEmail)
+		token.CreatedAt = time.Now().Add(-time.Duration(i) * 10 * time.Minute)
+		sent = append(sent, token)
+	}
+	mockResendEmailConfirmation(manager, user, sent)
+
+	err := manager.ResendEmailConfirmation(&user)
+	require.ErrorIsf(t, err, ErrTooManyResends, "ResendEmailConfirmation should return ErrTooManyResends")
+	var throttledErr *ResendThrottledError
+	require.Truef(t, errors.As(err, &throttledErr), "ResendEmailConfirmation should return ResendThrottledError")
+	require.InDeltaf(t, float64(10*time.Minute), float64(throttledErr.RetryAfter), float64(time.Second),
+		"another email should be allowed when the oldest one leaves the window")
+}
+
 // Tests if AuthManagerImpl.ChangePassword works correctly on the happy path
 func TestAuthManagerChangePassword(t *testing.T) {
 	ctrl := gomock.NewController(t)
@@ -643,7 +941,7 @@ func TestAuthManagerChangePassword(t *testing.T) {
 			return db
 		})
 
-	_, err := manager.ChangePassword(&user, "zaq1@WSX", "nu9AhYoo")
+	_, err := manager.ChangePassword(&user, "zaq1@WSX", "nu9AhYoo", nil)
 	require.Nilf(t, err, "manager.ChangePassword should return a nil error")
 	bcryptErr := bcrypt.CompareHashAndPassword([]byte(hash), []byte("nu9AhYoo"))
 	require.Nilf(t, bcryptErr, "bcrypt.CompareHashAndPassword should return a nil error")
@@ -657,11 +955,11 @@ func TestAuthManagerChangePasswordInvalid(t *testing.T) {
 
 	user := getExampleUser()
 
-	_, err := manager.ChangePassword(&user, "test", "nu9AhYoo")
+	_, err := manager.ChangePassword(&user, "test", "nu9AhYoo", nil)
 	require.ErrorIsf(t, ErrInvalidOldPassword, err, "manager.ChangePassword should return a nil error")
 }
 
-// Tests if AuthManagerImpl.ChangeEmail works correctly on the happy path
+// Tests if AuthManagerImpl.ChangeEmail stores the pending email and sends both emails on the happy path
 func TestAuthManagerChangeEmail(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -669,54 +967,268 @@ func TestAuthManagerChangeEmail(t *testing.T) {
 	db := manager.baseServices.Database
 
 	user := getExampleUser()
+	user.EmailVerified = true
+
+	db.(*MockGormDB).
+		EXPECT().
+		First(gomock.Any(), &StructMatcher{userMatcher{
+			Email: Ptr("test2@example.com"),
+		}}).
+		DoAndReturn(func(arg *User, conds ...interface{}) GormDB {
+			return returnError0(db, gorm.ErrRecordNotFound)()
+		})
+
+	mockTransaction(db)
+
+	db.(*MockGormDB).
+		EXPECT().
+		Model(&user).
+		Return(db)
+
+	db.(*MockGormDB).
+		EXPECT().
+		Update("pending_email", sql.NullString{String: "test2@example.com", Valid: true}).
+		DoAndReturn(returnError2(db, nil))
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		WithTransaction(db).
+		Return(manager.tokenService, nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		InvalidateAll(&user, TokenPurposeEmailChange).
+		Return(nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		InvalidateAll(&user, TokenPurposeEmailRevert).
+		Return(nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Create(&user, TokenPurposeEmailChange, &TimeGreaterThanNow{time.Now().Add(24 * time.Hour)}).
+		Return(&Token{TokenId: "change_id", TokenPurpose: TokenPurposeEmailChange}, "change_secret", nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Create(&user, TokenPurposeEmailRevert, &TimeGreaterThanNow{time.Now().Add(7 * 24 * time.Hour)}).
+		Return(&Token{TokenId: "revert_id", TokenPurpose: TokenPurposeEmailRevert}, "revert_secret", nil)
+
+	manager.emailOutbox.(*MockEmailOutbox).
+		EXPECT().
+		WithTransaction(db).
+		Return(manager.emailOutbox, nil)
+
+	manager.emailOutbox.(*MockEmailOutbox).
+		EXPECT().
+		Enqueue("test2@example.com", EmailMessage{Subject: "email change", HTMLBody: "change_id:change_secret"}).
+		Return(nil)
+
+	manager.emailOutbox.(*MockEmailOutbox).
+		EXPECT().
+		Enqueue("test@example.com", EmailMessage{
+			Subject:  "email change notice",
+			HTMLBody: "test2@example.com revert_id:revert_secret",
+		}).
+		Return(nil)
+
+	changedUser, err := manager.ChangeEmail(&user, "test2@example.com", nil)
+
+	require.Nilf(t, err, "ChangeEmail should return a nil error")
+	require.NotNilf(t, changedUser, "ChangeEmail should not return a nil user")
+	require.Equalf(t, "test@example.com", changedUser.Email, "ChangeEmail should not change the email before confirmation")
+	require.Truef(t, changedUser.EmailVerified, "ChangeEmail should not change EmailVerified")
+	require.Equalf(t, sql.NullString{String: "test2@example.com", Valid: true}, changedUser.PendingEmail,
+		"ChangeEmail should store the pending email")
+}
+
+// Tests if AuthManagerImpl.ChangeEmail returns ErrEmailExists when another user has the email
+func TestAuthManagerChangeEmailExisting(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleUser()
+	user.Email = "other@example.com"
+	mockExampleUser(db.(*MockGormDB))
+
+	changedUser, err := manager.ChangeEmail(&user, "test@example.com", nil)
+	require.ErrorIsf(t, err, ErrEmailExists, "ChangeEmail should return ErrEmailExists")
+	require.Nilf(t, changedUser, "changedUser should be nil")
+
+	_, err = manager.ChangeEmail(&user, "other@example.com", nil)
+	require.ErrorIsf(t, err, ErrEmailExists, "ChangeEmail should return ErrEmailExists for the current email")
+}
+
+// Tests if AuthManagerImpl.ConfirmEmail switches to the pending email with an email change token
+func TestAuthManagerConfirmEmailChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleUser()
+	user.PendingEmail = sql.NullString{String: "test2@example.com", Valid: true}
+	token := createExampleToken("change_id", TokenPurposeEmailChange)
+	token.User = &user
 
 	mockBegin(db)
 
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Check("change_id", "change_secret").
+		Return(&token, nil)
+
 	db.(*MockGormDB).
 		EXPECT().
 		Save(&StructMatcher{userMatcher{
 			ID:            Ptr(user.ID),
-			EmailVerified: Ptr(false),
+			Email:         Ptr("test2@example.com"),
+			EmailVerified: Ptr(true),
 		}}).
 		DoAndReturn(returnError1(db, nil))
 
+	mockCommit(db)
+
+	changedUser, err := manager.ConfirmEmail("change_id", "change_secret")
+	require.Nilf(t, err, "ConfirmEmail should return a nil error")
+	require.Equalf(t, "test2@example.com", changedUser.Email, "ConfirmEmail should switch to the pending email")
+	require.Falsef(t, changedUser.PendingEmail.Valid, "ConfirmEmail should clear the pending email")
+	require.Equalf(t, sql.NullString{String: "test@example.com", Valid: true}, changedUser.PreviousEmail,
+		"ConfirmEmail should remember the previous email")
+}
+
+// Tests if AuthManagerImpl.ConfirmEmail returns ErrEmailExists when the pending email was taken
+func TestAuthManagerConfirmEmailChangeTaken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleUser()
+	user.PendingEmail = sql.NullString{String: "test2@example.com", Valid: true}
+	token := createExampleToken("change_id", TokenPurposeEmailChange)
+	token.User = &user
+
+	mockBegin(db)
+
 	manager.tokenService.(*MockTokenService).
 		EXPECT().
-		WithTransaction(
-			db,
-		).
+		Check("change_id", "change_secret").
+		Return(&token, nil)
+
+	db.(*MockGormDB).
+		EXPECT().
+		Save(gomock.Any()).
+		DoAndReturn(returnError1(db, &pgconn.PgError{Code: "23505"}))
+
+	mockRollback(db)
+
+	_, err := manager.ConfirmEmail("change_id", "change_secret")
+	require.ErrorIsf(t, err, ErrEmailExists, "ConfirmEmail should return ErrEmailExists")
+}
+
+// Mocks the part of AuthManagerImpl.RevertEmailChange before the user is updated
+func mockRevertEmailChangeToken(manager *AuthManagerImpl, user *User, purpose TokenPurposeEnum) {
+	db := manager.baseServices.Database
+	token := createExampleToken("revert_id", purpose)
+	token.User = user
+
+	mockTransaction(db)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		WithTransaction(db).
 		Return(manager.tokenService, nil)
 
 	manager.tokenService.(*MockTokenService).
 		EXPECT().
-		Create(
-			&StructMatcher{userMatcher{
-				Email:         Ptr("test2@example.com"),
-				EmailVerified: Ptr(false),
-			}},
-			gomock.Eq(TokenPurposeEmail),
-			&TimeGreaterThanNow{time.Now().Add(24 * time.Hour)},
-		).
-		Return(&Token{
-			TokenPurpose: TokenPurposeEmail,
-			Used:         false,
-			Recalled:     false,
-		}, "test_secret", nil)
+		Check("revert_id", "revert_secret").
+		Return(&token, nil)
+}
 
-	manager.emailService.(*MockEmailService).
+// Mocks invalidation of every token of user by AuthManagerImpl.RevertEmailChange
+func mockRevertEmailChangeInvalidate(manager *AuthManagerImpl, user *User) {
+	for _, purpose := range []TokenPurposeEnum{
+		TokenPurposeSession, TokenPurposePasswordReset, TokenPurposeEmailChange, TokenPurposeEmailRevert,
+	} {
+		manager.tokenService.(*MockTokenService).
+			EXPECT().
+			InvalidateAll(user, purpose).
+			Return(nil)
+	}
+}
+
+// Tests if AuthManagerImpl.RevertEmailChange cancels a pending email change
+func TestAuthManagerRevertEmailChangePending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleUser()
+	user.PendingEmail = sql.NullString{String: "test2@example.com", Valid: true}
+	mockRevertEmailChangeToken(manager, &user, TokenPurposeEmailRevert)
+
+	db.(*MockGormDB).EXPECT().Model(&user).Return(db)
+	db.(*MockGormDB).
 		EXPECT().
-		Send(
-			gomock.Eq("test2@example.com"),
-			gomock.Any(),
-			gomock.Any())
+		Updates(map[string]any{"pending_email": sql.NullString{}}).
+		DoAndReturn(returnError1(db, nil))
 
-	mockCommit(db)
+	mockRevertEmailChangeInvalidate(manager, &user)
+
+	revertedUser, err := manager.RevertEmailChange("revert_id", "revert_secret")
+	require.Nilf(t, err, "RevertEmailChange should return a nil error")
+	require.Equalf(t, "test@example.com", revertedUser.Email, "RevertEmailChange should keep the email")
+	require.Falsef(t, revertedUser.PendingEmail.Valid, "RevertEmailChange should clear the pending email")
+}
 
-	changedUser, err := manager.ChangeEmail(&user, "test2@example.com")
+// Tests if AuthManagerImpl.RevertEmailChange restores the old email after the change was confirmed
+func TestAuthManagerRevertEmailChangeConfirmed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
 
-	require.Nilf(t, err, "ChangeEmail should return a nil error")
-	require.NotNilf(t, changedUser, "ChangeEmail should not return a nil user")
-	require.Falsef(t, changedUser.EmailVerified, "ChangeEmail should return a user with EmailVerified set to false")
+	user := getExampleUser()
+	user.Email = "test2@example.com"
+	user.PreviousEmail = sql.NullString{String: "test@example.com", Valid: true}
+	mockRevertEmailChangeToken(manager, &user, TokenPurposeEmailRevert)
+
+	db.(*MockGormDB).EXPECT().Model(&user).Return(db)
+	db.(*MockGormDB).
+		EXPECT().
+		Updates(map[string]any{
+			"email":          "test@example.com",
+			"email_verified": true,
+			"previous_email": sql.NullString{},
+		}).
+		DoAndReturn(returnError1(db, nil))
+
+	mockRevertEmailChangeInvalidate(manager, &user)
+
+	revertedUser, err := manager.RevertEmailChange("revert_id", "revert_secret")
+	require.Nilf(t, err, "RevertEmailChange should return a nil error")
+	require.Equalf(t, "test@example.com", revertedUser.Email, "RevertEmailChange should restore the old email")
+	require.Truef(t, revertedUser.EmailVerified, "RevertEmailChange should mark the old email as verified")
+	require.Falsef(t, revertedUser.PreviousEmail.Valid, "RevertEmailChange should clear the previous email")
+}
+
+// Tests if AuthManagerImpl.RevertEmailChange does not accept tokens with other purpose
+func TestAuthManagerRevertEmailChangeInvalidPurpose(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	user := getExampleUser()
+	user.PendingEmail = sql.NullString{String: "test2@example.com", Valid: true}
+	mockRevertEmailChangeToken(manager, &user, TokenPurposeEmailChange)
+
+	_, err := manager.RevertEmailChange("revert_id", "revert_secret")
+	require.Equalf(t, ErrInvalidTokenPurpose, err, "RevertEmailChange should return ErrInvalidTokenPurpose")
 }
 
 // Tests if AuthManagerImpl.ChangeEmail works correctly when email is invalid
@@ -727,8 +1239,378 @@ func TestAuthManagerChangeEmailInvalid(t *testing.T) {
 
 	user := getExampleUser()
 
-	changedUser, err := manager.ChangeEmail(&user, "asd")
+	changedUser, err := manager.ChangeEmail(&user, "asd", nil)
 
 	require.ErrorIsf(t, ErrInvalidEmail, err, "error should be InvalidEmail")
 	require.Nilf(t, changedUser, "changedUser should be nil")
 }
+
+// Tests if AuthManagerImpl.ChangeLocale stores the normalized locale
+func TestAuthManagerChangeLocale(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database.(*MockGormDB)
+
+	user := getExampleUser()
+	db.EXPECT().Model(&user).Return(db)
+	db.EXPECT().Update("locale", "pl-PL").Return(db)
+	db.EXPECT().GetError().Return(nil)
+
+	changedUser, err := manager.ChangeLocale(&user, "pl_pl")
+	require.Nilf(t, err, "manager.ChangeLocale should return a nil error")
+	require.Equalf(t, "pl-PL", changedUser.Locale, "manager.ChangeLocale should normalize the locale")
+
+	_, err = manager.ChangeLocale(&user, "polish please")
+	require.ErrorIsf(t, err, ErrInvalidLocale, "manager.ChangeLocale should return InvalidLocale error")
+}
+
+// Mocks Database.Transaction, runs the transaction function with db
+func mockTransaction(db GormDB) {
+	db.(*MockGormDB).
+		EXPECT().
+		Transaction(gomock.Any()).
+		DoAndReturn(func(fc func(tx GormDB) error, opts ...*sql.TxOptions) error {
+			return fc(db)
+		})
+}
+
+func mockRequestPasswordReset(manager *AuthManagerImpl, sent []Token) User {
+	db := manager.baseServices.Database
+
+	mockUser := mockExampleUser(db.(*MockGormDB))
+	mockTransaction(db)
+
+	db.(*MockGormDB).
+		EXPECT().
+		Clauses(gomock.Any()).
+		Return(db)
+
+	db.(*MockGormDB).
+		EXPECT().
+		First(gomock.Any(), mockUser.ID).
+		DoAndReturn(func(arg *User, conds ...interface{}) GormDB {
+			// GetError is already expected by mockExampleUser
+			*arg = mockUser
+			return db
+		})
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		WithTransaction(db).
+		Return(manager.tokenService, nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		GetCreatedSince(&StructMatcher{userMatcher{ID: Ptr(mockUser.ID)}}, TokenPurposePasswordReset,
+			&TimeGreaterThanNow{time.Now().Add(-time.Hour)}).
+		Return(sent, nil)
+
+	return mockUser
+}
+
+// Tests if AuthManagerImpl.RequestPasswordReset works correctly on the happy path
+func TestAuthManagerRequestPasswordReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	mockUser := mockRequestPasswordReset(manager,
+		[]Token{createExampleToken("test_reset", TokenPurposePasswordReset)})
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		InvalidateAll(&StructMatcher{userMatcher{ID: &mockUser.ID}}, TokenPurposePasswordReset).
+		Return(nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Create(
+			&StructMatcher{userMatcher{ID: &mockUser.ID}},
+			TokenPurposePasswordReset,
+			TimeGreaterThanNow{time.Now().Add(59 * time.Minute)},
+		).
+		Return(&Token{TokenId: "reset_id", TokenPurpose: TokenPurposePasswordReset}, "reset_secret", nil)
+
+	manager.emailOutbox.(*MockEmailOutbox).
+		EXPECT().
+		WithTransaction(db).
+		Return(manager.emailOutbox, nil)
+
+	manager.emailOutbox.(*MockEmailOutbox).
+		EXPECT().
+		Enqueue("test@example.com", EmailMessage{
+			Subject:  "password reset",
+			HTMLBody: "reset_id:reset_secret",
+			TextBody: "text reset_id:reset_secret",
+		}).
+		Return(nil)
+
+	err := manager.RequestPasswordReset("test@example.com")
+	require.Nilf(t, err, "manager.RequestPasswordReset should return a nil error")
+}
+
+// Tests if AuthManagerImpl.RequestPasswordReset silently stops sending emails after too many requests
+func TestAuthManagerRequestPasswordResetThrottled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	var sent []Token
+	for i := 3; i > 0; i-- {
+		token := createExampleToken("test_reset", TokenPurposePasswordReset)
+		token.CreatedAt = time.Now().Add(-time.Duration(i) * 10 * time.Minute)
+		sent = append(sent, token)
+	}
+	mockRequestPasswordReset(manager, sent)
+
+	err := manager.RequestPasswordReset("test@example.com")
+	require.Nilf(t, err, "manager.RequestPasswordReset should return a nil error")
+}
+
+// Tests if AuthManagerImpl.RequestPasswordReset does not report unknown emails
+func TestAuthManagerRequestPasswordResetUnknownEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	db.(*MockGormDB).
+		EXPECT().
+		First(gomock.Any(), &StructMatcher{userMatcher{
+			Email: Ptr("unknown@example.com"),
+		}}).
+		DoAndReturn(func(arg *User, conds ...interface{}) GormDB {
+			db.(*MockGormDB).
+				EXPECT().
+				GetError().
+				Return(gorm.ErrRecordNotFound)
+			return db
+		})
+
+	err := manager.RequestPasswordReset("unknown@example.com")
+	require.Nilf(t, err, "manager.RequestPasswordReset should return a nil error")
+
+	err = manager.RequestPasswordReset("invalid email")
+	require.Nilf(t, err, "manager.RequestPasswordReset should return a nil error")
+}
+
+// Tests if AuthManagerImpl.ResetPassword works correctly on the happy path
+func TestAuthManagerResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleUser()
+	token := createExampleToken("reset_id", TokenPurposePasswordReset)
+	token.User = &user
+	var hash string
+
+	mockTransaction(db)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		WithTransaction(db).
+		Return(manager.tokenService, nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Check("reset_id", "reset_secret").
+		Return(&token, nil)
+
+	db.(*MockGormDB).
+		EXPECT().
+		Model(&user).
+		Return(db)
+
+	db.(*MockGormDB).
+		EXPECT().
+		Update("password_hash", gomock.Any()).
+		DoAndReturn(func(column string, value any) GormDB {
+			hash = value.(string)
+			return returnError0(db, nil)()
+		})
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		InvalidateAll(&user, TokenPurposeSession).
+		Return(nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		InvalidateAll(&user, TokenPurposePasswordReset).
+		Return(nil)
+
+	resetUser, err := manager.ResetPassword("reset_id", "reset_secret", "nu9AhYoo")
+	require.Nilf(t, err, "manager.ResetPassword should return a nil error")
+	require.Equalf(t, user.ID, resetUser.ID, "manager.ResetPassword returned invalid user")
+	bcryptErr := bcrypt.CompareHashAndPassword([]byte(hash), []byte("nu9AhYoo"))
+	require.Nilf(t, bcryptErr, "bcrypt.CompareHashAndPassword should return a nil error")
+}
+
+// Tests if AuthManagerImpl.ResetPassword does not use up the token when password is too weak
+func TestAuthManagerResetPasswordTooWeak(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	_, err := manager.ResetPassword("reset_id", "reset_secret", "weak")
+	require.Equalf(t, ErrPasswordTooWeak, err, "manager.ResetPassword should return ErrPasswordTooWeak")
+}
+
+// Tests if AuthManagerImpl.ResetPassword works correctly when token is invalid
+func TestAuthManagerResetPasswordInvalidToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	mockTransaction(db)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		WithTransaction(db).
+		Return(manager.tokenService, nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Check("reset_id", "reset_secret").
+		Return(nil, ErrTokenUsed)
+
+	_, err := manager.ResetPassword("reset_id", "reset_secret", "nu9AhYoo")
+	require.Equalf(t, ErrInvalidToken, err, "manager.ResetPassword should return ErrInvalidToken")
+}
+
+// Tests if AuthManagerImpl.ResetPassword does not accept tokens with other purpose
+func TestAuthManagerResetPasswordInvalidPurpose(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	token := createExampleToken("test_login", TokenPurposeSession)
+
+	mockTransaction(db)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		WithTransaction(db).
+		Return(manager.tokenService, nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Check("test_login", "test_hash").
+		Return(&token, nil)
+
+	_, err := manager.ResetPassword("test_login", "test_hash", "nu9AhYoo")
+	require.Equalf(t, ErrInvalidTokenPurpose, err, "manager.ResetPassword should return ErrInvalidTokenPurpose")
+}
+
+// Tests if AuthManagerImpl.ChangePassword logs out other sessions when keepSession is passed
+func TestAuthManagerChangePasswordLogoutOtherSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleUser()
+	token := getExampleUserLogin()
+
+	db.(*MockGormDB).
+		EXPECT().
+		Save(gomock.Any()).
+		DoAndReturn(returnError1(db, nil))
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		InvalidateOthers(&token).
+		Return(nil)
+
+	_, err := manager.ChangePassword(&user, "zaq1@WSX", "nu9AhYoo", &token)
+	require.Nilf(t, err, "manager.ChangePassword should return a nil error")
+}
+
+// Tests if AuthManagerImpl.GetSessions returns active session tokens
+func TestAuthManagerGetSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	user := getExampleUser()
+	token := getExampleUserLogin()
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		GetActive(&user, TokenPurposeSession).
+		Return([]Token{token}, nil)
+
+	sessions, err := manager.GetSessions(&user)
+	require.Nilf(t, err, "manager.GetSessions should return a nil error")
+	require.Lenf(t, sessions, 1, "manager.GetSessions returned unexpected number of sessions")
+	require.Equalf(t, token.TokenId, sessions[0].TokenId, "manager.GetSessions returned unexpected session")
+}
+
+// Tests if AuthManagerImpl.RevokeSession invalidates session with matching id
+func TestAuthManagerRevokeSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	user := getExampleUser()
+	token := getExampleUserLogin()
+	otherToken := createExampleToken("test_other_login", TokenPurposeSession)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		GetActive(&user, TokenPurposeSession).
+		Return([]Token{otherToken, token}, nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Invalidate(&StructMatcher{tokenMatcher{
+			TokenId: Ptr(token.TokenId),
+		}}).
+		DoAndReturn(func(token *Token) (*Token, error) {
+			token.Recalled = true
+			return token, nil
+		})
+
+	err := manager.RevokeSession(&user, token.TokenId)
+	require.Nilf(t, err, "manager.RevokeSession should return a nil error")
+}
+
+// Tests if AuthManagerImpl.RevokeSession works correctly when user has no such session
+func TestAuthManagerRevokeSessionUnknown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	user := getExampleUser()
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		GetActive(&user, TokenPurposeSession).
+		Return([]Token{getExampleUserLogin()}, nil)
+
+	err := manager.RevokeSession(&user, "unknown")
+	require.Equalf(t, ErrNoSuchSession, err, "manager.RevokeSession should return ErrNoSuchSession")
+}
+
+// Tests if AuthManagerImpl.RevokeOtherSessions invalidates all sessions except the current one
+func TestAuthManagerRevokeOtherSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	token := getExampleUserLogin()
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		InvalidateOthers(&token).
+		Return(nil)
+
+	err := manager.RevokeOtherSessions(&token)
+	require.Nilf(t, err, "manager.RevokeOtherSessions should return a nil error")
+}
@@ -3,17 +3,25 @@ package managers
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	. "github.com/StampWallet/backend/internal/database"
 	. "github.com/StampWallet/backend/internal/services"
 	"github.com/lithammer/shortuuid/v4"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
 	ErrBusinessAlreadyExists = errors.New("Business already exists")
 	ErrTooManyMenuImages     = errors.New("Too many menu images")
 	ErrNoSuchBusiness        = errors.New("Business not found")
+	ErrInvalidCategory       = errors.New("Invalid category")
+	ErrInvalidSort           = errors.New("Sort requires a missing query parameter")
+	ErrInvalidTimeZone       = errors.New("Invalid time zone")
+	ErrInvalidOpeningHours   = errors.New("Invalid opening hours")
+	ErrInvalidLocation       = errors.New("Invalid location details")
+	ErrLastLocation          = errors.New("Business must have at least one location")
 )
 
 type BusinessManager interface {
@@ -23,9 +31,25 @@ type BusinessManager interface {
 	AddMenuImage(user *User, business *Business) (*MenuImage, error)
 	RemoveMenuImage(menuImage *MenuImage) error
 
-	//? not a fan
-	Search(name *string, location *GPSCoordinates, proximityInMeters uint, offset uint, limit uint) ([]Business, error)
+	// Returns businesses matching all filters of the query and number of businesses in every category
+	Search(query *BusinessSearchQuery) (*BusinessSearchResult, error)
 	GetById(businessId string, preloadDetails bool) (*Business, error)
+	// Returns all categories businesses can belong to
+	GetCategories() ([]Category, error)
+	// Returns categories of business
+	GetBusinessCategories(business *Business) ([]Category, error)
+	// Returns regular opening hours and exceptions of business
+	GetOpeningHours(business *Business) ([]OpeningHours, []OpeningHoursException, error)
+	// Returns files of business and the business quota
+	GetFileUsage(business *Business) (*FileUsage, error)
+
+	// Adds a location (branch) to business
+	AddLocation(business *Business, details *BusinessLocationDetails) (*BusinessLocation, error)
+	ChangeLocation(location *BusinessLocation, details *ChangeableBusinessLocationDetails) (*BusinessLocation, error)
+	// Removes location. Returns ErrLastLocation if it is the only location of its business
+	RemoveLocation(location *BusinessLocation) error
+	// Returns locations of business with their own opening hours and exceptions
+	GetLocations(business *Business) ([]BusinessLocation, error)
 }
 
 type BusinessDetails struct {
@@ -37,11 +61,71 @@ type BusinessDetails struct {
 	KRS            string
 	REGON          string
 	OwnerName      string
+	Categories     []string // Category slugs
 }
 
 type ChangeableBusinessDetails struct {
-	Name        *string
-	Description *string
+	Name             *string
+	Description      *string
+	PointsExpiryDays *uint
+	RequireTotp      *bool
+	Categories       *[]string // Category slugs, replace all current categories
+	TimeZone         *string   // IANA time zone name
+	// Replace all current opening hours and exceptions. BusinessId of entries is ignored
+	OpeningHours           *[]OpeningHours
+	OpeningHoursExceptions *[]OpeningHoursException
+}
+
+type BusinessLocationDetails struct {
+	Name           string
+	Address        string
+	GPSCoordinates GPSCoordinates
+	// Own opening hours of the location. If both are empty, opening hours of the business apply
+	OpeningHours           []OpeningHours
+	OpeningHoursExceptions []OpeningHoursException
+}
+
+type ChangeableBusinessLocationDetails struct {
+	Name           *string
+	Address        *string
+	GPSCoordinates *GPSCoordinates
+	// Replace all own opening hours and exceptions of the location
+	OpeningHours           *[]OpeningHours
+	OpeningHoursExceptions *[]OpeningHoursException
+}
+
+type BusinessSearchSortEnum string
+
+const (
+	BusinessSearchSortDistance  BusinessSearchSortEnum = "distance"  // nearest to Location first, requires Location
+	BusinessSearchSortRelevance                        = "relevance" // best match of Text first, requires Text
+	BusinessSearchSortNewest                           = "newest"    // most recently created first
+)
+
+type BusinessSearchQuery struct {
+	Text              *string         // Full-text query of name, description and address
+	Location          *GPSCoordinates // Businesses with a location up to ProximityInMeters away from Location
+	ProximityInMeters uint
+	Categories        []string   // Businesses in any of these categories
+	OpenAt            *time.Time // Businesses with a location open at this time
+	// Empty sorts by distance if Location is set, by relevance if Text is set, by newest otherwise
+	Sort BusinessSearchSortEnum
+	Page Page
+}
+
+type FoundBusiness struct {
+	Business
+	// Nearest location matching the query and distance to it, nil if BusinessSearchQuery.Location was not set
+	NearestLocation  *BusinessLocation `gorm:"-"`
+	DistanceInMeters *float64
+}
+
+type BusinessSearchResult struct {
+	Businesses []FoundBusiness
+	// Number of businesses matching all other filters in each category, regardless of Categories.
+	// Categories without businesses are skipped
+	CategoryCounts map[string]uint
+	NextCursor     string // Cursor of the next page, empty if this is the last one
 }
 
 type BusinessManagerImpl struct {
@@ -70,13 +154,27 @@ func (manager *BusinessManagerImpl) Create(user *User, businessDetails *Business
 			return fmt.Errorf("tx.First returned an error: %+v", err)
 		}
 
-		bannerImageStub, err := manager.fileStorageService.CreateStub(user)
+		// Members of other businesses can't have their own business
+		r = tx.First(&BusinessMember{}, &BusinessMember{OwnerId: user.ID})
+		err = r.GetError()
+		if err == nil {
+			return ErrAlreadyMember
+		} else if err != gorm.ErrRecordNotFound {
+			return fmt.Errorf("tx.First(BusinessMember) returned an error: %+v", err)
+		}
+
+		categories, err := findCategories(tx, businessDetails.Categories)
+		if err != nil {
+			return err
+		}
+
+		bannerImageStub, err := manager.fileStorageService.CreateStub(user, nil, FilePurposeBusinessBanner)
 		if err != nil {
-			return fmt.Errorf("fileStorageService.CreateStub for bannerImageStub returned an error: %+v", err)
+			return fmt.Errorf("fileStorageService.CreateStub for bannerImageStub returned an error: %w", err)
 		}
-		iconImageStub, err := manager.fileStorageService.CreateStub(user)
+		iconImageStub, err := manager.fileStorageService.CreateStub(user, nil, FilePurposeBusinessIcon)
 		if err != nil {
-			return fmt.Errorf("fileStorageService.CreateStub for iconImageStub returned an error: %+v", err)
+			return fmt.Errorf("fileStorageService.CreateStub for iconImageStub returned an error: %w", err)
 		}
 
 		business = Business{
@@ -92,6 +190,14 @@ func (manager *BusinessManagerImpl) Create(user *User, businessDetails *Business
 			BannerImageId:  bannerImageStub.PublicId,
 			IconImageId:    iconImageStub.PublicId,
 			OwnerId:        user.ID,
+			Categories:     categories,
+			// Every business has at least one location, the first one is at the registered address
+			Locations: []BusinessLocation{{
+				PublicId:       shortuuid.New(),
+				Name:           businessDetails.Name,
+				Address:        businessDetails.Address,
+				GPSCoordinates: businessDetails.GPSCoordinates,
+			}},
 		}
 
 		r = tx.Create(&business)
@@ -103,6 +209,24 @@ func (manager *BusinessManagerImpl) Create(user *User, businessDetails *Business
 			}
 		}
 
+		// Images are created before the business, they count against its quota from now on
+		r = tx.Model(&FileMetadata{}).
+			Where("public_id IN ?", []string{business.BannerImageId, business.IconImageId}).
+			Update("business_id", business.ID)
+		if err := r.GetError(); err != nil {
+			return fmt.Errorf("tx.Update(FileMetadata) returned an error: %+v", err)
+		}
+
+		r = tx.Create(&BusinessMember{
+			PublicId:   shortuuid.New(),
+			BusinessId: business.ID,
+			OwnerId:    user.ID,
+			Role:       BusinessMemberRoleOwner,
+		})
+		if err := r.GetError(); err != nil {
+			return fmt.Errorf("tx.Create(BusinessMember) returned an error: %+v", err)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -112,25 +236,175 @@ func (manager *BusinessManagerImpl) Create(user *User, businessDetails *Business
 }
 
 func (manager *BusinessManagerImpl) ChangeDetails(business *Business, businessDetails *ChangeableBusinessDetails) (*Business, error) {
+	db := manager.baseServices.Database
+	var categories []Category
+	if businessDetails.Categories != nil {
+		var err error
+		categories, err = findCategories(db, *businessDetails.Categories)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if businessDetails.TimeZone != nil {
+		if _, err := time.LoadLocation(*businessDetails.TimeZone); err != nil || *businessDetails.TimeZone == "" {
+			return nil, ErrInvalidTimeZone
+		}
+	}
+	if err := validateOpeningHours(businessDetails.OpeningHours, businessDetails.OpeningHoursExceptions); err != nil {
+		return nil, err
+	}
+
 	if businessDetails.Name != nil {
 		business.Name = *businessDetails.Name
 	}
 	if businessDetails.Description != nil {
 		business.Description = *businessDetails.Description
 	}
+	if businessDetails.PointsExpiryDays != nil {
+		business.PointsExpiryDays = *businessDetails.PointsExpiryDays
+	}
+	if businessDetails.RequireTotp != nil {
+		business.RequireTotp = *businessDetails.RequireTotp
+	}
+	if businessDetails.TimeZone != nil {
+		business.TimeZone = *businessDetails.TimeZone
+	}
 
-	tx := manager.baseServices.Database.Save(business)
-	if err := tx.GetError(); err != nil {
+	err := db.Transaction(func(tx GormDB) error {
+		r := tx.Omit("Categories", "Locations", "OpeningHours", "OpeningHoursExceptions").Save(business)
+		if err := r.GetError(); err != nil {
+			return err
+		}
+		var err error
+		if businessDetails.OpeningHours != nil {
+			business.OpeningHours, err = replaceOpeningHours(tx, business.ID, nil, *businessDetails.OpeningHours)
+			if err != nil {
+				return err
+			}
+		}
+		if businessDetails.OpeningHoursExceptions != nil {
+			business.OpeningHoursExceptions, err = replaceOpeningHoursExceptions(tx, business.ID, nil,
+				*businessDetails.OpeningHoursExceptions)
+			if err != nil {
+				return err
+			}
+		}
+		if businessDetails.Categories != nil {
+			err := tx.Model(business).Association("Categories").Replace(categories)
+			if err != nil {
+				return fmt.Errorf("Association(Categories).Replace returned an error: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return business, nil
 }
 
+// Checks that opening hours are within a day and exceptions do not both close and open the business on the same date
+func validateOpeningHours(hours *[]OpeningHours, exceptions *[]OpeningHoursException) error {
+	if hours != nil {
+		for _, v := range *hours {
+			if v.Weekday < time.Sunday || v.Weekday > time.Saturday || v.OpensAt >= v.ClosesAt || v.ClosesAt > MinutesInDay {
+				return ErrInvalidOpeningHours
+			}
+		}
+	}
+	if exceptions != nil {
+		closedDates := map[string]bool{}
+		openDates := map[string]bool{}
+		for _, v := range *exceptions {
+			date := v.Date.Format(time.DateOnly)
+			if v.Closed {
+				closedDates[date] = true
+			} else if v.OpensAt >= v.ClosesAt || v.ClosesAt > MinutesInDay {
+				return ErrInvalidOpeningHours
+			} else {
+				openDates[date] = true
+			}
+			if closedDates[date] && openDates[date] {
+				return ErrInvalidOpeningHours
+			}
+		}
+	}
+	return nil
+}
+
+// Limits tx to opening hours of business, or of its location if locationId is not nil
+func whereOpeningHoursOf(tx GormDB, businessId uint, locationId *uint) GormDB {
+	if locationId == nil {
+		return tx.Where("business_id = ? AND business_location_id IS NULL", businessId)
+	}
+	return tx.Where("business_id = ? AND business_location_id = ?", businessId, *locationId)
+}
+
+// Replaces opening hours of business, or of its location if locationId is not nil. Returns created opening hours
+func replaceOpeningHours(tx GormDB, businessId uint, locationId *uint, hours []OpeningHours) ([]OpeningHours, error) {
+	r := whereOpeningHoursOf(tx, businessId, locationId).Delete(&OpeningHours{})
+	if err := r.GetError(); err != nil {
+		return nil, fmt.Errorf("tx.Delete(OpeningHours) returned an error: %w", err)
+	}
+	var created []OpeningHours
+	for _, v := range hours {
+		created = append(created, OpeningHours{
+			BusinessId:         businessId,
+			BusinessLocationId: locationId,
+			Weekday:            v.Weekday,
+			OpensAt:            v.OpensAt,
+			ClosesAt:           v.ClosesAt,
+		})
+	}
+	if len(created) == 0 {
+		return nil, nil
+	}
+	r = tx.Create(&created)
+	if err := r.GetError(); err != nil {
+		return nil, fmt.Errorf("tx.Create(OpeningHours) returned an error: %w", err)
+	}
+	return created, nil
+}
+
+// Replaces opening hours exceptions of business, or of its location if locationId is not nil.
+// Returns created exceptions
+func replaceOpeningHoursExceptions(tx GormDB, businessId uint, locationId *uint,
+	exceptions []OpeningHoursException) ([]OpeningHoursException, error) {
+
+	r := whereOpeningHoursOf(tx, businessId, locationId).Delete(&OpeningHoursException{})
+	if err := r.GetError(); err != nil {
+		return nil, fmt.Errorf("tx.Delete(OpeningHoursException) returned an error: %w", err)
+	}
+	var created []OpeningHoursException
+	for _, v := range exceptions {
+		exception := OpeningHoursException{
+			BusinessId:         businessId,
+			BusinessLocationId: locationId,
+			Date:               v.Date,
+			Closed:             v.Closed,
+		}
+		if !v.Closed {
+			exception.OpensAt = v.OpensAt
+			exception.ClosesAt = v.ClosesAt
+		}
+		created = append(created, exception)
+	}
+	if len(created) == 0 {
+		return nil, nil
+	}
+	r = tx.Create(&created)
+	if err := r.GetError(); err != nil {
+		return nil, fmt.Errorf("tx.Create(OpeningHoursException) returned an error: %w", err)
+	}
+	return created, nil
+}
+
 func (manager *BusinessManagerImpl) AddMenuImage(user *User, business *Business) (*MenuImage, error) {
 	var menuImage *MenuImage
-	if user.ID != business.OwnerId {
-		return nil, fmt.Errorf("invalid user passed, business.OwnerId != user.ID")
+	if err := checkBusinessManager(manager.baseServices.Database, user, business); err != nil {
+		return nil, err
 	}
 	err := manager.baseServices.Database.Transaction(func(db GormDB) error {
 		var images []MenuImage
@@ -142,7 +416,7 @@ func (manager *BusinessManagerImpl) AddMenuImage(user *User, business *Business)
 			return ErrTooManyMenuImages
 		}
 
-		metadata, err := manager.fileStorageService.CreateStub(user)
+		metadata, err := manager.fileStorageService.CreateStub(user, business, FilePurposeMenuImage)
 		if err != nil {
 			return fmt.Errorf("failed to create image stub: %w", err)
 		}
@@ -183,34 +457,246 @@ func (manager *BusinessManagerImpl) RemoveMenuImage(menuImage *MenuImage) error
 	return nil
 }
 
-// NOTE limit offset is not a very good pagination method
-// https://www.citusdata.com/blog/2016/03/30/five-ways-to-paginate/
-func (manager *BusinessManagerImpl) Search(name *string, location *GPSCoordinates, proximityInMeters uint, offset uint, limit uint) ([]Business, error) {
-	var args []interface{}
+// Finds categories with slugs. Returns ErrInvalidCategory if any of them does not exist
+func findCategories(db GormDB, slugs []string) ([]Category, error) {
+	if len(slugs) == 0 {
+		return nil, nil
+	}
+	unique := map[string]bool{}
+	for _, slug := range slugs {
+		unique[slug] = true
+	}
+
+	var categories []Category
+	tx := db.Where("slug IN ?", slugs).Order("slug").Find(&categories)
+	if err := tx.GetError(); err != nil {
+		return nil, fmt.Errorf("db.Find(Category) returned an error: %w", err)
+	}
+	if len(categories) != len(unique) {
+		return nil, ErrInvalidCategory
+	}
+	return categories, nil
+}
+
+// Loads Categories of every business
+func loadCategories(db GormDB, businesses []*Business) error {
+	if len(businesses) == 0 {
+		return nil
+	}
+	var ids []uint
+	for _, business := range businesses {
+		ids = append(ids, business.ID)
+	}
+
+	var rows []struct {
+		BusinessId uint
+		Category
+	}
+	tx := db.Table("categories").
+		Select("business_categories.business_id, categories.*").
+		Joins("JOIN business_categories ON business_categories.category_id = categories.id").
+		Where("business_categories.business_id IN ? AND categories.deleted_at IS NULL", ids).
+		Order("categories.slug").
+		Scan(&rows)
+	if err := tx.GetError(); err != nil {
+		return fmt.Errorf("db.Scan(business_categories) returned an error: %w", err)
+	}
+
+	categories := map[uint][]Category{}
+	for _, row := range rows {
+		categories[row.BusinessId] = append(categories[row.BusinessId], row.Category)
+	}
+	for _, business := range businesses {
+		business.Categories = categories[business.ID]
+	}
+	return nil
+}
+
+func (manager *BusinessManagerImpl) Search(query *BusinessSearchQuery) (*BusinessSearchResult, error) {
+	db := manager.baseServices.Database
+	sort := query.Sort
+	if sort == "" && query.Location != nil {
+		sort = BusinessSearchSortDistance
+	} else if sort == "" && query.Text != nil {
+		sort = BusinessSearchSortRelevance
+	} else if sort == "" {
+		sort = BusinessSearchSortNewest
+	}
+	if (sort == BusinessSearchSortDistance && query.Location == nil) ||
+		(sort == BusinessSearchSortRelevance && query.Text == nil) {
+		return nil, ErrInvalidSort
+	}
+
+	categories, err := findCategories(db, query.Categories)
+	if err != nil {
+		return nil, err
+	}
+
 	//not a fan of constructing sql queries, but it should be safe this time. query is constructed only
 	//from constants, args are passed in parameters
-	query := "SELECT * FROM businesses WHERE "
-	if name != nil {
-		query += `to_tsvector('simple', f_concat_ws(' ', name, description, address))
-		@@ plainto_tsquery('simple', ?)`
-		if location != nil {
-			query += " AND "
+	filters := "businesses.deleted_at IS NULL"
+	var args []interface{}
+	if query.Text != nil {
+		filters += ` AND to_tsvector('simple', f_concat_ws(' ', businesses.name, businesses.description,
+		businesses.address)) @@ plainto_tsquery('simple', ?)`
+		args = append(args, *query.Text)
+	}
+
+	// Businesses match if any of their locations matches
+	var locationFilters string
+	var locationArgs []interface{}
+	if query.Location != nil {
+		locationFilters += ` AND ST_DWithin(business_locations.gps_coordinates, ?, ?)`
+		locationArgs = append(locationArgs, query.Location, query.ProximityInMeters)
+	}
+	if query.OpenAt != nil {
+		// local time in the time zone of every business
+		locationFilters += ` AND location_open_at(business_locations.id,
+		?::timestamptz AT TIME ZONE businesses.time_zone)`
+		locationArgs = append(locationArgs, *query.OpenAt)
+	}
+	if locationFilters != "" {
+		filters += ` AND EXISTS (SELECT 1 FROM business_locations
+		WHERE business_locations.business_id = businesses.id AND business_locations.deleted_at IS NULL` +
+			locationFilters + `)`
+		args = append(args, locationArgs...)
+	}
+
+	// Category filter is skipped in counts, so that the client can show how many results
+	// selecting another category would add
+	var counts []struct {
+		Slug  string
+		Count uint
+	}
+	result := db.Raw(`SELECT categories.slug, count(*) AS count FROM businesses
+		JOIN business_categories ON business_categories.business_id = businesses.id
+		JOIN categories ON categories.id = business_categories.category_id
+		WHERE categories.deleted_at IS NULL AND `+filters+`
+		GROUP BY categories.slug`, args...).Scan(&counts)
+	if err := result.GetError(); err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("db.Raw(category counts) returned an error: %w", err)
+	}
+
+	if len(categories) > 0 {
+		var categoryIds []uint
+		for _, category := range categories {
+			categoryIds = append(categoryIds, category.ID)
 		}
-		args = append(args, name)
+		filters += ` AND EXISTS (SELECT 1 FROM business_categories
+		WHERE business_categories.business_id = businesses.id AND business_categories.category_id IN ?)`
+		args = append(args, categoryIds)
 	}
-	if location != nil {
-		query += `ST_DWithin(gps_coordinates, ?, ?)`
-		args = append(args, location, proximityInMeters)
+
+	// Every sort is ascending by (sort_key, id), so that the cursor is the same for all of them.
+	// id makes the order stable between pages
+	var selectArgs []interface{}
+	var joinArgs []interface{}
+	columns := "businesses.*"
+	join := ""
+	if query.Location != nil {
+		// Nearest location matching the filters. Businesses are sorted by distance to it
+		columns += ", nearest.id AS nearest_location_id, nearest.distance AS distance_in_meters"
+		join = ` CROSS JOIN LATERAL (SELECT business_locations.id,
+		ST_Distance(business_locations.gps_coordinates, ?) AS distance FROM business_locations
+		WHERE business_locations.business_id = businesses.id AND business_locations.deleted_at IS NULL` +
+			locationFilters + ` ORDER BY distance, business_locations.id LIMIT 1) AS nearest`
+		joinArgs = append(append(joinArgs, query.Location), locationArgs...)
+	}
+	switch sort {
+	case BusinessSearchSortDistance:
+		columns += ", nearest.distance AS sort_key"
+	case BusinessSearchSortRelevance:
+		columns += `, -ts_rank(to_tsvector('simple', f_concat_ws(' ', businesses.name, businesses.description,
+		businesses.address)), plainto_tsquery('simple', ?))::float8 AS sort_key`
+		selectArgs = append(selectArgs, *query.Text)
+	case BusinessSearchSortNewest:
+		columns += ", -extract(epoch FROM businesses.created_at)::float8 AS sort_key"
+	default:
+		return nil, ErrInvalidSort
+	}
+
+	pageFilter := "TRUE"
+	var pageArgs []interface{}
+	if query.Page.Cursor != "" {
+		var cursorSort BusinessSearchSortEnum
+		var sortKey float64
+		var id uint
+		if err := DecodeCursor(query.Page.Cursor, &cursorSort, &sortKey, &id); err != nil {
+			return nil, err
+		} else if cursorSort != sort {
+			return nil, ErrInvalidCursor
+		}
+		pageFilter = "(sort_key, id) > (?, ?)"
+		pageArgs = append(pageArgs, sortKey, id)
 	}
-	query += ` LIMIT ? OFFSET ?`
-	args = append(args, limit, offset)
 
-	var businesses []Business
-	result := manager.baseServices.Database.Raw(query, args...).Scan(&businesses)
+	businessArgs := append(append(append(append(selectArgs, joinArgs...), args...), pageArgs...), query.Page.Limit+1)
+	var rows []struct {
+		FoundBusiness
+		NearestLocationId *uint
+		SortKey           float64
+	}
+	result = db.Raw("SELECT * FROM (SELECT "+columns+" FROM businesses"+join+" WHERE "+filters+") AS found"+
+		" WHERE "+pageFilter+" ORDER BY sort_key, id LIMIT ?", businessArgs...).
+		Scan(&rows)
 	if err := result.GetError(); err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
-	return businesses, nil
+
+	// One more row than the limit was fetched to check if there is a next page
+	var nextCursor string
+	if len(rows) > int(query.Page.Limit) {
+		rows = rows[:query.Page.Limit]
+		if len(rows) > 0 {
+			last := rows[len(rows)-1]
+			nextCursor, err = EncodeCursor(sort, last.SortKey, last.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	var locationIds []uint
+	for _, row := range rows {
+		if row.NearestLocationId != nil {
+			locationIds = append(locationIds, *row.NearestLocationId)
+		}
+	}
+	locations := map[uint]*BusinessLocation{}
+	if len(locationIds) > 0 {
+		var nearestLocations []BusinessLocation
+		result = db.Find(&nearestLocations, locationIds)
+		if err := result.GetError(); err != nil {
+			return nil, fmt.Errorf("db.Find(BusinessLocation) returned an error: %w", err)
+		}
+		for i := range nearestLocations {
+			locations[nearestLocations[i].ID] = &nearestLocations[i]
+		}
+	}
+
+	var businesses []FoundBusiness
+	for _, row := range rows {
+		if row.NearestLocationId != nil {
+			row.FoundBusiness.NearestLocation = locations[*row.NearestLocationId]
+		}
+		businesses = append(businesses, row.FoundBusiness)
+	}
+	var businessPtrs []*Business
+	for i := range businesses {
+		businessPtrs = append(businessPtrs, &businesses[i].Business)
+	}
+	if err := loadCategories(db, businessPtrs); err != nil {
+		return nil, err
+	}
+
+	searchResult := &BusinessSearchResult{
+		Businesses:     businesses,
+		CategoryCounts: map[string]uint{},
+		NextCursor:     nextCursor,
+	}
+	for _, count := range counts {
+		searchResult.CategoryCounts[count.Slug] = count.Count
+	}
+	return searchResult, nil
 }
 
 func (manager *BusinessManagerImpl) GetById(businessId string, preloadDetails bool) (*Business, error) {
@@ -218,7 +704,19 @@ func (manager *BusinessManagerImpl) GetById(businessId string, preloadDetails bo
 	db := manager.baseServices.Database
 
 	if preloadDetails {
-		db = db.Preload("ItemDefinitions").Preload("MenuImages")
+		db = db.Preload("ItemDefinitions").Preload("MenuImages").Preload("Categories", func(db *gorm.DB) *gorm.DB {
+			return db.Order("slug")
+		}).Preload("OpeningHours", func(db *gorm.DB) *gorm.DB {
+			return db.Where("business_location_id IS NULL").Order("weekday, opens_at")
+		}).Preload("OpeningHoursExceptions", func(db *gorm.DB) *gorm.DB {
+			return db.Where("business_location_id IS NULL").Order("date, opens_at")
+		}).Preload("Locations", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).Preload("Locations.OpeningHours", func(db *gorm.DB) *gorm.DB {
+			return db.Order("weekday, opens_at")
+		}).Preload("Locations.OpeningHoursExceptions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date, opens_at")
+		})
 	}
 
 	r := db.First(&business, &Business{PublicId: businessId})
@@ -231,3 +729,177 @@ func (manager *BusinessManagerImpl) GetById(businessId string, preloadDetails bo
 
 	return &business, nil
 }
+
+func (manager *BusinessManagerImpl) GetFileUsage(business *Business) (*FileUsage, error) {
+	usage, err := manager.fileStorageService.GetBusinessUsage(business)
+	if err != nil {
+		return nil, fmt.Errorf("fileStorageService.GetBusinessUsage returned an error: %w", err)
+	}
+	return usage, nil
+}
+
+func (manager *BusinessManagerImpl) GetCategories() ([]Category, error) {
+	var categories []Category
+	tx := manager.baseServices.Database.Order("slug").Find(&categories)
+	if err := tx.GetError(); err != nil {
+		return nil, fmt.Errorf("db.Find(Category) returned an error: %w", err)
+	}
+	return categories, nil
+}
+
+func (manager *BusinessManagerImpl) GetBusinessCategories(business *Business) ([]Category, error) {
+	var categories []Category
+	err := manager.baseServices.Database.Model(business).Order("slug").Association("Categories").Find(&categories)
+	if err != nil {
+		return nil, fmt.Errorf("Association(Categories).Find returned an error: %w", err)
+	}
+	return categories, nil
+}
+
+func (manager *BusinessManagerImpl) GetOpeningHours(business *Business) ([]OpeningHours, []OpeningHoursException, error) {
+	db := manager.baseServices.Database
+	var hours []OpeningHours
+	tx := whereOpeningHoursOf(db, business.ID, nil).Order("weekday, opens_at").Find(&hours)
+	if err := tx.GetError(); err != nil {
+		return nil, nil, fmt.Errorf("db.Find(OpeningHours) returned an error: %w", err)
+	}
+	var exceptions []OpeningHoursException
+	tx = whereOpeningHoursOf(db, business.ID, nil).Order("date, opens_at").Find(&exceptions)
+	if err := tx.GetError(); err != nil {
+		return nil, nil, fmt.Errorf("db.Find(OpeningHoursException) returned an error: %w", err)
+	}
+	return hours, exceptions, nil
+}
+
+func (manager *BusinessManagerImpl) AddLocation(business *Business, details *BusinessLocationDetails) (*BusinessLocation, error) {
+	if details.Name == "" || details.Address == "" {
+		return nil, ErrInvalidLocation
+	}
+	if err := validateOpeningHours(&details.OpeningHours, &details.OpeningHoursExceptions); err != nil {
+		return nil, err
+	}
+
+	location := BusinessLocation{
+		PublicId:       shortuuid.New(),
+		BusinessId:     business.ID,
+		Name:           details.Name,
+		Address:        details.Address,
+		GPSCoordinates: details.GPSCoordinates,
+	}
+	err := manager.baseServices.Database.Transaction(func(tx GormDB) error {
+		r := tx.Create(&location)
+		if err := r.GetError(); err != nil {
+			return fmt.Errorf("tx.Create(BusinessLocation) returned an error: %w", err)
+		}
+		var err error
+		location.OpeningHours, err = replaceOpeningHours(tx, business.ID, &location.ID, details.OpeningHours)
+		if err != nil {
+			return err
+		}
+		location.OpeningHoursExceptions, err = replaceOpeningHoursExceptions(tx, business.ID, &location.ID,
+			details.OpeningHoursExceptions)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+func (manager *BusinessManagerImpl) ChangeLocation(location *BusinessLocation,
+	details *ChangeableBusinessLocationDetails) (*BusinessLocation, error) {
+
+	if (details.Name != nil && *details.Name == "") || (details.Address != nil && *details.Address == "") {
+		return nil, ErrInvalidLocation
+	}
+	if err := validateOpeningHours(details.OpeningHours, details.OpeningHoursExceptions); err != nil {
+		return nil, err
+	}
+
+	if details.Name != nil {
+		location.Name = *details.Name
+	}
+	if details.Address != nil {
+		location.Address = *details.Address
+	}
+	if details.GPSCoordinates != nil {
+		location.GPSCoordinates = *details.GPSCoordinates
+	}
+
+	err := manager.baseServices.Database.Transaction(func(tx GormDB) error {
+		r := tx.Omit("Business", "OpeningHours", "OpeningHoursExceptions").Save(location)
+		if err := r.GetError(); err != nil {
+			return fmt.Errorf("tx.Save(BusinessLocation) returned an error: %w", err)
+		}
+		var err error
+		if details.OpeningHours != nil {
+			location.OpeningHours, err = replaceOpeningHours(tx, location.BusinessId, &location.ID,
+				*details.OpeningHours)
+			if err != nil {
+				return err
+			}
+		}
+		if details.OpeningHoursExceptions != nil {
+			location.OpeningHoursExceptions, err = replaceOpeningHoursExceptions(tx, location.BusinessId,
+				&location.ID, *details.OpeningHoursExceptions)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+func (manager *BusinessManagerImpl) RemoveLocation(location *BusinessLocation) error {
+	return manager.baseServices.Database.Transaction(func(tx GormDB) error {
+		// Locks the business, so that two last locations can't be removed concurrently
+		var business Business
+		r := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&business, location.BusinessId)
+		if err := r.GetError(); err != nil {
+			return fmt.Errorf("tx.First(Business) returned an error: %w", err)
+		}
+
+		var count int64
+		r = tx.Model(&BusinessLocation{}).Where("business_id = ?", location.BusinessId).Count(&count)
+		if err := r.GetError(); err != nil {
+			return fmt.Errorf("tx.Count(BusinessLocation) returned an error: %w", err)
+		}
+		if count <= 1 {
+			return ErrLastLocation
+		}
+
+		if _, err := replaceOpeningHours(tx, location.BusinessId, &location.ID, nil); err != nil {
+			return err
+		}
+		if _, err := replaceOpeningHoursExceptions(tx, location.BusinessId, &location.ID, nil); err != nil {
+			return err
+		}
+		r = tx.Delete(location)
+		if err := r.GetError(); err != nil {
+			return fmt.Errorf("tx.Delete(BusinessLocation) returned an error: %w", err)
+		}
+		return nil
+	})
+}
+
+func (manager *BusinessManagerImpl) GetLocations(business *Business) ([]BusinessLocation, error) {
+	var locations []BusinessLocation
+	tx := manager.baseServices.Database.
+		Preload("OpeningHours", func(db *gorm.DB) *gorm.DB {
+			return db.Order("weekday, opens_at")
+		}).
+		Preload("OpeningHoursExceptions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date, opens_at")
+		}).
+		Where("business_id = ?", business.ID).
+		Order("id").
+		Find(&locations)
+	if err := tx.GetError(); err != nil {
+		return nil, fmt.Errorf("db.Find(BusinessLocation) returned an error: %w", err)
+	}
+	return locations, nil
+}
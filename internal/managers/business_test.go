@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -49,12 +50,12 @@ func TestBusinessManagerCreate(t *testing.T) {
 	bannerImage := GetTestFileMetadata(manager.baseServices.Database, user)
 	manager.fileStorageService.(*MockFileStorageService).
 		EXPECT().
-		CreateStub(user).
+		CreateStub(user, nil, FilePurposeBusinessBanner).
 		Return(bannerImage, nil)
 	iconImage := GetTestFileMetadata(manager.baseServices.Database, user)
 	manager.fileStorageService.(*MockFileStorageService).
 		EXPECT().
-		CreateStub(user).
+		CreateStub(user, nil, FilePurposeBusinessIcon).
 		Return(iconImage, nil)
 	details := BusinessDetails{
 		Name:           "test business",
@@ -77,6 +78,27 @@ func TestBusinessManagerCreate(t *testing.T) {
 	assert.Truef(t, bannerImage.PublicId == dbBusiness.BannerImageId || bannerImage.PublicId == dbBusiness.IconImageId, "invalid banner image id")
 	assert.Truef(t, iconImage.PublicId == dbBusiness.BannerImageId || iconImage.PublicId == dbBusiness.IconImageId, "invalid icon image id")
 	assert.Equalf(t, dbBusiness.Name, business.Name, "business name does not match")
+
+	var dbImages []FileMetadata
+	tx := manager.baseServices.Database.
+		Find(&dbImages, "public_id IN ?", []string{bannerImage.PublicId, iconImage.PublicId})
+	require.Nilf(t, tx.GetError(), "database find for FileMetadata returned an error")
+	for _, image := range dbImages {
+		require.NotNilf(t, image.BusinessId, "business images should belong to the business")
+		assert.Equalf(t, business.ID, *image.BusinessId, "business images should belong to the business")
+	}
+
+	var dbMember BusinessMember
+	tx = manager.baseServices.Database.First(&dbMember, &BusinessMember{OwnerId: user.ID})
+	require.Nilf(t, tx.GetError(), "owner should be a member of the business")
+	assert.Equalf(t, business.ID, dbMember.BusinessId, "owner member has invalid business")
+	assert.Equalf(t, BusinessMemberRoleEnum(BusinessMemberRoleOwner), dbMember.Role, "owner member has invalid role")
+
+	locations, err := manager.GetLocations(business)
+	require.Nilf(t, err, "BusinessManager.GetLocations returned an error")
+	require.Lenf(t, locations, 1, "business should have a location at its address")
+	assert.Equalf(t, details.Address, locations[0].Address, "location address does not match")
+	assert.Equalf(t, details.GPSCoordinates, locations[0].GPSCoordinates, "location coordinates do not match")
 }
 
 func TestBusinessManagerCreateAccountAlreadyExists(t *testing.T) {
@@ -191,7 +213,7 @@ func TestBusinessManagerAddMenuImage(t *testing.T) {
 	menuImageMetadata := GetTestFileMetadata(manager.baseServices.Database, user)
 	manager.fileStorageService.(*MockFileStorageService).
 		EXPECT().
-		CreateStub(user).
+		CreateStub(user, business, FilePurposeMenuImage).
 		Return(menuImageMetadata, nil)
 
 	menuImage, err := manager.AddMenuImage(user, business)
@@ -219,7 +241,7 @@ func TestBusinessManagerAddMenuImageTooManyImages(t *testing.T) {
 			menuImageMetadata := GetTestFileMetadata(manager.baseServices.Database, user)
 			manager.fileStorageService.(*MockFileStorageService).
 				EXPECT().
-				CreateStub(user).
+				CreateStub(user, business, FilePurposeMenuImage).
 				Return(menuImageMetadata, nil)
 		}
 
@@ -246,7 +268,7 @@ func TestBusinessManagerRemoveMenuImage(t *testing.T) {
 	menuImageMetadata := GetTestFileMetadata(manager.baseServices.Database, user)
 	manager.fileStorageService.(*MockFileStorageService).
 		EXPECT().
-		CreateStub(user).
+		CreateStub(user, business, FilePurposeMenuImage).
 		Return(menuImageMetadata, nil)
 
 	menuImage, err := manager.AddMenuImage(user, business)
@@ -280,14 +302,17 @@ func TestBusinessManagerSearchExistingByName(t *testing.T) {
 	user := GetTestUser(manager.baseServices.Database)
 	business := GetTestBusiness(manager.baseServices.Database, user)
 
-	result, err := manager.Search(&business.Name, nil, 0, 0, 5)
+	result, err := manager.Search(&BusinessSearchQuery{Text: &business.Name, Page: Page{Limit: 5}})
 	require.Nilf(t, err, "BusinessManager.Search returned an error")
-	require.Equalf(t, 1, len(result), "BusinessManager.Search returned more or less than one result")
-	require.Equalf(t, business.Name, result[0].Name, "BusinessManager.Search returned invalid busines")
+	require.Equalf(t, 1, len(result.Businesses), "BusinessManager.Search returned more or less than one result")
+	require.Equalf(t, business.Name, result.Businesses[0].Name, "BusinessManager.Search returned invalid busines")
 
-	resultNone, errNone := manager.Search(Ptr("no such business"), nil, 0, 0, 5)
+	resultNone, errNone := manager.Search(&BusinessSearchQuery{
+		Text: Ptr("no such business"),
+		Page: Page{Limit: 5},
+	})
 	require.Nilf(t, errNone, "BusinessManager.Search returned an error")
-	require.Equalf(t, 0, len(resultNone), "BusinessManager.Search returned more than one result")
+	require.Equalf(t, 0, len(resultNone.Businesses), "BusinessManager.Search returned more than one result")
 }
 
 func TestBusinessManagerSearchExistingByLocation(t *testing.T) {
@@ -297,14 +322,22 @@ func TestBusinessManagerSearchExistingByLocation(t *testing.T) {
 	user := GetTestUser(manager.baseServices.Database)
 	business := GetTestBusiness(manager.baseServices.Database, user)
 
-	result, err := manager.Search(nil, Ptr(FromCoords(27.59161, 086.56401)), 100, 0, 5)
+	result, err := manager.Search(&BusinessSearchQuery{
+		Location:          Ptr(FromCoords(27.59161, 086.56401)),
+		ProximityInMeters: 100,
+		Page:              Page{Limit: 5},
+	})
 	require.Nilf(t, err, "BusinessManager.Search returned an error")
-	require.Equalf(t, 1, len(result), "BusinessManager.Search returned more or less than one result")
-	require.Equalf(t, business.Name, result[0].Name, "BusinessManager.Search returned invalid busines")
-
-	resultNone, errNone := manager.Search(nil, Ptr(FromCoords(27.69161, 086.16401)), 100, 0, 5)
+	require.Equalf(t, 1, len(result.Businesses), "BusinessManager.Search returned more or less than one result")
+	require.Equalf(t, business.Name, result.Businesses[0].Name, "BusinessManager.Search returned invalid busines")
+
+	resultNone, errNone := manager.Search(&BusinessSearchQuery{
+		Location:          Ptr(FromCoords(27.69161, 086.16401)),
+		ProximityInMeters: 100,
+		Page:              Page{Limit: 5},
+	})
 	require.Nilf(t, errNone, "BusinessManager.Search returned an error")
-	require.Equalf(t, 0, len(resultNone), "BusinessManager.Search returned more than one result")
+	require.Equalf(t, 0, len(resultNone.Businesses), "BusinessManager.Search returned more than one result")
 }
 
 func TestBusinessManagerSearchExistingByNameAndLocation(t *testing.T) {
@@ -314,18 +347,406 @@ func TestBusinessManagerSearchExistingByNameAndLocation(t *testing.T) {
 	user := GetTestUser(manager.baseServices.Database)
 	business := GetTestBusiness(manager.baseServices.Database, user)
 
-	result, err := manager.Search(&business.Name, Ptr(FromCoords(27.59161, 086.56401)), 100, 0, 5)
+	result, err := manager.Search(&BusinessSearchQuery{
+		Text:              &business.Name,
+		Location:          Ptr(FromCoords(27.59161, 086.56401)),
+		ProximityInMeters: 100,
+		Page:              Page{Limit: 5},
+	})
 	require.Nilf(t, err, "BusinessManager.Search returned an error")
-	require.Equalf(t, 1, len(result), "BusinessManager.Search returned more or less than one result")
-	require.Equalf(t, business.Name, result[0].Name, "BusinessManager.Search returned invalid busines")
-
-	resultNone, errNone := manager.Search(&business.Name, Ptr(FromCoords(27.19161, 086.86401)), 100, 0, 5)
+	require.Equalf(t, 1, len(result.Businesses), "BusinessManager.Search returned more or less than one result")
+	require.Equalf(t, business.Name, result.Businesses[0].Name, "BusinessManager.Search returned invalid busines")
+
+	resultNone, errNone := manager.Search(&BusinessSearchQuery{
+		Text:              &business.Name,
+		Location:          Ptr(FromCoords(27.19161, 086.86401)),
+		ProximityInMeters: 100,
+		Page:              Page{Limit: 5},
+	})
 	require.Nilf(t, errNone, "BusinessManager.Search returned an error")
-	require.Equalf(t, 0, len(resultNone), "BusinessManager.Search returned more than one result")
-
-	resultNone, errNone = manager.Search(Ptr("invalid name"), Ptr(FromCoords(27.59161, 086.56401)), 100, 0, 5)
+	require.Equalf(t, 0, len(resultNone.Businesses), "BusinessManager.Search returned more than one result")
+
+	resultNone, errNone = manager.Search(&BusinessSearchQuery{
+		Text:              Ptr("invalid name"),
+		Location:          Ptr(FromCoords(27.59161, 086.56401)),
+		ProximityInMeters: 100,
+		Page:              Page{Limit: 5},
+	})
 	require.Nilf(t, errNone, "BusinessManager.Search returned an error")
-	require.Equalf(t, 0, len(resultNone), "BusinessManager.Search returned more than one result")
+	require.Equalf(t, 0, len(resultNone.Businesses), "BusinessManager.Search returned more than one result")
+}
+
+func TestBusinessManagerSearchSortByDistance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetBusinessManager(ctrl)
+	db := manager.baseServices.Database
+	far := GetTestBusiness(db, GetTestUser(db))
+	MoveTestBusiness(db, far, FromCoords(27.5946, 086.5640))
+	near := GetTestBusiness(db, GetTestUser(db))
+
+	result, err := manager.Search(&BusinessSearchQuery{
+		Location:          Ptr(FromCoords(27.5916, 086.5641)),
+		ProximityInMeters: 1000,
+		Page:              Page{Limit: 5},
+	})
+	require.Nilf(t, err, "BusinessManager.Search returned an error")
+	require.Equalf(t, 2, len(result.Businesses), "BusinessManager.Search should return both businesses")
+	require.Equalf(t, near.PublicId, result.Businesses[0].PublicId, "nearest business should be first")
+	require.Equalf(t, far.PublicId, result.Businesses[1].PublicId, "farthest business should be last")
+	require.NotNilf(t, result.Businesses[0].DistanceInMeters, "BusinessManager.Search should return distance")
+	require.InDeltaf(t, 11.0, *result.Businesses[0].DistanceInMeters, 2.0, "BusinessManager.Search returned invalid distance")
+	require.Lessf(t, *result.Businesses[0].DistanceInMeters, *result.Businesses[1].DistanceInMeters,
+		"BusinessManager.Search returned invalid distance")
+
+	result, err = manager.Search(&BusinessSearchQuery{Text: Ptr("test business"), Page: Page{Limit: 5},
+		Sort: BusinessSearchSortNewest})
+	require.Nilf(t, err, "BusinessManager.Search returned an error")
+	require.Equalf(t, near.PublicId, result.Businesses[0].PublicId, "newest business should be first")
+	require.Nilf(t, result.Businesses[0].DistanceInMeters, "distance should be nil without location")
+
+	_, err = manager.Search(&BusinessSearchQuery{Text: Ptr("test business"), Page: Page{Limit: 5},
+		Sort: BusinessSearchSortDistance})
+	require.Equalf(t, ErrInvalidSort, err, "sort by distance without location should return ErrInvalidSort")
+}
+
+func TestBusinessManagerSearchPages(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetBusinessManager(ctrl)
+	db := manager.baseServices.Database
+	first := GetTestBusiness(db, GetTestUser(db))
+	second := GetTestBusiness(db, GetTestUser(db))
+	MoveTestBusiness(db, second, FromCoords(27.5926, 086.5640))
+	third := GetTestBusiness(db, GetTestUser(db))
+	MoveTestBusiness(db, third, FromCoords(27.5936, 086.5640))
+
+	query := &BusinessSearchQuery{
+		Location:          Ptr(FromCoords(27.5916, 086.5640)),
+		ProximityInMeters: 1000,
+		Page:              Page{Limit: 2},
+	}
+	result, err := manager.Search(query)
+	require.Nilf(t, err, "BusinessManager.Search returned an error")
+	require.Equalf(t, 2, len(result.Businesses), "first page should have two businesses")
+	require.Equalf(t, first.PublicId, result.Businesses[0].PublicId, "first page has unexpected first business")
+	require.Equalf(t, second.PublicId, result.Businesses[1].PublicId, "first page has unexpected second business")
+	require.NotEmptyf(t, result.NextCursor, "first page should have cursor of the next page")
+
+	query.Page.Cursor = result.NextCursor
+	result, err = manager.Search(query)
+	require.Nilf(t, err, "BusinessManager.Search returned an error")
+	require.Equalf(t, 1, len(result.Businesses), "last page should have one business")
+	require.Equalf(t, third.PublicId, result.Businesses[0].PublicId, "last page has unexpected business")
+	require.Emptyf(t, result.NextCursor, "last page should not have cursor of the next page")
+
+	query.Sort = BusinessSearchSortNewest
+	_, err = manager.Search(query)
+	require.Equalf(t, ErrInvalidCursor, err, "cursor of a different sort should return ErrInvalidCursor")
+}
+
+func TestBusinessManagerSearchByCategory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetBusinessManager(ctrl)
+	cafe := GetTestBusiness(manager.baseServices.Database, GetTestUser(manager.baseServices.Database))
+	_, err := manager.ChangeDetails(cafe, &ChangeableBusinessDetails{Categories: &[]string{"cafe", "bakery"}})
+	require.Nilf(t, err, "BusinessManager.ChangeDetails returned an error")
+	barber := GetTestBusiness(manager.baseServices.Database, GetTestUser(manager.baseServices.Database))
+	_, err = manager.ChangeDetails(barber, &ChangeableBusinessDetails{Categories: &[]string{"barber"}})
+	require.Nilf(t, err, "BusinessManager.ChangeDetails returned an error")
+
+	result, err := manager.Search(&BusinessSearchQuery{
+		Text:       Ptr("test business"),
+		Categories: []string{"cafe", "restaurant"},
+		Page:       Page{Limit: 5},
+	})
+	require.Nilf(t, err, "BusinessManager.Search returned an error")
+	require.Equalf(t, 1, len(result.Businesses), "BusinessManager.Search returned more or less than one result")
+	require.Equalf(t, cafe.PublicId, result.Businesses[0].PublicId, "BusinessManager.Search returned invalid business")
+	require.Equalf(t, []string{"bakery", "cafe"},
+		[]string{result.Businesses[0].Categories[0].Slug, result.Businesses[0].Categories[1].Slug},
+		"BusinessManager.Search returned invalid categories")
+	require.Equalf(t, map[string]uint{"bakery": 1, "barber": 1, "cafe": 1}, result.CategoryCounts,
+		"BusinessManager.Search returned invalid category counts")
+
+	_, err = manager.Search(&BusinessSearchQuery{Categories: []string{"spaceport"}, Page: Page{Limit: 5}})
+	require.Equalf(t, ErrInvalidCategory, err, "BusinessManager.Search should return ErrInvalidCategory")
+}
+
+func TestBusinessManagerChangeDetailsCategories(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetBusinessManager(ctrl)
+	business := GetTestBusiness(manager.baseServices.Database, GetTestUser(manager.baseServices.Database))
+
+	_, err := manager.ChangeDetails(business, &ChangeableBusinessDetails{Categories: &[]string{"cafe", "bakery"}})
+	require.Nilf(t, err, "BusinessManager.ChangeDetails returned an error")
+	_, err = manager.ChangeDetails(business, &ChangeableBusinessDetails{Categories: &[]string{"bar"}})
+	require.Nilf(t, err, "BusinessManager.ChangeDetails returned an error")
+
+	categories, err := manager.GetBusinessCategories(business)
+	require.Nilf(t, err, "BusinessManager.GetBusinessCategories returned an error")
+	require.Equalf(t, 1, len(categories), "categories should be replaced")
+	require.Equalf(t, "bar", categories[0].Slug, "categories should be replaced")
+
+	_, err = manager.ChangeDetails(business, &ChangeableBusinessDetails{
+		Name:       Ptr("changed name"),
+		Categories: &[]string{"bar", "spaceport"},
+	})
+	require.Equalf(t, ErrInvalidCategory, err, "BusinessManager.ChangeDetails should return ErrInvalidCategory")
+	require.NotEqualf(t, "changed name", business.Name, "business should not change when a category is invalid")
+}
+
+func TestBusinessManagerChangeDetailsOpeningHours(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetBusinessManager(ctrl)
+	db := manager.baseServices.Database
+	business := GetTestBusiness(db, GetTestUser(db))
+	christmas := time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC)
+
+	_, err := manager.ChangeDetails(business, &ChangeableBusinessDetails{
+		TimeZone: Ptr("Europe/Warsaw"),
+		OpeningHours: &[]OpeningHours{
+			{Weekday: time.Monday, OpensAt: 8 * 60, ClosesAt: 16 * 60},
+			{Weekday: time.Tuesday, OpensAt: 8 * 60, ClosesAt: 16 * 60},
+		},
+		OpeningHoursExceptions: &[]OpeningHoursException{{Date: christmas, Closed: true}},
+	})
+	require.Nilf(t, err, "BusinessManager.ChangeDetails returned an error")
+
+	// replaces all opening hours
+	_, err = manager.ChangeDetails(business, &ChangeableBusinessDetails{
+		OpeningHours: &[]OpeningHours{{Weekday: time.Friday, OpensAt: 18 * 60, ClosesAt: 24 * 60}},
+	})
+	require.Nilf(t, err, "BusinessManager.ChangeDetails returned an error")
+
+	dbBusiness, err := manager.GetById(business.PublicId, true)
+	require.Nilf(t, err, "BusinessManager.GetById returned an error")
+	require.Equalf(t, "Europe/Warsaw", dbBusiness.TimeZone, "business time zone does not match")
+	require.Lenf(t, dbBusiness.OpeningHours, 1, "opening hours should be replaced")
+	require.Equalf(t, time.Friday, dbBusiness.OpeningHours[0].Weekday, "opening hours do not match")
+	require.Equalf(t, uint(24*60), dbBusiness.OpeningHours[0].ClosesAt, "opening hours do not match")
+	require.Lenf(t, dbBusiness.OpeningHoursExceptions, 1, "opening hours exceptions should not be changed")
+	require.Equalf(t, christmas.Format(time.DateOnly), dbBusiness.OpeningHoursExceptions[0].Date.Format(time.DateOnly),
+		"opening hours exception date does not match")
+
+	// Friday 2023-12-22 20:00 in Warsaw
+	require.Truef(t, dbBusiness.IsOpenAt(time.Date(2023, 12, 22, 19, 0, 0, 0, time.UTC)), "business should be open")
+	require.Falsef(t, dbBusiness.IsOpenAt(time.Date(2023, 12, 22, 16, 0, 0, 0, time.UTC)), "business should be closed")
+
+	hours, exceptions, err := manager.GetOpeningHours(business)
+	require.Nilf(t, err, "BusinessManager.GetOpeningHours returned an error")
+	require.Lenf(t, hours, 1, "BusinessManager.GetOpeningHours returned unexpected opening hours")
+	require.Lenf(t, exceptions, 1, "BusinessManager.GetOpeningHours returned unexpected exceptions")
+}
+
+func TestBusinessManagerChangeDetailsInvalidOpeningHours(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetBusinessManager(ctrl)
+	db := manager.baseServices.Database
+	business := GetTestBusiness(db, GetTestUser(db))
+	christmas := time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC)
+
+	_, err := manager.ChangeDetails(business, &ChangeableBusinessDetails{TimeZone: Ptr("Mars/Olympus_Mons")})
+	require.Equalf(t, ErrInvalidTimeZone, err, "BusinessManager.ChangeDetails accepted invalid time zone")
+
+	for _, details := range []ChangeableBusinessDetails{
+		{OpeningHours: &[]OpeningHours{{Weekday: 7, OpensAt: 8 * 60, ClosesAt: 16 * 60}}},
+		{OpeningHours: &[]OpeningHours{{Weekday: time.Monday, OpensAt: 16 * 60, ClosesAt: 8 * 60}}},
+		{OpeningHours: &[]OpeningHours{{Weekday: time.Monday, OpensAt: 8 * 60, ClosesAt: 25 * 60}}},
+		{OpeningHoursExceptions: &[]OpeningHoursException{
+			{Date: christmas, Closed: true},
+			{Date: christmas, OpensAt: 8 * 60, ClosesAt: 12 * 60},
+		}},
+	} {
+		_, err := manager.ChangeDetails(business, &details)
+		require.Equalf(t, ErrInvalidOpeningHours, err, "BusinessManager.ChangeDetails accepted invalid opening hours")
+	}
+}
+
+func TestBusinessManagerSearchOpenAt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetBusinessManager(ctrl)
+	db := manager.baseServices.Database
+	christmas := time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC)
+
+	// open on Mondays in Warsaw, closed on Christmas
+	warsaw := GetTestBusiness(db, GetTestUser(db))
+	_, err := manager.ChangeDetails(warsaw, &ChangeableBusinessDetails{
+		TimeZone:               Ptr("Europe/Warsaw"),
+		OpeningHours:           &[]OpeningHours{{Weekday: time.Monday, OpensAt: 8 * 60, ClosesAt: 16 * 60}},
+		OpeningHoursExceptions: &[]OpeningHoursException{{Date: christmas, Closed: true}},
+	})
+	require.Nilf(t, err, "BusinessManager.ChangeDetails returned an error")
+	// open on Mondays in New York
+	newYork := GetTestBusiness(db, GetTestUser(db))
+	_, err = manager.ChangeDetails(newYork, &ChangeableBusinessDetails{
+		TimeZone:     Ptr("America/New_York"),
+		OpeningHours: &[]OpeningHours{{Weekday: time.Monday, OpensAt: 8 * 60, ClosesAt: 16 * 60}},
+	})
+	require.Nilf(t, err, "BusinessManager.ChangeDetails returned an error")
+	// no opening hours
+	_ = GetTestBusiness(db, GetTestUser(db))
+
+	search := func(openAt time.Time) []string {
+		result, err := manager.Search(&BusinessSearchQuery{
+			Text:   Ptr("test business"),
+			OpenAt: &openAt,
+			Page:   Page{Limit: 5},
+		})
+		require.Nilf(t, err, "BusinessManager.Search returned an error")
+		var ids []string
+		for _, business := range result.Businesses {
+			ids = append(ids, business.PublicId)
+		}
+		return ids
+	}
+
+	// Monday 2023-12-18 10:00 in Warsaw, 4:00 in New York
+	require.Equalf(t, []string{warsaw.PublicId}, search(time.Date(2023, 12, 18, 9, 0, 0, 0, time.UTC)),
+		"only the business in Warsaw should be open")
+	// Monday 2023-12-18 16:00 in Warsaw, 10:00 in New York
+	require.Equalf(t, []string{newYork.PublicId}, search(time.Date(2023, 12, 18, 15, 0, 0, 0, time.UTC)),
+		"only the business in New York should be open")
+	// Christmas 10:00 in Warsaw
+	require.Emptyf(t, search(time.Date(2023, 12, 25, 9, 0, 0, 0, time.UTC)),
+		"no business should be open")
+}
+
+func TestBusinessManagerLocations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetBusinessManager(ctrl)
+	db := manager.baseServices.Database
+	business := GetTestBusiness(db, GetTestUser(db))
+
+	location, err := manager.AddLocation(business, &BusinessLocationDetails{
+		Name:           "second location",
+		Address:        "second address",
+		GPSCoordinates: FromCoords(27.6016, 086.5640),
+		OpeningHours:   []OpeningHours{{Weekday: time.Monday, OpensAt: 8 * 60, ClosesAt: 16 * 60}},
+	})
+	require.Nilf(t, err, "BusinessManager.AddLocation returned an error")
+	require.NotEmptyf(t, location.PublicId, "location should have a public id")
+
+	_, err = manager.AddLocation(business, &BusinessLocationDetails{Address: "no name"})
+	require.Equalf(t, ErrInvalidLocation, err, "BusinessManager.AddLocation accepted location without name")
+
+	location, err = manager.ChangeLocation(location, &ChangeableBusinessLocationDetails{
+		Name:                   Ptr("changed name"),
+		OpeningHoursExceptions: &[]OpeningHoursException{{Date: time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC), Closed: true}},
+	})
+	require.Nilf(t, err, "BusinessManager.ChangeLocation returned an error")
+
+	locations, err := manager.GetLocations(business)
+	require.Nilf(t, err, "BusinessManager.GetLocations returned an error")
+	require.Lenf(t, locations, 2, "business should have two locations")
+	require.Equalf(t, "changed name", locations[1].Name, "location name should be changed")
+	require.Lenf(t, locations[1].OpeningHours, 1, "location opening hours should not be changed")
+	require.Lenf(t, locations[1].OpeningHoursExceptions, 1, "location exceptions should be changed")
+	require.Emptyf(t, locations[0].OpeningHours, "first location should not have its own opening hours")
+
+	// own opening hours of locations are not opening hours of the business
+	hours, _, err := manager.GetOpeningHours(business)
+	require.Nilf(t, err, "BusinessManager.GetOpeningHours returned an error")
+	require.Emptyf(t, hours, "business should not have opening hours of its location")
+
+	require.Nilf(t, manager.RemoveLocation(&locations[0]), "BusinessManager.RemoveLocation returned an error")
+	require.Equalf(t, ErrLastLocation, manager.RemoveLocation(location),
+		"BusinessManager.RemoveLocation should not remove the last location")
+
+	locations, err = manager.GetLocations(business)
+	require.Nilf(t, err, "BusinessManager.GetLocations returned an error")
+	require.Lenf(t, locations, 1, "business should have one location")
+	require.Equalf(t, location.PublicId, locations[0].PublicId, "unexpected location was removed")
+}
+
+func TestBusinessManagerSearchNearestLocation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetBusinessManager(ctrl)
+	db := manager.baseServices.Database
+	business := GetTestBusiness(db, GetTestUser(db))
+	MoveTestBusiness(db, business, FromCoords(28.5916, 086.5640))
+	branch := GetTestBusinessLocation(db, business, FromCoords(27.5916, 086.5640))
+
+	result, err := manager.Search(&BusinessSearchQuery{
+		Location:          Ptr(FromCoords(27.5916, 086.5641)),
+		ProximityInMeters: 1000,
+		Page:              Page{Limit: 5},
+	})
+	require.Nilf(t, err, "BusinessManager.Search returned an error")
+	require.Lenf(t, result.Businesses, 1, "business should be found by its branch")
+	require.Equalf(t, business.PublicId, result.Businesses[0].PublicId, "BusinessManager.Search returned unexpected business")
+	require.NotNilf(t, result.Businesses[0].NearestLocation, "BusinessManager.Search should return nearest location")
+	require.Equalf(t, branch.PublicId, result.Businesses[0].NearestLocation.PublicId,
+		"BusinessManager.Search returned unexpected nearest location")
+	require.InDeltaf(t, 11.0, *result.Businesses[0].DistanceInMeters, 2.0, "BusinessManager.Search returned invalid distance")
+}
+
+func TestBusinessManagerSearchOpenAtLocation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetBusinessManager(ctrl)
+	db := manager.baseServices.Database
+
+	// open on Mondays, only its second location is open on Sundays
+	business := GetTestBusiness(db, GetTestUser(db))
+	_, err := manager.ChangeDetails(business, &ChangeableBusinessDetails{
+		OpeningHours: &[]OpeningHours{{Weekday: time.Monday, OpensAt: 8 * 60, ClosesAt: 16 * 60}},
+	})
+	require.Nilf(t, err, "BusinessManager.ChangeDetails returned an error")
+	_, err = manager.AddLocation(business, &BusinessLocationDetails{
+		Name:           "second location",
+		Address:        "second address",
+		GPSCoordinates: FromCoords(27.6016, 086.5640),
+		OpeningHours:   []OpeningHours{{Weekday: time.Sunday, OpensAt: 8 * 60, ClosesAt: 16 * 60}},
+	})
+	require.Nilf(t, err, "BusinessManager.AddLocation returned an error")
+
+	search := func(openAt time.Time) int {
+		result, err := manager.Search(&BusinessSearchQuery{
+			Text:   Ptr("test business"),
+			OpenAt: &openAt,
+			Page:   Page{Limit: 5},
+		})
+		require.Nilf(t, err, "BusinessManager.Search returned an error")
+		return len(result.Businesses)
+	}
+
+	// Monday 2023-12-18 10:00, first location is open
+	require.Equalf(t, 1, search(time.Date(2023, 12, 18, 10, 0, 0, 0, time.UTC)), "business should be open")
+	// Sunday 2023-12-17 10:00, second location is open
+	require.Equalf(t, 1, search(time.Date(2023, 12, 17, 10, 0, 0, 0, time.UTC)), "business should be open")
+	// Tuesday 2023-12-19 10:00
+	require.Equalf(t, 0, search(time.Date(2023, 12, 19, 10, 0, 0, 0, time.UTC)), "business should be closed")
+
+	dbBusiness, err := manager.GetById(business.PublicId, true)
+	require.Nilf(t, err, "BusinessManager.GetById returned an error")
+	require.Lenf(t, dbBusiness.OpeningHours, 1, "business should not have opening hours of its location")
+	require.Lenf(t, dbBusiness.Locations, 2, "BusinessManager.GetById should preload locations")
+	require.Truef(t, dbBusiness.IsOpenAt(time.Date(2023, 12, 17, 10, 0, 0, 0, time.UTC)), "business should be open")
+	require.Falsef(t, dbBusiness.IsLocationOpenAt(&dbBusiness.Locations[0], time.Date(2023, 12, 17, 10, 0, 0, 0, time.UTC)),
+		"first location should be closed")
+}
+
+func TestBusinessManagerGetCategories(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetBusinessManager(ctrl)
+
+	categories, err := manager.GetCategories()
+	require.Nilf(t, err, "BusinessManager.GetCategories returned an error")
+	var slugs []string
+	for _, category := range categories {
+		slugs = append(slugs, category.Slug)
+	}
+	require.Containsf(t, slugs, "cafe", "categories should contain cafe")
+	require.Containsf(t, slugs, "barber", "categories should contain barber")
 }
 
 func TestBusinessManagerGetById(t *testing.T) {
@@ -0,0 +1,244 @@
+package managers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/lithammer/shortuuid/v4"
+	"gorm.io/gorm"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/services"
+)
+
+var (
+	ErrAlreadyMember      = errors.New("User is already a member of a business")
+	ErrNotMember          = errors.New("User is not a member of any business")
+	ErrNoSuchInvitation   = errors.New("Invitation not found")
+	ErrInvalidRole        = errors.New("Invalid role")
+	ErrCannotRemoveOwner  = errors.New("Owner cannot be removed from business")
+	ErrNotBusinessManager = errors.New("User is not an owner or a manager of business")
+)
+
+type BusinessMemberManager interface {
+	// Creates an invitation to business with role for email and sends it to that email.
+	// Only BusinessMemberRoleManager and BusinessMemberRoleCashier can be granted.
+	Invite(business *Business, email string, role BusinessMemberRoleEnum) (*BusinessInvitation, error)
+
+	// Returns invitations sent to email of user that were not accepted yet, with Business loaded.
+	GetInvitations(user *User) ([]BusinessInvitation, error)
+
+	// Accepts invitation with invitationId sent to email of user. User becomes a member of the business
+	// with role from the invitation.
+	AcceptInvitation(user *User, invitationId string) (*BusinessMember, error)
+
+	// Returns membership of user, with Business loaded. Returns ErrNotMember if user
+	// is not a member of any business.
+	GetMembership(user *User) (*BusinessMember, error)
+
+	// Returns all members of business, including the owner, with User loaded.
+	GetMembers(business *Business) ([]BusinessMember, error)
+
+	// Removes member from their business. Owner cannot be removed.
+	RemoveMember(member *BusinessMember) error
+}
+
+type BusinessMemberManagerImpl struct {
+	baseServices   BaseServices
+	emailOutbox    EmailOutbox
+	emailTemplates EmailTemplates
+}
+
+func CreateBusinessMemberManagerImpl(baseServices BaseServices, emailOutbox EmailOutbox,
+	emailTemplates EmailTemplates) *BusinessMemberManagerImpl {
+	return &BusinessMemberManagerImpl{
+		baseServices:   baseServices,
+		emailOutbox:    emailOutbox,
+		emailTemplates: emailTemplates,
+	}
+}
+
+// Checks if user is the owner or a manager of business. Used by managers for actions that
+// cashiers are not allowed to do.
+func checkBusinessManager(db GormDB, user *User, business *Business) error {
+	if user.ID == business.OwnerId {
+		return nil
+	}
+	var member BusinessMember
+	result := db.First(&member, "owner_id = ? AND business_id = ?", user.ID, business.ID)
+	if err := result.GetError(); err == gorm.ErrRecordNotFound {
+		return ErrNotBusinessManager
+	} else if err != nil {
+		return fmt.Errorf("db.First(BusinessMember) returned an error: %w", err)
+	}
+	if member.Role != BusinessMemberRoleOwner && member.Role != BusinessMemberRoleManager {
+		return ErrNotBusinessManager
+	}
+	return nil
+}
+
+func (manager *BusinessMemberManagerImpl) Invite(business *Business, email string,
+	role BusinessMemberRoleEnum) (*BusinessInvitation, error) {
+
+	if _, err := mail.ParseAddress(email); err != nil {
+		return nil, ErrInvalidEmail
+	}
+	if role != BusinessMemberRoleManager && role != BusinessMemberRoleCashier {
+		return nil, ErrInvalidRole
+	}
+
+	var invitation BusinessInvitation
+	err := manager.baseServices.Database.Transaction(func(db GormDB) error {
+		// Don't invite users that are already members of this business
+		var count int64
+		result := db.Model(&BusinessMember{}).
+			Joins("JOIN users ON users.id = business_members.owner_id").
+			Where("business_members.business_id = ? AND users.email = ?", business.ID, email).
+			Count(&count)
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("db.Count(BusinessMember) returned an error: %w", err)
+		}
+		if count != 0 {
+			return ErrAlreadyMember
+		}
+
+		invitation = BusinessInvitation{
+			PublicId:   shortuuid.New(),
+			BusinessId: business.ID,
+			Email:      email,
+			Role:       role,
+		}
+		result = db.Create(&invitation)
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("db.Create(BusinessInvitation) returned an error: %w", err)
+		}
+
+		// Invited email may not have an account yet, then the default locale is used
+		var invited User
+		result = db.Where("email = ?", email).Limit(1).Find(&invited)
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("db.Find(User) returned an error: %w", err)
+		}
+		message, err := manager.emailTemplates.Render(EmailKindBusinessInvitation, invited.Locale, struct {
+			BusinessName string
+			Role         BusinessMemberRoleEnum
+		}{
+			BusinessName: business.Name,
+			Role:         role,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to render email: %w", err)
+		}
+		outboxTx, err := manager.emailOutbox.WithTransaction(db)
+		if err != nil {
+			return fmt.Errorf("emailOutbox.WithTransaction returned an error: %w", err)
+		}
+		err = outboxTx.Enqueue(email, *message)
+		if err != nil {
+			return fmt.Errorf("failed to queue email, emailoutbox error: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (manager *BusinessMemberManagerImpl) GetInvitations(user *User) ([]BusinessInvitation, error) {
+	var invitations []BusinessInvitation
+	result := manager.baseServices.Database.
+		Preload("Business").
+		Where("email = ? AND accepted IS NULL", user.Email).
+		Order("created_at desc").
+		Find(&invitations)
+	if err := result.GetError(); err != nil {
+		return nil, fmt.Errorf("db.Find(BusinessInvitation) returned an error: %w", err)
+	}
+	return invitations, nil
+}
+
+func (manager *BusinessMemberManagerImpl) AcceptInvitation(user *User, invitationId string) (*BusinessMember, error) {
+	var member BusinessMember
+	err := manager.baseServices.Database.Transaction(func(db GormDB) error {
+		var invitation BusinessInvitation
+		result := db.First(&invitation, "public_id = ? AND accepted IS NULL", invitationId)
+		if err := result.GetError(); err == gorm.ErrRecordNotFound {
+			return ErrNoSuchInvitation
+		} else if err != nil {
+			return fmt.Errorf("db.First(BusinessInvitation) returned an error: %w", err)
+		}
+		if invitation.Email != user.Email {
+			return ErrNoSuchInvitation
+		}
+
+		result = db.First(&BusinessMember{}, "owner_id = ?", user.ID)
+		if err := result.GetError(); err == nil {
+			return ErrAlreadyMember
+		} else if err != gorm.ErrRecordNotFound {
+			return fmt.Errorf("db.First(BusinessMember) returned an error: %w", err)
+		}
+
+		member = BusinessMember{
+			PublicId:   shortuuid.New(),
+			BusinessId: invitation.BusinessId,
+			OwnerId:    user.ID,
+			Role:       invitation.Role,
+		}
+		result = db.Create(&member)
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("db.Create(BusinessMember) returned an error: %w", err)
+		}
+
+		result = db.Model(&invitation).Update("accepted", sql.NullTime{Time: time.Now(), Valid: true})
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("db.Update(BusinessInvitation) returned an error: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (manager *BusinessMemberManagerImpl) GetMembership(user *User) (*BusinessMember, error) {
+	var member BusinessMember
+	result := manager.baseServices.Database.
+		Preload("Business").
+		First(&member, "owner_id = ?", user.ID)
+	if err := result.GetError(); err == gorm.ErrRecordNotFound {
+		return nil, ErrNotMember
+	} else if err != nil {
+		return nil, fmt.Errorf("db.First(BusinessMember) returned an error: %w", err)
+	}
+	return &member, nil
+}
+
+func (manager *BusinessMemberManagerImpl) GetMembers(business *Business) ([]BusinessMember, error) {
+	var members []BusinessMember
+	result := manager.baseServices.Database.
+		Preload("User").
+		Where("business_id = ?", business.ID).
+		Order("created_at, id").
+		Find(&members)
+	if err := result.GetError(); err != nil {
+		return nil, fmt.Errorf("db.Find(BusinessMember) returned an error: %w", err)
+	}
+	return members, nil
+}
+
+func (manager *BusinessMemberManagerImpl) RemoveMember(member *BusinessMember) error {
+	if member.Role == BusinessMemberRoleOwner {
+		return ErrCannotRemoveOwner
+	}
+	// OwnerId is unique, removed user has to be able to join a business again
+	result := manager.baseServices.Database.Unscoped().Delete(member)
+	if err := result.GetError(); err != nil {
+		return fmt.Errorf("db.Delete(BusinessMember) returned an error: %w", err)
+	}
+	return nil
+}
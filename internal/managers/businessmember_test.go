@@ -0,0 +1,198 @@
+package managers
+
+import (
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/services"
+	. "github.com/StampWallet/backend/internal/testutils"
+)
+
+func GetTestBusinessMemberManager(ctrl *gomock.Controller) *BusinessMemberManagerImpl {
+	baseServices := BaseServices{
+		Logger:   log.Default(),
+		Database: GetTestDatabase(),
+	}
+	return CreateBusinessMemberManagerImpl(
+		baseServices,
+		CreateEmailOutboxImpl(baseServices),
+		getTestEmailTemplates(),
+	)
+}
+
+func TestBusinessMemberManagerInvite(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestBusinessMemberManager(ctrl)
+	db := manager.baseServices.Database
+	business := GetTestBusiness(db, GetTestUser(db))
+	user := GetTestUser(db)
+
+	invitation, err := manager.Invite(business, user.Email, BusinessMemberRoleCashier)
+	require.Nilf(t, err, "BusinessMemberManager.Invite returned an error %w", err)
+	require.Equalf(t, business.ID, invitation.BusinessId, "invitation has invalid business")
+
+	var email EmailOutboxEntry
+	result := db.Where("recipient = ?", user.Email).First(&email)
+	require.Nilf(t, result.GetError(), "BusinessMemberManager.Invite should queue the invitation email")
+	require.Equalf(t, "invitation", email.Subject, "invitation email has invalid subject")
+	require.Equalf(t, "invited to "+business.Name+" as CASHIER", email.Body, "invitation email has invalid body")
+
+	invitations, err := manager.GetInvitations(user)
+	require.Nilf(t, err, "BusinessMemberManager.GetInvitations returned an error %w", err)
+	require.Lenf(t, invitations, 1, "BusinessMemberManager.GetInvitations should return sent invitation")
+	require.Equalf(t, invitation.PublicId, invitations[0].PublicId, "BusinessMemberManager.GetInvitations returned invalid invitation")
+	require.Equalf(t, business.Name, invitations[0].Business.Name, "BusinessMemberManager.GetInvitations should load business")
+}
+
+func TestBusinessMemberManagerInviteInUserLocale(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestBusinessMemberManager(ctrl)
+	db := manager.baseServices.Database
+	business := GetTestBusiness(db, GetTestUser(db))
+	user := GetTestUser(db)
+	user.Locale = "pl-PL"
+	Save(db, user)
+
+	_, err := manager.Invite(business, user.Email, BusinessMemberRoleManager)
+	require.Nilf(t, err, "BusinessMemberManager.Invite returned an error %w", err)
+
+	var email EmailOutboxEntry
+	result := db.Where("recipient = ?", user.Email).First(&email)
+	require.Nilf(t, result.GetError(), "BusinessMemberManager.Invite should queue the invitation email")
+	require.Equalf(t, "zaproszenie", email.Subject, "invitation email should be in the language of the user")
+	require.Equalf(t, "zaproszenie do "+business.Name+" jako MANAGER", email.Body,
+		"invitation email should be in the language of the user")
+}
+
+func TestBusinessMemberManagerInviteInvalidRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestBusinessMemberManager(ctrl)
+	db := manager.baseServices.Database
+	business := GetTestBusiness(db, GetTestUser(db))
+
+	_, err := manager.Invite(business, "user@example.com", BusinessMemberRoleOwner)
+	require.Equalf(t, ErrInvalidRole, err, "BusinessMemberManager.Invite should not invite owners")
+}
+
+func TestBusinessMemberManagerInviteExistingMember(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestBusinessMemberManager(ctrl)
+	db := manager.baseServices.Database
+	business := GetTestBusiness(db, GetTestUser(db))
+	user := GetTestUser(db)
+	GetTestBusinessMember(db, business, user, BusinessMemberRoleCashier)
+
+	_, err := manager.Invite(business, user.Email, BusinessMemberRoleManager)
+	require.Equalf(t, ErrAlreadyMember, err, "BusinessMemberManager.Invite should not invite existing members")
+}
+
+func TestBusinessMemberManagerAcceptInvitation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestBusinessMemberManager(ctrl)
+	db := manager.baseServices.Database
+	business := GetTestBusiness(db, GetTestUser(db))
+	user := GetTestUser(db)
+	invitation := GetTestBusinessInvitation(db, business, user.Email, BusinessMemberRoleManager)
+
+	member, err := manager.AcceptInvitation(user, invitation.PublicId)
+	require.Nilf(t, err, "BusinessMemberManager.AcceptInvitation returned an error %w", err)
+	require.Equalf(t, business.ID, member.BusinessId, "member has invalid business")
+	require.Equalf(t, BusinessMemberRoleEnum(BusinessMemberRoleManager), member.Role, "member has invalid role")
+
+	membership, err := manager.GetMembership(user)
+	require.Nilf(t, err, "BusinessMemberManager.GetMembership returned an error %w", err)
+	require.Equalf(t, member.ID, membership.ID, "BusinessMemberManager.GetMembership returned invalid member")
+	require.Equalf(t, business.ID, membership.Business.ID, "BusinessMemberManager.GetMembership should load business")
+
+	invitations, err := manager.GetInvitations(user)
+	require.Nilf(t, err, "BusinessMemberManager.GetInvitations returned an error %w", err)
+	require.Lenf(t, invitations, 0, "BusinessMemberManager.GetInvitations should not return accepted invitations")
+
+	_, err = manager.AcceptInvitation(user, invitation.PublicId)
+	require.Equalf(t, ErrNoSuchInvitation, err, "invitation should not be accepted twice")
+}
+
+func TestBusinessMemberManagerAcceptInvitationOtherEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestBusinessMemberManager(ctrl)
+	db := manager.baseServices.Database
+	business := GetTestBusiness(db, GetTestUser(db))
+	user := GetTestUser(db)
+	invitation := GetTestBusinessInvitation(db, business, strings.ToUpper(user.Email), BusinessMemberRoleManager)
+
+	_, err := manager.AcceptInvitation(GetTestUser(db), invitation.PublicId)
+	require.Equalf(t, ErrNoSuchInvitation, err, "invitation should not be accepted by another user")
+}
+
+func TestBusinessMemberManagerAcceptInvitationAlreadyMember(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestBusinessMemberManager(ctrl)
+	db := manager.baseServices.Database
+	business := GetTestBusiness(db, GetTestUser(db))
+	user := GetTestUser(db)
+	GetTestBusinessMember(db, GetTestBusiness(db, GetTestUser(db)), user, BusinessMemberRoleCashier)
+	invitation := GetTestBusinessInvitation(db, business, user.Email, BusinessMemberRoleManager)
+
+	_, err := manager.AcceptInvitation(user, invitation.PublicId)
+	require.Equalf(t, ErrAlreadyMember, err, "members of a business should not join another business")
+}
+
+func TestBusinessMemberManagerGetMembershipNotMember(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestBusinessMemberManager(ctrl)
+	db := manager.baseServices.Database
+
+	_, err := manager.GetMembership(GetTestUser(db))
+	require.Equalf(t, ErrNotMember, err, "BusinessMemberManager.GetMembership should return ErrNotMember")
+}
+
+func TestBusinessMemberManagerRemoveMember(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestBusinessMemberManager(ctrl)
+	db := manager.baseServices.Database
+	owner := GetTestUser(db)
+	business := GetTestBusiness(db, owner)
+	ownerMember := GetTestBusinessMember(db, business, owner, BusinessMemberRoleOwner)
+	cashier := GetTestUser(db)
+	cashierMember := GetTestBusinessMember(db, business, cashier, BusinessMemberRoleCashier)
+
+	members, err := manager.GetMembers(business)
+	require.Nilf(t, err, "BusinessMemberManager.GetMembers returned an error %w", err)
+	require.Lenf(t, members, 2, "BusinessMemberManager.GetMembers returned unexpected number of members")
+	require.Equalf(t, owner.Email, members[0].User.Email, "BusinessMemberManager.GetMembers should load users")
+
+	err = manager.RemoveMember(ownerMember)
+	require.Equalf(t, ErrCannotRemoveOwner, err, "BusinessMemberManager.RemoveMember should not remove owner")
+
+	err = manager.RemoveMember(cashierMember)
+	require.Nilf(t, err, "BusinessMemberManager.RemoveMember returned an error %w", err)
+	_, err = manager.GetMembership(cashier)
+	require.Equalf(t, ErrNotMember, err, "removed member should not be a member")
+
+	// removed user can be invited again
+	GetTestBusinessMember(db, business, cashier, BusinessMemberRoleManager)
+}
+
+func TestBusinessMemberManagerCheckBusinessManager(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestBusinessMemberManager(ctrl)
+	db := manager.baseServices.Database
+	owner := GetTestUser(db)
+	business := GetTestBusiness(db, owner)
+	managerUser := GetTestUser(db)
+	GetTestBusinessMember(db, business, managerUser, BusinessMemberRoleManager)
+	cashier := GetTestUser(db)
+	GetTestBusinessMember(db, business, cashier, BusinessMemberRoleCashier)
+
+	require.Nil(t, checkBusinessManager(db, owner, business), "owner should manage business")
+	require.Nil(t, checkBusinessManager(db, managerUser, business), "manager should manage business")
+	require.Equal(t, ErrNotBusinessManager, checkBusinessManager(db, cashier, business),
+		"cashier should not manage business")
+	require.Equal(t, ErrNotBusinessManager, checkBusinessManager(db, GetTestUser(db), business),
+		"other users should not manage business")
+}
@@ -0,0 +1,150 @@
+package managers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/services"
+)
+
+type EmailOutboxManager interface {
+	// Sends at most limit pending emails that are due, queued by EmailOutbox.
+	// Emails that failed temporarily are retried later with exponential backoff. Emails rejected by
+	// the email service and emails that ran out of attempts are dead-lettered and never retried.
+	// Entries locked by another instance are skipped, so this is safe to run concurrently.
+	DeliverPending(limit int) (*EmailDeliveryReport, error)
+}
+
+// Result of EmailOutboxManager.DeliverPending
+type EmailDeliveryReport struct {
+	Sent         uint // emails delivered
+	Retried      uint // emails that failed and will be retried
+	DeadLettered uint // emails that failed and will not be retried
+}
+
+// Controls how failed deliveries of queued emails are retried
+type EmailRetryConfig struct {
+	MaxAttempts uint          // attempts after which the email is dead-lettered
+	BackoffBase time.Duration // wait time after the first failed attempt, doubled after each next one
+	BackoffMax  time.Duration // max wait time between attempts
+}
+
+type EmailOutboxManagerImpl struct {
+	baseServices BaseServices
+	emailService EmailService
+	retry        EmailRetryConfig
+}
+
+// Used in place of fields of EmailRetryConfig that are not positive. Without them, emails would be
+// retried forever without waiting.
+var defaultEmailRetryConfig = EmailRetryConfig{
+	MaxAttempts: 8,
+	BackoffBase: time.Minute,
+	BackoffMax:  6 * time.Hour,
+}
+
+func CreateEmailOutboxManagerImpl(baseServices BaseServices, emailService EmailService,
+	retry EmailRetryConfig) *EmailOutboxManagerImpl {
+	if retry.MaxAttempts == 0 {
+		baseServices.Logger.Printf("email max attempts is 0, using %d", defaultEmailRetryConfig.MaxAttempts)
+		retry.MaxAttempts = defaultEmailRetryConfig.MaxAttempts
+	}
+	if retry.BackoffBase <= 0 {
+		baseServices.Logger.Printf("email retry backoff base %s is not positive, using %s", retry.BackoffBase,
+			defaultEmailRetryConfig.BackoffBase)
+		retry.BackoffBase = defaultEmailRetryConfig.BackoffBase
+	}
+	if retry.BackoffMax <= 0 {
+		baseServices.Logger.Printf("email retry backoff max %s is not positive, using %s", retry.BackoffMax,
+			defaultEmailRetryConfig.BackoffMax)
+		retry.BackoffMax = defaultEmailRetryConfig.BackoffMax
+	}
+	return &EmailOutboxManagerImpl{
+		baseServices: baseServices,
+		emailService: emailService,
+		retry:        retry,
+	}
+}
+
+// Returns how long to wait before the next delivery attempt, after attempts failed attempts
+func (manager *EmailOutboxManagerImpl) retryAfter(attempts uint) time.Duration {
+	delay := manager.retry.BackoffBase
+	for i := uint(1); i < attempts && delay < manager.retry.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > manager.retry.BackoffMax {
+		delay = manager.retry.BackoffMax
+	}
+	return delay
+}
+
+// Delivers the oldest due entry. Returns false if there was nothing to deliver.
+// The entry stays locked until it's updated, so it's never sent twice at the same time.
+func (manager *EmailOutboxManagerImpl) deliverNext(report *EmailDeliveryReport) (bool, error) {
+	found := false
+	err := manager.baseServices.Database.Transaction(func(tx GormDB) error {
+		now := time.Now()
+		var entry EmailOutboxEntry
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("state = ? AND next_attempt_at <= ?", EmailOutboxStatePending, now).
+			Order("next_attempt_at, id").
+			First(&entry)
+		if err := result.GetError(); errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("tx.First(EmailOutboxEntry) returned an error: %w", err)
+		}
+		found = true
+
+		entry.Attempts++
+		sendErr := manager.emailService.Send(entry.Recipient, EmailMessage{
+			Subject:  entry.Subject,
+			HTMLBody: entry.Body,
+			TextBody: entry.TextBody,
+		})
+		if sendErr == nil {
+			entry.State = EmailOutboxStateSent
+			entry.SentAt.Time = now
+			entry.SentAt.Valid = true
+			report.Sent++
+		} else {
+			entry.LastError = sendErr.Error()
+			if errors.Is(sendErr, ErrEmailRejected) ||
+				entry.Attempts >= manager.retry.MaxAttempts {
+				entry.State = EmailOutboxStateDead
+				report.DeadLettered++
+				manager.baseServices.Logger.Printf("email %d to %s dead-lettered after %d attempts: %+v",
+					entry.ID, entry.Recipient, entry.Attempts, sendErr)
+			} else {
+				entry.NextAttemptAt = now.Add(manager.retryAfter(entry.Attempts))
+				report.Retried++
+			}
+		}
+
+		result = tx.Save(&entry)
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("tx.Save(EmailOutboxEntry) returned an error: %w", err)
+		}
+		return nil
+	})
+	return found, err
+}
+
+func (manager *EmailOutboxManagerImpl) DeliverPending(limit int) (*EmailDeliveryReport, error) {
+	report := &EmailDeliveryReport{}
+	for i := 0; i < limit; i++ {
+		found, err := manager.deliverNext(report)
+		if err != nil {
+			return report, err
+		}
+		if !found {
+			break
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,131 @@
+package managers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/services"
+	. "github.com/StampWallet/backend/internal/services/mocks"
+	. "github.com/StampWallet/backend/internal/testutils"
+)
+
+func GetTestEmailOutboxManager(ctrl *gomock.Controller) *EmailOutboxManagerImpl {
+	return CreateEmailOutboxManagerImpl(
+		BaseServices{
+			Logger:   log.Default(),
+			Database: GetTestDatabase(),
+		},
+		NewMockEmailService(ctrl),
+		EmailRetryConfig{
+			MaxAttempts: 3,
+			BackoffBase: time.Minute,
+			BackoffMax:  time.Hour,
+		},
+	)
+}
+
+var testEmailMessage = EmailMessage{Subject: "subject", HTMLBody: "body", TextBody: "text"}
+
+// Queues testEmailMessage to recipient with EmailOutboxImpl
+func enqueueTestEmail(t *testing.T, db GormDB, recipient string) {
+	outbox := CreateEmailOutboxImpl(BaseServices{Logger: log.Default(), Database: db})
+	err := outbox.Enqueue(recipient, testEmailMessage)
+	require.Nilf(t, err, "EmailOutbox.Enqueue returned an error")
+}
+
+func getTestEmail(t *testing.T, db GormDB, recipient string) EmailOutboxEntry {
+	var entry EmailOutboxEntry
+	result := db.Where("recipient = ?", recipient).First(&entry)
+	require.Nilf(t, result.GetError(), "database First for EmailOutboxEntry returned an error")
+	return entry
+}
+
+func TestEmailOutboxManagerDeliverPending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestEmailOutboxManager(ctrl)
+	emailService := manager.emailService.(*MockEmailService)
+	db := manager.baseServices.Database
+
+	enqueueTestEmail(t, db, "sent@example.com")
+	enqueueTestEmail(t, db, "retried@example.com")
+	enqueueTestEmail(t, db, "rejected@example.com")
+
+	emailService.EXPECT().Send("sent@example.com", testEmailMessage).Return(nil)
+	emailService.EXPECT().Send("retried@example.com", testEmailMessage).Return(errors.New("connection refused"))
+	emailService.EXPECT().Send("rejected@example.com", testEmailMessage).
+		Return(fmt.Errorf("%w: invalid address", ErrEmailRejected))
+
+	report, err := manager.DeliverPending(10)
+	require.Nilf(t, err, "DeliverPending should return a nil error")
+	require.Equalf(t, EmailDeliveryReport{Sent: 1, Retried: 1, DeadLettered: 1}, *report,
+		"DeliverPending returned invalid report")
+
+	sent := getTestEmail(t, db, "sent@example.com")
+	require.Equalf(t, EmailOutboxStateEnum(EmailOutboxStateSent), sent.State, "sent email has invalid state")
+	require.Truef(t, sent.SentAt.Valid, "sent email should have SentAt")
+
+	retried := getTestEmail(t, db, "retried@example.com")
+	require.Equalf(t, EmailOutboxStatePending, retried.State, "retried email has invalid state")
+	require.Equalf(t, uint(1), retried.Attempts, "retried email has invalid attempts")
+	require.Equalf(t, "connection refused", retried.LastError, "retried email has invalid last error")
+	require.WithinDurationf(t, time.Now().Add(time.Minute), retried.NextAttemptAt, 5*time.Second,
+		"retried email should be retried after BackoffBase")
+
+	rejected := getTestEmail(t, db, "rejected@example.com")
+	require.Equalf(t, EmailOutboxStateEnum(EmailOutboxStateDead), rejected.State,
+		"rejected email should be dead-lettered")
+
+	// Nothing is due now
+	report, err = manager.DeliverPending(10)
+	require.Nilf(t, err, "DeliverPending should return a nil error")
+	require.Equalf(t, EmailDeliveryReport{}, *report, "DeliverPending should not deliver emails that are not due")
+}
+
+func TestEmailOutboxManagerDeliverPendingOutOfAttempts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestEmailOutboxManager(ctrl)
+	emailService := manager.emailService.(*MockEmailService)
+	db := manager.baseServices.Database
+
+	enqueueTestEmail(t, db, "retried@example.com")
+	emailService.EXPECT().Send("retried@example.com", testEmailMessage).
+		Return(errors.New("connection refused")).Times(3)
+
+	for i := 0; i != 3; i++ {
+		// Make the email due again
+		result := db.Model(&EmailOutboxEntry{}).Where("recipient = ?", "retried@example.com").
+			Update("next_attempt_at", time.Now().Add(-time.Second))
+		require.Nilf(t, result.GetError(), "database Update for EmailOutboxEntry returned an error")
+
+		_, err := manager.DeliverPending(10)
+		require.Nilf(t, err, "DeliverPending should return a nil error")
+	}
+
+	entry := getTestEmail(t, db, "retried@example.com")
+	require.Equalf(t, uint(3), entry.Attempts, "email has invalid attempts")
+	require.Equalf(t, EmailOutboxStateEnum(EmailOutboxStateDead), entry.State,
+		"email should be dead-lettered after MaxAttempts")
+}
+
+func TestEmailOutboxManagerRetryAfter(t *testing.T) {
+	manager := &EmailOutboxManagerImpl{retry: EmailRetryConfig{
+		BackoffBase: time.Minute,
+		BackoffMax:  time.Hour,
+	}}
+	require.Equalf(t, time.Minute, manager.retryAfter(1), "first retry should wait BackoffBase")
+	require.Equalf(t, 4*time.Minute, manager.retryAfter(3), "wait time should double after each attempt")
+	require.Equalf(t, time.Hour, manager.retryAfter(20), "wait time should not exceed BackoffMax")
+}
+
+func TestCreateEmailOutboxManagerImplDefaultRetryConfig(t *testing.T) {
+	manager := CreateEmailOutboxManagerImpl(BaseServices{Logger: log.Default()}, nil, EmailRetryConfig{})
+	require.Equalf(t, EmailRetryConfig{MaxAttempts: 8, BackoffBase: time.Minute, BackoffMax: 6 * time.Hour},
+		manager.retry, "zero retry config should be replaced with the defaults")
+}
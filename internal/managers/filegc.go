@@ -0,0 +1,123 @@
+package managers
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/services"
+)
+
+type FileGCManager interface {
+	// Finds files that nothing refers to: stubs that were never uploaded, files that are not used
+	// by a business, menu image or item definition (or only by deleted ones), and stored files
+	// without metadata. Only metadata older than the grace period is considered, so that files
+	// that are being created are left alone. Unless dryRun is true, found files are removed.
+	CollectGarbage(dryRun bool) (*FileGCReport, error)
+}
+
+// Files found by FileGCManager.CollectGarbage
+type FileGCReport struct {
+	AbandonedStubs    []string // PublicIds of unreferenced metadata of files that were never uploaded
+	UnreferencedFiles []string // PublicIds of unreferenced metadata of uploaded files
+	OrphanedFiles     []string // Ids of stored data without metadata or blob, see FileStorageService.ListStoredFiles
+	Failed            uint     // Files that could not be removed, errors are logged
+}
+
+type FileGCManagerImpl struct {
+	baseServices       BaseServices
+	fileStorageService FileStorageService
+	gracePeriod        time.Duration
+}
+
+// Grace period if the configured one is not positive. Without a grace period, files that are being
+// created would be removed.
+const defaultFileGCGracePeriod = 24 * time.Hour
+
+func CreateFileGCManagerImpl(baseServices BaseServices, fileStorageService FileStorageService,
+	gracePeriod time.Duration) *FileGCManagerImpl {
+	if gracePeriod <= 0 {
+		baseServices.Logger.Printf("file GC grace period %s is not positive, using %s", gracePeriod,
+			defaultFileGCGracePeriod)
+		gracePeriod = defaultFileGCGracePeriod
+	}
+	return &FileGCManagerImpl{
+		baseServices:       baseServices,
+		fileStorageService: fileStorageService,
+		gracePeriod:        gracePeriod,
+	}
+}
+
+// PublicIds of files used by entities that were not deleted
+const referencedFilesQuery = `
+	SELECT banner_image_id FROM businesses WHERE deleted_at IS NULL
+	UNION ALL SELECT icon_image_id FROM businesses WHERE deleted_at IS NULL
+	UNION ALL SELECT file_id FROM menu_images WHERE deleted_at IS NULL
+	UNION ALL SELECT image_id FROM item_definitions WHERE deleted_at IS NULL AND image_id IS NOT NULL`
+
+func (manager *FileGCManagerImpl) CollectGarbage(dryRun bool) (*FileGCReport, error) {
+	report := &FileGCReport{AbandonedStubs: []string{}, UnreferencedFiles: []string{}, OrphanedFiles: []string{}}
+	db := manager.baseServices.Database
+
+	var unreferenced []FileMetadata
+	tx := db.Where("created_at < ? AND public_id NOT IN ("+referencedFilesQuery+")",
+		time.Now().Add(-manager.gracePeriod)).
+		Order("id").
+		Find(&unreferenced)
+	if err := tx.GetError(); err != nil {
+		return nil, fmt.Errorf("db.Find(FileMetadata) returned an error: %w", err)
+	}
+	for _, metadata := range unreferenced {
+		if metadata.Uploaded.Valid {
+			report.UnreferencedFiles = append(report.UnreferencedFiles, metadata.PublicId)
+		} else {
+			report.AbandonedStubs = append(report.AbandonedStubs, metadata.PublicId)
+		}
+		if dryRun {
+			continue
+		}
+		if err := manager.fileStorageService.RemoveMetadata(metadata); err != nil {
+			manager.baseServices.Logger.Printf("failed to remove file %s: %+v", metadata.PublicId, err)
+			report.Failed++
+		}
+	}
+
+	stored, err := manager.fileStorageService.ListStoredFiles()
+	if err != nil {
+		return nil, fmt.Errorf("fileStorageService.ListStoredFiles returned an error: %w", err)
+	}
+	if len(stored) == 0 {
+		return report, nil
+	}
+	var known []string
+	// Removed metadata is soft deleted, its files are orphaned if removing them failed
+	tx = db.Model(&FileMetadata{}).Where("public_id IN ?", stored).Pluck("public_id", &known)
+	if err := tx.GetError(); err != nil {
+		return nil, fmt.Errorf("db.Pluck(FileMetadata) returned an error: %w", err)
+	}
+	var knownBlobs []string
+	tx = db.Model(&FileBlob{}).Where("hash IN ?", stored).Pluck("hash", &knownBlobs)
+	if err := tx.GetError(); err != nil {
+		return nil, fmt.Errorf("db.Pluck(FileBlob) returned an error: %w", err)
+	}
+	known = append(known, knownBlobs...)
+	knownSet := map[string]bool{}
+	for _, id := range known {
+		knownSet[id] = true
+	}
+	for _, id := range stored {
+		if knownSet[id] {
+			continue
+		}
+		report.OrphanedFiles = append(report.OrphanedFiles, id)
+		if dryRun {
+			continue
+		}
+		if err := manager.fileStorageService.RemoveStoredFile(id); err != nil {
+			manager.baseServices.Logger.Printf("failed to remove stored file %s: %+v", id, err)
+			report.Failed++
+		}
+	}
+
+	return report, nil
+}
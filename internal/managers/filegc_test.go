@@ -0,0 +1,88 @@
+package managers
+
+import (
+	"database/sql"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lithammer/shortuuid/v4"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/services"
+	. "github.com/StampWallet/backend/internal/services/mocks"
+	. "github.com/StampWallet/backend/internal/testutils"
+)
+
+func GetTestFileGCManager(ctrl *gomock.Controller) *FileGCManagerImpl {
+	return &FileGCManagerImpl{
+		baseServices: BaseServices{
+			Logger:   log.Default(),
+			Database: GetTestDatabase(),
+		},
+		fileStorageService: NewMockFileStorageService(ctrl),
+		gracePeriod:        time.Hour,
+	}
+}
+
+// Moves creation time of file with publicId back by age
+func ageFileMetadata(t *testing.T, db GormDB, publicId string, age time.Duration) {
+	tx := db.Model(&FileMetadata{}).Where("public_id = ?", publicId).Update("created_at", time.Now().Add(-age))
+	require.Nilf(t, tx.GetError(), "database update for FileMetadata returned an error")
+}
+
+func TestCreateFileGCManagerImplDefaultGracePeriod(t *testing.T) {
+	manager := CreateFileGCManagerImpl(BaseServices{Logger: log.Default()}, nil, 0)
+	require.Equalf(t, 24*time.Hour, manager.gracePeriod, "zero grace period should be replaced with the default")
+}
+
+func TestFileGCManagerCollectGarbage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestFileGCManager(ctrl)
+	fileStorageService := manager.fileStorageService.(*MockFileStorageService)
+	db := manager.baseServices.Database
+	user := GetTestUser(db)
+
+	business := GetTestBusiness(db, user)
+	ageFileMetadata(t, db, business.BannerImageId, 2*time.Hour)
+	stub := GetTestFileMetadata(db, user)
+	ageFileMetadata(t, db, stub.PublicId, 2*time.Hour)
+	unreferenced := GetTestFileMetadata(db, user)
+	unreferenced.Uploaded = sql.NullTime{Time: time.Now(), Valid: true}
+	Save(db, unreferenced)
+	ageFileMetadata(t, db, unreferenced.PublicId, 2*time.Hour)
+	fresh := GetTestFileMetadata(db, user)
+	orphaned := shortuuid.New()
+
+	fileStorageService.EXPECT().RemoveMetadata(gomock.Any()).Return(nil).Times(2)
+	fileStorageService.EXPECT().ListStoredFiles().
+		Return([]string{business.BannerImageId, fresh.PublicId, orphaned}, nil)
+	fileStorageService.EXPECT().RemoveStoredFile(orphaned).Return(nil)
+
+	report, err := manager.CollectGarbage(false)
+	require.Nilf(t, err, "CollectGarbage should return a nil error")
+	require.Equalf(t, []string{stub.PublicId}, report.AbandonedStubs, "CollectGarbage should find the stub")
+	require.Equalf(t, []string{unreferenced.PublicId}, report.UnreferencedFiles,
+		"CollectGarbage should find the unreferenced file")
+	require.Equalf(t, []string{orphaned}, report.OrphanedFiles, "CollectGarbage should find the orphaned file")
+	require.Equalf(t, uint(0), report.Failed, "CollectGarbage should not fail")
+}
+
+func TestFileGCManagerCollectGarbageDryRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestFileGCManager(ctrl)
+	fileStorageService := manager.fileStorageService.(*MockFileStorageService)
+	db := manager.baseServices.Database
+	stub := GetTestFileMetadata(db, GetTestUser(db))
+	ageFileMetadata(t, db, stub.PublicId, 2*time.Hour)
+	orphaned := shortuuid.New()
+
+	fileStorageService.EXPECT().ListStoredFiles().Return([]string{orphaned}, nil)
+
+	report, err := manager.CollectGarbage(true)
+	require.Nilf(t, err, "CollectGarbage should return a nil error")
+	require.Containsf(t, report.AbandonedStubs, stub.PublicId, "CollectGarbage should report the stub")
+	require.Equalf(t, []string{orphaned}, report.OrphanedFiles, "CollectGarbage should report the orphaned file")
+}
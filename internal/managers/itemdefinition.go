@@ -50,8 +50,10 @@ func CreateItemDefinitionManagerImpl(baseServices BaseServices, fileStorageServi
 func (manager *ItemDefinitionManagerImpl) AddItem(user *User, business *Business, details *ItemDetails) (*ItemDefinition, error) {
 	var itemDefinition ItemDefinition
 
-	if user.ID != business.OwnerId {
+	if err := checkBusinessManager(manager.baseServices.Database, user, business); err == ErrNotBusinessManager {
 		return nil, ErrInvalidArgs
+	} else if err != nil {
+		return nil, err
 	}
 
 	if details.Name == "" || details.Description == "" {
@@ -85,7 +87,7 @@ func (manager *ItemDefinitionManagerImpl) AddItem(user *User, business *Business
 	}
 
 	err := manager.baseServices.Database.Transaction(func(db GormDB) error {
-		imageFile, err := manager.fileStorageService.CreateStub(user)
+		imageFile, err := manager.fileStorageService.CreateStub(user, business, FilePurposeItemImage)
 		if err != nil {
 			return fmt.Errorf("fileStorageService.CreateStub returned an error: %w", err)
 		}
@@ -167,7 +169,20 @@ func (manager *ItemDefinitionManagerImpl) WithdrawItem(item *ItemDefinition) (*I
 		item.Withdrawn = true
 		item.Available = false
 
-		execDb := db.Exec(`UPDATE virtual_cards AS vc
+		// Records refunds in the ledger, before owned items change their status
+		execDb := db.Exec(`INSERT INTO points_ledger_entries
+				(created_at, updated_at, virtual_card_id, delta, reason, owned_item_id)
+			SELECT now(), now(), oi.virtual_card_id, itd.price, ?, oi.id
+			FROM owned_items oi
+				JOIN item_definitions AS itd ON itd.id = oi.definition_id
+			WHERE oi.definition_id=? AND oi.used is NULL AND oi.status='OWNED' AND itd.price <> 0`,
+			PointsLedgerReasonItemWithdrawn, item.ID)
+
+		if err := execDb.GetError(); err != nil {
+			return fmt.Errorf("failed to record refunds in WithdrawItem: %w", err)
+		}
+
+		execDb = db.Exec(`UPDATE virtual_cards AS vc
 				SET points = vc.points + t.points
 			FROM		
 				(SELECT oi.virtual_card_id as vid, sum(itd.price) as points
@@ -182,6 +197,17 @@ func (manager *ItemDefinitionManagerImpl) WithdrawItem(item *ItemDefinition) (*I
 			return fmt.Errorf("failed to update virtual cards in WithdrawItem: %w", err)
 		}
 
+		// Gives the points back to lots they were spent from
+		for _, ownedItem := range item.OwnedItems {
+			if ownedItem.Status != OwnedItemStatusOwned || ownedItem.Used.Valid {
+				continue
+			}
+			err := refundPointsLots(db, ownedItem.VirtualCardId, ownedItem.ID, item.Price)
+			if err != nil {
+				return fmt.Errorf("failed to refund points lots in WithdrawItem: %w", err)
+			}
+		}
+
 		execDb = db.Exec(`UPDATE owned_items SET status=? WHERE definition_id=? AND status=?`,
 			OwnedItemStatusWithdrawn, item.ID, OwnedItemStatusOwned)
 
@@ -46,7 +46,7 @@ func TestItemDefinitionAddItem(t *testing.T) {
 	imageFile := GetTestFileMetadata(manager.baseServices.Database, user)
 	manager.fileStorageService.(*MockFileStorageService).
 		EXPECT().
-		CreateStub(user).
+		CreateStub(user, business, FilePurposeItemImage).
 		Return(imageFile, nil)
 
 	details := &ItemDetails{
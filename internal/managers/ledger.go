@@ -0,0 +1,196 @@
+package managers
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/services"
+)
+
+type PointsLedgerManager interface {
+	// Returns page of points history of virtualCard, newest entries first, and cursor of the next page
+	GetHistory(virtualCard *VirtualCard, page *Page) ([]PointsLedgerEntry, string, error)
+
+	// Recomputes balances of all virtual cards from the ledger and returns cards
+	// with balance that does not match the ledger. If fix is true, balances of these cards
+	// are replaced with balances computed from the ledger.
+	Reconcile(fix bool) ([]PointsBalanceMismatch, error)
+
+	// Expires points older than Business.PointsExpiryDays of all virtual cards.
+	// Returns the number of expired points.
+	ExpirePoints() (uint64, error)
+
+	// Returns points of virtualCard that will expire soon (as configured in the manager), oldest first
+	GetExpiringPoints(virtualCard *VirtualCard) ([]ExpiringPoints, error)
+}
+
+// Remaining points of a single lot and the time they expire at
+type ExpiringPoints struct {
+	Points  uint
+	Expires time.Time
+}
+
+// Virtual card with balance that does not match its ledger
+type PointsBalanceMismatch struct {
+	VirtualCardId uint
+	Points        uint  // VirtualCard.Points
+	LedgerPoints  int64 // sum of PointsLedgerEntry.Delta
+}
+
+type PointsLedgerManagerImpl struct {
+	baseServices        BaseServices
+	expiryWarningPeriod time.Duration
+}
+
+// GetExpiringPoints returns points that expire within expiryWarningPeriod
+func CreatePointsLedgerManagerImpl(baseServices BaseServices, expiryWarningPeriod time.Duration) *PointsLedgerManagerImpl {
+	return &PointsLedgerManagerImpl{
+		baseServices:        baseServices,
+		expiryWarningPeriod: expiryWarningPeriod,
+	}
+}
+
+// Creates a ledger entry for a change of points of virtualCardId. Has to be called in the same
+// database transaction as the change. transactionId and ownedItemId are optional.
+// Changes by 0 points are not recorded.
+func recordPointsChange(db GormDB, virtualCardId uint, delta int64, reason PointsLedgerReasonEnum,
+	transactionId *uint, ownedItemId *uint) error {
+	if delta == 0 {
+		return nil
+	}
+	entry := PointsLedgerEntry{
+		VirtualCardId: virtualCardId,
+		Delta:         delta,
+		Reason:        reason,
+		TransactionId: transactionId,
+		OwnedItemId:   ownedItemId,
+	}
+	result := db.Create(&entry)
+	if err := result.GetError(); err != nil {
+		return fmt.Errorf("db.Create(PointsLedgerEntry) returned an error: %w", err)
+	}
+	return nil
+}
+
+func (manager *PointsLedgerManagerImpl) GetHistory(virtualCard *VirtualCard, page *Page) ([]PointsLedgerEntry, string, error) {
+	db := manager.baseServices.Database
+	if page.Cursor != "" {
+		var createdAt time.Time
+		var id uint
+		if err := DecodeCursor(page.Cursor, &createdAt, &id); err != nil {
+			return nil, "", err
+		}
+		db = db.Where("(created_at, id) < (?, ?)", createdAt, id)
+	}
+
+	// One more entry than the limit is fetched to check if there is a next page
+	var entries []PointsLedgerEntry
+	result := db.
+		Preload("Transaction").
+		Preload("OwnedItem").
+		Preload("OwnedItem.ItemDefinition").
+		Where("virtual_card_id = ?", virtualCard.ID).
+		Order("created_at desc, id desc").
+		Limit(int(page.Limit) + 1).
+		Find(&entries)
+	if err := result.GetError(); err != nil {
+		return nil, "", fmt.Errorf("db.Find(PointsLedgerEntry) returned an error: %w", err)
+	}
+
+	if len(entries) <= int(page.Limit) || page.Limit == 0 {
+		return entries, "", nil
+	}
+	entries = entries[:page.Limit]
+	last := entries[len(entries)-1]
+	nextCursor, err := EncodeCursor(last.CreatedAt, last.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return entries, nextCursor, nil
+}
+
+func (manager *PointsLedgerManagerImpl) Reconcile(fix bool) ([]PointsBalanceMismatch, error) {
+	var mismatches []PointsBalanceMismatch
+	err := manager.baseServices.Database.Transaction(func(db GormDB) error {
+		result := db.Raw(`SELECT vc.id AS virtual_card_id, vc.points AS points,
+				COALESCE(SUM(ple.delta), 0) AS ledger_points
+			FROM virtual_cards AS vc
+				LEFT JOIN points_ledger_entries AS ple
+					ON ple.virtual_card_id = vc.id AND ple.deleted_at IS NULL
+			WHERE vc.deleted_at IS NULL
+			GROUP BY vc.id, vc.points
+			HAVING vc.points <> COALESCE(SUM(ple.delta), 0)
+			ORDER BY vc.id`).
+			Scan(&mismatches)
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("failed to compute balances from ledger: %w", err)
+		}
+
+		if !fix {
+			return nil
+		}
+		for _, mismatch := range mismatches {
+			if mismatch.LedgerPoints < 0 {
+				manager.baseServices.Logger.Printf("ledger of virtual card %d has negative balance %d, not fixing",
+					mismatch.VirtualCardId, mismatch.LedgerPoints)
+				continue
+			}
+			result := db.Model(&VirtualCard{}).
+				Where("id = ?", mismatch.VirtualCardId).
+				Update("points", mismatch.LedgerPoints)
+			if err := result.GetError(); err != nil {
+				return fmt.Errorf("failed to update virtual card %d: %w", mismatch.VirtualCardId, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mismatches, nil
+}
+
+func (manager *PointsLedgerManagerImpl) ExpirePoints() (uint64, error) {
+	var expired uint64
+	err := manager.baseServices.Database.Transaction(func(db GormDB) error {
+		var err error
+		expired, err = expirePointsLots(db, nil)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire points: %w", err)
+	}
+	return expired, nil
+}
+
+func (manager *PointsLedgerManagerImpl) GetExpiringPoints(virtualCard *VirtualCard) ([]ExpiringPoints, error) {
+	var business Business
+	result := manager.baseServices.Database.First(&business, "id = ?", virtualCard.BusinessId)
+	if err := result.GetError(); err != nil {
+		return nil, fmt.Errorf("db.First(Business) returned an error: %w", err)
+	}
+	if business.PointsExpiryDays == 0 {
+		return nil, nil
+	}
+	expiry := time.Duration(business.PointsExpiryDays) * 24 * time.Hour
+
+	var lots []PointsLot
+	result = manager.baseServices.Database.
+		Where("virtual_card_id = ? AND remaining > 0 AND created_at < ?",
+			virtualCard.ID, time.Now().Add(manager.expiryWarningPeriod).Add(-expiry)).
+		Order("created_at, id").
+		Find(&lots)
+	if err := result.GetError(); err != nil {
+		return nil, fmt.Errorf("db.Find(PointsLot) returned an error: %w", err)
+	}
+
+	var expiring []ExpiringPoints
+	for _, lot := range lots {
+		expiring = append(expiring, ExpiringPoints{
+			Points:  lot.Remaining,
+			Expires: lot.CreatedAt.Add(expiry),
+		})
+	}
+	return expiring, nil
+}
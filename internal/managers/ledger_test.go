@@ -0,0 +1,179 @@
+package managers
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/services"
+	. "github.com/StampWallet/backend/internal/testutils"
+)
+
+// Builds PointsLedgerManagerImpl with test database
+func GetTestPointsLedgerManager(ctrl *gomock.Controller) *PointsLedgerManagerImpl {
+	return &PointsLedgerManagerImpl{
+		baseServices: BaseServices{
+			Logger:   log.Default(),
+			Database: GetTestDatabase(),
+		},
+		expiryWarningPeriod: 30 * 24 * time.Hour,
+	}
+}
+
+// Returns ledger entries of virtualCard, oldest first
+func getLedgerEntries(t *testing.T, db GormDB, virtualCard *VirtualCard) []PointsLedgerEntry {
+	var entries []PointsLedgerEntry
+	tx := db.Where("virtual_card_id = ?", virtualCard.ID).Order("id").Find(&entries)
+	require.Nilf(t, tx.GetError(), "database find for PointsLedgerEntry returned an error")
+	return entries
+}
+
+func TestPointsLedgerBuyAndReturnItem(t *testing.T) {
+	s := setupVirtualCardManagerTest(t)
+	virtualCard := GetTestVirtualCard(s.db, s.user, s.business)
+
+	ownedItem, err := s.manager.BuyItem(virtualCard, s.itemDefinition.PublicId)
+	require.Nilf(t, err, "VirtualCardManager.BuyItem should return a nil error")
+	err = s.manager.ReturnItem(ownedItem)
+	require.Nilf(t, err, "VirtualCardManager.ReturnItem should return a nil error")
+
+	entries := getLedgerEntries(t, s.db, virtualCard)
+	require.Lenf(t, entries, 2, "ledger should contain an entry for purchase and return")
+	require.Equalf(t, -int64(s.itemDefinition.Price), entries[0].Delta, "purchase entry has invalid delta")
+	require.Equalf(t, PointsLedgerReasonEnum(PointsLedgerReasonItemBought), entries[0].Reason,
+		"purchase entry has invalid reason")
+	require.Equalf(t, ownedItem.ID, *entries[0].OwnedItemId, "purchase entry has invalid owned item")
+	require.Equalf(t, int64(s.itemDefinition.Price), entries[1].Delta, "return entry has invalid delta")
+	require.Equalf(t, PointsLedgerReasonEnum(PointsLedgerReasonItemReturned), entries[1].Reason,
+		"return entry has invalid reason")
+	require.Equalf(t, ownedItem.ID, *entries[1].OwnedItemId, "return entry has invalid owned item")
+}
+
+func TestPointsLedgerFinalize(t *testing.T) {
+	s := setupTransactionTest(t)
+	ownedItemToRecall := GetTestOwnedItem(s.db, s.itemDefinition, s.virtualCard)
+	transaction, _ := GetTestTransaction(s.db, s.virtualCard, []OwnedItem{*ownedItemToRecall})
+
+	transaction, err := s.manager.Finalize(transaction, []ItemWithAction{
+		{ownedItemToRecall, RecalledActionType},
+	}, 10, nil)
+	require.Nilf(t, err, "transaction finalize returned an error %w", err)
+
+	entries := getLedgerEntries(t, s.db, s.virtualCard)
+	require.Lenf(t, entries, 2, "ledger should contain an entry for recalled item and added points")
+	require.Equalf(t, int64(s.itemDefinition.Price), entries[0].Delta, "recall entry has invalid delta")
+	require.Equalf(t, PointsLedgerReasonEnum(PointsLedgerReasonItemRecalled), entries[0].Reason,
+		"recall entry has invalid reason")
+	require.Equalf(t, ownedItemToRecall.ID, *entries[0].OwnedItemId, "recall entry has invalid owned item")
+	require.Equalf(t, transaction.ID, *entries[0].TransactionId, "recall entry has invalid transaction")
+	require.Equalf(t, int64(10), entries[1].Delta, "transaction entry has invalid delta")
+	require.Equalf(t, PointsLedgerReasonEnum(PointsLedgerReasonTransaction), entries[1].Reason,
+		"transaction entry has invalid reason")
+	require.Equalf(t, transaction.ID, *entries[1].TransactionId, "transaction entry has invalid transaction")
+	require.Nilf(t, entries[1].OwnedItemId, "transaction entry should not reference an owned item")
+}
+
+func TestPointsLedgerWithdrawItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager := GetItemDefinitionManager(ctrl)
+	db := manager.baseServices.Database
+	user := GetTestUser(db)
+	business := GetTestBusiness(db, user)
+	definition := GetTestItemDefinition(db, business, *GetTestFileMetadata(db, user))
+	virtualCard := GetTestVirtualCard(db, user, business)
+	ownedItem := GetTestOwnedItem(db, definition, virtualCard)
+	usedItem := GetTestOwnedItem(db, definition, virtualCard)
+	usedItem.Status = OwnedItemStatusUsed
+	Save(db, usedItem)
+
+	_, err := manager.WithdrawItem(definition)
+	require.Nilf(t, err, "WithdrawItem returned an error")
+
+	entries := getLedgerEntries(t, db, virtualCard)
+	require.Lenf(t, entries, 1, "ledger should contain a single entry for withdrawn item")
+	require.Equalf(t, int64(definition.Price), entries[0].Delta, "withdraw entry has invalid delta")
+	require.Equalf(t, PointsLedgerReasonEnum(PointsLedgerReasonItemWithdrawn), entries[0].Reason,
+		"withdraw entry has invalid reason")
+	require.Equalf(t, ownedItem.ID, *entries[0].OwnedItemId, "withdraw entry has invalid owned item")
+}
+
+func TestPointsLedgerManagerGetHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestPointsLedgerManager(ctrl)
+	db := manager.baseServices.Database
+	user := GetTestUser(db)
+	business := GetTestBusiness(db, GetTestUser(db))
+	virtualCard := GetTestVirtualCardWithPoints(db, user, business, 0)
+	otherCard := GetTestVirtualCardWithPoints(db, user, GetTestBusiness(db, GetTestUser(db)), 0)
+
+	require.Nil(t, recordPointsChange(db, virtualCard.ID, 20, PointsLedgerReasonTransaction, nil, nil))
+	require.Nil(t, recordPointsChange(db, virtualCard.ID, -5, PointsLedgerReasonItemBought, nil, nil))
+	require.Nil(t, recordPointsChange(db, virtualCard.ID, 0, PointsLedgerReasonTransaction, nil, nil))
+	require.Nil(t, recordPointsChange(db, otherCard.ID, 7, PointsLedgerReasonTransaction, nil, nil))
+
+	entries, nextCursor, err := manager.GetHistory(virtualCard, &Page{Limit: 10})
+	require.Nilf(t, err, "PointsLedgerManager.GetHistory returned an error %w", err)
+	require.Lenf(t, entries, 2, "PointsLedgerManager.GetHistory returned unexpected number of entries")
+	require.Equalf(t, int64(-5), entries[0].Delta, "PointsLedgerManager.GetHistory should return newest entries first")
+	require.Equalf(t, int64(20), entries[1].Delta, "PointsLedgerManager.GetHistory should return newest entries first")
+	require.Emptyf(t, nextCursor, "PointsLedgerManager.GetHistory returned cursor on the last page")
+
+	entries, nextCursor, err = manager.GetHistory(virtualCard, &Page{Limit: 1})
+	require.Nilf(t, err, "PointsLedgerManager.GetHistory returned an error %w", err)
+	require.Lenf(t, entries, 1, "PointsLedgerManager.GetHistory returned unexpected number of entries")
+	require.Equalf(t, int64(-5), entries[0].Delta, "PointsLedgerManager.GetHistory returned unexpected first page")
+	require.NotEmptyf(t, nextCursor, "PointsLedgerManager.GetHistory did not return cursor of the next page")
+
+	entries, nextCursor, err = manager.GetHistory(virtualCard, &Page{Cursor: nextCursor, Limit: 1})
+	require.Nilf(t, err, "PointsLedgerManager.GetHistory returned an error %w", err)
+	require.Lenf(t, entries, 1, "PointsLedgerManager.GetHistory returned unexpected number of entries")
+	require.Equalf(t, int64(20), entries[0].Delta, "PointsLedgerManager.GetHistory returned unexpected second page")
+	require.Emptyf(t, nextCursor, "PointsLedgerManager.GetHistory returned cursor on the last page")
+}
+
+func TestPointsLedgerManagerReconcile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestPointsLedgerManager(ctrl)
+	db := manager.baseServices.Database
+	user := GetTestUser(db)
+	business := GetTestBusiness(db, GetTestUser(db))
+	virtualCard := GetTestVirtualCardWithPoints(db, user, business, 0)
+	require.Nil(t, recordPointsChange(db, virtualCard.ID, 30, PointsLedgerReasonTransaction, nil, nil))
+
+	findMismatch := func(mismatches []PointsBalanceMismatch) *PointsBalanceMismatch {
+		for _, m := range mismatches {
+			if m.VirtualCardId == virtualCard.ID {
+				return &m
+			}
+		}
+		return nil
+	}
+
+	mismatches, err := manager.Reconcile(false)
+	require.Nilf(t, err, "PointsLedgerManager.Reconcile returned an error %w", err)
+	mismatch := findMismatch(mismatches)
+	require.NotNilf(t, mismatch, "PointsLedgerManager.Reconcile should return mismatched card")
+	require.Equalf(t, uint(0), mismatch.Points, "PointsLedgerManager.Reconcile returned invalid card balance")
+	require.Equalf(t, int64(30), mismatch.LedgerPoints, "PointsLedgerManager.Reconcile returned invalid ledger balance")
+
+	var dbVirtualCard VirtualCard
+	tx := db.First(&dbVirtualCard, VirtualCard{Model: gorm.Model{ID: virtualCard.ID}})
+	require.Nilf(t, tx.GetError(), "database find for VirtualCard returned an error")
+	require.Equalf(t, uint(0), dbVirtualCard.Points, "PointsLedgerManager.Reconcile should not fix balance without fix")
+
+	_, err = manager.Reconcile(true)
+	require.Nilf(t, err, "PointsLedgerManager.Reconcile returned an error %w", err)
+	tx = db.First(&dbVirtualCard, VirtualCard{Model: gorm.Model{ID: virtualCard.ID}})
+	require.Nilf(t, tx.GetError(), "database find for VirtualCard returned an error")
+	require.Equalf(t, uint(30), dbVirtualCard.Points, "PointsLedgerManager.Reconcile should fix balance")
+
+	mismatches, err = manager.Reconcile(false)
+	require.Nilf(t, err, "PointsLedgerManager.Reconcile returned an error %w", err)
+	require.Nilf(t, findMismatch(mismatches), "PointsLedgerManager.Reconcile should not return fixed card")
+}
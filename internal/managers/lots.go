@@ -0,0 +1,155 @@
+package managers
+
+import (
+	"fmt"
+
+	"gorm.io/gorm/clause"
+
+	. "github.com/StampWallet/backend/internal/database"
+)
+
+// Helpers for PointsLot bookkeeping. Like recordPointsChange, all of these have to be called in the same
+// database transaction as the change of VirtualCard.Points.
+
+// Adds a new lot with points to virtualCardId
+func addPointsLot(db GormDB, virtualCardId uint, points uint) error {
+	if points == 0 {
+		return nil
+	}
+	lot := PointsLot{
+		VirtualCardId: virtualCardId,
+		Points:        points,
+		Remaining:     points,
+	}
+	result := db.Create(&lot)
+	if err := result.GetError(); err != nil {
+		return fmt.Errorf("db.Create(PointsLot) returned an error: %w", err)
+	}
+	return nil
+}
+
+// Spends points on ownedItemId from the oldest lots of virtualCardId first.
+// Lots should be expired before, see expirePointsLots. Points not covered by lots are spent without
+// a lot - that should only happen to cards with balance that was not migrated to lots.
+func spendPointsLots(db GormDB, virtualCardId uint, ownedItemId uint, points uint) error {
+	// Lots are locked, so they can't be expired or spent by another transaction in the meantime
+	var lots []PointsLot
+	result := db.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("virtual_card_id = ? AND remaining > 0", virtualCardId).
+		Order("created_at, id").
+		Find(&lots)
+	if err := result.GetError(); err != nil {
+		return fmt.Errorf("db.Find(PointsLot) returned an error: %w", err)
+	}
+
+	for i := 0; i != len(lots) && points != 0; i++ {
+		lot := &lots[i]
+		spent := lot.Remaining
+		if points < spent {
+			spent = points
+		}
+		points -= spent
+
+		lot.Remaining -= spent
+		result = db.Model(lot).Update("remaining", lot.Remaining)
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("db.Update(PointsLot) returned an error: %w", err)
+		}
+
+		result = db.Create(&PointsLotSpending{
+			PointsLotId: lot.ID,
+			OwnedItemId: ownedItemId,
+			Points:      spent,
+		})
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("db.Create(PointsLotSpending) returned an error: %w", err)
+		}
+	}
+	return nil
+}
+
+// Gives points spent on ownedItemId back to the lots they were spent from, so refunded points keep
+// their original expiration date. Lots that expired in the meantime will expire again on the next
+// expirePointsLots. Points that were not spent from a lot are added to virtualCardId as a new lot.
+func refundPointsLots(db GormDB, virtualCardId uint, ownedItemId uint, points uint) error {
+	var spendings []PointsLotSpending
+	result := db.
+		Preload("PointsLot").
+		Where("owned_item_id = ?", ownedItemId).
+		Find(&spendings)
+	if err := result.GetError(); err != nil {
+		return fmt.Errorf("db.Find(PointsLotSpending) returned an error: %w", err)
+	}
+
+	for _, spending := range spendings {
+		refunded := spending.Points
+		if points < refunded {
+			refunded = points
+		}
+		points -= refunded
+
+		result = db.Model(spending.PointsLot).Update("remaining", spending.PointsLot.Remaining+refunded)
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("db.Update(PointsLot) returned an error: %w", err)
+		}
+
+		result = db.Delete(&spending)
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("db.Delete(PointsLotSpending) returned an error: %w", err)
+		}
+	}
+
+	return addPointsLot(db, virtualCardId, points)
+}
+
+// Expires lots created more than Business.PointsExpiryDays ago. Remaining points of these lots
+// are subtracted from their cards and recorded in the ledger. If virtualCardId is not nil,
+// only lots of that card are expired. Returns the number of expired points.
+func expirePointsLots(db GormDB, virtualCardId *uint) (uint64, error) {
+	// Only lots are locked, not the joined cards and businesses. A lot expired or spent by another
+	// transaction while waiting for the lock no longer matches remaining > 0 and is skipped
+	query := db.
+		Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "points_lots"}}).
+		Joins("JOIN virtual_cards ON virtual_cards.id = points_lots.virtual_card_id").
+		Joins("JOIN businesses ON businesses.id = virtual_cards.business_id").
+		Where("points_lots.remaining > 0 AND businesses.points_expiry_days > 0").
+		Where("points_lots.created_at < now() - businesses.points_expiry_days * interval '1 day'")
+	if virtualCardId != nil {
+		query = query.Where("points_lots.virtual_card_id = ?", *virtualCardId)
+	}
+
+	var lots []PointsLot
+	result := query.Order("points_lots.id").Find(&lots)
+	if err := result.GetError(); err != nil {
+		return 0, fmt.Errorf("db.Find(PointsLot) returned an error: %w", err)
+	}
+
+	var expired uint64
+	for i := range lots {
+		lot := &lots[i]
+		result = db.Model(lot).Update("remaining", 0)
+		if err := result.GetError(); err != nil {
+			return 0, fmt.Errorf("db.Update(PointsLot) returned an error: %w", err)
+		}
+
+		result = db.Exec(`UPDATE virtual_cards SET points = points - ? WHERE id = ?`,
+			lot.Remaining, lot.VirtualCardId)
+		if err := result.GetError(); err != nil {
+			return 0, fmt.Errorf("failed to subtract expired points from virtual card: %w", err)
+		}
+
+		result = db.Create(&PointsLedgerEntry{
+			VirtualCardId: lot.VirtualCardId,
+			Delta:         -int64(lot.Remaining),
+			Reason:        PointsLedgerReasonPointsExpired,
+			PointsLotId:   &lot.ID,
+		})
+		if err := result.GetError(); err != nil {
+			return 0, fmt.Errorf("db.Create(PointsLedgerEntry) returned an error: %w", err)
+		}
+
+		expired += uint64(lot.Remaining)
+	}
+	return expired, nil
+}
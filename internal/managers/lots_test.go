@@ -0,0 +1,176 @@
+package managers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/testutils"
+)
+
+// Reloads lot from the database
+func getDbPointsLot(t *testing.T, db GormDB, lot *PointsLot) *PointsLot {
+	var dbLot PointsLot
+	tx := db.First(&dbLot, PointsLot{Model: gorm.Model{ID: lot.ID}})
+	require.Nilf(t, tx.GetError(), "database find for PointsLot returned an error")
+	return &dbLot
+}
+
+// Reloads virtualCard from the database
+func getDbVirtualCard(t *testing.T, db GormDB, virtualCard *VirtualCard) *VirtualCard {
+	var dbVirtualCard VirtualCard
+	tx := db.First(&dbVirtualCard, VirtualCard{Model: gorm.Model{ID: virtualCard.ID}})
+	require.Nilf(t, tx.GetError(), "database find for VirtualCard returned an error")
+	return &dbVirtualCard
+}
+
+func TestPointsLotsBuyItemSpendsOldestFirst(t *testing.T) {
+	s := setupVirtualCardManagerTest(t)
+	virtualCard := GetTestVirtualCardWithPoints(s.db, s.user, s.business, 16)
+	newLot := GetTestPointsLot(s.db, virtualCard, 8, time.Now().Add(-time.Hour))
+	oldLot := GetTestPointsLot(s.db, virtualCard, 8, time.Now().Add(-2*time.Hour))
+
+	// price is 10
+	ownedItem, err := s.manager.BuyItem(virtualCard, s.itemDefinition.PublicId)
+	require.Nilf(t, err, "VirtualCardManager.BuyItem should return a nil error")
+	require.Equalf(t, uint(0), getDbPointsLot(t, s.db, oldLot).Remaining, "oldest lot should be spent first")
+	require.Equalf(t, uint(6), getDbPointsLot(t, s.db, newLot).Remaining, "newer lot should be spent after the oldest")
+
+	// returned points go back to the same lots
+	err = s.manager.ReturnItem(ownedItem)
+	require.Nilf(t, err, "VirtualCardManager.ReturnItem should return a nil error")
+	require.Equalf(t, uint(8), getDbPointsLot(t, s.db, oldLot).Remaining, "returned points should go back to oldest lot")
+	require.Equalf(t, uint(8), getDbPointsLot(t, s.db, newLot).Remaining, "returned points should go back to newer lot")
+	require.Equalf(t, uint(16), getDbVirtualCard(t, s.db, virtualCard).Points, "card should regain its points")
+}
+
+func TestPointsLotsBuyItemWithExpiredPoints(t *testing.T) {
+	s := setupVirtualCardManagerTest(t)
+	s.business.PointsExpiryDays = 30
+	Save(s.db, s.business)
+	virtualCard := GetTestVirtualCardWithPoints(s.db, s.user, s.business, 16)
+	GetTestPointsLot(s.db, virtualCard, 8, time.Now().Add(-31*24*time.Hour))
+	GetTestPointsLot(s.db, virtualCard, 8, time.Now())
+
+	_, err := s.manager.BuyItem(virtualCard, s.itemDefinition.PublicId)
+	require.Equalf(t, ErrNotEnoughPoints, err, "VirtualCardManager.BuyItem should not spend expired points")
+	// BuyItem runs in a transaction - expiration is rolled back too
+	require.Equalf(t, uint(16), getDbVirtualCard(t, s.db, virtualCard).Points, "card points should not change")
+}
+
+func TestPointsLotsFinalizeAddsLot(t *testing.T) {
+	s := setupTransactionTest(t)
+	transaction, _ := GetTestTransaction(s.db, s.virtualCard, []OwnedItem{})
+
+	_, err := s.manager.Finalize(transaction, []ItemWithAction{}, 15, nil)
+	require.Nilf(t, err, "transaction finalize returned an error %w", err)
+
+	var lots []PointsLot
+	tx := s.db.Find(&lots, PointsLot{VirtualCardId: s.virtualCard.ID})
+	require.Nilf(t, tx.GetError(), "database find for PointsLot returned an error")
+	require.Lenf(t, lots, 1, "transaction finalize should add a single lot")
+	require.Equalf(t, uint(15), lots[0].Points, "lot has invalid amount of points")
+	require.Equalf(t, uint(15), lots[0].Remaining, "lot has invalid amount of remaining points")
+}
+
+func TestPointsLotsWithdrawItemRefundsLots(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	itemDefinitionManager := GetItemDefinitionManager(ctrl)
+	virtualCardManager := GetTestVirtualCardManager(ctrl)
+	db := itemDefinitionManager.baseServices.Database
+	user := GetTestUser(db)
+	business := GetTestBusiness(db, GetTestUser(db))
+	definition := GetTestItemDefinition(db, business, *GetTestFileMetadata(db, user))
+	virtualCard := GetTestVirtualCardWithPoints(db, user, business, 10)
+	lot := GetTestPointsLot(db, virtualCard, 10, time.Now())
+
+	_, err := virtualCardManager.BuyItem(virtualCard, definition.PublicId)
+	require.Nilf(t, err, "VirtualCardManager.BuyItem should return a nil error")
+	require.Equalf(t, uint(0), getDbPointsLot(t, db, lot).Remaining, "lot should be spent")
+
+	_, err = itemDefinitionManager.WithdrawItem(definition)
+	require.Nilf(t, err, "WithdrawItem returned an error")
+	require.Equalf(t, uint(10), getDbPointsLot(t, db, lot).Remaining, "withdrawn item should refund the lot")
+	require.Equalf(t, uint(10), getDbVirtualCard(t, db, virtualCard).Points, "card should regain its points")
+}
+
+func TestPointsLedgerManagerExpirePoints(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestPointsLedgerManager(ctrl)
+	db := manager.baseServices.Database
+	user := GetTestUser(db)
+	business := GetTestBusiness(db, GetTestUser(db))
+	business.PointsExpiryDays = 30
+	Save(db, business)
+	virtualCard := GetTestVirtualCardWithPoints(db, user, business, 15)
+	oldLot := GetTestPointsLot(db, virtualCard, 10, time.Now().Add(-31*24*time.Hour))
+	newLot := GetTestPointsLot(db, virtualCard, 5, time.Now().Add(-29*24*time.Hour))
+
+	// lots of businesses without expiry policy never expire
+	otherCard := GetTestVirtualCardWithPoints(db, user, GetTestBusiness(db, GetTestUser(db)), 10)
+	otherLot := GetTestPointsLot(db, otherCard, 10, time.Now().Add(-365*24*time.Hour))
+
+	expired, err := manager.ExpirePoints()
+	require.Nilf(t, err, "PointsLedgerManager.ExpirePoints returned an error %w", err)
+	require.GreaterOrEqualf(t, expired, uint64(10), "PointsLedgerManager.ExpirePoints should expire points of old lot")
+
+	require.Equalf(t, uint(0), getDbPointsLot(t, db, oldLot).Remaining, "old lot should expire")
+	require.Equalf(t, uint(5), getDbPointsLot(t, db, newLot).Remaining, "new lot should not expire")
+	require.Equalf(t, uint(10), getDbPointsLot(t, db, otherLot).Remaining, "lot without expiry policy should not expire")
+	require.Equalf(t, uint(5), getDbVirtualCard(t, db, virtualCard).Points, "expired points should be subtracted from card")
+	require.Equalf(t, uint(10), getDbVirtualCard(t, db, otherCard).Points, "card without expiry policy should not change")
+
+	entries := getLedgerEntries(t, db, virtualCard)
+	require.Lenf(t, entries, 1, "ledger should contain an entry for expired points")
+	require.Equalf(t, int64(-10), entries[0].Delta, "expiration entry has invalid delta")
+	require.Equalf(t, PointsLedgerReasonEnum(PointsLedgerReasonPointsExpired), entries[0].Reason,
+		"expiration entry has invalid reason")
+	require.Equalf(t, oldLot.ID, *entries[0].PointsLotId, "expiration entry has invalid lot")
+}
+
+func TestPointsLedgerManagerExpirePointsTwice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestPointsLedgerManager(ctrl)
+	db := manager.baseServices.Database
+	user := GetTestUser(db)
+	business := GetTestBusiness(db, GetTestUser(db))
+	business.PointsExpiryDays = 30
+	Save(db, business)
+	virtualCard := GetTestVirtualCardWithPoints(db, user, business, 15)
+	lot := GetTestPointsLot(db, virtualCard, 10, time.Now().Add(-31*24*time.Hour))
+
+	_, err := manager.ExpirePoints()
+	require.Nilf(t, err, "PointsLedgerManager.ExpirePoints returned an error %w", err)
+	_, err = manager.ExpirePoints()
+	require.Nilf(t, err, "PointsLedgerManager.ExpirePoints returned an error %w", err)
+
+	require.Equalf(t, uint(0), getDbPointsLot(t, db, lot).Remaining, "old lot should expire")
+	require.Equalf(t, uint(5), getDbVirtualCard(t, db, virtualCard).Points, "expired points should be subtracted once")
+	entries := getLedgerEntries(t, db, virtualCard)
+	require.Lenf(t, entries, 1, "lot should be expired only once")
+	require.Equalf(t, int64(-10), entries[0].Delta, "expiration entry has invalid delta")
+}
+
+func TestPointsLedgerManagerGetExpiringPoints(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	manager := GetTestPointsLedgerManager(ctrl)
+	db := manager.baseServices.Database
+	user := GetTestUser(db)
+	business := GetTestBusiness(db, GetTestUser(db))
+	business.PointsExpiryDays = 60
+	Save(db, business)
+	virtualCard := GetTestVirtualCardWithPoints(db, user, business, 15)
+	oldLot := GetTestPointsLot(db, virtualCard, 10, time.Now().Add(-50*24*time.Hour))
+	GetTestPointsLot(db, virtualCard, 5, time.Now().Add(-10*24*time.Hour))
+
+	expiring, err := manager.GetExpiringPoints(virtualCard)
+	require.Nilf(t, err, "PointsLedgerManager.GetExpiringPoints returned an error %w", err)
+	require.Lenf(t, expiring, 1, "only the old lot expires within the warning period")
+	require.Equalf(t, uint(10), expiring[0].Points, "expiring points have invalid amount")
+	require.WithinDurationf(t, oldLot.CreatedAt.Add(60*24*time.Hour), expiring[0].Expires, time.Second,
+		"expiring points have invalid expiration date")
+}
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/StampWallet/backend/internal/managers (interfaces: AuthManager,BusinessManager,ItemDefinitionManager,LocalCardManager,TransactionManager,VirtualCardManager)
+// Source: github.com/StampWallet/backend/internal/managers (interfaces: AuthManager,BusinessManager,BusinessMemberManager,FileGCManager,ItemDefinitionManager,LocalCardManager,PointsLedgerManager,TransactionManager,VirtualCardManager)
 
 // Package mock_managers is a generated GoMock package.
 package mock_managers
@@ -9,6 +9,7 @@ import (
 
 	database "github.com/StampWallet/backend/internal/database"
 	managers "github.com/StampWallet/backend/internal/managers"
+	services "github.com/StampWallet/backend/internal/services"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -36,33 +37,48 @@ func (m *MockAuthManager) EXPECT() *MockAuthManagerMockRecorder {
 }
 
 // ChangeEmail mocks base method.
-func (m *MockAuthManager) ChangeEmail(arg0 *database.User, arg1 string) (*database.User, error) {
+func (m *MockAuthManager) ChangeEmail(arg0 *database.User, arg1 string, arg2 *database.Token) (*database.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ChangeEmail", arg0, arg1)
+	ret := m.ctrl.Call(m, "ChangeEmail", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*database.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ChangeEmail indicates an expected call of ChangeEmail.
-func (mr *MockAuthManagerMockRecorder) ChangeEmail(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockAuthManagerMockRecorder) ChangeEmail(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeEmail", reflect.TypeOf((*MockAuthManager)(nil).ChangeEmail), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeEmail", reflect.TypeOf((*MockAuthManager)(nil).ChangeEmail), arg0, arg1, arg2)
+}
+
+// ChangeLocale mocks base method.
+func (m *MockAuthManager) ChangeLocale(arg0 *database.User, arg1 string) (*database.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeLocale", arg0, arg1)
+	ret0, _ := ret[0].(*database.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangeLocale indicates an expected call of ChangeLocale.
+func (mr *MockAuthManagerMockRecorder) ChangeLocale(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeLocale", reflect.TypeOf((*MockAuthManager)(nil).ChangeLocale), arg0, arg1)
 }
 
 // ChangePassword mocks base method.
-func (m *MockAuthManager) ChangePassword(arg0 *database.User, arg1, arg2 string) (*database.User, error) {
+func (m *MockAuthManager) ChangePassword(arg0 *database.User, arg1, arg2 string, arg3 *database.Token) (*database.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ChangePassword", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "ChangePassword", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(*database.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ChangePassword indicates an expected call of ChangePassword.
-func (mr *MockAuthManagerMockRecorder) ChangePassword(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockAuthManagerMockRecorder) ChangePassword(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockAuthManager)(nil).ChangePassword), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockAuthManager)(nil).ChangePassword), arg0, arg1, arg2, arg3)
 }
 
 // ConfirmEmail mocks base method.
@@ -80,6 +96,21 @@ func (mr *MockAuthManagerMockRecorder) ConfirmEmail(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmEmail", reflect.TypeOf((*MockAuthManager)(nil).ConfirmEmail), arg0, arg1)
 }
 
+// ConfirmTotp mocks base method.
+func (m *MockAuthManager) ConfirmTotp(arg0 *database.User, arg1 string, arg2 *database.Token) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTotp", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTotp indicates an expected call of ConfirmTotp.
+func (mr *MockAuthManagerMockRecorder) ConfirmTotp(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTotp", reflect.TypeOf((*MockAuthManager)(nil).ConfirmTotp), arg0, arg1, arg2)
+}
+
 // Create mocks base method.
 func (m *MockAuthManager) Create(arg0 managers.UserDetails) (*database.User, *database.Token, string, error) {
 	m.ctrl.T.Helper()
@@ -97,10 +128,56 @@ func (mr *MockAuthManagerMockRecorder) Create(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAuthManager)(nil).Create), arg0)
 }
 
+// DisableTotp mocks base method.
+func (m *MockAuthManager) DisableTotp(arg0 *database.User, arg1 string) (*database.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTotp", arg0, arg1)
+	ret0, _ := ret[0].(*database.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DisableTotp indicates an expected call of DisableTotp.
+func (mr *MockAuthManagerMockRecorder) DisableTotp(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTotp", reflect.TypeOf((*MockAuthManager)(nil).DisableTotp), arg0, arg1)
+}
+
+// EnrollTotp mocks base method.
+func (m *MockAuthManager) EnrollTotp(arg0 *database.User) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTotp", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// EnrollTotp indicates an expected call of EnrollTotp.
+func (mr *MockAuthManagerMockRecorder) EnrollTotp(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTotp", reflect.TypeOf((*MockAuthManager)(nil).EnrollTotp), arg0)
+}
+
+// GetSessions mocks base method.
+func (m *MockAuthManager) GetSessions(arg0 *database.User) ([]database.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessions", arg0)
+	ret0, _ := ret[0].([]database.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessions indicates an expected call of GetSessions.
+func (mr *MockAuthManagerMockRecorder) GetSessions(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessions", reflect.TypeOf((*MockAuthManager)(nil).GetSessions), arg0)
+}
+
 // Login mocks base method.
-func (m *MockAuthManager) Login(arg0, arg1 string) (*database.User, *database.Token, string, error) {
+func (m *MockAuthManager) Login(arg0, arg1 string, arg2 services.SessionMetadata) (*database.User, *database.Token, string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Login", arg0, arg1)
+	ret := m.ctrl.Call(m, "Login", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*database.User)
 	ret1, _ := ret[1].(*database.Token)
 	ret2, _ := ret[2].(string)
@@ -109,9 +186,9 @@ func (m *MockAuthManager) Login(arg0, arg1 string) (*database.User, *database.To
 }
 
 // Login indicates an expected call of Login.
-func (mr *MockAuthManagerMockRecorder) Login(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockAuthManagerMockRecorder) Login(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockAuthManager)(nil).Login), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockAuthManager)(nil).Login), arg0, arg1, arg2)
 }
 
 // Logout mocks base method.
@@ -130,6 +207,109 @@ func (mr *MockAuthManagerMockRecorder) Logout(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockAuthManager)(nil).Logout), arg0, arg1)
 }
 
+// RequestPasswordReset mocks base method.
+func (m *MockAuthManager) RequestPasswordReset(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestPasswordReset", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestPasswordReset indicates an expected call of RequestPasswordReset.
+func (mr *MockAuthManagerMockRecorder) RequestPasswordReset(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestPasswordReset", reflect.TypeOf((*MockAuthManager)(nil).RequestPasswordReset), arg0)
+}
+
+// ResendEmailConfirmation mocks base method.
+func (m *MockAuthManager) ResendEmailConfirmation(arg0 *database.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResendEmailConfirmation", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResendEmailConfirmation indicates an expected call of ResendEmailConfirmation.
+func (mr *MockAuthManagerMockRecorder) ResendEmailConfirmation(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResendEmailConfirmation", reflect.TypeOf((*MockAuthManager)(nil).ResendEmailConfirmation), arg0)
+}
+
+// ResetPassword mocks base method.
+func (m *MockAuthManager) ResetPassword(arg0, arg1, arg2 string) (*database.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*database.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockAuthManagerMockRecorder) ResetPassword(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockAuthManager)(nil).ResetPassword), arg0, arg1, arg2)
+}
+
+// RevertEmailChange mocks base method.
+func (m *MockAuthManager) RevertEmailChange(arg0, arg1 string) (*database.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevertEmailChange", arg0, arg1)
+	ret0, _ := ret[0].(*database.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevertEmailChange indicates an expected call of RevertEmailChange.
+func (mr *MockAuthManagerMockRecorder) RevertEmailChange(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevertEmailChange", reflect.TypeOf((*MockAuthManager)(nil).RevertEmailChange), arg0, arg1)
+}
+
+// RevokeOtherSessions mocks base method.
+func (m *MockAuthManager) RevokeOtherSessions(arg0 *database.Token) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeOtherSessions", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeOtherSessions indicates an expected call of RevokeOtherSessions.
+func (mr *MockAuthManagerMockRecorder) RevokeOtherSessions(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeOtherSessions", reflect.TypeOf((*MockAuthManager)(nil).RevokeOtherSessions), arg0)
+}
+
+// RevokeSession mocks base method.
+func (m *MockAuthManager) RevokeSession(arg0 *database.User, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockAuthManagerMockRecorder) RevokeSession(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockAuthManager)(nil).RevokeSession), arg0, arg1)
+}
+
+// VerifyTotpLogin mocks base method.
+func (m *MockAuthManager) VerifyTotpLogin(arg0, arg1, arg2 string, arg3 services.SessionMetadata) (*database.User, *database.Token, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyTotpLogin", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*database.User)
+	ret1, _ := ret[1].(*database.Token)
+	ret2, _ := ret[2].(string)
+	ret3, _ := ret[3].(error)
+	return ret0, ret1, ret2, ret3
+}
+
+// VerifyTotpLogin indicates an expected call of VerifyTotpLogin.
+func (mr *MockAuthManagerMockRecorder) VerifyTotpLogin(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyTotpLogin", reflect.TypeOf((*MockAuthManager)(nil).VerifyTotpLogin), arg0, arg1, arg2, arg3)
+}
+
 // MockBusinessManager is a mock of BusinessManager interface.
 type MockBusinessManager struct {
 	ctrl     *gomock.Controller
@@ -153,6 +333,21 @@ func (m *MockBusinessManager) EXPECT() *MockBusinessManagerMockRecorder {
 	return m.recorder
 }
 
+// AddLocation mocks base method.
+func (m *MockBusinessManager) AddLocation(arg0 *database.Business, arg1 *managers.BusinessLocationDetails) (*database.BusinessLocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddLocation", arg0, arg1)
+	ret0, _ := ret[0].(*database.BusinessLocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddLocation indicates an expected call of AddLocation.
+func (mr *MockBusinessManagerMockRecorder) AddLocation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLocation", reflect.TypeOf((*MockBusinessManager)(nil).AddLocation), arg0, arg1)
+}
+
 // AddMenuImage mocks base method.
 func (m *MockBusinessManager) AddMenuImage(arg0 *database.User, arg1 *database.Business) (*database.MenuImage, error) {
 	m.ctrl.T.Helper()
@@ -183,6 +378,21 @@ func (mr *MockBusinessManagerMockRecorder) ChangeDetails(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeDetails", reflect.TypeOf((*MockBusinessManager)(nil).ChangeDetails), arg0, arg1)
 }
 
+// ChangeLocation mocks base method.
+func (m *MockBusinessManager) ChangeLocation(arg0 *database.BusinessLocation, arg1 *managers.ChangeableBusinessLocationDetails) (*database.BusinessLocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeLocation", arg0, arg1)
+	ret0, _ := ret[0].(*database.BusinessLocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangeLocation indicates an expected call of ChangeLocation.
+func (mr *MockBusinessManagerMockRecorder) ChangeLocation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeLocation", reflect.TypeOf((*MockBusinessManager)(nil).ChangeLocation), arg0, arg1)
+}
+
 // Create mocks base method.
 func (m *MockBusinessManager) Create(arg0 *database.User, arg1 *managers.BusinessDetails) (*database.Business, error) {
 	m.ctrl.T.Helper()
@@ -198,6 +408,21 @@ func (mr *MockBusinessManagerMockRecorder) Create(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockBusinessManager)(nil).Create), arg0, arg1)
 }
 
+// GetBusinessCategories mocks base method.
+func (m *MockBusinessManager) GetBusinessCategories(arg0 *database.Business) ([]database.Category, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBusinessCategories", arg0)
+	ret0, _ := ret[0].([]database.Category)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBusinessCategories indicates an expected call of GetBusinessCategories.
+func (mr *MockBusinessManagerMockRecorder) GetBusinessCategories(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBusinessCategories", reflect.TypeOf((*MockBusinessManager)(nil).GetBusinessCategories), arg0)
+}
+
 // GetById mocks base method.
 func (m *MockBusinessManager) GetById(arg0 string, arg1 bool) (*database.Business, error) {
 	m.ctrl.T.Helper()
@@ -213,6 +438,81 @@ func (mr *MockBusinessManagerMockRecorder) GetById(arg0, arg1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetById", reflect.TypeOf((*MockBusinessManager)(nil).GetById), arg0, arg1)
 }
 
+// GetCategories mocks base method.
+func (m *MockBusinessManager) GetCategories() ([]database.Category, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCategories")
+	ret0, _ := ret[0].([]database.Category)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCategories indicates an expected call of GetCategories.
+func (mr *MockBusinessManagerMockRecorder) GetCategories() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategories", reflect.TypeOf((*MockBusinessManager)(nil).GetCategories))
+}
+
+// GetFileUsage mocks base method.
+func (m *MockBusinessManager) GetFileUsage(arg0 *database.Business) (*services.FileUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFileUsage", arg0)
+	ret0, _ := ret[0].(*services.FileUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFileUsage indicates an expected call of GetFileUsage.
+func (mr *MockBusinessManagerMockRecorder) GetFileUsage(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFileUsage", reflect.TypeOf((*MockBusinessManager)(nil).GetFileUsage), arg0)
+}
+
+// GetLocations mocks base method.
+func (m *MockBusinessManager) GetLocations(arg0 *database.Business) ([]database.BusinessLocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLocations", arg0)
+	ret0, _ := ret[0].([]database.BusinessLocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLocations indicates an expected call of GetLocations.
+func (mr *MockBusinessManagerMockRecorder) GetLocations(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLocations", reflect.TypeOf((*MockBusinessManager)(nil).GetLocations), arg0)
+}
+
+// GetOpeningHours mocks base method.
+func (m *MockBusinessManager) GetOpeningHours(arg0 *database.Business) ([]database.OpeningHours, []database.OpeningHoursException, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOpeningHours", arg0)
+	ret0, _ := ret[0].([]database.OpeningHours)
+	ret1, _ := ret[1].([]database.OpeningHoursException)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetOpeningHours indicates an expected call of GetOpeningHours.
+func (mr *MockBusinessManagerMockRecorder) GetOpeningHours(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOpeningHours", reflect.TypeOf((*MockBusinessManager)(nil).GetOpeningHours), arg0)
+}
+
+// RemoveLocation mocks base method.
+func (m *MockBusinessManager) RemoveLocation(arg0 *database.BusinessLocation) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveLocation", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveLocation indicates an expected call of RemoveLocation.
+func (mr *MockBusinessManagerMockRecorder) RemoveLocation(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveLocation", reflect.TypeOf((*MockBusinessManager)(nil).RemoveLocation), arg0)
+}
+
 // RemoveMenuImage mocks base method.
 func (m *MockBusinessManager) RemoveMenuImage(arg0 *database.MenuImage) error {
 	m.ctrl.T.Helper()
@@ -228,18 +528,206 @@ func (mr *MockBusinessManagerMockRecorder) RemoveMenuImage(arg0 interface{}) *go
 }
 
 // Search mocks base method.
-func (m *MockBusinessManager) Search(arg0 *string, arg1 *database.GPSCoordinates, arg2, arg3, arg4 uint) ([]database.Business, error) {
+func (m *MockBusinessManager) Search(arg0 *managers.BusinessSearchQuery) (*managers.BusinessSearchResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Search", arg0, arg1, arg2, arg3, arg4)
-	ret0, _ := ret[0].([]database.Business)
+	ret := m.ctrl.Call(m, "Search", arg0)
+	ret0, _ := ret[0].(*managers.BusinessSearchResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Search indicates an expected call of Search.
-func (mr *MockBusinessManagerMockRecorder) Search(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+func (mr *MockBusinessManagerMockRecorder) Search(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockBusinessManager)(nil).Search), arg0)
+}
+
+// MockBusinessMemberManager is a mock of BusinessMemberManager interface.
+type MockBusinessMemberManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockBusinessMemberManagerMockRecorder
+}
+
+// MockBusinessMemberManagerMockRecorder is the mock recorder for MockBusinessMemberManager.
+type MockBusinessMemberManagerMockRecorder struct {
+	mock *MockBusinessMemberManager
+}
+
+// NewMockBusinessMemberManager creates a new mock instance.
+func NewMockBusinessMemberManager(ctrl *gomock.Controller) *MockBusinessMemberManager {
+	mock := &MockBusinessMemberManager{ctrl: ctrl}
+	mock.recorder = &MockBusinessMemberManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBusinessMemberManager) EXPECT() *MockBusinessMemberManagerMockRecorder {
+	return m.recorder
+}
+
+// AcceptInvitation mocks base method.
+func (m *MockBusinessMemberManager) AcceptInvitation(arg0 *database.User, arg1 string) (*database.BusinessMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcceptInvitation", arg0, arg1)
+	ret0, _ := ret[0].(*database.BusinessMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcceptInvitation indicates an expected call of AcceptInvitation.
+func (mr *MockBusinessMemberManagerMockRecorder) AcceptInvitation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptInvitation", reflect.TypeOf((*MockBusinessMemberManager)(nil).AcceptInvitation), arg0, arg1)
+}
+
+// GetInvitations mocks base method.
+func (m *MockBusinessMemberManager) GetInvitations(arg0 *database.User) ([]database.BusinessInvitation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInvitations", arg0)
+	ret0, _ := ret[0].([]database.BusinessInvitation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInvitations indicates an expected call of GetInvitations.
+func (mr *MockBusinessMemberManagerMockRecorder) GetInvitations(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInvitations", reflect.TypeOf((*MockBusinessMemberManager)(nil).GetInvitations), arg0)
+}
+
+// GetMembers mocks base method.
+func (m *MockBusinessMemberManager) GetMembers(arg0 *database.Business) ([]database.BusinessMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMembers", arg0)
+	ret0, _ := ret[0].([]database.BusinessMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMembers indicates an expected call of GetMembers.
+func (mr *MockBusinessMemberManagerMockRecorder) GetMembers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMembers", reflect.TypeOf((*MockBusinessMemberManager)(nil).GetMembers), arg0)
+}
+
+// GetMembership mocks base method.
+func (m *MockBusinessMemberManager) GetMembership(arg0 *database.User) (*database.BusinessMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMembership", arg0)
+	ret0, _ := ret[0].(*database.BusinessMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMembership indicates an expected call of GetMembership.
+func (mr *MockBusinessMemberManagerMockRecorder) GetMembership(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMembership", reflect.TypeOf((*MockBusinessMemberManager)(nil).GetMembership), arg0)
+}
+
+// Invite mocks base method.
+func (m *MockBusinessMemberManager) Invite(arg0 *database.Business, arg1 string, arg2 database.BusinessMemberRoleEnum) (*database.BusinessInvitation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Invite", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*database.BusinessInvitation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Invite indicates an expected call of Invite.
+func (mr *MockBusinessMemberManagerMockRecorder) Invite(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Invite", reflect.TypeOf((*MockBusinessMemberManager)(nil).Invite), arg0, arg1, arg2)
+}
+
+// RemoveMember mocks base method.
+func (m *MockBusinessMemberManager) RemoveMember(arg0 *database.BusinessMember) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMember", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMember indicates an expected call of RemoveMember.
+func (mr *MockBusinessMemberManagerMockRecorder) RemoveMember(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockBusinessManager)(nil).Search), arg0, arg1, arg2, arg3, arg4)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMember", reflect.TypeOf((*MockBusinessMemberManager)(nil).RemoveMember), arg0)
+}
+
+// MockEmailOutboxManager is a mock of EmailOutboxManager interface.
+type MockEmailOutboxManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockEmailOutboxManagerMockRecorder
+}
+
+// MockEmailOutboxManagerMockRecorder is the mock recorder for MockEmailOutboxManager.
+type MockEmailOutboxManagerMockRecorder struct {
+	mock *MockEmailOutboxManager
+}
+
+// NewMockEmailOutboxManager creates a new mock instance.
+func NewMockEmailOutboxManager(ctrl *gomock.Controller) *MockEmailOutboxManager {
+	mock := &MockEmailOutboxManager{ctrl: ctrl}
+	mock.recorder = &MockEmailOutboxManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEmailOutboxManager) EXPECT() *MockEmailOutboxManagerMockRecorder {
+	return m.recorder
+}
+
+// DeliverPending mocks base method.
+func (m *MockEmailOutboxManager) DeliverPending(arg0 int) (*managers.EmailDeliveryReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeliverPending", arg0)
+	ret0, _ := ret[0].(*managers.EmailDeliveryReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeliverPending indicates an expected call of DeliverPending.
+func (mr *MockEmailOutboxManagerMockRecorder) DeliverPending(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeliverPending", reflect.TypeOf((*MockEmailOutboxManager)(nil).DeliverPending), arg0)
+}
+
+// MockFileGCManager is a mock of FileGCManager interface.
+type MockFileGCManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockFileGCManagerMockRecorder
+}
+
+// MockFileGCManagerMockRecorder is the mock recorder for MockFileGCManager.
+type MockFileGCManagerMockRecorder struct {
+	mock *MockFileGCManager
+}
+
+// NewMockFileGCManager creates a new mock instance.
+func NewMockFileGCManager(ctrl *gomock.Controller) *MockFileGCManager {
+	mock := &MockFileGCManager{ctrl: ctrl}
+	mock.recorder = &MockFileGCManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFileGCManager) EXPECT() *MockFileGCManagerMockRecorder {
+	return m.recorder
+}
+
+// CollectGarbage mocks base method.
+func (m *MockFileGCManager) CollectGarbage(arg0 bool) (*managers.FileGCReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CollectGarbage", arg0)
+	ret0, _ := ret[0].(*managers.FileGCReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CollectGarbage indicates an expected call of CollectGarbage.
+func (mr *MockFileGCManagerMockRecorder) CollectGarbage(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CollectGarbage", reflect.TypeOf((*MockFileGCManager)(nil).CollectGarbage), arg0)
 }
 
 // MockItemDefinitionManager is a mock of ItemDefinitionManager interface.
@@ -391,6 +879,90 @@ func (mr *MockLocalCardManagerMockRecorder) Remove(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockLocalCardManager)(nil).Remove), arg0)
 }
 
+// MockPointsLedgerManager is a mock of PointsLedgerManager interface.
+type MockPointsLedgerManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockPointsLedgerManagerMockRecorder
+}
+
+// MockPointsLedgerManagerMockRecorder is the mock recorder for MockPointsLedgerManager.
+type MockPointsLedgerManagerMockRecorder struct {
+	mock *MockPointsLedgerManager
+}
+
+// NewMockPointsLedgerManager creates a new mock instance.
+func NewMockPointsLedgerManager(ctrl *gomock.Controller) *MockPointsLedgerManager {
+	mock := &MockPointsLedgerManager{ctrl: ctrl}
+	mock.recorder = &MockPointsLedgerManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPointsLedgerManager) EXPECT() *MockPointsLedgerManagerMockRecorder {
+	return m.recorder
+}
+
+// ExpirePoints mocks base method.
+func (m *MockPointsLedgerManager) ExpirePoints() (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePoints")
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePoints indicates an expected call of ExpirePoints.
+func (mr *MockPointsLedgerManagerMockRecorder) ExpirePoints() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePoints", reflect.TypeOf((*MockPointsLedgerManager)(nil).ExpirePoints))
+}
+
+// GetExpiringPoints mocks base method.
+func (m *MockPointsLedgerManager) GetExpiringPoints(arg0 *database.VirtualCard) ([]managers.ExpiringPoints, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExpiringPoints", arg0)
+	ret0, _ := ret[0].([]managers.ExpiringPoints)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExpiringPoints indicates an expected call of GetExpiringPoints.
+func (mr *MockPointsLedgerManagerMockRecorder) GetExpiringPoints(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExpiringPoints", reflect.TypeOf((*MockPointsLedgerManager)(nil).GetExpiringPoints), arg0)
+}
+
+// GetHistory mocks base method.
+func (m *MockPointsLedgerManager) GetHistory(arg0 *database.VirtualCard, arg1 *database.Page) ([]database.PointsLedgerEntry, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistory", arg0, arg1)
+	ret0, _ := ret[0].([]database.PointsLedgerEntry)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetHistory indicates an expected call of GetHistory.
+func (mr *MockPointsLedgerManagerMockRecorder) GetHistory(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistory", reflect.TypeOf((*MockPointsLedgerManager)(nil).GetHistory), arg0, arg1)
+}
+
+// Reconcile mocks base method.
+func (m *MockPointsLedgerManager) Reconcile(arg0 bool) ([]managers.PointsBalanceMismatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reconcile", arg0)
+	ret0, _ := ret[0].([]managers.PointsBalanceMismatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reconcile indicates an expected call of Reconcile.
+func (mr *MockPointsLedgerManagerMockRecorder) Reconcile(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reconcile", reflect.TypeOf((*MockPointsLedgerManager)(nil).Reconcile), arg0)
+}
+
 // MockTransactionManager is a mock of TransactionManager interface.
 type MockTransactionManager struct {
 	ctrl     *gomock.Controller
@@ -414,19 +986,34 @@ func (m *MockTransactionManager) EXPECT() *MockTransactionManagerMockRecorder {
 	return m.recorder
 }
 
+// ExpireTransactions mocks base method.
+func (m *MockTransactionManager) ExpireTransactions() (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireTransactions")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireTransactions indicates an expected call of ExpireTransactions.
+func (mr *MockTransactionManagerMockRecorder) ExpireTransactions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireTransactions", reflect.TypeOf((*MockTransactionManager)(nil).ExpireTransactions))
+}
+
 // Finalize mocks base method.
-func (m *MockTransactionManager) Finalize(arg0 *database.Transaction, arg1 []managers.ItemWithAction, arg2 uint64) (*database.Transaction, error) {
+func (m *MockTransactionManager) Finalize(arg0 *database.Transaction, arg1 []managers.ItemWithAction, arg2 uint64, arg3 *database.BusinessLocation) (*database.Transaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Finalize", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "Finalize", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(*database.Transaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Finalize indicates an expected call of Finalize.
-func (mr *MockTransactionManagerMockRecorder) Finalize(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockTransactionManagerMockRecorder) Finalize(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Finalize", reflect.TypeOf((*MockTransactionManager)(nil).Finalize), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Finalize", reflect.TypeOf((*MockTransactionManager)(nil).Finalize), arg0, arg1, arg2, arg3)
 }
 
 // Start mocks base method.
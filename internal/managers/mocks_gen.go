@@ -1,3 +1,3 @@
 package managers
 
-//go:generate $GOPATH/bin/mockgen --destination mocks/mocks.go --build_flags=--mod=mod . AuthManager,BusinessManager,ItemDefinitionManager,LocalCardManager,TransactionManager,VirtualCardManager
+//go:generate $GOPATH/bin/mockgen --destination mocks/mocks.go --build_flags=--mod=mod . AuthManager,BusinessManager,BusinessMemberManager,EmailOutboxManager,FileGCManager,ItemDefinitionManager,LocalCardManager,PointsLedgerManager,TransactionManager,VirtualCardManager
@@ -0,0 +1,261 @@
+package managers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/services"
+	. "github.com/StampWallet/backend/internal/utils"
+)
+
+// How long the token returned by Login can be exchanged for a session
+const totpPendingTokenTTL = 5 * time.Minute
+
+// Number of recovery codes generated when TOTP is enabled
+const totpRecoveryCodeCount = 10
+
+const totpRecoveryCodeAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+
+// Generates a random recovery code, formatted as two groups of 5 characters
+func generateTotpRecoveryCode() (string, error) {
+	code := make([]byte, 0, 11)
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			code = append(code, '-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(totpRecoveryCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code = append(code, totpRecoveryCodeAlphabet[n.Int64()])
+	}
+	return string(code), nil
+}
+
+// Returns the hash of recovery code stored in the database. Recovery codes are random, so a fast
+// hash is good enough and allows looking the code up directly.
+func hashTotpRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// Checks if code is a valid TOTP code or an unused recovery code of user, and marks it as used,
+// so that it cannot be used again.
+func useTotpCode(db GormDB, user *User, code string) (bool, error) {
+	if !user.TotpEnabled || !user.TotpSecret.Valid {
+		return false, nil
+	}
+
+	if step, ok := CheckTotpCode(user.TotpSecret.String, code, time.Now()); ok {
+		// Codes of a time step can be used once, whichever request stores the step first wins
+		tx := db.Model(user).Where("totp_last_step < ?", step).Update("totp_last_step", step)
+		if err := tx.GetError(); err != nil {
+			return false, fmt.Errorf("db.Update(User) returned an error: %w", err)
+		}
+		return tx.GetRowsAffected() == 1, nil
+	}
+
+	tx := db.Model(&TotpRecoveryCode{}).
+		Where("owner_id = ? AND code_hash = ? AND used = false", user.ID, hashTotpRecoveryCode(code)).
+		Update("used", true)
+	if err := tx.GetError(); err != nil {
+		return false, fmt.Errorf("db.Update(TotpRecoveryCode) returned an error: %w", err)
+	}
+	return tx.GetRowsAffected() == 1, nil
+}
+
+// Checks if user owns or is a member of a business that requires TOTP
+func totpRequiredByBusiness(db GormDB, user *User) (bool, error) {
+	var count int64
+	tx := db.Model(&Business{}).
+		Where(`require_totp = true AND (owner_id = ? OR id IN (
+			SELECT business_id FROM business_members WHERE owner_id = ? AND deleted_at IS NULL))`,
+			user.ID, user.ID).
+		Count(&count)
+	if err := tx.GetError(); err != nil {
+		return false, fmt.Errorf("db.Count(Business) returned an error: %w", err)
+	}
+	return count != 0, nil
+}
+
+func (manager *AuthManagerImpl) EnrollTotp(user *User) (string, string, error) {
+	if user.TotpEnabled {
+		return "", "", ErrTotpAlreadyEnabled
+	}
+
+	secret, err := GenerateTotpSecret()
+	if err != nil {
+		return "", "", fmt.Errorf("%s failed to generate totp secret: %+v", CallerFilename(), err)
+	}
+
+	// Enrolling again replaces the previous, unconfirmed secret
+	user.TotpSecret = sql.NullString{String: secret, Valid: true}
+	tx := manager.baseServices.Database.Model(user).Update("totp_secret", user.TotpSecret)
+	if err := tx.GetError(); err != nil {
+		return "", "", fmt.Errorf("%s failed to save totp secret, database error: %+v", CallerFilename(), err)
+	}
+
+	return secret, TotpUri(manager.totpIssuer, user.Email, secret), nil
+}
+
+func (manager *AuthManagerImpl) ConfirmTotp(user *User, code string, keepSession *Token) ([]string, error) {
+	if user.TotpEnabled {
+		return nil, ErrTotpAlreadyEnabled
+	}
+	if !user.TotpSecret.Valid {
+		return nil, ErrTotpNotEnrolled
+	}
+	step, ok := CheckTotpCode(user.TotpSecret.String, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTotpCode
+	}
+
+	// Generate recovery codes, only their hashes are stored
+	recoveryCodes := make([]string, totpRecoveryCodeCount)
+	recoveryCodeModels := make([]TotpRecoveryCode, totpRecoveryCodeCount)
+	for i := range recoveryCodes {
+		recoveryCode, err := generateTotpRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("%s failed to generate recovery code: %+v", CallerFilename(), err)
+		}
+		recoveryCodes[i] = recoveryCode
+		recoveryCodeModels[i] = TotpRecoveryCode{OwnerId: user.ID, CodeHash: hashTotpRecoveryCode(recoveryCode)}
+	}
+
+	err := manager.baseServices.Database.Transaction(func(db GormDB) error {
+		tx := db.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		})
+		if err := tx.GetError(); err != nil {
+			return fmt.Errorf("%s failed to enable totp, database error: %+v", CallerFilename(), err)
+		}
+
+		// Recovery codes from previous enrollments can't be used anymore
+		tx = db.Where("owner_id = ?", user.ID).Delete(&TotpRecoveryCode{})
+		if err := tx.GetError(); err != nil {
+			return fmt.Errorf("%s failed to delete recovery codes, database error: %+v", CallerFilename(), err)
+		}
+		tx = db.Create(&recoveryCodeModels)
+		if err := tx.GetError(); err != nil {
+			return fmt.Errorf("%s failed to create recovery codes, database error: %+v", CallerFilename(), err)
+		}
+
+		// Log out everywhere else
+		if keepSession != nil {
+			tokenTx, err := manager.tokenService.WithTransaction(db)
+			if err != nil {
+				return fmt.Errorf("%s failed to call TokenService.WithTransaction %+v", CallerFilename(), err)
+			}
+			if err := tokenTx.InvalidateOthers(keepSession); err != nil {
+				return fmt.Errorf("%s failed to invalidate sessions: %+v", CallerFilename(), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.TotpEnabled = true
+	user.TotpLastStep = step
+	return recoveryCodes, nil
+}
+
+func (manager *AuthManagerImpl) DisableTotp(user *User, code string) (*User, error) {
+	if !user.TotpEnabled {
+		return nil, ErrTotpNotEnabled
+	}
+
+	err := manager.baseServices.Database.Transaction(func(db GormDB) error {
+		required, err := totpRequiredByBusiness(db, user)
+		if err != nil {
+			return err
+		} else if required {
+			return ErrTotpRequired
+		}
+
+		ok, err := useTotpCode(db, user, code)
+		if err != nil {
+			return err
+		} else if !ok {
+			return ErrInvalidTotpCode
+		}
+
+		tx := db.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    sql.NullString{},
+			"totp_last_step": 0,
+		})
+		if err := tx.GetError(); err != nil {
+			return fmt.Errorf("%s failed to disable totp, database error: %+v", CallerFilename(), err)
+		}
+		tx = db.Where("owner_id = ?", user.ID).Delete(&TotpRecoveryCode{})
+		if err := tx.GetError(); err != nil {
+			return fmt.Errorf("%s failed to delete recovery codes, database error: %+v", CallerFilename(), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.TotpEnabled = false
+	user.TotpSecret = sql.NullString{}
+	user.TotpLastStep = 0
+	return user, nil
+}
+
+func (manager *AuthManagerImpl) VerifyTotpLogin(tokenId string, tokenSecret string, code string,
+	metadata SessionMetadata) (*User, *Token, string, error) {
+	// Find pending token
+	pendingToken, err := manager.tokenService.Check(tokenId, tokenSecret)
+	if err == ErrUnknownToken || err == ErrTokenUsed || err == ErrTokenExpired {
+		return nil, nil, "", ErrInvalidToken
+	} else if err != nil {
+		return nil, nil, "", err
+	}
+	if pendingToken.TokenPurpose != TokenPurposeTotpPending {
+		return nil, nil, "", ErrInvalidTokenPurpose
+	}
+	user := pendingToken.User
+
+	// Codes are throttled together with passwords
+	keys := loginAttemptKeys(user.Email, metadata.IpAddress)
+	if err := manager.checkLoginThrottle(keys); err != nil {
+		return nil, nil, "", err
+	}
+
+	ok, err := useTotpCode(manager.baseServices.Database, user, code)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("%s failed to check totp code: %+v", CallerFilename(), err)
+	} else if !ok {
+		if err := manager.recordLoginFailure(keys, user); err != nil {
+			return nil, nil, "", fmt.Errorf("%s failed to record login attempt: %+v", CallerFilename(), err)
+		}
+		return nil, nil, "", ErrInvalidTotpCode
+	}
+
+	if err := manager.loginAttemptStore.Reset(keys[0]); err != nil {
+		return nil, nil, "", fmt.Errorf("%s failed to reset login attempts: %+v", CallerFilename(), err)
+	}
+
+	// Pending token is exchanged for a session token
+	if _, err := manager.tokenService.Invalidate(pendingToken); err != nil {
+		return nil, nil, "", fmt.Errorf("%s failed to invalidate pending token, tokenservice error: %+v",
+			CallerFilename(), err)
+	}
+	sessionToken, sessionSecret, err := manager.createSession(user, metadata)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return user, sessionToken, sessionSecret, nil
+}
@@ -0,0 +1,298 @@
+package managers
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/database/mocks"
+	. "github.com/StampWallet/backend/internal/services"
+	. "github.com/StampWallet/backend/internal/services/mocks"
+	. "github.com/StampWallet/backend/internal/testutils"
+	. "github.com/StampWallet/backend/internal/utils"
+)
+
+const exampleTotpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Returns example user with TOTP enabled
+func getExampleTotpUser() User {
+	user := getExampleUser()
+	user.TotpSecret = sql.NullString{String: exampleTotpSecret, Valid: true}
+	user.TotpEnabled = true
+	return user
+}
+
+// Returns TOTP code of exampleTotpSecret for step
+func getExampleTotpCode(step int64) string {
+	code, err := TotpCode(exampleTotpSecret, step)
+	if err != nil {
+		panic(err)
+	}
+	return code
+}
+
+// Mocks an update query that affects rowsAffected rows
+func mockUpdate(db GormDB, column string, rowsAffected int64) {
+	db.(*MockGormDB).
+		EXPECT().
+		Update(column, gomock.Any()).
+		DoAndReturn(func(column string, value any) GormDB {
+			db.(*MockGormDB).EXPECT().GetError().Return(nil)
+			db.(*MockGormDB).EXPECT().GetRowsAffected().Return(rowsAffected)
+			return db
+		})
+}
+
+// Tests if AuthManagerImpl.Login returns a pending token when user has TOTP enabled
+func TestAuthManagerLoginTotpPending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleTotpUser()
+	db.(*MockGormDB).
+		EXPECT().
+		First(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(arg *User, conds ...interface{}) GormDB {
+			*arg = user
+			return returnError0(db, nil)()
+		})
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Create(&StructMatcher{userMatcher{ID: &user.ID}}, TokenPurposeTotpPending,
+			TimeGreaterThanNow{time.Now().Add(4 * time.Minute)}).
+		Return(&Token{TokenId: "pending", TokenPurpose: TokenPurposeTotpPending}, "pendingSecret", nil)
+
+	_, token, secret, err := manager.Login("test@example.com", "zaq1@WSX", SessionMetadata{IpAddress: "192.0.2.1"})
+	require.Nilf(t, err, "manager.Login should return a nil error")
+	require.Equalf(t, TokenPurposeTotpPending, token.TokenPurpose, "manager.Login should return a pending token")
+	require.Equalf(t, "pendingSecret", secret, "manager.Login returned invalid secret")
+}
+
+// Tests if AuthManagerImpl.VerifyTotpLogin exchanges pending token for a session token
+func TestAuthManagerVerifyTotpLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleTotpUser()
+	pendingToken := createExampleToken("pending", TokenPurposeTotpPending)
+	pendingToken.User = &user
+	metadata := SessionMetadata{UserAgent: "test agent", IpAddress: "192.0.2.1"}
+	step := TotpStep(time.Now())
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Check("pending", "pendingSecret").
+		Return(&pendingToken, nil)
+
+	db.(*MockGormDB).EXPECT().Model(&user).Return(db)
+	db.(*MockGormDB).EXPECT().Where("totp_last_step < ?", step).Return(db)
+	mockUpdate(db, "totp_last_step", 1)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Invalidate(&pendingToken).
+		Return(&pendingToken, nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Create(&user, TokenPurposeSession, gomock.Any()).
+		Return(&Token{TokenId: "session", TokenPurpose: TokenPurposeSession}, "sessionSecret", nil)
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		SetSessionMetadata(gomock.Any(), metadata).
+		DoAndReturn(func(token *Token, metadata SessionMetadata) (*Token, error) {
+			return token, nil
+		})
+
+	_, token, secret, err := manager.VerifyTotpLogin("pending", "pendingSecret", getExampleTotpCode(step), metadata)
+	require.Nilf(t, err, "manager.VerifyTotpLogin should return a nil error")
+	require.Equalf(t, TokenPurposeSession, token.TokenPurpose, "manager.VerifyTotpLogin should return a session token")
+	require.Equalf(t, "sessionSecret", secret, "manager.VerifyTotpLogin returned invalid secret")
+}
+
+// Tests if AuthManagerImpl.VerifyTotpLogin rejects invalid codes and counts them as failed attempts
+func TestAuthManagerVerifyTotpLoginInvalidCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleTotpUser()
+	pendingToken := createExampleToken("pending", TokenPurposeTotpPending)
+	pendingToken.User = &user
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Check("pending", "pendingSecret").
+		Return(&pendingToken, nil)
+
+	// Not a TOTP code, looked up as a recovery code
+	db.(*MockGormDB).EXPECT().Model(&TotpRecoveryCode{}).Return(db)
+	db.(*MockGormDB).
+		EXPECT().
+		Where("owner_id = ? AND code_hash = ? AND used = false", user.ID, hashTotpRecoveryCode("invalid")).
+		Return(db)
+	mockUpdate(db, "used", 0)
+
+	_, token, _, err := manager.VerifyTotpLogin("pending", "pendingSecret", "invalid", SessionMetadata{})
+	require.Equalf(t, ErrInvalidTotpCode, err, "manager.VerifyTotpLogin should return ErrInvalidTotpCode")
+	require.Nilf(t, token, "manager.VerifyTotpLogin should return a nil token")
+
+	attempts, err := manager.loginAttemptStore.Get("email:test@example.com")
+	require.Nilf(t, err, "loginAttemptStore.Get should return a nil error")
+	require.Equalf(t, uint(1), attempts.Failures, "manager.VerifyTotpLogin should record the failure")
+}
+
+// Tests if AuthManagerImpl.VerifyTotpLogin rejects session tokens
+func TestAuthManagerVerifyTotpLoginInvalidPurpose(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	user := getExampleTotpUser()
+	sessionToken := getExampleUserLogin()
+	sessionToken.User = &user
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		Check("test_login", "test_hash").
+		Return(&sessionToken, nil)
+
+	_, _, _, err := manager.VerifyTotpLogin("test_login", "test_hash", getExampleTotpCode(TotpStep(time.Now())), SessionMetadata{})
+	require.Equalf(t, ErrInvalidTokenPurpose, err, "manager.VerifyTotpLogin should return ErrInvalidTokenPurpose")
+}
+
+// Tests if AuthManagerImpl.EnrollTotp stores a new secret
+func TestAuthManagerEnrollTotp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleUser()
+
+	db.(*MockGormDB).EXPECT().Model(&user).Return(db)
+	db.(*MockGormDB).
+		EXPECT().
+		Update("totp_secret", gomock.Any()).
+		DoAndReturn(func(column string, value any) GormDB {
+			return returnError0(db, nil)()
+		})
+
+	secret, uri, err := manager.EnrollTotp(&user)
+	require.Nilf(t, err, "manager.EnrollTotp should return a nil error")
+	require.Equalf(t, secret, user.TotpSecret.String, "manager.EnrollTotp should set user secret")
+	require.Falsef(t, user.TotpEnabled, "manager.EnrollTotp should not enable TOTP")
+	require.Truef(t, strings.HasPrefix(uri, "otpauth://totp/StampWallet:test@example.com?"),
+		"manager.EnrollTotp returned invalid uri %s", uri)
+
+	user.TotpEnabled = true
+	_, _, err = manager.EnrollTotp(&user)
+	require.Equalf(t, ErrTotpAlreadyEnabled, err, "manager.EnrollTotp should return ErrTotpAlreadyEnabled")
+}
+
+// Tests if AuthManagerImpl.ConfirmTotp enables TOTP and creates recovery codes
+func TestAuthManagerConfirmTotp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleTotpUser()
+	user.TotpEnabled = false
+	session := getExampleUserLogin()
+	var recoveryCodeModels *[]TotpRecoveryCode
+
+	mockTransaction(db)
+	db.(*MockGormDB).EXPECT().Model(&user).Return(db)
+	db.(*MockGormDB).
+		EXPECT().
+		Updates(gomock.Any()).
+		DoAndReturn(returnError1(db, nil))
+	db.(*MockGormDB).EXPECT().Where("owner_id = ?", user.ID).Return(db)
+	db.(*MockGormDB).
+		EXPECT().
+		Delete(&TotpRecoveryCode{}).
+		DoAndReturn(func(value any, conds ...any) GormDB {
+			return returnError0(db, nil)()
+		})
+	db.(*MockGormDB).
+		EXPECT().
+		Create(gomock.Any()).
+		DoAndReturn(func(arg any) GormDB {
+			recoveryCodeModels = arg.(*[]TotpRecoveryCode)
+			return returnError0(db, nil)()
+		})
+
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		WithTransaction(db).
+		Return(manager.tokenService, nil)
+	manager.tokenService.(*MockTokenService).
+		EXPECT().
+		InvalidateOthers(&session).
+		Return(nil)
+
+	recoveryCodes, err := manager.ConfirmTotp(&user, getExampleTotpCode(TotpStep(time.Now())), &session)
+	require.Nilf(t, err, "manager.ConfirmTotp should return a nil error")
+	require.Truef(t, user.TotpEnabled, "manager.ConfirmTotp should enable TOTP")
+	require.Lenf(t, recoveryCodes, totpRecoveryCodeCount, "manager.ConfirmTotp returned invalid number of codes")
+	require.Lenf(t, *recoveryCodeModels, totpRecoveryCodeCount, "manager.ConfirmTotp stored invalid number of codes")
+	for i, code := range recoveryCodes {
+		require.Equalf(t, hashTotpRecoveryCode(code), (*recoveryCodeModels)[i].CodeHash,
+			"manager.ConfirmTotp should store hashes of recovery codes")
+		require.NotEqualf(t, code, (*recoveryCodeModels)[i].CodeHash, "recovery codes should not be stored in plain text")
+	}
+}
+
+// Tests if AuthManagerImpl.ConfirmTotp rejects invalid codes
+func TestAuthManagerConfirmTotpInvalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+
+	user := getExampleUser()
+	_, err := manager.ConfirmTotp(&user, "123456", nil)
+	require.Equalf(t, ErrTotpNotEnrolled, err, "manager.ConfirmTotp should return ErrTotpNotEnrolled")
+
+	user = getExampleTotpUser()
+	user.TotpEnabled = false
+	_, err = manager.ConfirmTotp(&user, "invalid", nil)
+	require.Equalf(t, ErrInvalidTotpCode, err, "manager.ConfirmTotp should return ErrInvalidTotpCode")
+}
+
+// Tests if AuthManagerImpl.DisableTotp does not disable TOTP required by a business
+func TestAuthManagerDisableTotpRequired(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	manager, _ := getAuthManager(ctrl)
+	db := manager.baseServices.Database
+
+	user := getExampleTotpUser()
+
+	mockTransaction(db)
+	db.(*MockGormDB).EXPECT().Model(&Business{}).Return(db)
+	db.(*MockGormDB).EXPECT().Where(gomock.Any(), user.ID, user.ID).Return(db)
+	db.(*MockGormDB).
+		EXPECT().
+		Count(gomock.Any()).
+		DoAndReturn(func(count *int64) GormDB {
+			*count = 1
+			return returnError0(db, nil)()
+		})
+
+	_, err := manager.DisableTotp(&user, getExampleTotpCode(TotpStep(time.Now())))
+	require.Equalf(t, ErrTotpRequired, err, "manager.DisableTotp should return ErrTotpRequired")
+	require.Truef(t, user.TotpEnabled, "manager.DisableTotp should not disable TOTP")
+}
@@ -1,26 +1,35 @@
 package managers
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"errors"
-	"strconv"
+	"fmt"
+	"math/big"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lithammer/shortuuid/v4"
+
 	. "github.com/StampWallet/backend/internal/database"
 	. "github.com/StampWallet/backend/internal/services"
-	"github.com/StampWallet/backend/internal/utils"
-	"github.com/lithammer/shortuuid/v4"
 )
 
 const transactionCodeLength = 12
 
+// How many codes Start will try before giving up
+const transactionCodeAttempts = 5
+
 var (
 	ErrInvalidItem        = errors.New("Invalid item")        // no such item or item already used
 	ErrInvalidTransaction = errors.New("Invalid transaction") // transaction finished
 	ErrItemBadCardId      = errors.New("Owned item vcard id does not match that of provided vcard")
 	ErrInvalidAction      = errors.New("NoActionType is not a valid action when finalizing transaction")
 	ErrInvalidActionSet   = errors.New("Invalid action set - does not match started transaction details")
+	ErrTransactionExpired = errors.New("Transaction expired") // transaction was not finalized in time
+	ErrCodeCollision      = errors.New("Failed to generate a unique transaction code")
+	ErrInvalidLocationId  = errors.New("Location does not belong to the business of the transaction")
 )
 
 // TODO
@@ -31,30 +40,70 @@ type ItemWithAction struct {
 
 type TransactionManager interface {
 	Start(card *VirtualCard, items []OwnedItem) (*Transaction, error)
-	Finalize(transaction *Transaction, items []ItemWithAction, points uint64) (*Transaction, error)
+	// Finishes transaction at location of the business. Location is optional
+	Finalize(transaction *Transaction, items []ItemWithAction, points uint64,
+		location *BusinessLocation) (*Transaction, error)
+
+	// Moves all started or processing transactions past their expiration date to TransactionStateExpired.
+	// Returns the number of expired transactions.
+	ExpireTransactions() (int64, error)
 }
 
 type TransactionManagerImpl struct {
-	baseServices BaseServices
+	baseServices   BaseServices
+	transactionTTL time.Duration
+	// replaced in tests to force collisions
+	generateCode func() (string, error)
 }
 
-func digitsSlice() []int {
-	return []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+// Generates a random code of transactionCodeLength digits. Codes are typed in by hand or scanned by
+// the business, so they have to be short - collisions are handled by Start.
+func generateCode() (string, error) {
+	var code strings.Builder
+	for i := 0; i != transactionCodeLength; i++ {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate random digit: %w", err)
+		}
+		code.WriteString(digit.String())
+	}
+	return code.String(), nil
 }
 
-func generateCode() string {
-	randomIntSlice := utils.RandomSlice(transactionCodeLength, digitsSlice())
-	randomStrDigits := utils.Map(randomIntSlice, strconv.Itoa)
-	randomDigitStr := strings.Join(randomStrDigits, "")
-	return randomDigitStr
+// Returns true if err is a unique violation of one of the transaction code indexes
+func isCodeCollision(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" &&
+		(pgErr.ConstraintName == "code" || pgErr.ConstraintName == "transaction_active_code_idx")
 }
 
-func CreateTransactionManagerImpl(baseServices BaseServices) *TransactionManagerImpl {
+// TTL of transactions if the configured one is not positive. Transactions that expire right away
+// could never be finalized.
+const defaultTransactionTTL = 15 * time.Minute
+
+// transactionTTL controls how long a started transaction can wait for Finalize
+func CreateTransactionManagerImpl(baseServices BaseServices, transactionTTL time.Duration) *TransactionManagerImpl {
+	if transactionTTL <= 0 {
+		baseServices.Logger.Printf("transaction TTL %s is not positive, using %s", transactionTTL,
+			defaultTransactionTTL)
+		transactionTTL = defaultTransactionTTL
+	}
 	return &TransactionManagerImpl{
-		baseServices: baseServices,
+		baseServices:   baseServices,
+		transactionTTL: transactionTTL,
+		generateCode:   generateCode,
 	}
 }
 
+// Returns true if transaction can no longer be finalized because it was not finalized in time
+func isExpired(transaction *Transaction) bool {
+	if transaction.State == TransactionStateExpired {
+		return true
+	}
+	return (transaction.State == TransactionStateStarted || transaction.State == TransactionStateProcesing) &&
+		transaction.ExpiresAt.Valid && time.Now().After(transaction.ExpiresAt.Time)
+}
+
 func (manager *TransactionManagerImpl) Start(card *VirtualCard, items []OwnedItem) (*Transaction, error) {
 	for _, chosenItem := range items {
 		if chosenItem.VirtualCardId != card.ID {
@@ -65,15 +114,38 @@ func (manager *TransactionManagerImpl) Start(card *VirtualCard, items []OwnedIte
 		}
 	}
 
+	// Codes are unique among active transactions of a business. On collision, the whole database
+	// transaction is retried with a new code - postgres does not allow to continue after an error.
+	for attempt := 0; attempt != transactionCodeAttempts; attempt++ {
+		code, err := manager.generateCode()
+		if err != nil {
+			return nil, err
+		}
+
+		transaction, err := manager.start(card, items, code)
+		if isCodeCollision(err) {
+			manager.baseServices.Logger.Printf("transaction code collision, attempt %d", attempt+1)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return transaction, nil
+	}
+	return nil, ErrCodeCollision
+}
+
+// Creates the transaction and its details with code
+func (manager *TransactionManagerImpl) start(card *VirtualCard, items []OwnedItem, code string) (*Transaction, error) {
 	var transaction *Transaction
 	err := manager.baseServices.Database.Transaction(func(tx GormDB) error {
-		// __jm__ TODO handle code collisions
 		transaction = &Transaction{
 			PublicId:      shortuuid.New(),
 			VirtualCardId: card.ID,
-			Code:          generateCode(),
+			BusinessId:    card.BusinessId,
+			Code:          code,
 			State:         TransactionStateStarted,
 			AddedPoints:   0,
+			ExpiresAt:     sql.NullTime{Time: time.Now().Add(manager.transactionTTL), Valid: true},
 		}
 		res := tx.Create(transaction)
 		if err := res.GetError(); err != nil {
@@ -103,8 +175,11 @@ func (manager *TransactionManagerImpl) Start(card *VirtualCard, items []OwnedIte
 	return transaction, nil
 }
 
-func (manager *TransactionManagerImpl) Finalize(transaction *Transaction, actions []ItemWithAction, points uint64) (*Transaction, error) {
+func (manager *TransactionManagerImpl) Finalize(transaction *Transaction, actions []ItemWithAction, points uint64,
+	location *BusinessLocation) (*Transaction, error) {
+
 	failTransaction := false
+	expireTransaction := false
 	for _, chosenItem := range actions {
 		if chosenItem.Item.VirtualCardId != transaction.VirtualCardId {
 			return nil, ErrItemBadCardId
@@ -132,9 +207,16 @@ func (manager *TransactionManagerImpl) Finalize(transaction *Transaction, action
 			return err
 		}
 
+		if isExpired(transaction) {
+			expireTransaction = transaction.State != TransactionStateExpired
+			return ErrTransactionExpired
+		}
 		if transaction.State != TransactionStateStarted && transaction.State != TransactionStateProcesing {
 			return ErrInvalidTransaction
 		}
+		if location != nil && location.BusinessId != transaction.VirtualCard.BusinessId {
+			return ErrInvalidLocationId
+		}
 
 		tds := transaction.TransactionDetails
 		if len(actions) != len(tds) {
@@ -164,6 +246,17 @@ func (manager *TransactionManagerImpl) Finalize(transaction *Transaction, action
 				case RecalledActionType:
 					td.OwnedItem.Status = OwnedItemStatusWithdrawn
 					transaction.VirtualCard.Points += td.OwnedItem.ItemDefinition.Price
+					err := recordPointsChange(tx, transaction.VirtualCard.ID,
+						int64(td.OwnedItem.ItemDefinition.Price), PointsLedgerReasonItemRecalled,
+						&transaction.ID, &td.OwnedItem.ID)
+					if err != nil {
+						return err
+					}
+					err = refundPointsLots(tx, transaction.VirtualCard.ID, td.OwnedItem.ID,
+						td.OwnedItem.ItemDefinition.Price)
+					if err != nil {
+						return err
+					}
 				case CancelledActionType:
 					// ?
 				}
@@ -180,8 +273,20 @@ func (manager *TransactionManagerImpl) Finalize(transaction *Transaction, action
 		}
 
 		transaction.State = TransactionStateFinished
+		if location != nil {
+			transaction.BusinessLocationId = &location.ID
+		}
 		transaction.AddedPoints = uint(points)
 		transaction.VirtualCard.Points += transaction.AddedPoints
+		err := recordPointsChange(tx, transaction.VirtualCard.ID, int64(transaction.AddedPoints),
+			PointsLedgerReasonTransaction, &transaction.ID, nil)
+		if err != nil {
+			return err
+		}
+		err = addPointsLot(tx, transaction.VirtualCard.ID, transaction.AddedPoints)
+		if err != nil {
+			return err
+		}
 
 		result = tx.Save(transaction.VirtualCard)
 		if err := result.GetError(); err != nil {
@@ -204,5 +309,31 @@ func (manager *TransactionManagerImpl) Finalize(transaction *Transaction, action
 		}
 	}
 
+	// Reaper did not get to this transaction yet
+	if expireTransaction {
+		result := manager.baseServices.Database.
+			Model(&Transaction{}).
+			Where("id = ?", transaction.ID).
+			Update("state", TransactionStateExpired)
+		if err := result.GetError(); err != nil {
+			return nil, err
+		}
+		transaction.State = TransactionStateExpired
+	}
+
 	return transaction, err
 }
+
+func (manager *TransactionManagerImpl) ExpireTransactions() (int64, error) {
+	// Transactions created before ExpiresAt was introduced expire transactionTTL after creation
+	result := manager.baseServices.Database.
+		Model(&Transaction{}).
+		Where("state IN ?", []TransactionStateEnum{TransactionStateStarted, TransactionStateProcesing}).
+		Where("(expires_at IS NOT NULL AND expires_at < ?) OR (expires_at IS NULL AND created_at < ?)",
+			time.Now(), time.Now().Add(-manager.transactionTTL)).
+		Update("state", TransactionStateExpired)
+	if err := result.GetError(); err != nil {
+		return 0, fmt.Errorf("failed to expire transactions: %w", err)
+	}
+	return result.GetRowsAffected(), nil
+}
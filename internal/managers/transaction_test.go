@@ -1,8 +1,10 @@
 package managers
 
 import (
+	"database/sql"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -15,10 +17,29 @@ import (
 
 func GetTransactionManager(ctrl *gomock.Controller) *TransactionManagerImpl {
 	return &TransactionManagerImpl{
-		BaseServices{
+		baseServices: BaseServices{
 			Logger:   log.Default(),
 			Database: GetTestDatabase(),
 		},
+		transactionTTL: 15 * time.Minute,
+		generateCode:   generateCode,
+	}
+}
+
+func TestCreateTransactionManagerImplDefaultTTL(t *testing.T) {
+	manager := CreateTransactionManagerImpl(BaseServices{Logger: log.Default()}, 0)
+	require.Equalf(t, 15*time.Minute, manager.transactionTTL, "zero TTL should be replaced with the default")
+}
+
+// Returns a code generator that returns codes in order, then repeats the last one
+func sequenceCodeGenerator(codes ...string) func() (string, error) {
+	i := 0
+	return func() (string, error) {
+		code := codes[i]
+		if i != len(codes)-1 {
+			i++
+		}
+		return code, nil
 	}
 }
 
@@ -110,7 +131,129 @@ func TestTransactionManagerStartWithInvalidItems(t *testing.T) {
 	}
 }
 
-//TODO transaction expiration? status exists
+func TestTransactionManagerStartSetsExpiration(t *testing.T) {
+	s := setupTransactionTest(t)
+
+	transaction, err := s.manager.Start(s.virtualCard, []OwnedItem{*s.ownedItem})
+	require.Nilf(t, err, "transaction start returned an error %w", err)
+	require.Truef(t, transaction.ExpiresAt.Valid, "TransactionManager.Start returned transaction without expiration date")
+	require.Truef(t, transaction.ExpiresAt.Time.After(time.Now().Add(14*time.Minute)),
+		"TransactionManager.Start returned transaction that expires too early %s", transaction.ExpiresAt.Time)
+}
+
+func TestTransactionManagerStartRetriesOnCodeCollision(t *testing.T) {
+	s := setupTransactionTest(t)
+	otherUser := GetTestUser(s.db)
+	otherCard := GetTestVirtualCard(s.db, otherUser, s.business)
+	activeTransaction, _ := GetTestTransaction(s.db, otherCard, []OwnedItem{})
+
+	s.manager.generateCode = sequenceCodeGenerator(activeTransaction.Code, "000000000001")
+	transaction, err := s.manager.Start(s.virtualCard, []OwnedItem{*s.ownedItem})
+	require.Nilf(t, err, "transaction start returned an error %w", err)
+	require.Equalf(t, "000000000001", transaction.Code,
+		"TransactionManager.Start returned transaction with a colliding code")
+	require.Equalf(t, s.business.ID, transaction.BusinessId,
+		"TransactionManager.Start returned transaction with invalid business id")
+}
+
+func TestTransactionManagerStartFailsAfterCodeCollisions(t *testing.T) {
+	s := setupTransactionTest(t)
+	otherUser := GetTestUser(s.db)
+	otherCard := GetTestVirtualCard(s.db, otherUser, s.business)
+	activeTransaction, _ := GetTestTransaction(s.db, otherCard, []OwnedItem{})
+
+	s.manager.generateCode = sequenceCodeGenerator(activeTransaction.Code)
+	transaction, err := s.manager.Start(s.virtualCard, []OwnedItem{*s.ownedItem})
+	require.Nilf(t, transaction, "TransactionManager.Start should return a nil transaction")
+	require.Equalf(t, ErrCodeCollision, err, "TransactionManager.Start should return a CodeCollision error")
+}
+
+func TestTransactionManagerStartReusesCodes(t *testing.T) {
+	s := setupTransactionTest(t)
+	otherUser := GetTestUser(s.db)
+	otherCard := GetTestVirtualCard(s.db, otherUser, s.business)
+	finishedTransaction, _ := GetTestTransaction(s.db, otherCard, []OwnedItem{})
+	finishedTransaction.State = TransactionStateFinished
+	Save(s.db, finishedTransaction)
+
+	otherBusiness := GetTestBusiness(s.db, GetTestUser(s.db))
+	otherBusinessCard := GetTestVirtualCard(s.db, otherUser, otherBusiness)
+	otherBusinessTransaction, _ := GetTestTransaction(s.db, otherBusinessCard, []OwnedItem{})
+	otherBusinessTransaction.Code = finishedTransaction.Code
+	Save(s.db, otherBusinessTransaction)
+
+	s.manager.generateCode = sequenceCodeGenerator(finishedTransaction.Code)
+	transaction, err := s.manager.Start(s.virtualCard, []OwnedItem{*s.ownedItem})
+	require.Nilf(t, err, "transaction start returned an error %w", err)
+	require.Equalf(t, finishedTransaction.Code, transaction.Code,
+		"TransactionManager.Start should reuse codes of finished transactions and other businesses")
+}
+
+// Sets ExpiresAt of transaction in the database
+func setTransactionExpiration(t *testing.T, db GormDB, transaction *Transaction, expiresAt time.Time) {
+	transaction.ExpiresAt = sql.NullTime{Time: expiresAt, Valid: true}
+	tx := db.Save(transaction)
+	require.Nilf(t, tx.GetError(), "failed to save transaction in the database")
+}
+
+func TestTransactionManagerExpireTransactions(t *testing.T) {
+	s := setupTransactionTest(t)
+	staleTransaction, _ := GetTestTransaction(s.db, s.virtualCard, []OwnedItem{*s.ownedItem})
+	setTransactionExpiration(t, s.db, staleTransaction, time.Now().Add(-time.Minute))
+	freshTransaction, _ := GetTestTransaction(s.db, s.virtualCard, []OwnedItem{})
+	setTransactionExpiration(t, s.db, freshTransaction, time.Now().Add(time.Minute))
+	finishedTransaction, _ := GetTestTransaction(s.db, s.virtualCard, []OwnedItem{})
+	finishedTransaction.State = TransactionStateFinished
+	setTransactionExpiration(t, s.db, finishedTransaction, time.Now().Add(-time.Minute))
+
+	expired, err := s.manager.ExpireTransactions()
+	require.Nilf(t, err, "TransactionManager.ExpireTransactions returned an error %w", err)
+	require.Equalf(t, int64(1), expired, "TransactionManager.ExpireTransactions expired %d transactions", expired)
+
+	for _, v := range []struct {
+		transaction *Transaction
+		state       TransactionStateEnum
+	}{
+		{staleTransaction, TransactionStateExpired},
+		{freshTransaction, TransactionStateStarted},
+		{finishedTransaction, TransactionStateFinished},
+	} {
+		var dbTransaction Transaction
+		tx := s.db.First(&dbTransaction, Transaction{Model: gorm.Model{ID: v.transaction.ID}})
+		require.Nilf(t, tx.GetError(), "database find for Transaction returned an error")
+		require.Equalf(t, v.state, dbTransaction.State, "transaction has state %s, expected %s",
+			dbTransaction.State, v.state)
+	}
+}
+
+func TestTransactionManagerFinalizeExpired(t *testing.T) {
+	s := setupTransactionTest(t)
+	transaction, _ := GetTestTransaction(s.db, s.virtualCard, []OwnedItem{*s.ownedItem})
+	setTransactionExpiration(t, s.db, transaction, time.Now().Add(-time.Minute))
+
+	_, err := s.manager.Finalize(transaction, []ItemWithAction{
+		{s.ownedItem, RedeemedActionType},
+	}, 10, nil)
+	require.ErrorIsf(t, err, ErrTransactionExpired, "TransactionManager.Finalize should return ErrTransactionExpired")
+
+	var dbTransaction Transaction
+	tx := s.db.First(&dbTransaction, Transaction{Model: gorm.Model{ID: transaction.ID}})
+	require.Nilf(t, tx.GetError(), "database find for Transaction returned an error")
+	require.Equalf(t, TransactionStateEnum(TransactionStateExpired), dbTransaction.State,
+		"transaction is not expired")
+
+	var dbVirtualCard VirtualCard
+	tx = s.db.First(&dbVirtualCard, VirtualCard{Model: gorm.Model{ID: s.virtualCard.ID}})
+	require.Nilf(t, tx.GetError(), "database find for VirtualCard returned an error")
+	require.Equalf(t, s.virtualCard.Points, dbVirtualCard.Points, "number of points on card should not change")
+
+	// Expired transactions stay expired
+	_, err = s.manager.Finalize(transaction, []ItemWithAction{
+		{s.ownedItem, RedeemedActionType},
+	}, 10, nil)
+	require.ErrorIsf(t, err, ErrTransactionExpired, "TransactionManager.Finalize should return ErrTransactionExpired")
+}
+
 //TODO transaction cancellation?
 
 func TestTransactionManagerFinalize(t *testing.T) {
@@ -125,7 +268,7 @@ func TestTransactionManagerFinalize(t *testing.T) {
 		{ownedItemToRedeem, RedeemedActionType},
 		{ownedItemToRecall, RecalledActionType},
 		{ownedItemToCancel, CancelledActionType},
-	}, 10)
+	}, 10, nil)
 	require.Nilf(t, err, "transaction finalize returned an error %w", err)
 	require.NotNilf(t, transaction, "transaction is nil")
 	require.Equalf(t, uint(10), transaction.AddedPoints, "transaction has a different number of added points. Expected: %d, got %d", 10, transaction.AddedPoints)
@@ -183,6 +326,26 @@ func TestTransactionManagerFinalize(t *testing.T) {
 		s.virtualCard.Points+s.itemDefinition.Price+10, dbVirtualCard.Points)
 }
 
+func TestTransactionManagerFinalizeAtLocation(t *testing.T) {
+	s := setupTransactionTest(t)
+	location := GetTestBusinessLocation(s.db, s.business, s.business.GPSCoordinates)
+	otherBusiness := GetTestBusiness(s.db, GetTestUser(s.db))
+	otherLocation := GetTestBusinessLocation(s.db, otherBusiness, otherBusiness.GPSCoordinates)
+
+	transaction, _ := GetTestTransaction(s.db, s.virtualCard, []OwnedItem{})
+	_, err := s.manager.Finalize(transaction, []ItemWithAction{}, 10, otherLocation)
+	require.Equalf(t, ErrInvalidLocationId, err, "TransactionManager.Finalize accepted location of another business")
+
+	transaction, err = s.manager.Finalize(transaction, []ItemWithAction{}, 10, location)
+	require.Nilf(t, err, "transaction finalize returned an error %w", err)
+
+	var dbTransaction Transaction
+	tx := s.db.First(&dbTransaction, Transaction{Model: gorm.Model{ID: transaction.ID}})
+	require.Nilf(t, tx.GetError(), "database find for Transaction returned an error")
+	require.NotNilf(t, dbTransaction.BusinessLocationId, "transaction should have a location")
+	require.Equalf(t, location.ID, *dbTransaction.BusinessLocationId, "transaction has unexpected location")
+}
+
 func TestTransactionManagerFinalizeWithItemsNotFromTransaction(t *testing.T) {
 	s := setupTransactionTest(t)
 	ownedItemToRedeem := GetTestOwnedItem(s.db, s.itemDefinition, s.virtualCard)
@@ -192,7 +355,7 @@ func TestTransactionManagerFinalizeWithItemsNotFromTransaction(t *testing.T) {
 
 	transaction, err := s.manager.Finalize(transaction, []ItemWithAction{
 		{ownedItemFromOutside, RedeemedActionType},
-	}, 10)
+	}, 10, nil)
 	require.Equalf(t, ErrInvalidItem, err, "TransactionManager.Finalize did not return InvalidItemError %w",
 		ErrInvalidItem)
 
@@ -228,8 +391,8 @@ func testTransactionManagerFinalizeWithChnagedItemStatusTemplate(t *testing.T, i
 	transaction, err := s.manager.Finalize(transaction, []ItemWithAction{
 		{ownedItemToRedeem, RedeemedActionType},
 		{ownedItemToRedeemNewStatus, RedeemedActionType},
-	}, 10)
-  
+	}, 10, nil)
+
 	//require.Nilf(t, transaction, "transaction finalize should not return a transaction")
 	require.ErrorIsf(t, err, ErrInvalidItem, "transaction should return a InvalidItem error")
 
@@ -264,7 +427,7 @@ func testTransactionManagerFinalizeFinishedTransactionTemplate(t *testing.T, sta
 
 	transaction, err := s.manager.Finalize(transaction, []ItemWithAction{
 		{ownedItemToRedeem, RedeemedActionType},
-	}, 10)
+	}, 10, nil)
 	require.ErrorAsf(t, err, ErrInvalidTransaction, "transaction finalize should return InvalidTransaction error")
 	require.Nilf(t, transaction, "transaction finalize should return a nil transaction")
 
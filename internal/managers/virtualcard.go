@@ -178,6 +178,13 @@ func (manager *VirtualCardManagerImpl) GetForUser(user *User, businessId string)
 		Preload("Business").
 		Preload("Business.ItemDefinitions").
 		Preload("Business.MenuImages").
+		Preload("Business.OpeningHours", "business_location_id IS NULL").
+		Preload("Business.OpeningHoursExceptions", "business_location_id IS NULL").
+		Preload("Business.Locations", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Preload("Business.Locations.OpeningHours").
+		Preload("Business.Locations.OpeningHoursExceptions").
 		Find(&virtualCard, &VirtualCard{BusinessId: business.ID,
 			OwnerId: user.ID})
 	err = result.GetError()
@@ -291,6 +298,11 @@ func (manager *VirtualCardManagerImpl) BuyItem(virtualCard *VirtualCard, itemDef
 			return fmt.Errorf("db.First(itemDefinition) returned an error %+v", err)
 		}
 
+		// Expires old points of the card first, they can't be spent anymore
+		if _, err := expirePointsLots(db, &virtualCard.ID); err != nil {
+			return err
+		}
+
 		// Checks if itemDefinition is valid
 		if itemDefinition.Withdrawn {
 			return ErrWithdrawnItem
@@ -315,7 +327,11 @@ func (manager *VirtualCardManagerImpl) BuyItem(virtualCard *VirtualCard, itemDef
 			VirtualCard:    virtualCard,
 		}
 
-		// Subtracts points from card
+		// Subtracts points from card. Points in virtualCard may be outdated after expiration
+		result = db.Model(&VirtualCard{}).Select("points").Where("id = ?", virtualCard.ID).Scan(&virtualCard.Points)
+		if err := result.GetError(); err != nil {
+			return fmt.Errorf("db.Select(points) returned an error %+v", err)
+		}
 		virtualCard.Points -= itemDefinition.Price
 		result = db.Save(virtualCard)
 		if err := result.GetError(); err != nil {
@@ -328,6 +344,19 @@ func (manager *VirtualCardManagerImpl) BuyItem(virtualCard *VirtualCard, itemDef
 			return fmt.Errorf("db.First(itemDefinition) returned an error %+v", err)
 		}
 
+		// Records the purchase in the ledger
+		err = recordPointsChange(db, virtualCard.ID, -int64(itemDefinition.Price), PointsLedgerReasonItemBought,
+			nil, &ownedItem.ID)
+		if err != nil {
+			return err
+		}
+
+		// Spends points from the oldest lots
+		err = spendPointsLots(db, virtualCard.ID, ownedItem.ID, itemDefinition.Price)
+		if err != nil {
+			return err
+		}
+
 		return nil
 	}, &sql.TxOptions{Isolation: sql.LevelSerializable})
 	if err != nil {
@@ -370,6 +399,14 @@ func (manager *VirtualCardManagerImpl) ReturnItem(ownedItem *OwnedItem) error {
 			return fmt.Errorf("db.Save(ownedItem.VirtualCard) returned an error %+v", err)
 		}
 
-		return nil
+		// Records the refund in the ledger
+		err := recordPointsChange(db, ownedItem.VirtualCardId, int64(ownedItem.ItemDefinition.Price),
+			PointsLedgerReasonItemReturned, nil, &ownedItem.ID)
+		if err != nil {
+			return err
+		}
+
+		// Gives the points back to lots they were spent from
+		return refundPointsLots(db, ownedItem.VirtualCardId, ownedItem.ID, ownedItem.ItemDefinition.Price)
 	}, &sql.TxOptions{Isolation: sql.LevelSerializable})
 }
@@ -14,8 +14,9 @@ import (
 // Middleware that checks if the requests contains a valid (not expired, not recalled) session token.
 // Session token is expected to be in the Authorization HTTP header. Expected HTTP authorization scheme is "Bearer".
 // Token is expected to be in the following format: {{.TokenId}}:{{.TokenSecret}}
-// If the token is valid, database.User object of the token owner is inserted into the context under "user" key and
-// the request is passed to the next handler.
+// If the token is valid, database.User object of the token owner is inserted into the context under "user" key,
+// the token is inserted under "token" key and the request is passed to the next handler. User agent and IP address
+// of the client are stored with the token.
 // If the token is not valid, the middleware returns 401 Unauthorized and the request is not passed to the next handler.
 type AuthMiddleware struct {
 	logger       *log.Logger
@@ -70,6 +71,17 @@ func (middleware *AuthMiddleware) Handle(c *gin.Context) {
 		return
 	}
 
+	// Update session metadata if the token is used by a different client
+	metadata := services.SessionMetadata{
+		UserAgent: c.Request.UserAgent(),
+		IpAddress: c.ClientIP(),
+	}
+	if token.UserAgent != metadata.UserAgent || token.IpAddress != metadata.IpAddress {
+		if _, err := middleware.tokenService.SetSessionMetadata(token, metadata); err != nil {
+			middleware.logger.Printf("Error: in AuthMiddleware.Handle, middleware.tokenService.SetSessionMetadata: %s", err)
+		}
+	}
+
 	c.Set("user", token.User)
 	c.Set("token", token)
 	c.Next()
@@ -79,6 +79,69 @@ func TestAuthMiddlewareHandleOk(t *testing.T) {
 	// TODO: test MatchEntities usage
 }
 
+// Test if AuthMiddleware stores user agent and ip address of the client with the token
+func TestAuthMiddlewareHandleSessionMetadata(t *testing.T) {
+	// data prep
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+
+	testTokenSecret := "ZWVnaDhhZWg4bGVpbDJhaXBlaW5nZWViNWFpU2hlaGUK"
+	testTokenId := "0123456789"
+	testToken := testTokenId + ":" + testTokenSecret
+	testUser := GetDefaultUser()
+	testTokenStruct := &Token{
+		OwnerId:      testUser.ID,
+		TokenId:      testTokenId,
+		TokenHash:    testToken,
+		Expires:      time.Now().Add(time.Hour * 24),
+		TokenPurpose: TokenPurposeSession,
+		Used:         true,
+		Recalled:     false,
+		User:         testUser,
+	}
+
+	context := NewTestContextBuilder(w).
+		SetDefaultUrl().
+		SetEndpoint("/user/cards").
+		SetMethod("GET").
+		SetHeader("Authorization", "Bearer "+testToken).
+		SetHeader("Accept", "application/json").
+		SetHeader("User-Agent", "test agent").
+		Context
+	context.Request.RemoteAddr = "192.0.2.1:1234"
+
+	// test env prep
+	ctrl := gomock.NewController(t)
+	authMiddleware := getAuthMiddleware(ctrl)
+
+	authMiddleware.tokenService.(*MockTokenService).
+		EXPECT().
+		Check(
+			gomock.Eq(testTokenId),
+			gomock.Eq(testTokenSecret),
+		).
+		Return(
+			testTokenStruct,
+			nil,
+		)
+
+	authMiddleware.tokenService.(*MockTokenService).
+		EXPECT().
+		SetSessionMetadata(
+			gomock.Eq(testTokenStruct),
+			gomock.Eq(services.SessionMetadata{UserAgent: "test agent", IpAddress: "192.0.2.1"}),
+		).
+		Return(
+			testTokenStruct,
+			nil,
+		)
+
+	authMiddleware.Handle(context)
+
+	_, ok := context.Get("token")
+	require.Truef(t, ok, "Context holds no token")
+}
+
 func TestAuthMiddlewareHandleNok_EmailToken(t *testing.T) {
 	// data prep
 	gin.SetMode(gin.TestMode)
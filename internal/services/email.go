@@ -1,8 +1,16 @@
 package services
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	mail "github.com/wneessen/go-mail"
 
@@ -10,52 +18,104 @@ import (
 	. "github.com/StampWallet/backend/internal/utils"
 )
 
+// Returned by EmailService.Send when the email can't be delivered and retrying won't help,
+// for example because the recipient address is invalid.
+var ErrEmailRejected = errors.New("email rejected")
+
+// Returned by CreateEmailServiceImpl when SMTPConfig has an unknown TLS mode or auth mechanism
+var ErrInvalidSMTPConfig = errors.New("invalid SMTP config")
+
+// Email ready to be sent, see EmailTemplates
+type EmailMessage struct {
+	Subject  string
+	HTMLBody string
+	TextBody string // plaintext alternative of HTMLBody, not sent if empty
+}
+
 // An EmailService is a service for sending emails.
 // It's only a thin wrapper over github.com/wneessen/go-mail
 // Configuration options are documented in config.SMTPConfig.
 type EmailService interface {
-	Send(email string, subject string, body string) error
+	Send(email string, message EmailMessage) error
 }
 
 type EmailServiceImpl struct {
-	mailClient mail.Client
+	mailClient *mail.Client
 	smtpConfig SMTPConfig
 	logger     *log.Logger
+
+	// state of the kept alive connection, see SMTPConfig.KeepAlive
+	mutex     sync.Mutex
+	connected bool
+	lastUsed  time.Time
+	idleTimer *time.Timer
 }
 
 func CreateEmailServiceImpl(smtpConfig SMTPConfig, logger *log.Logger) (*EmailServiceImpl, error) {
-	client, err := mail.NewClient(
-		smtpConfig.ServerHostname,
-		//mail.WithLogger(*logger),
-
-		// authorization
-		mail.WithSMTPAuth(mail.SMTPAuthLogin),
-		mail.WithPassword(smtpConfig.Password),
-		mail.WithUsername(smtpConfig.Username),
-
-		// connection options - ssl is currently forced
+	options := []mail.Option{
 		mail.WithPort(int(smtpConfig.ServerPort)),
-		mail.WithSSL(),
+		// connection liveness is checked with RSET before reusing it, see Send
+		mail.WithoutNoop(),
+	}
+	if smtpConfig.Timeout > 0 {
+		options = append(options, mail.WithTimeout(smtpConfig.Timeout))
+	}
 
-		// not 100% sure if this is necessary
-		mail.WithoutNoop())
+	// empty values keep the behavior from before these options existed
+	switch strings.ToLower(smtpConfig.TLSMode) {
+	case "", "implicit":
+		options = append(options, mail.WithSSL())
+	case "starttls":
+		options = append(options, mail.WithTLSPolicy(mail.TLSMandatory))
+	case "none":
+		options = append(options, mail.WithTLSPolicy(mail.NoTLS))
+	default:
+		return nil, fmt.Errorf("%w: unknown TLS mode %s", ErrInvalidSMTPConfig, smtpConfig.TLSMode)
+	}
+	if smtpConfig.TLSSkipVerify {
+		options = append(options, mail.WithTLSConfig(&tls.Config{
+			ServerName:         smtpConfig.ServerHostname,
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: true,
+		}))
+	}
 
+	switch strings.ToLower(smtpConfig.AuthMechanism) {
+	case "", "login":
+		options = append(options, mail.WithSMTPAuth(mail.SMTPAuthLogin))
+	case "plain":
+		options = append(options, mail.WithSMTPAuth(mail.SMTPAuthPlain))
+	case "cram-md5":
+		options = append(options, mail.WithSMTPAuth(mail.SMTPAuthCramMD5))
+	case "none":
+	default:
+		return nil, fmt.Errorf("%w: unknown auth mechanism %s", ErrInvalidSMTPConfig, smtpConfig.AuthMechanism)
+	}
+	if strings.ToLower(smtpConfig.AuthMechanism) != "none" {
+		options = append(options,
+			mail.WithUsername(smtpConfig.Username),
+			mail.WithPassword(smtpConfig.Password))
+	}
+
+	client, err := mail.NewClient(smtpConfig.ServerHostname, options...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &EmailServiceImpl{
-		mailClient: *client,
+		mailClient: client,
 		smtpConfig: smtpConfig,
 		logger:     logger,
 	}, nil
 }
 
-// Sends an email message to address email, with subject and body from args.
-// body is currently assumed to be valid html - watch out for injections.
-// It's recommended to use html templates to create emails,
+// Sends message to address email.
+// message.HTMLBody is assumed to be valid html - watch out for injections.
+// It's recommended to use EmailTemplates to create emails,
 // but this struct is not responsible for this.
-func (service *EmailServiceImpl) Send(email string, subject string, body string) error {
+// If message.TextBody is set, the email has both parts and clients pick the one they can display.
+// With SMTPConfig.KeepAlive the connection is reused by emails sent before it runs out.
+func (service *EmailServiceImpl) Send(email string, message EmailMessage) error {
 	msg := mail.NewMsg(
 		// URL encode - should make this email readable on text only clients
 		// (but that was not tested yet)
@@ -65,17 +125,130 @@ func (service *EmailServiceImpl) Send(email string, subject string, body string)
 	)
 
 	// set up message content
-	msg.Subject(subject)
+	msg.Subject(message.Subject)
 	msg.From(service.smtpConfig.SenderEmail)
 	err := msg.AddTo(email)
 	if err != nil {
-		return fmt.Errorf("%s failed to add recipient: %+v", CallerFilename(), err)
+		return fmt.Errorf("%w: %s failed to add recipient: %+v", ErrEmailRejected, CallerFilename(), err)
+	}
+	// The last alternative is preferred by clients
+	if message.TextBody != "" {
+		msg.SetBodyString(mail.TypeTextPlain, message.TextBody)
+		msg.AddAlternativeString(mail.TypeTextHTML, message.HTMLBody)
+	} else {
+		msg.SetBodyString(mail.TypeTextHTML, message.HTMLBody)
 	}
-	msg.SetBodyString(mail.TypeTextHTML, body)
 
-	err = service.mailClient.DialAndSend(msg)
-	if err != nil {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if service.smtpConfig.KeepAlive > 0 {
+		err = service.sendKeepAlive(msg)
+	} else {
+		err = service.mailClient.DialAndSend(msg)
+	}
+
+	var sendErr *mail.SendError
+	if errors.As(err, &sendErr) && !sendErr.IsTemp() {
+		return fmt.Errorf("%w: %s failed to send email: %+v", ErrEmailRejected, CallerFilename(), err)
+	} else if err != nil {
 		return fmt.Errorf("%s failed to send email: %+v", CallerFilename(), err)
 	}
 	return nil
 }
+
+// Sends msg over the kept alive connection, connecting first if there is none or it was dropped by the server.
+// Must be called with service.mutex locked.
+func (service *EmailServiceImpl) sendKeepAlive(msg *mail.Msg) error {
+	if service.connected && service.mailClient.Reset() != nil {
+		// the server closed the connection or it timed out, QUIT will most likely fail too
+		service.mailClient.Close()
+		service.connected = false
+	}
+	if !service.connected {
+		if err := service.mailClient.DialWithContext(context.Background()); err != nil {
+			return err
+		}
+		service.connected = true
+	}
+
+	err := service.mailClient.Send(msg)
+	var sendErr *mail.SendError
+	if err != nil && !(errors.As(err, &sendErr) && sendErr.Reason == mail.ErrSMTPRcptTo) {
+		// the connection can be in any state after failed DATA, don't reuse it
+		service.mailClient.Close()
+		service.connected = false
+		return err
+	}
+
+	service.lastUsed = time.Now()
+	if service.idleTimer == nil {
+		service.idleTimer = time.AfterFunc(service.smtpConfig.KeepAlive, service.closeIdle)
+	} else {
+		service.idleTimer.Reset(service.smtpConfig.KeepAlive)
+	}
+	return err
+}
+
+// Closes the connection when it was not used for SMTPConfig.KeepAlive
+func (service *EmailServiceImpl) closeIdle() {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if !service.connected || time.Since(service.lastUsed) < service.smtpConfig.KeepAlive {
+		return
+	}
+	if err := service.mailClient.Close(); err != nil {
+		service.logger.Printf("%s failed to close idle SMTP connection: %+v", CallerFilename(), err)
+	}
+	service.connected = false
+}
+
+// Closes the kept alive connection, if there is one
+func (service *EmailServiceImpl) Close() error {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if service.idleTimer != nil {
+		service.idleTimer.Stop()
+	}
+	if !service.connected {
+		return nil
+	}
+	service.connected = false
+	if err := service.mailClient.Close(); err != nil {
+		return fmt.Errorf("%s failed to close SMTP connection: %+v", CallerFilename(), err)
+	}
+	return nil
+}
+
+// EmailService that writes emails to a file instead of sending them.
+// Meant for development and tests, see config.Config.EmailBackend.
+type FileEmailServiceImpl struct {
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+// Emails are appended to the file at path. If path is empty or "-", they are written to stdout.
+func CreateFileEmailServiceImpl(path string) (*FileEmailServiceImpl, error) {
+	if path == "" || path == "-" {
+		return &FileEmailServiceImpl{writer: os.Stdout}, nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to open email file: %+v", CallerFilename(), err)
+	}
+	return &FileEmailServiceImpl{writer: file}, nil
+}
+
+// Writes both parts of message, plaintext first
+func (service *FileEmailServiceImpl) Send(email string, message EmailMessage) error {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	body := message.HTMLBody
+	if message.TextBody != "" {
+		body = message.TextBody + "\n\n" + body
+	}
+	_, err := fmt.Fprintf(service.writer, "To: %s\nSubject: %s\n\n%s\n\n", email, message.Subject, body)
+	if err != nil {
+		return fmt.Errorf("%s failed to write email: %+v", CallerFilename(), err)
+	}
+	return nil
+}
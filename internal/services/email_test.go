@@ -0,0 +1,440 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"math/big"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/StampWallet/backend/internal/config"
+)
+
+func TestFileEmailServiceSend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emails.txt")
+	service, err := CreateFileEmailServiceImpl(path)
+	require.Nilf(t, err, "CreateFileEmailServiceImpl should return nil error")
+
+	err = service.Send("first@example.com", EmailMessage{Subject: "first", HTMLBody: "first body"})
+	require.Nilf(t, err, "Send should return nil error")
+	err = service.Send("second@example.com", EmailMessage{Subject: "second", HTMLBody: "<p>second</p>", TextBody: "second"})
+	require.Nilf(t, err, "Send should return nil error")
+
+	content, err := os.ReadFile(path)
+	require.Nilf(t, err, "failed to read emails file")
+	require.Equalf(t,
+		"To: first@example.com\nSubject: first\n\nfirst body\n\n"+
+			"To: second@example.com\nSubject: second\n\nsecond\n\n<p>second</p>\n\n",
+		string(content), "FileEmailServiceImpl should append emails to the file")
+}
+
+// Email received by fakeSMTPServer
+type fakeSMTPMessage struct {
+	from          string
+	to            []string
+	data          string
+	tls           bool
+	authMechanism string
+}
+
+// Minimal in-process stand-in for an SMTP server.
+// RCPT of addresses starting with "rejected" fails permanently, "busy" fails temporarily.
+// If username is empty, clients don't have to authenticate.
+type fakeSMTPServer struct {
+	listener    net.Listener
+	tlsConfig   *tls.Config // enables STARTTLS
+	implicitTLS bool        // TLS from the start of the connection, requires tlsConfig
+	username    string
+	password    string
+
+	mutex       sync.Mutex
+	messages    []fakeSMTPMessage
+	connections int
+	active      []net.Conn
+}
+
+func startFakeSMTPServer(t *testing.T, server *fakeSMTPServer) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nilf(t, err, "failed to listen")
+	server.listener = listener
+	t.Cleanup(func() {
+		listener.Close()
+		server.dropConnections()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mutex.Lock()
+			server.connections += 1
+			server.active = append(server.active, conn)
+			server.mutex.Unlock()
+			go server.handle(conn)
+		}
+	}()
+	return server
+}
+
+func (server *fakeSMTPServer) smtpConfig(tlsMode string, authMechanism string) SMTPConfig {
+	return SMTPConfig{
+		ServerHostname: "127.0.0.1",
+		ServerPort:     uint16(server.listener.Addr().(*net.TCPAddr).Port),
+		TLSMode:        tlsMode,
+		TLSSkipVerify:  true,
+		AuthMechanism:  authMechanism,
+		Username:       "user",
+		Password:       "password",
+		SenderEmail:    "sender@example.com",
+		Timeout:        5 * time.Second,
+	}
+}
+
+func (server *fakeSMTPServer) getMessages() []fakeSMTPMessage {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]fakeSMTPMessage{}, server.messages...)
+}
+
+func (server *fakeSMTPServer) getConnections() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.connections
+}
+
+// Closes open connections, like a server that times out idle clients
+func (server *fakeSMTPServer) dropConnections() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	for _, conn := range server.active {
+		conn.Close()
+	}
+	server.active = nil
+}
+
+func (server *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	isTLS := false
+	if server.implicitTLS {
+		conn = tls.Server(conn, server.tlsConfig)
+		isTLS = true
+	}
+	text := textproto.NewConn(conn)
+	authMechanism := ""
+	var message *fakeSMTPMessage
+
+	reply := func(lines ...string) {
+		for i, line := range lines {
+			if i < len(lines)-1 {
+				text.PrintfLine("%s-%s", line[:3], line[4:])
+			} else {
+				text.PrintfLine("%s", line)
+			}
+		}
+	}
+	readBase64 := func() string {
+		line, _ := text.ReadLine()
+		decoded, _ := base64.StdEncoding.DecodeString(line)
+		return string(decoded)
+	}
+
+	reply("220 127.0.0.1 ESMTP fake")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, argument, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			lines := []string{"250 127.0.0.1", "250 8BITMIME", "250 AUTH PLAIN LOGIN CRAM-MD5"}
+			if server.tlsConfig != nil && !isTLS {
+				lines = append(lines, "250 STARTTLS")
+			}
+			reply(append(lines, "250 OK")...)
+		case "STARTTLS":
+			reply("220 Ready to start TLS")
+			conn = tls.Server(conn, server.tlsConfig)
+			text = textproto.NewConn(conn)
+			isTLS = true
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(argument, " ")
+			var username, password string
+			switch strings.ToUpper(mechanism) {
+			case "PLAIN":
+				decoded, _ := base64.StdEncoding.DecodeString(initial)
+				parts := strings.Split(string(decoded), "\x00")
+				if len(parts) == 3 {
+					username, password = parts[1], parts[2]
+				}
+			case "LOGIN":
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+				username = readBase64()
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+				password = readBase64()
+			case "CRAM-MD5":
+				challenge := "<1234.5678@127.0.0.1>"
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte(challenge)))
+				username, password, _ = strings.Cut(readBase64(), " ")
+				mac := hmac.New(md5.New, []byte(server.password))
+				mac.Write([]byte(challenge))
+				if password == hex.EncodeToString(mac.Sum(nil)) {
+					password = server.password
+				}
+			}
+			if username != server.username || password != server.password {
+				reply("535 Authentication failed")
+				continue
+			}
+			authMechanism = strings.ToUpper(mechanism)
+			reply("235 Authentication successful")
+		case "MAIL":
+			if server.username != "" && authMechanism == "" {
+				reply("530 Authentication required")
+				continue
+			}
+			from, _, _ := strings.Cut(strings.TrimPrefix(argument, "FROM:"), " ")
+			message = &fakeSMTPMessage{from: strings.Trim(from, "<>"), tls: isTLS, authMechanism: authMechanism}
+			reply("250 OK")
+		case "RCPT":
+			to := strings.Trim(strings.TrimPrefix(argument, "TO:"), "<>")
+			if message == nil {
+				reply("503 Bad sequence of commands")
+			} else if strings.HasPrefix(to, "rejected") {
+				reply("550 No such user")
+			} else if strings.HasPrefix(to, "busy") {
+				reply("450 Mailbox busy")
+			} else {
+				message.to = append(message.to, to)
+				reply("250 OK")
+			}
+		case "DATA":
+			if message == nil || len(message.to) == 0 {
+				reply("503 Bad sequence of commands")
+				continue
+			}
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			lines, err := text.ReadDotLines()
+			if err != nil {
+				return
+			}
+			message.data = strings.Join(lines, "\n")
+			server.mutex.Lock()
+			server.messages = append(server.messages, *message)
+			server.mutex.Unlock()
+			message = nil
+			reply("250 OK")
+		case "RSET":
+			message = nil
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// Self-signed certificate of 127.0.0.1
+func getTestTLSConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nilf(t, err, "failed to generate key")
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.Nilf(t, err, "failed to create certificate")
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+func sendTestEmail(service *EmailServiceImpl, email string) error {
+	return service.Send(email, EmailMessage{Subject: "Test subject", HTMLBody: "<p>test</p>", TextBody: "test"})
+}
+
+func TestEmailServiceAuthMechanisms(t *testing.T) {
+	for _, mechanism := range []string{"plain", "login", "cram-md5"} {
+		t.Run(mechanism, func(t *testing.T) {
+			server := startFakeSMTPServer(t, &fakeSMTPServer{username: "user", password: "password"})
+			service, err := CreateEmailServiceImpl(server.smtpConfig("none", mechanism), log.Default())
+			require.Nilf(t, err, "CreateEmailServiceImpl should return nil error")
+
+			err = sendTestEmail(service, "recipient@example.com")
+			require.Nilf(t, err, "Send should return nil error")
+
+			messages := server.getMessages()
+			require.Lenf(t, messages, 1, "server should receive one email")
+			require.Equalf(t, strings.ToUpper(mechanism), messages[0].authMechanism, "client should use configured auth mechanism")
+			require.Equalf(t, "sender@example.com", messages[0].from, "email should be sent from SenderEmail")
+			require.Equalf(t, []string{"recipient@example.com"}, messages[0].to, "email should be sent to recipient")
+			require.Containsf(t, messages[0].data, "Subject: Test subject", "email should have subject")
+			require.Containsf(t, messages[0].data, "<p>test</p>", "email should have html body")
+		})
+	}
+}
+
+func TestEmailServiceWrongPassword(t *testing.T) {
+	server := startFakeSMTPServer(t, &fakeSMTPServer{username: "user", password: "other password"})
+	service, err := CreateEmailServiceImpl(server.smtpConfig("none", "plain"), log.Default())
+	require.Nilf(t, err, "CreateEmailServiceImpl should return nil error")
+
+	err = sendTestEmail(service, "recipient@example.com")
+	require.NotNilf(t, err, "Send should return an error")
+	require.Falsef(t, errors.Is(err, ErrEmailRejected), "failed auth should be retried, not rejected")
+	require.Lenf(t, server.getMessages(), 0, "server should not receive emails")
+}
+
+func TestEmailServiceNoAuth(t *testing.T) {
+	server := startFakeSMTPServer(t, &fakeSMTPServer{})
+	service, err := CreateEmailServiceImpl(server.smtpConfig("none", "none"), log.Default())
+	require.Nilf(t, err, "CreateEmailServiceImpl should return nil error")
+
+	err = sendTestEmail(service, "recipient@example.com")
+	require.Nilf(t, err, "Send should return nil error")
+	messages := server.getMessages()
+	require.Lenf(t, messages, 1, "server should receive one email")
+	require.Equalf(t, "", messages[0].authMechanism, "client should not authenticate")
+	require.Falsef(t, messages[0].tls, "client should not use TLS")
+}
+
+func TestEmailServiceTLSModes(t *testing.T) {
+	tlsConfig := getTestTLSConfig(t)
+	for _, tlsMode := range []string{"starttls", "implicit"} {
+		t.Run(tlsMode, func(t *testing.T) {
+			server := startFakeSMTPServer(t, &fakeSMTPServer{
+				tlsConfig:   tlsConfig,
+				implicitTLS: tlsMode == "implicit",
+				username:    "user",
+				password:    "password",
+			})
+			service, err := CreateEmailServiceImpl(server.smtpConfig(tlsMode, "login"), log.Default())
+			require.Nilf(t, err, "CreateEmailServiceImpl should return nil error")
+
+			err = sendTestEmail(service, "recipient@example.com")
+			require.Nilf(t, err, "Send should return nil error")
+			messages := server.getMessages()
+			require.Lenf(t, messages, 1, "server should receive one email")
+			require.Truef(t, messages[0].tls, "email should be sent over TLS")
+		})
+	}
+}
+
+func TestEmailServiceStartTLSUnsupported(t *testing.T) {
+	server := startFakeSMTPServer(t, &fakeSMTPServer{})
+	service, err := CreateEmailServiceImpl(server.smtpConfig("starttls", "none"), log.Default())
+	require.Nilf(t, err, "CreateEmailServiceImpl should return nil error")
+
+	err = sendTestEmail(service, "recipient@example.com")
+	require.NotNilf(t, err, "Send should not fall back to plaintext")
+	require.Lenf(t, server.getMessages(), 0, "server should not receive emails")
+}
+
+func TestEmailServiceRejected(t *testing.T) {
+	server := startFakeSMTPServer(t, &fakeSMTPServer{})
+	service, err := CreateEmailServiceImpl(server.smtpConfig("none", "none"), log.Default())
+	require.Nilf(t, err, "CreateEmailServiceImpl should return nil error")
+
+	err = sendTestEmail(service, "rejected@example.com")
+	require.Truef(t, errors.Is(err, ErrEmailRejected), "Send should return ErrEmailRejected for 5xx replies")
+	err = sendTestEmail(service, "busy@example.com")
+	require.NotNilf(t, err, "Send should return an error for 4xx replies")
+	require.Falsef(t, errors.Is(err, ErrEmailRejected), "4xx replies should be retried, not rejected")
+	require.Lenf(t, server.getMessages(), 0, "server should not receive emails")
+}
+
+func TestEmailServiceKeepAlive(t *testing.T) {
+	server := startFakeSMTPServer(t, &fakeSMTPServer{})
+	config := server.smtpConfig("none", "none")
+	config.KeepAlive = time.Minute
+	service, err := CreateEmailServiceImpl(config, log.Default())
+	require.Nilf(t, err, "CreateEmailServiceImpl should return nil error")
+	defer service.Close()
+
+	for i := 0; i < 3; i++ {
+		err = sendTestEmail(service, "recipient@example.com")
+		require.Nilf(t, err, "Send should return nil error")
+	}
+	err = sendTestEmail(service, "rejected@example.com")
+	require.Truef(t, errors.Is(err, ErrEmailRejected), "Send should return ErrEmailRejected")
+	err = sendTestEmail(service, "recipient@example.com")
+	require.Nilf(t, err, "Send should return nil error")
+	require.Lenf(t, server.getMessages(), 4, "server should receive every accepted email")
+	require.Equalf(t, 1, server.getConnections(), "emails should be sent over one connection")
+
+	server.dropConnections()
+	err = sendTestEmail(service, "recipient@example.com")
+	require.Nilf(t, err, "Send should reconnect after the connection was dropped")
+	require.Lenf(t, server.getMessages(), 5, "server should receive the email sent after reconnecting")
+	require.Equalf(t, 2, server.getConnections(), "Send should reconnect once")
+}
+
+func TestEmailServiceKeepAliveIdle(t *testing.T) {
+	server := startFakeSMTPServer(t, &fakeSMTPServer{})
+	config := server.smtpConfig("none", "none")
+	config.KeepAlive = 50 * time.Millisecond
+	service, err := CreateEmailServiceImpl(config, log.Default())
+	require.Nilf(t, err, "CreateEmailServiceImpl should return nil error")
+	defer service.Close()
+
+	err = sendTestEmail(service, "recipient@example.com")
+	require.Nilf(t, err, "Send should return nil error")
+	require.Eventuallyf(t, func() bool {
+		service.mutex.Lock()
+		defer service.mutex.Unlock()
+		return !service.connected
+	}, time.Second, 10*time.Millisecond, "idle connection should be closed")
+
+	err = sendTestEmail(service, "recipient@example.com")
+	require.Nilf(t, err, "Send should return nil error")
+	require.Equalf(t, 2, server.getConnections(), "Send should reconnect after idle connection was closed")
+}
+
+func TestEmailServiceWithoutKeepAlive(t *testing.T) {
+	server := startFakeSMTPServer(t, &fakeSMTPServer{})
+	service, err := CreateEmailServiceImpl(server.smtpConfig("none", "none"), log.Default())
+	require.Nilf(t, err, "CreateEmailServiceImpl should return nil error")
+
+	for i := 0; i < 3; i++ {
+		err = sendTestEmail(service, "recipient@example.com")
+		require.Nilf(t, err, "Send should return nil error")
+	}
+	require.Lenf(t, server.getMessages(), 3, "server should receive every email")
+	require.Equalf(t, 3, server.getConnections(), "every email should be sent over a new connection")
+}
+
+func TestCreateEmailServiceInvalidConfig(t *testing.T) {
+	config := GetDefaultConfig().SmtpConfig
+	config.TLSMode = "ssl"
+	_, err := CreateEmailServiceImpl(config, log.Default())
+	require.Truef(t, errors.Is(err, ErrInvalidSMTPConfig), "CreateEmailServiceImpl should return ErrInvalidSMTPConfig for unknown TLS mode")
+
+	config = GetDefaultConfig().SmtpConfig
+	config.AuthMechanism = "xoauth2"
+	_, err = CreateEmailServiceImpl(config, log.Default())
+	require.Truef(t, errors.Is(err, ErrInvalidSMTPConfig), "CreateEmailServiceImpl should return ErrInvalidSMTPConfig for unknown auth mechanism")
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/utils"
+)
+
+var ErrOutboxDatabaseMismatch = errors.New("email outbox is using a different database from tx")
+
+// An EmailOutbox queues emails in the database, instead of sending them right away.
+// Queued emails are delivered by managers.EmailOutboxManager, so an email is only sent
+// if the transaction that queued it was committed, and delivery failures are retried.
+type EmailOutbox interface {
+	// Queues message to address email. Arguments are the same as in EmailService.Send.
+	Enqueue(email string, message EmailMessage) error
+
+	// Returns EmailOutbox that will queue emails within transaction tx.
+	// Unlike TokenService.WithTransaction, returns ErrOutboxDatabaseMismatch if tx is using
+	// a different database - the email would be sent even if tx was rolled back.
+	WithTransaction(tx GormDB) (EmailOutbox, error)
+}
+
+type EmailOutboxImpl struct {
+	baseServices BaseServices
+}
+
+func CreateEmailOutboxImpl(baseServices BaseServices) *EmailOutboxImpl {
+	return &EmailOutboxImpl{
+		baseServices: baseServices,
+	}
+}
+
+func (outbox *EmailOutboxImpl) Enqueue(email string, message EmailMessage) error {
+	entry := EmailOutboxEntry{
+		Recipient:     email,
+		Subject:       message.Subject,
+		Body:          message.HTMLBody,
+		TextBody:      message.TextBody,
+		State:         EmailOutboxStatePending,
+		NextAttemptAt: time.Now(),
+	}
+	tx := outbox.baseServices.Database.Create(&entry)
+	if err := tx.GetError(); err != nil {
+		return fmt.Errorf("%s database failed to create outbox entry: %+v", CallerFilename(), err)
+	}
+	return nil
+}
+
+func (outbox *EmailOutboxImpl) WithTransaction(tx GormDB) (EmailOutbox, error) {
+	txDb, err := tx.DB()
+	if err != nil {
+		return nil, err
+	}
+	currDb, err := outbox.baseServices.Database.DB()
+	if err != nil {
+		return nil, err
+	}
+	if txDb != currDb {
+		return nil, ErrOutboxDatabaseMismatch
+	}
+	return &EmailOutboxImpl{
+		baseServices: outbox.baseServices.WithTransaction(tx),
+	}, nil
+}
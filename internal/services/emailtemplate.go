@@ -0,0 +1,220 @@
+package services
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+)
+
+var ErrMissingEmailTemplate = errors.New("missing email template")
+var ErrUnknownEmailKind = errors.New("unknown email kind")
+
+type EmailKindEnum string
+
+const (
+	EmailKindVerification       EmailKindEnum = "verification"        // receives .Token
+	EmailKindPasswordReset                    = "password_reset"      // receives .Token
+	EmailKindLockout                          = "lockout"             // receives .Until - end of the lockout
+	EmailKindBusinessInvitation               = "business_invitation" // receives .BusinessName and .Role
+	EmailKindEmailChange                      = "email_change"        // receives .Token, sent to the new email
+	EmailKindEmailChangeNotice                = "email_change_notice" // receives .Token and .NewEmail, sent to the old email
+)
+
+var emailKinds = []EmailKindEnum{
+	EmailKindVerification, EmailKindPasswordReset, EmailKindLockout, EmailKindBusinessInvitation,
+	EmailKindEmailChange, EmailKindEmailChangeNotice,
+}
+
+// Templates shipped with the binary, used when config.Config.EmailTemplatePath is empty.
+//
+//go:embed emailtemplates
+var embeddedEmailTemplates embed.FS
+
+// Matches BCP 47 language tags, like "en" or "pl-PL"
+var localeRegexp = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// Returns locale in the form used by EmailTemplates, ex. "pl_pl" -> "pl-PL".
+// Returns false if locale is not a valid language tag.
+func NormalizeLocale(locale string) (string, bool) {
+	locale = strings.ReplaceAll(locale, "_", "-")
+	if !localeRegexp.MatchString(locale) {
+		return "", false
+	}
+	parts := strings.Split(locale, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i != len(parts); i++ {
+		if len(parts[i]) == 2 {
+			parts[i] = strings.ToUpper(parts[i])
+		}
+	}
+	return strings.Join(parts, "-"), true
+}
+
+// An EmailTemplates renders emails of every EmailKindEnum in languages it has templates for.
+type EmailTemplates interface {
+	// Renders email of kind in locale, with data passed to the templates. If there are no templates
+	// for locale, falls back to its base language ("pl-PL" -> "pl"), then to the default locale.
+	Render(kind EmailKindEnum, locale string, data any) (*EmailMessage, error)
+}
+
+// Templates of a single email kind in a single locale
+type emailTemplate struct {
+	subject *texttemplate.Template
+	html    *htmltemplate.Template
+	text    *texttemplate.Template // nil if the email has no plaintext alternative
+}
+
+// EmailTemplates loaded from a directory. Every locale is a subdirectory, with these files for
+// every email kind:
+//   - <kind>.subject.txt - subject, required
+//   - <kind>.html - HTML body, required
+//   - <kind>.txt - plaintext alternative of the HTML body, optional
+//
+// Templates of the default locale are required for every kind, other locales may be incomplete.
+// Templates can call {{ backendURL }} to get the public URL of this server.
+type EmailTemplateRegistry struct {
+	defaultLocale string
+	templates     map[string]map[EmailKindEnum]*emailTemplate // locale -> kind -> templates
+}
+
+// Loads templates from fsys, see EmailTemplateRegistry
+func LoadEmailTemplateRegistry(fsys fs.FS, defaultLocale string, backendURL string) (*EmailTemplateRegistry, error) {
+	normalizedDefault, ok := NormalizeLocale(defaultLocale)
+	if !ok {
+		return nil, fmt.Errorf("invalid default locale %s", defaultLocale)
+	}
+	funcs := map[string]any{
+		"backendURL": func() string { return backendURL },
+	}
+
+	registry := &EmailTemplateRegistry{
+		defaultLocale: normalizedDefault,
+		templates:     map[string]map[EmailKindEnum]*emailTemplate{},
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("fs.ReadDir returned an error: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		locale, ok := NormalizeLocale(entry.Name())
+		if !ok {
+			return nil, fmt.Errorf("invalid email template locale %s", entry.Name())
+		}
+		registry.templates[locale] = map[EmailKindEnum]*emailTemplate{}
+		for _, kind := range emailKinds {
+			tmpl, err := loadEmailTemplate(fsys, path.Join(entry.Name(), string(kind)), funcs)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			registry.templates[locale][kind] = tmpl
+		}
+	}
+
+	for _, kind := range emailKinds {
+		if registry.templates[normalizedDefault][kind] == nil {
+			return nil, fmt.Errorf("%w: %s of default locale %s", ErrMissingEmailTemplate, kind, normalizedDefault)
+		}
+	}
+	return registry, nil
+}
+
+// Loads templates from the directory at dirPath, or embedded templates if dirPath is empty
+func CreateEmailTemplateRegistry(dirPath string, defaultLocale string, backendURL string) (*EmailTemplateRegistry, error) {
+	var fsys fs.FS
+	if dirPath == "" {
+		sub, err := fs.Sub(embeddedEmailTemplates, "emailtemplates")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	} else {
+		fsys = os.DirFS(dirPath)
+	}
+	return LoadEmailTemplateRegistry(fsys, defaultLocale, backendURL)
+}
+
+// Loads templates of a single kind, prefix is the path without extensions.
+// Returns fs.ErrNotExist if there is no subject or HTML template.
+func loadEmailTemplate(fsys fs.FS, prefix string, funcs map[string]any) (*emailTemplate, error) {
+	subject, err := fs.ReadFile(fsys, prefix+".subject.txt")
+	if err != nil {
+		return nil, err
+	}
+	html, err := fs.ReadFile(fsys, prefix+".html")
+	if err != nil {
+		return nil, err
+	}
+	text, err := fs.ReadFile(fsys, prefix+".txt")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	tmpl := &emailTemplate{}
+	tmpl.subject, err = texttemplate.New(prefix + ".subject.txt").Funcs(funcs).
+		Parse(strings.TrimSpace(string(subject)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email template: %w", err)
+	}
+	tmpl.html, err = htmltemplate.New(prefix + ".html").Funcs(funcs).Parse(string(html))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email template: %w", err)
+	}
+	if text != nil {
+		tmpl.text, err = texttemplate.New(prefix + ".txt").Funcs(funcs).Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse email template: %w", err)
+		}
+	}
+	return tmpl, nil
+}
+
+// Returns templates of kind in locale, or in the locale it falls back to
+func (registry *EmailTemplateRegistry) find(kind EmailKindEnum, locale string) *emailTemplate {
+	if locale, ok := NormalizeLocale(locale); ok {
+		if tmpl := registry.templates[locale][kind]; tmpl != nil {
+			return tmpl
+		}
+		language, _, _ := strings.Cut(locale, "-")
+		if tmpl := registry.templates[language][kind]; tmpl != nil {
+			return tmpl
+		}
+	}
+	return registry.templates[registry.defaultLocale][kind]
+}
+
+func (registry *EmailTemplateRegistry) Render(kind EmailKindEnum, locale string, data any) (*EmailMessage, error) {
+	tmpl := registry.find(kind, locale)
+	if tmpl == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEmailKind, kind)
+	}
+
+	var subject, html, text strings.Builder
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return nil, fmt.Errorf("failed to render email subject: %w", err)
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("failed to render email body: %w", err)
+	}
+	if tmpl.text != nil {
+		if err := tmpl.text.Execute(&text, data); err != nil {
+			return nil, fmt.Errorf("failed to render email text body: %w", err)
+		}
+	}
+	return &EmailMessage{
+		Subject:  subject.String(),
+		HTMLBody: html.String(),
+		TextBody: text.String(),
+	}, nil
+}
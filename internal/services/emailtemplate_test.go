@@ -0,0 +1,106 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	for input, expected := range map[string]string{
+		"en":         "en",
+		"PL":         "pl",
+		"pl_pl":      "pl-PL",
+		"zh-Hant-TW": "zh-Hant-TW",
+	} {
+		locale, ok := NormalizeLocale(input)
+		require.Truef(t, ok, "NormalizeLocale should accept %s", input)
+		require.Equalf(t, expected, locale, "NormalizeLocale returned invalid locale for %s", input)
+	}
+	for _, input := range []string{"", "e", "english please", "en-", "../en"} {
+		_, ok := NormalizeLocale(input)
+		require.Falsef(t, ok, "NormalizeLocale should reject %s", input)
+	}
+}
+
+func TestEmailTemplateRegistryEmbedded(t *testing.T) {
+	registry, err := CreateEmailTemplateRegistry("", "en", "https://example.com/")
+	require.Nilf(t, err, "CreateEmailTemplateRegistry should load embedded templates")
+
+	data := map[EmailKindEnum]any{
+		EmailKindVerification:       struct{ Token string }{"id:secret"},
+		EmailKindPasswordReset:      struct{ Token string }{"id:secret"},
+		EmailKindLockout:            struct{ Until time.Time }{time.Now()},
+		EmailKindBusinessInvitation: struct{ BusinessName, Role string }{"Cafe", "CASHIER"},
+		EmailKindEmailChange:        struct{ Token string }{"id:secret"},
+		EmailKindEmailChangeNotice:  struct{ Token, NewEmail string }{"id:secret", "new@example.com"},
+	}
+	// Every embedded locale has every kind, with a plaintext alternative
+	for _, locale := range []string{"en", "pl"} {
+		for kind, kindData := range data {
+			message, err := registry.Render(kind, locale, kindData)
+			require.Nilf(t, err, "Render returned an error for %s in %s: %+v", kind, locale, err)
+			require.NotEmptyf(t, message.Subject, "%s in %s has no subject", kind, locale)
+			require.NotEmptyf(t, message.HTMLBody, "%s in %s has no HTML body", kind, locale)
+			require.NotEmptyf(t, message.TextBody, "%s in %s has no text body", kind, locale)
+		}
+	}
+
+	message, err := registry.Render(EmailKindVerification, "en", data[EmailKindVerification])
+	require.Nilf(t, err, "Render returned an error")
+	require.Containsf(t, message.TextBody, "https://example.com/static/emailVerification.html?token=id:secret",
+		"verification email should link to the backend")
+}
+
+func TestEmailTemplateRegistryFallback(t *testing.T) {
+	registry, err := LoadEmailTemplateRegistry(fstest.MapFS{
+		"en/verification.subject.txt":        {Data: []byte("verify {{ .Name }}\n")},
+		"en/verification.html":               {Data: []byte("<p>{{ .Name }}</p>")},
+		"en/verification.txt":                {Data: []byte("{{ .Name }}")},
+		"en/password_reset.subject.txt":      {Data: []byte("reset")},
+		"en/password_reset.html":             {Data: []byte("reset")},
+		"en/lockout.subject.txt":             {Data: []byte("lockout")},
+		"en/lockout.html":                    {Data: []byte("lockout")},
+		"en/business_invitation.subject.txt": {Data: []byte("invitation")},
+		"en/business_invitation.html":        {Data: []byte("invitation")},
+		"en/email_change.subject.txt":        {Data: []byte("email change")},
+		"en/email_change.html":               {Data: []byte("email change")},
+		"en/email_change_notice.subject.txt": {Data: []byte("email change notice")},
+		"en/email_change_notice.html":        {Data: []byte("email change notice")},
+		"pl/verification.subject.txt":        {Data: []byte("weryfikacja")},
+		"pl/verification.html":               {Data: []byte("weryfikacja")},
+	}, "en", "")
+	require.Nilf(t, err, "LoadEmailTemplateRegistry returned an error")
+
+	data := struct{ Name string }{"<b>"}
+	message, err := registry.Render(EmailKindVerification, "en", data)
+	require.Nilf(t, err, "Render returned an error")
+	require.Equalf(t, EmailMessage{Subject: "verify <b>", HTMLBody: "<p>&lt;b&gt;</p>", TextBody: "<b>"}, *message,
+		"only the HTML body should be escaped")
+
+	message, err = registry.Render(EmailKindVerification, "pl-PL", data)
+	require.Nilf(t, err, "Render returned an error")
+	require.Equalf(t, "weryfikacja", message.Subject, "pl-PL should fall back to pl")
+	require.Equalf(t, "", message.TextBody, "email without text template should have no text body")
+
+	for _, locale := range []string{"", "de", "not a locale"} {
+		message, err = registry.Render(EmailKindVerification, locale, data)
+		require.Nilf(t, err, "Render returned an error")
+		require.Equalf(t, "verify <b>", message.Subject, "%s should fall back to the default locale", locale)
+	}
+	message, err = registry.Render(EmailKindLockout, "pl", data)
+	require.Nilf(t, err, "Render returned an error")
+	require.Equalf(t, "lockout", message.Subject, "kinds missing in pl should fall back to the default locale")
+}
+
+func TestEmailTemplateRegistryMissingDefault(t *testing.T) {
+	_, err := LoadEmailTemplateRegistry(fstest.MapFS{
+		"en/verification.subject.txt": {Data: []byte("verify")},
+		"en/verification.html":        {Data: []byte("verify")},
+	}, "en", "")
+	require.ErrorIsf(t, err, ErrMissingEmailTemplate, "default locale should have templates of every kind")
+	require.Truef(t, strings.Contains(err.Error(), "password_reset"), "error should name the missing kind")
+}
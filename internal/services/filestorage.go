@@ -1,15 +1,17 @@
 package services
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
-	"io/fs"
 	"net/http"
-	"os"
-	"path"
+	"regexp"
 	"time"
 
+	. "github.com/StampWallet/backend/internal/config"
 	. "github.com/StampWallet/backend/internal/database"
 	"github.com/StampWallet/backend/internal/utils"
 	"github.com/lithammer/shortuuid/v4"
@@ -22,60 +24,143 @@ var (
 	ErrFileNotUploaded    = errors.New("file not uploaded")
 	ErrInvalidMimeType    = errors.New("invalid mimetype")
 	ErrUploadSizeExceeded = errors.New("upload size exceeded")
+	ErrFileQuotaExceeded  = errors.New("file quota exceeded")
+	ErrBlobInUse          = errors.New("blob in use")
 )
 
 var AllowedMimeTypes = []string{
 	"image/jpeg",
 	"image/png",
 	"image/gif",
-	"image/webp",
 }
 
+// Keys of stored files - shortuuid PublicId of files stored before deduplication or blob hash,
+// followed by the purpose and variant (see variantKey and blobVariantKey)
+var storedFileKeyRegexp = regexp.MustCompile(`^([0-9A-Za-z]{22}|[0-9a-f]{64})(?:_(?:[A-Z_]*_)?(?:thumb|medium))?$`)
+
+var blobHashRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
 // limit upload to ~1mb
 const UploadSizeLimit_b = 1_000_000
 
+// Contents of a file returned by FileStorageService.GetData. Either Reader or RedirectUrl is set.
+type FileData struct {
+	// Contents of the file, has to be closed by the caller
+	Reader io.ReadCloser
+	// URL the file can be downloaded from directly
+	RedirectUrl string
+	ContentType string
+	// Strong entity tag of the data, empty for files stored before deduplication
+	ETag string
+}
+
+// Files of a user or a business and their limits. Limits equal to 0 mean no limit.
+type FileUsage struct {
+	Files    uint
+	Bytes    int64
+	MaxFiles uint
+	MaxBytes int64
+}
+
 type FileStorageService interface {
-	CreateStub(user *User) (*FileMetadata, error)
-	// generally not very useful, will be served by a static server either way
-	// FileMetadata, mimetype, error
-	GetData(id string) (*os.File, string, error)
+	// Creates metadata of a file that is uploaded later. business is nil for files that don't belong
+	// to a business. Returns ErrFileQuotaExceeded if user or business have too many files already.
+	CreateStub(user *User, business *Business, purpose FilePurposeEnum) (*FileMetadata, error)
+	// Returns variant of the file. Files uploaded before variants existed return the original.
+	GetData(id string, variant ImageVariant) (*FileData, error)
 	// TODO how to recive an os.File from gin? data perhaps should be changed to reader
+	// Returns ErrFileQuotaExceeded if the file does not fit in the quota of its owner or business.
 	Upload(fileMetadata FileMetadata, data io.Reader, mimetype string) (*FileMetadata, error)
 	RemoveFile(fileMetadata FileMetadata) error
 	// NOTE it's responsibility of the caller to make sure that all references to this FileMetadata are removed
 	RemoveMetadata(fileMetadata FileMetadata) error
+	// Returns ids of all data in storage, whether it's used or not: PublicIds of files stored before
+	// deduplication and hashes of blobs
+	ListStoredFiles() ([]string, error)
+	// Removes all variants stored under id, without touching metadata. Returns ErrBlobInUse if id
+	// is a hash of an existing blob.
+	RemoveStoredFile(id string) error
+	// Returns files of business and the business quota
+	GetBusinessUsage(business *Business) (*FileUsage, error)
 }
 
 type FileStorageServiceImpl struct {
-	basePath     string
+	backend      StorageBackend
 	baseServices BaseServices
+	quota        QuotaConfig
 }
 
-func addTrailingSlash(path string) string {
-	if path[len(path)-1] != '/' {
-		return path + "/"
+func CreateFileStorageServiceImpl(baseServices BaseServices, backend StorageBackend,
+	quota QuotaConfig) *FileStorageServiceImpl {
+	return &FileStorageServiceImpl{
+		backend:      backend,
+		baseServices: baseServices,
+		quota:        quota,
 	}
-	return path
 }
 
-func CreateFileStorageServiceImpl(baseServices BaseServices, basePath string) (*FileStorageServiceImpl, error) {
-	fi, err := os.Stat(basePath)
+// Returns number of files and bytes of file metadata matching query, except file with excludedId
+func (service *FileStorageServiceImpl) usage(query string, id uint, excludedId uint) (uint, int64, error) {
+	var result struct {
+		Files uint
+		Bytes int64
+	}
+	tx := service.baseServices.Database.Model(&FileMetadata{}).
+		Select("COUNT(*) AS files, COALESCE(SUM(size), 0) AS bytes").
+		Where(query, id).
+		Where("id <> ?", excludedId).
+		Scan(&result)
+	if err := tx.GetError(); err != nil {
+		return 0, 0, fmt.Errorf("db.Scan(FileMetadata) returned an error: %w", err)
+	}
+	return result.Files, result.Bytes, nil
+}
+
+// Returns ErrFileQuotaExceeded if files and bytes exceed the limits
+func checkQuota(files uint, bytes int64, maxFiles uint, maxBytes int64) error {
+	if (maxFiles > 0 && files > maxFiles) || (maxBytes > 0 && bytes > maxBytes) {
+		return ErrFileQuotaExceeded
+	}
+	return nil
+}
+
+// Returns ErrFileQuotaExceeded if fileMetadata, with size and counted as a new file if isNew,
+// does not fit in the quota of its owner or business
+func (service *FileStorageServiceImpl) checkQuotas(fileMetadata *FileMetadata, isNew bool) error {
+	var added uint = 0
+	if isNew {
+		added = 1
+	}
+
+	files, bytes, err := service.usage("owner_id = ?", fileMetadata.OwnerId, fileMetadata.ID)
 	if err != nil {
-		return nil, err
-	} else if !fi.IsDir() {
-		return nil, ErrInvalidBasePath
+		return err
+	}
+	err = checkQuota(files+added, bytes+fileMetadata.Size, service.quota.UserMaxFiles, service.quota.UserMaxBytes)
+	if err != nil || fileMetadata.BusinessId == nil {
+		return err
 	}
 
-	return &FileStorageServiceImpl{
-		basePath:     basePath,
-		baseServices: baseServices,
-	}, nil
+	files, bytes, err = service.usage("business_id = ?", *fileMetadata.BusinessId, fileMetadata.ID)
+	if err != nil {
+		return err
+	}
+	return checkQuota(files+added, bytes+fileMetadata.Size, service.quota.BusinessMaxFiles,
+		service.quota.BusinessMaxBytes)
 }
 
-func (service *FileStorageServiceImpl) CreateStub(user *User) (*FileMetadata, error) {
+func (service *FileStorageServiceImpl) CreateStub(user *User, business *Business,
+	purpose FilePurposeEnum) (*FileMetadata, error) {
 	fileMetadata := &FileMetadata{
 		PublicId: shortuuid.New(),
 		OwnerId:  user.ID,
+		Purpose:  purpose,
+	}
+	if business != nil {
+		fileMetadata.BusinessId = &business.ID
+	}
+	if err := service.checkQuotas(fileMetadata, true); err != nil {
+		return nil, err
 	}
 	tx := service.baseServices.Database.Create(fileMetadata)
 	if err := tx.GetError(); err != nil {
@@ -84,26 +169,41 @@ func (service *FileStorageServiceImpl) CreateStub(user *User) (*FileMetadata, er
 	return fileMetadata, nil
 }
 
-func (service *FileStorageServiceImpl) GetData(id string) (*os.File, string, error) {
+func (service *FileStorageServiceImpl) GetData(id string, variant ImageVariant) (*FileData, error) {
 	md := FileMetadata{}
-	tx := service.baseServices.Database.Find(&md, FileMetadata{PublicId: id})
+	tx := service.baseServices.Database.First(&md, FileMetadata{PublicId: id})
 	if err := tx.GetError(); errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, "", ErrNoSuchFile // TODO: using errors.Join?
+		return nil, ErrNoSuchFile // TODO: using errors.Join?
 	} else if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+	if !md.Uploaded.Valid {
+		return nil, ErrFileNotUploaded
+	}
+	if !md.Processed {
+		variant = ImageVariantOriginal
+	}
+	key := variantKey(md.PublicId, variant)
+	etag := ""
+	if md.BlobHash.Valid {
+		// Data of a blob never changes. ETag of the original is the checksum of the file.
+		key = blobVariantKey(md.BlobHash.String, md.Purpose, variant)
+		etag = `"` + key + `"`
 	}
 
-	filePath := path.Join(service.basePath, md.PublicId)
-	if path.Dir(filePath) != path.Dir(addTrailingSlash(service.basePath)) {
-		return nil, "", errors.New("file base somehow changed in GetData")
+	redirectUrl, err := service.backend.PresignGet(key)
+	if err != nil {
+		return nil, err
+	} else if redirectUrl != "" {
+		return &FileData{RedirectUrl: redirectUrl, ContentType: md.ContentType.String, ETag: etag}, nil
 	}
 
-	file, err := os.Open(filePath)
+	reader, err := service.backend.Get(key)
 	if err != nil {
-		return nil, "", ErrFileNotUploaded
+		return nil, err
 	}
 
-	return file, md.ContentType.String, nil
+	return &FileData{Reader: reader, ContentType: md.ContentType.String, ETag: etag}, nil
 }
 
 func (service *FileStorageServiceImpl) Upload(fileMetadata FileMetadata, data io.Reader, mimetype string) (*FileMetadata, error) {
@@ -131,45 +231,235 @@ func (service *FileStorageServiceImpl) Upload(fileMetadata FileMetadata, data io
 		return nil, ErrInvalidMimeType
 	}
 
-	filePath := path.Join(service.basePath, fileMetadata.PublicId)
-	if path.Dir(filePath) != path.Dir(addTrailingSlash(service.basePath)) {
-		return nil, errors.New("file base somehow changed after upload")
-	}
-	err := os.WriteFile(
-		filePath,
-		dataBytes,
-		fs.FileMode(0660),
-	)
-	if err != nil {
-		return nil, err
-	}
+	checksum := sha256.Sum256(dataBytes)
+	hash := hex.EncodeToString(checksum[:])
+	previous := fileMetadata
+	err := service.baseServices.Database.Transaction(func(tx GormDB) error {
+		if err := lockBlob(tx, hash); err != nil {
+			return err
+		}
+		var blob FileBlob
+		r := tx.First(&blob, FileBlob{Hash: hash})
+		newBlob := false
+		if err := r.GetError(); errors.Is(err, gorm.ErrRecordNotFound) {
+			newBlob = true
+		} else if err != nil {
+			return fmt.Errorf("tx.First(FileBlob) returned an error: %w", err)
+		}
+		// Where instead of struct conditions, because files might have no purpose
+		var blobVariant FileBlobVariant
+		r = tx.Where("hash = ? AND purpose = ?", hash, fileMetadata.Purpose).First(&blobVariant)
+		newBlobVariant := false
+		if err := r.GetError(); errors.Is(err, gorm.ErrRecordNotFound) {
+			newBlobVariant = true
+		} else if err != nil {
+			return fmt.Errorf("tx.First(FileBlobVariant) returned an error: %w", err)
+		}
 
-	fileMetadata.ContentType = sql.NullString{String: mimetype, Valid: true}
-	fileMetadata.Uploaded = sql.NullTime{Time: time.Now().Round(time.Microsecond), Valid: true}
-	tx := service.baseServices.Database.Save(&fileMetadata)
-	if err = tx.GetError(); err != nil {
+		var processed *processedImage
+		if newBlob || newBlobVariant {
+			var err error
+			processed, err = processImage(dataBytes, GetImageLimits(fileMetadata.Purpose))
+			if err != nil {
+				return err
+			}
+		}
+		if newBlob {
+			blob = FileBlob{
+				Hash:        hash,
+				ContentType: processed.contentType,
+				Size:        int64(len(processed.variants[ImageVariantOriginal])),
+			}
+		}
+		if newBlobVariant {
+			blobVariant = FileBlobVariant{Hash: hash, Purpose: fileMetadata.Purpose}
+			for _, variant := range ImageVariants {
+				if variant != ImageVariantOriginal {
+					blobVariant.Size += int64(len(processed.variants[variant]))
+				}
+			}
+		}
+
+		// Every file counts against the quota, even if its blob is shared
+		fileMetadata.Size = blob.Size + blobVariant.Size
+		if err := service.checkQuotas(&fileMetadata, false); err != nil {
+			return err
+		}
+		// Original is stored last, so that files are never served without their variants
+		for _, variant := range ImageVariants {
+			store := newBlobVariant
+			if variant == ImageVariantOriginal {
+				store = newBlob
+			}
+			if !store {
+				continue
+			}
+			err := service.backend.Put(blobVariantKey(hash, fileMetadata.Purpose, variant),
+				processed.variants[variant], processed.contentType)
+			if err != nil {
+				return err
+			}
+		}
+		blob.RefCount++
+		if err := tx.Save(&blob).GetError(); err != nil {
+			return fmt.Errorf("tx.Save(FileBlob) returned an error: %w", err)
+		}
+		blobVariant.RefCount++
+		if err := tx.Save(&blobVariant).GetError(); err != nil {
+			return fmt.Errorf("tx.Save(FileBlobVariant) returned an error: %w", err)
+		}
+
+		fileMetadata.ContentType = sql.NullString{String: blob.ContentType, Valid: true}
+		fileMetadata.Uploaded = sql.NullTime{Time: time.Now().Round(time.Microsecond), Valid: true}
+		fileMetadata.Processed = true
+		fileMetadata.Checksum = sql.NullString{String: hash, Valid: true}
+		fileMetadata.BlobHash = sql.NullString{String: hash, Valid: true}
+		if err := tx.Save(&fileMetadata).GetError(); err != nil {
+			return fmt.Errorf("tx.Save(FileMetadata) returned an error: %w", err)
+		}
+
+		// Data of a file uploaded again is replaced
+		if previous.Uploaded.Valid {
+			return service.removeData(tx, previous)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &fileMetadata, nil
 }
 
-func (service *FileStorageServiceImpl) RemoveFile(fileMetadata FileMetadata) error {
-	err := os.Remove(path.Join(service.basePath, fileMetadata.PublicId))
-	if err != nil {
-		return ErrFileNotUploaded
+// Locks blob with hash until the end of tx, so that it's not stored and removed at the same time
+func lockBlob(tx GormDB, hash string) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "file_blob:"+hash).GetError(); err != nil {
+		return fmt.Errorf("failed to lock blob %s: %w", hash, err)
 	}
+	return nil
+}
 
-	fileMetadata.ContentType = sql.NullString{}
-	fileMetadata.Uploaded = sql.NullTime{}
-	tx := service.baseServices.Database.Save(&fileMetadata)
-	if err := tx.GetError(); err != nil {
+// Removes stored data of uploaded fileMetadata. Blobs are only removed together with their last file.
+// Storage is modified last, so that tx can be rolled back if that fails.
+func (service *FileStorageServiceImpl) removeData(tx GormDB, fileMetadata FileMetadata) error {
+	if !fileMetadata.BlobHash.Valid {
+		// Stored under PublicId before deduplication
+		if err := service.backend.Delete(fileMetadata.PublicId); err != nil {
+			return err
+		}
+		if fileMetadata.Processed {
+			return service.removeVariants(fileMetadata.PublicId)
+		}
+		return nil
+	}
+
+	hash := fileMetadata.BlobHash.String
+	if err := lockBlob(tx, hash); err != nil {
 		return err
 	}
 
+	// Variants of the purpose are removed with the last file with the purpose, the original with the last file
+	var blobVariant FileBlobVariant
+	r := tx.Where("hash = ? AND purpose = ?", hash, fileMetadata.Purpose).First(&blobVariant)
+	err := r.GetError()
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("tx.First(FileBlobVariant) returned an error: %w", err)
+	}
+	removeBlobVariant := err == nil && blobVariant.RefCount <= 1
+	if removeBlobVariant {
+		if err := tx.Unscoped().Delete(&blobVariant).GetError(); err != nil {
+			return fmt.Errorf("tx.Delete(FileBlobVariant) returned an error: %w", err)
+		}
+	} else if err == nil {
+		blobVariant.RefCount--
+		if err := tx.Save(&blobVariant).GetError(); err != nil {
+			return fmt.Errorf("tx.Save(FileBlobVariant) returned an error: %w", err)
+		}
+	}
+
+	var blob FileBlob
+	r = tx.First(&blob, FileBlob{Hash: hash})
+	err = r.GetError()
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("tx.First(FileBlob) returned an error: %w", err)
+	}
+	removeBlob := err == nil && blob.RefCount <= 1
+	if removeBlob {
+		if err := tx.Unscoped().Delete(&blob).GetError(); err != nil {
+			return fmt.Errorf("tx.Delete(FileBlob) returned an error: %w", err)
+		}
+	} else if err == nil {
+		blob.RefCount--
+		if err := tx.Save(&blob).GetError(); err != nil {
+			return fmt.Errorf("tx.Save(FileBlob) returned an error: %w", err)
+		}
+	}
+
+	if removeBlobVariant {
+		if err := service.removeBlobVariants(hash, fileMetadata.Purpose); err != nil {
+			return err
+		}
+	}
+	if removeBlob {
+		return service.removeBlobOriginal(hash)
+	}
+	return nil
+}
+
+// Removes all variants stored under id, missing variants are skipped
+func (service *FileStorageServiceImpl) removeVariants(id string) error {
+	for _, variant := range ImageVariants {
+		err := service.backend.Delete(variantKey(id, variant))
+		if err != nil && err != ErrFileNotUploaded {
+			return err
+		}
+	}
+	return nil
+}
+
+// Removes variants of blob with hash stored for purpose, except the original. Missing variants are skipped.
+func (service *FileStorageServiceImpl) removeBlobVariants(hash string, purpose FilePurposeEnum) error {
+	for _, variant := range ImageVariants {
+		if variant == ImageVariantOriginal {
+			continue
+		}
+		err := service.backend.Delete(blobVariantKey(hash, purpose, variant))
+		if err != nil && err != ErrFileNotUploaded {
+			return err
+		}
+	}
 	return nil
 }
 
+// Removes the original of blob with hash, if it's stored
+func (service *FileStorageServiceImpl) removeBlobOriginal(hash string) error {
+	err := service.backend.Delete(blobVariantKey(hash, "", ImageVariantOriginal))
+	if err != nil && err != ErrFileNotUploaded {
+		return err
+	}
+	return nil
+}
+
+func (service *FileStorageServiceImpl) RemoveFile(fileMetadata FileMetadata) error {
+	if !fileMetadata.Uploaded.Valid {
+		return ErrFileNotUploaded
+	}
+
+	return service.baseServices.Database.Transaction(func(tx GormDB) error {
+		removed := fileMetadata
+		removed.ContentType = sql.NullString{}
+		removed.Uploaded = sql.NullTime{}
+		removed.Processed = false
+		removed.Size = 0
+		removed.Checksum = sql.NullString{}
+		removed.BlobHash = sql.NullString{}
+		if err := tx.Save(&removed).GetError(); err != nil {
+			return err
+		}
+		return service.removeData(tx, fileMetadata)
+	})
+}
+
 func (service *FileStorageServiceImpl) RemoveMetadata(fileMetadata FileMetadata) error {
 	err := service.RemoveFile(fileMetadata)
 	if err != nil && err != ErrFileNotUploaded {
@@ -183,3 +473,67 @@ func (service *FileStorageServiceImpl) RemoveMetadata(fileMetadata FileMetadata)
 
 	return nil
 }
+
+func (service *FileStorageServiceImpl) ListStoredFiles() ([]string, error) {
+	keys, err := service.backend.List()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	ids := []string{}
+	for _, key := range keys {
+		// Storage might be shared with something else, unknown keys are left alone
+		match := storedFileKeyRegexp.FindStringSubmatch(key)
+		if match == nil || seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		ids = append(ids, match[1])
+	}
+	return ids, nil
+}
+
+func (service *FileStorageServiceImpl) RemoveStoredFile(id string) error {
+	if !blobHashRegexp.MatchString(id) {
+		return service.removeVariants(id)
+	}
+
+	return service.baseServices.Database.Transaction(func(tx GormDB) error {
+		// Blob might have been stored by an upload that finished in the meantime
+		if err := lockBlob(tx, id); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&FileBlob{}).Where("hash = ?", id).Count(&count).GetError(); err != nil {
+			return fmt.Errorf("tx.Count(FileBlob) returned an error: %w", err)
+		}
+		if count > 0 {
+			return ErrBlobInUse
+		}
+		// Variants of every known purpose and of files without one
+		purposes := []FilePurposeEnum{""}
+		for purpose := range imageLimitsByPurpose {
+			purposes = append(purposes, purpose)
+		}
+		for _, purpose := range purposes {
+			if err := service.removeBlobVariants(id, purpose); err != nil {
+				return err
+			}
+		}
+		return service.removeBlobOriginal(id)
+	})
+}
+
+func (service *FileStorageServiceImpl) GetBusinessUsage(business *Business) (*FileUsage, error) {
+	files, bytes, err := service.usage("business_id = ?", business.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &FileUsage{
+		Files:    files,
+		Bytes:    bytes,
+		MaxFiles: service.quota.BusinessMaxFiles,
+		MaxBytes: service.quota.BusinessMaxBytes,
+	}, nil
+}
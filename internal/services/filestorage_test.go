@@ -3,6 +3,8 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"image"
+	"image/png"
 	"log"
 	"os"
 	"path"
@@ -24,7 +26,7 @@ func GetFileStorageService(ctrl *gomock.Controller) *FileStorageServiceImpl {
 		panic(fmt.Sprintf("failed to create temp dir %s", err))
 	}
 	return &FileStorageServiceImpl{
-		basePath: temp,
+		backend: &FilesystemStorageBackend{basePath: temp},
 		baseServices: BaseServices{
 			Logger:   log.Default(),
 			Database: GetTestDatabase(),
@@ -32,8 +34,13 @@ func GetFileStorageService(ctrl *gomock.Controller) *FileStorageServiceImpl {
 	}
 }
 
+// Returns directory of the filesystem backend of service
+func basePath(service *FileStorageServiceImpl) string {
+	return service.backend.(*FilesystemStorageBackend).basePath
+}
+
 func createFile(t *testing.T, service *FileStorageServiceImpl, publicId string) (*os.File, string) {
-	file, err := os.Create(path.Join(service.basePath, publicId))
+	file, err := os.Create(path.Join(basePath(service), publicId))
 	require.Nilf(t, err, "os.Create returned an error")
 
 	toWrite := "\x89PNG\x0D\x0A\x1A\x0A" // png magic numbers
@@ -44,12 +51,35 @@ func createFile(t *testing.T, service *FileStorageServiceImpl, publicId string)
 	return file, toWrite
 }
 
+// Creates a PNG image of size w x h
+func createPngFile(t *testing.T, service *FileStorageServiceImpl, publicId string, w int, h int) *os.File {
+	file, err := os.Create(path.Join(basePath(service), publicId))
+	require.Nilf(t, err, "os.Create returned an error")
+	err = png.Encode(file, image.NewRGBA(image.Rect(0, 0, w, h)))
+	require.Nilf(t, err, "png.Encode returned an error")
+	_, err = file.Seek(0, 0)
+	require.Nilf(t, err, "file.Seek returned an error")
+	return file
+}
+
+// Returns size of variant of file
+func readImageSize(t *testing.T, service *FileStorageServiceImpl, publicId string, variant ImageVariant) image.Point {
+	fileData, err := service.GetData(publicId, variant)
+	require.Nilf(t, err, "service.GetData returned an error")
+	defer fileData.Reader.Close()
+
+	config, _, err := image.DecodeConfig(fileData.Reader)
+	require.Nilf(t, err, "image.DecodeConfig returned an error")
+	return image.Pt(config.Width, config.Height)
+}
+
 func readAndCompare(t *testing.T, org string, service *FileStorageServiceImpl, publicId string) {
-	newFile, _, err := service.GetData(publicId)
+	fileData, err := service.GetData(publicId, ImageVariantOriginal)
 	require.Nilf(t, err, "service.GetData returned an error")
+	defer fileData.Reader.Close()
 
 	toRead := make([]byte, len(org))
-	newFile.Read(toRead)
+	fileData.Reader.Read(toRead)
 	require.Equalf(t, org, string(toRead), "file.Read returned a different file")
 }
 
@@ -58,20 +88,47 @@ func readAndCompare(t *testing.T, org string, service *FileStorageServiceImpl, p
 func TestFileStorageServiceCreateStub(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	service := GetFileStorageService(ctrl)
-	defer os.RemoveAll(service.basePath)
+	defer os.RemoveAll(basePath(service))
 	user := GetTestUser(service.baseServices.Database)
 
-	metadata, err := service.CreateStub(user)
+	metadata, err := service.CreateStub(user, nil, FilePurposeItemImage)
 	require.Nilf(t, err, "Error should be nil")
 	require.NotNilf(t, metadata, "FileMetadata should not be nil")
 	require.Equalf(t, user.ID, metadata.OwnerId, "Metadata has invalid owner")
 	require.Falsef(t, metadata.Uploaded.Valid, "Metadata has upload date")
+	require.Equalf(t, FilePurposeItemImage, metadata.Purpose, "Metadata has invalid purpose")
+	require.Nilf(t, metadata.BusinessId, "Metadata should not have a business")
+}
+
+func TestFileStorageServiceCreateStubQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetFileStorageService(ctrl)
+	defer os.RemoveAll(basePath(service))
+	user := GetTestUser(service.baseServices.Database)
+	business := GetTestBusiness(service.baseServices.Database, user)
+	service.quota.BusinessMaxFiles = 2
+
+	metadata, err := service.CreateStub(user, business, FilePurposeMenuImage)
+	require.Nilf(t, err, "service.CreateStub returned an error")
+	require.Equalf(t, business.ID, *metadata.BusinessId, "Metadata has invalid business")
+	_, err = service.CreateStub(user, business, FilePurposeMenuImage)
+	require.Nilf(t, err, "service.CreateStub returned an error")
+	_, err = service.CreateStub(user, business, FilePurposeMenuImage)
+	require.Equalf(t, ErrFileQuotaExceeded, err, "service.CreateStub should enforce the business quota")
+
+	service.quota.UserMaxFiles = 2
+	_, err = service.CreateStub(user, nil, FilePurposeItemImage)
+	require.Equalf(t, ErrFileQuotaExceeded, err, "service.CreateStub should enforce the user quota")
+
+	usage, err := service.GetBusinessUsage(business)
+	require.Nilf(t, err, "service.GetBusinessUsage returned an error")
+	require.Equalf(t, FileUsage{Files: 2, Bytes: 0, MaxFiles: 2}, *usage, "business usage is invalid")
 }
 
 func TestFileStorageServiceGetData(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	service := GetFileStorageService(ctrl)
-	defer os.RemoveAll(service.basePath)
+	defer os.RemoveAll(basePath(service))
 	user := GetTestUser(service.baseServices.Database)
 
 	metadata := FileMetadata{
@@ -90,18 +147,17 @@ func TestFileStorageServiceGetData(t *testing.T) {
 func TestFileStorageServiceGetDataInvalid(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	service := GetFileStorageService(ctrl)
-	defer os.RemoveAll(service.basePath)
+	defer os.RemoveAll(basePath(service))
 
-	file, mime, err := service.GetData("invalid uuid lol")
+	file, err := service.GetData("invalid uuid lol", ImageVariantOriginal)
 	require.Nilf(t, file, "service.GetData returned a file")
-	require.Equalf(t, "", mime, "service.GetData returned a mimetype")
 	require.ErrorAsf(t, err, &ErrNoSuchFile, "service.GetData returned a file")
 }
 
 func TestFileStorageServiceGetDataNotUploaded(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	service := GetFileStorageService(ctrl)
-	defer os.RemoveAll(service.basePath)
+	defer os.RemoveAll(basePath(service))
 	user := GetTestUser(service.baseServices.Database)
 
 	metadata := FileMetadata{
@@ -112,31 +168,29 @@ func TestFileStorageServiceGetDataNotUploaded(t *testing.T) {
 	tx := service.baseServices.Database.Create(&metadata)
 	require.Nilf(t, tx.GetError(), "Database.Create returned an error")
 
-	newFile, mime, err := service.GetData(metadata.PublicId)
+	newFile, err := service.GetData(metadata.PublicId, ImageVariantOriginal)
 	require.Nilf(t, newFile, "service.GetData returned a file")
-	require.Equalf(t, "", mime, "service.GetData returned a mimetype")
 	require.ErrorAsf(t, err, &ErrFileNotUploaded, "service.GetData returned a file")
 }
 
 func TestFileStorageServiceUpload(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	service := GetFileStorageService(ctrl)
-	defer os.RemoveAll(service.basePath)
+	defer os.RemoveAll(basePath(service))
 	user := GetTestUser(service.baseServices.Database)
 
 	metadata := FileMetadata{
 		PublicId: shortuuid.New(),
 		OwnerId:  user.ID,
+		Purpose:  FilePurposeItemImage,
 	}
 	tx := service.baseServices.Database.Create(&metadata)
 	require.Nilf(t, tx.GetError(), "Database.Create returned an error")
 
-	_, err := os.Create(path.Join(service.basePath, metadata.PublicId))
+	_, err := os.Create(path.Join(basePath(service), metadata.PublicId))
 	require.Nilf(t, err, "os.Create returned an error")
 
-	file, toWrite := createFile(t, service, shortuuid.New())
-	_, err = file.Seek(0, 0)
-	require.Nilf(t, err, "file.Seek returned an error")
+	file := createPngFile(t, service, shortuuid.New(), 2000, 1000)
 
 	newFileMetadata, err := service.Upload(metadata, file, AllowedMimeTypes[1])
 	require.Nilf(t, err, "service.Upload returned an error")
@@ -147,7 +201,15 @@ func TestFileStorageServiceUpload(t *testing.T) {
 	require.Equalf(t, AllowedMimeTypes[1], newFileMetadata.ContentType.String,
 		"newFileMetadata has invalid content type")
 
-	readAndCompare(t, toWrite, service, metadata.PublicId)
+	require.Truef(t, newFileMetadata.Processed, "newFileMetadata should be processed")
+
+	// Originals are shared by all purposes, only variants are scaled down to item image limits
+	require.Equalf(t, image.Pt(2000, 1000), readImageSize(t, service, metadata.PublicId, ImageVariantOriginal),
+		"original should fit in the limit of every purpose")
+	require.Equalf(t, image.Pt(512, 256), readImageSize(t, service, metadata.PublicId, ImageVariantMedium),
+		"medium variant has invalid size")
+	require.Equalf(t, image.Pt(128, 64), readImageSize(t, service, metadata.PublicId, ImageVariantThumb),
+		"thumb variant has invalid size")
 
 	var fileMetadataDb FileMetadata
 	tx = service.baseServices.Database.First(&fileMetadataDb,
@@ -156,12 +218,35 @@ func TestFileStorageServiceUpload(t *testing.T) {
 	require.True(t, newFileMetadata.Uploaded.Time.Equal(fileMetadataDb.Uploaded.Time))
 	require.Equal(t, newFileMetadata.ContentType.String, fileMetadataDb.ContentType.String)
 	require.Equal(t, newFileMetadata.OwnerId, fileMetadataDb.OwnerId)
+	require.Greaterf(t, fileMetadataDb.Size, int64(0), "fileMetadataDb has invalid size")
+	require.Equalf(t, newFileMetadata.Size, fileMetadataDb.Size, "fileMetadataDb has invalid size")
+	require.Lenf(t, fileMetadataDb.Checksum.String, 64, "fileMetadataDb has invalid checksum")
+}
+
+func TestFileStorageServiceUploadQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetFileStorageService(ctrl)
+	defer os.RemoveAll(basePath(service))
+	user := GetTestUser(service.baseServices.Database)
+	service.quota.UserMaxBytes = 100
+
+	metadata, err := service.CreateStub(user, nil, FilePurposeItemImage)
+	require.Nilf(t, err, "service.CreateStub returned an error")
+	file := createPngFile(t, service, shortuuid.New(), 200, 200)
+
+	_, err = service.Upload(*metadata, file, AllowedMimeTypes[1])
+	require.Equalf(t, ErrFileQuotaExceeded, err, "service.Upload should enforce the user quota")
+
+	var fileMetadataDb FileMetadata
+	tx := service.baseServices.Database.First(&fileMetadataDb, FileMetadata{PublicId: metadata.PublicId})
+	require.Nilf(t, tx.GetError(), "Database.First returned an error")
+	require.Falsef(t, fileMetadataDb.Uploaded.Valid, "file over the quota should not be uploaded")
 }
 
 func TestFileStorageServiceUploadInvalidMimeType(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	service := GetFileStorageService(ctrl)
-	defer os.RemoveAll(service.basePath)
+	defer os.RemoveAll(basePath(service))
 	user := GetTestUser(service.baseServices.Database)
 
 	metadata := FileMetadata{
@@ -171,7 +256,7 @@ func TestFileStorageServiceUploadInvalidMimeType(t *testing.T) {
 	tx := service.baseServices.Database.Create(&metadata)
 	require.Nilf(t, tx.GetError(), "Database.Create returned an error")
 
-	_, err := os.Create(path.Join(service.basePath, metadata.PublicId))
+	_, err := os.Create(path.Join(basePath(service), metadata.PublicId))
 	require.Nilf(t, err, "os.Create returned an error")
 
 	file, _ := createFile(t, service, shortuuid.New())
@@ -184,7 +269,7 @@ func TestFileStorageServiceUploadInvalidMimeType(t *testing.T) {
 func TestFileStorageServiceRemoveFile(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	service := GetFileStorageService(ctrl)
-	defer os.RemoveAll(service.basePath)
+	defer os.RemoveAll(basePath(service))
 	user := GetTestUser(service.baseServices.Database)
 
 	metadata := FileMetadata{
@@ -202,13 +287,12 @@ func TestFileStorageServiceRemoveFile(t *testing.T) {
 	err := service.RemoveFile(metadata)
 	require.Nilf(t, err, "FileStorageService.RemoveFile returned an error")
 
-	openedFile, err := os.Open(path.Join(service.basePath, metadata.PublicId))
+	openedFile, err := os.Open(path.Join(basePath(service), metadata.PublicId))
 	require.Nilf(t, openedFile, "os.Open returned a file - file exists, but should have been removed")
 	require.Errorf(t, err, "os.Open did not return an error")
 
-	serviceFile, mime, err := service.GetData(metadata.PublicId)
+	serviceFile, err := service.GetData(metadata.PublicId, ImageVariantOriginal)
 	require.Nilf(t, serviceFile, "service.GetData returned a file")
-	require.Equalf(t, "", mime, "service.GetData returned a mimetype")
 	require.ErrorAs(t, err, &ErrFileNotUploaded, "service.GetData did not return a FileNotUploaded error")
 
 	var fileMetadataDb FileMetadata
@@ -222,7 +306,7 @@ func TestFileStorageServiceRemoveFile(t *testing.T) {
 func TestFileStorageServiceRemoveFileNotUploaded(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	service := GetFileStorageService(ctrl)
-	defer os.RemoveAll(service.basePath)
+	defer os.RemoveAll(basePath(service))
 	user := GetTestUser(service.baseServices.Database)
 
 	metadata := FileMetadata{
@@ -248,7 +332,7 @@ func TestFileStorageServiceRemoveFileNotUploaded(t *testing.T) {
 func TestFileStorageServiceRemoveMetadataWithFile(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	service := GetFileStorageService(ctrl)
-	defer os.RemoveAll(service.basePath)
+	defer os.RemoveAll(basePath(service))
 	user := GetTestUser(service.baseServices.Database)
 
 	metadata := FileMetadata{
@@ -266,13 +350,12 @@ func TestFileStorageServiceRemoveMetadataWithFile(t *testing.T) {
 	err := service.RemoveMetadata(metadata)
 	require.Nilf(t, err, "FileStorageService.RemoveMetadata returned an error")
 
-	openedFile, err := os.Open(path.Join(service.basePath, metadata.PublicId))
+	openedFile, err := os.Open(path.Join(basePath(service), metadata.PublicId))
 	require.Nilf(t, openedFile, "os.Open returned a file - file exists, but should have been removed")
 	require.Errorf(t, err, "os.Open did not return an error")
 
-	serviceFile, mime, err := service.GetData(metadata.PublicId)
+	serviceFile, err := service.GetData(metadata.PublicId, ImageVariantOriginal)
 	require.Nilf(t, serviceFile, "service.GetData returned a file")
-	require.Equalf(t, "", mime, "service.GetData returned a mimetype")
 	require.ErrorAs(t, err, &ErrFileNotUploaded, "service.GetData did not return a FileNotUploaded error")
 
 	var fileMetadataDb FileMetadata
@@ -284,7 +367,7 @@ func TestFileStorageServiceRemoveMetadataWithFile(t *testing.T) {
 func TestFileStorageServiceRemoveMetadataNotUploaded(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	service := GetFileStorageService(ctrl)
-	defer os.RemoveAll(service.basePath)
+	defer os.RemoveAll(basePath(service))
 	user := GetTestUser(service.baseServices.Database)
 
 	metadata := FileMetadata{
@@ -304,3 +387,117 @@ func TestFileStorageServiceRemoveMetadataNotUploaded(t *testing.T) {
 		FileMetadata{PublicId: metadata.PublicId})
 	require.ErrorIsf(t, tx.GetError(), gorm.ErrRecordNotFound, "Database.First did not return ErrRecordNotFound")
 }
+
+func TestFileStorageServiceUploadDeduplicates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetFileStorageService(ctrl)
+	defer os.RemoveAll(basePath(service))
+	user := GetTestUser(service.baseServices.Database)
+	db := service.baseServices.Database
+
+	first, err := service.CreateStub(user, nil, FilePurposeItemImage)
+	require.Nilf(t, err, "service.CreateStub returned an error")
+	second, err := service.CreateStub(user, nil, FilePurposeItemImage)
+	require.Nilf(t, err, "service.CreateStub returned an error")
+
+	first, err = service.Upload(*first, createPngFile(t, service, shortuuid.New(), 300, 200), AllowedMimeTypes[1])
+	require.Nilf(t, err, "service.Upload returned an error")
+	second, err = service.Upload(*second, createPngFile(t, service, shortuuid.New(), 300, 200), AllowedMimeTypes[1])
+	require.Nilf(t, err, "service.Upload returned an error")
+	require.Truef(t, first.BlobHash.Valid, "uploaded file should have a blob")
+	require.Equalf(t, first.BlobHash, second.BlobHash, "files with the same data should share the blob")
+
+	var blob FileBlob
+	tx := db.First(&blob, FileBlob{Hash: first.BlobHash.String})
+	require.Nilf(t, tx.GetError(), "Database.First returned an error")
+	require.Equalf(t, uint(2), blob.RefCount, "blob should be used by both files")
+
+	// Blob stays while it's used by the second file
+	require.Nilf(t, service.RemoveFile(*first), "service.RemoveFile returned an error")
+	require.Equalf(t, image.Pt(300, 200), readImageSize(t, service, second.PublicId, ImageVariantOriginal),
+		"second file should still be readable")
+
+	require.Nilf(t, service.RemoveFile(*second), "service.RemoveFile returned an error")
+	tx = db.First(&blob, FileBlob{Hash: first.BlobHash.String})
+	require.ErrorIsf(t, tx.GetError(), gorm.ErrRecordNotFound, "blob should be removed with its last file")
+	_, err = os.Stat(path.Join(basePath(service), first.BlobHash.String))
+	require.Truef(t, os.IsNotExist(err), "blob data should be removed with its last file")
+}
+
+func TestFileStorageServiceUploadDeduplicatesPurposes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetFileStorageService(ctrl)
+	defer os.RemoveAll(basePath(service))
+	user := GetTestUser(service.baseServices.Database)
+	db := service.baseServices.Database
+
+	icon, err := service.CreateStub(user, nil, FilePurposeBusinessIcon)
+	require.Nilf(t, err, "service.CreateStub returned an error")
+	item, err := service.CreateStub(user, nil, FilePurposeItemImage)
+	require.Nilf(t, err, "service.CreateStub returned an error")
+
+	icon, err = service.Upload(*icon, createPngFile(t, service, shortuuid.New(), 600, 300), AllowedMimeTypes[1])
+	require.Nilf(t, err, "service.Upload returned an error")
+	item, err = service.Upload(*item, createPngFile(t, service, shortuuid.New(), 600, 300), AllowedMimeTypes[1])
+	require.Nilf(t, err, "service.Upload returned an error")
+	require.Equalf(t, icon.BlobHash, item.BlobHash, "files with different purposes should share the blob")
+	require.Equalf(t, icon.Checksum, icon.BlobHash, "blobs should be keyed by the checksum")
+
+	var blob FileBlob
+	tx := db.First(&blob, FileBlob{Hash: icon.BlobHash.String})
+	require.Nilf(t, tx.GetError(), "Database.First returned an error")
+	require.Equalf(t, uint(2), blob.RefCount, "blob should be used by both files")
+	require.Equalf(t, image.Pt(64, 32), readImageSize(t, service, icon.PublicId, ImageVariantThumb),
+		"icon thumb should fit in the icon limits")
+	require.Equalf(t, image.Pt(128, 64), readImageSize(t, service, item.PublicId, ImageVariantThumb),
+		"item thumb should fit in the item image limits")
+
+	// Variants of the icon go away with the icon, the original stays for the item
+	require.Nilf(t, service.RemoveFile(*icon), "service.RemoveFile returned an error")
+	_, err = os.Stat(path.Join(basePath(service), icon.BlobHash.String+"_BUSINESS_ICON_thumb"))
+	require.Truef(t, os.IsNotExist(err), "variants should be removed with the last file with the purpose")
+	require.Equalf(t, image.Pt(600, 300), readImageSize(t, service, item.PublicId, ImageVariantOriginal),
+		"item should still be readable")
+	require.Equalf(t, image.Pt(128, 64), readImageSize(t, service, item.PublicId, ImageVariantThumb),
+		"item thumb should still be readable")
+}
+
+func TestFileStorageServiceGetDataETag(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetFileStorageService(ctrl)
+	defer os.RemoveAll(basePath(service))
+	user := GetTestUser(service.baseServices.Database)
+
+	metadata, err := service.CreateStub(user, nil, FilePurposeItemImage)
+	require.Nilf(t, err, "service.CreateStub returned an error")
+	metadata, err = service.Upload(*metadata, createPngFile(t, service, shortuuid.New(), 30, 20), AllowedMimeTypes[1])
+	require.Nilf(t, err, "service.Upload returned an error")
+
+	fileData, err := service.GetData(metadata.PublicId, ImageVariantOriginal)
+	require.Nilf(t, err, "service.GetData returned an error")
+	fileData.Reader.Close()
+	require.Equalf(t, `"`+metadata.Checksum.String+`"`, fileData.ETag, "ETag of the original should be the checksum")
+
+	fileData, err = service.GetData(metadata.PublicId, ImageVariantThumb)
+	require.Nilf(t, err, "service.GetData returned an error")
+	fileData.Reader.Close()
+	require.Equalf(t, `"`+metadata.BlobHash.String+`_ITEM_IMAGE_thumb"`, fileData.ETag, "GetData returned invalid ETag")
+}
+
+func TestFileStorageServiceRemoveStoredFileInUse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetFileStorageService(ctrl)
+	defer os.RemoveAll(basePath(service))
+	user := GetTestUser(service.baseServices.Database)
+
+	metadata, err := service.CreateStub(user, nil, FilePurposeItemImage)
+	require.Nilf(t, err, "service.CreateStub returned an error")
+	metadata, err = service.Upload(*metadata, createPngFile(t, service, shortuuid.New(), 30, 20), AllowedMimeTypes[1])
+	require.Nilf(t, err, "service.Upload returned an error")
+
+	ids, err := service.ListStoredFiles()
+	require.Nilf(t, err, "service.ListStoredFiles returned an error")
+	require.Containsf(t, ids, metadata.BlobHash.String, "service.ListStoredFiles should list blobs")
+	err = service.RemoveStoredFile(metadata.BlobHash.String)
+	require.Equalf(t, ErrBlobInUse, err, "service.RemoveStoredFile should not remove blobs in use")
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	. "github.com/StampWallet/backend/internal/database"
+)
+
+var (
+	ErrInvalidImage  = errors.New("invalid image")
+	ErrImageTooLarge = errors.New("image too large")
+)
+
+type ImageVariant string
+
+const (
+	ImageVariantThumb    ImageVariant = "thumb"
+	ImageVariantMedium   ImageVariant = "medium"
+	ImageVariantOriginal ImageVariant = "original"
+)
+
+var ImageVariants = []ImageVariant{ImageVariantThumb, ImageVariantMedium, ImageVariantOriginal}
+
+// Max dimensions of image variants. Images are scaled down to fit, keeping the aspect ratio.
+// Originals are shared by files of every purpose (see FileBlob), so they all have the same limit,
+// originalImageLimit.
+type ImageLimits struct {
+	Thumb  image.Point
+	Medium image.Point
+}
+
+var imageLimitsByPurpose = map[FilePurposeEnum]ImageLimits{
+	FilePurposeBusinessBanner: {
+		Thumb:  image.Pt(320, 180),
+		Medium: image.Pt(960, 540),
+	},
+	FilePurposeBusinessIcon: {
+		Thumb:  image.Pt(64, 64),
+		Medium: image.Pt(256, 256),
+	},
+	FilePurposeMenuImage: {
+		Thumb:  image.Pt(256, 256),
+		Medium: image.Pt(1280, 1280),
+	},
+	FilePurposeItemImage: {
+		Thumb:  image.Pt(128, 128),
+		Medium: image.Pt(512, 512),
+	},
+}
+
+// Limits of files without a known purpose
+var defaultImageLimits = ImageLimits{
+	Thumb:  image.Pt(256, 256),
+	Medium: image.Pt(1024, 1024),
+}
+
+// Max dimensions of originals, of every purpose
+var originalImageLimit = image.Pt(2560, 2560)
+
+// Max number of pixels of an uploaded image. Small files can decode into huge images.
+const maxImagePixels = 40_000_000
+
+const jpegQuality = 85
+
+func GetImageLimits(purpose FilePurposeEnum) ImageLimits {
+	if limits, ok := imageLimitsByPurpose[purpose]; ok {
+		return limits
+	}
+	return defaultImageLimits
+}
+
+// Returns storage key of variant of file with publicId, stored before deduplication.
+// Originals are stored under publicId.
+func variantKey(publicId string, variant ImageVariant) string {
+	if variant == ImageVariantOriginal {
+		return publicId
+	}
+	return publicId + "_" + string(variant)
+}
+
+// Returns storage key of variant of blob with hash, for files with purpose. Originals are the same for
+// every purpose and are stored under hash, other variants are stored separately for every purpose.
+func blobVariantKey(hash string, purpose FilePurposeEnum, variant ImageVariant) string {
+	if variant == ImageVariantOriginal {
+		return hash
+	}
+	return hash + "_" + string(purpose) + "_" + string(variant)
+}
+
+// Encoded variants of an uploaded image
+type processedImage struct {
+	contentType string
+	variants    map[ImageVariant][]byte
+}
+
+// Decodes data and encodes it again as every variant. Metadata (like EXIF) is not copied, JPEG
+// orientation is applied to the pixels instead. JPEGs stay JPEGs, everything else becomes a PNG.
+func processImage(data []byte, limits ImageLimits) (*processedImage, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	img := toRGBA(decoded)
+	result := &processedImage{contentType: "image/png", variants: map[ImageVariant][]byte{}}
+	if format == "jpeg" {
+		img = applyExifOrientation(img, jpegExifOrientation(data))
+		result.contentType = "image/jpeg"
+	}
+
+	sizes := map[ImageVariant]image.Point{
+		ImageVariantThumb:    limits.Thumb,
+		ImageVariantMedium:   limits.Medium,
+		ImageVariantOriginal: originalImageLimit,
+	}
+	for variant, size := range sizes {
+		var buf bytes.Buffer
+		resized := resizeToFit(img, size)
+		if result.contentType == "image/jpeg" {
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			err = png.Encode(&buf, resized)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.variants[variant] = buf.Bytes()
+	}
+	return result, nil
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// Scales img down to fit in size, averaging source pixels. Smaller images are returned as they are.
+func resizeToFit(img *image.RGBA, size image.Point) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= size.X && h <= size.Y {
+		return img
+	}
+	scale := math.Min(float64(size.X)/float64(w), float64(size.Y)/float64(h))
+	dw := int(math.Max(1, math.Round(float64(w)*scale)))
+	dh := int(math.Max(1, math.Round(float64(h)*scale)))
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0, sy1 := y*h/dh, (y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			sx0, sx1 := x*w/dw, (x+1)*w/dw
+			var sum [4]uint64
+			for sy := sy0; sy < sy1; sy++ {
+				i := img.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += uint64(img.Pix[i+c])
+					}
+					i += 4
+				}
+			}
+			n := uint64((sy1 - sy0) * (sx1 - sx0))
+			o := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[o+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// Returns EXIF orientation (1-8) of JPEG data, 1 if there is none
+func jpegExifOrientation(data []byte) int {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		marker := data[i+1]
+		// Metadata segments are before the start of scan
+		if data[i] != 0xFF || marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// Returns the orientation tag of the first IFD of TIFF data (EXIF payload)
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for j := 0; j < count; j++ {
+		entry := offset + 2 + j*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// Transforms img so that it's displayed correctly without the EXIF orientation tag
+func applyExifOrientation(img *image.RGBA, orientation int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	// Returns source pixel of destination pixel x, y
+	var source func(x, y int) (int, int)
+	switch orientation {
+	case 2: // mirrored horizontally
+		source = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // rotated 180
+		source = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // mirrored vertically
+		source = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // transposed
+		source = func(x, y int) (int, int) { return y, x }
+	case 6: // rotated 90 clockwise
+		source = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7: // transversed
+		source = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8: // rotated 90 counterclockwise
+		source = func(x, y int) (int, int) { return w - 1 - y, x }
+	default:
+		return img
+	}
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := source(x, y)
+			d, s := dst.PixOffset(x, y), img.PixOffset(sx, sy)
+			copy(dst.Pix[d:d+4], img.Pix[s:s+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	. "github.com/StampWallet/backend/internal/database"
+)
+
+func encodeTestPng(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.Nilf(t, png.Encode(&buf, img), "png.Encode returned an error")
+	return buf.Bytes()
+}
+
+// Returns a JPEG with an EXIF segment containing only the orientation tag
+func encodeTestJpeg(t *testing.T, img image.Image, orientation uint16) []byte {
+	var buf bytes.Buffer
+	require.Nilf(t, jpeg.Encode(&buf, img, nil), "jpeg.Encode returned an error")
+	data := buf.Bytes()
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112) // orientation tag
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)      // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)      // count
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	// Right after SOI
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+func decodedSize(t *testing.T, data []byte) image.Point {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	require.Nilf(t, err, "image.DecodeConfig returned an error")
+	return image.Pt(config.Width, config.Height)
+}
+
+func TestProcessImageVariants(t *testing.T) {
+	data := encodeTestPng(t, image.NewRGBA(image.Rect(0, 0, 3000, 1000)))
+
+	processed, err := processImage(data, GetImageLimits(FilePurposeBusinessBanner))
+	require.Nilf(t, err, "processImage returned an error")
+	require.Equalf(t, "image/png", processed.contentType, "PNGs should stay PNGs")
+	require.Equalf(t, image.Pt(2560, 853), decodedSize(t, processed.variants[ImageVariantOriginal]),
+		"original should fit in the limit of every purpose")
+	require.Equalf(t, image.Pt(960, 320), decodedSize(t, processed.variants[ImageVariantMedium]),
+		"medium variant has invalid size")
+	require.Equalf(t, image.Pt(320, 107), decodedSize(t, processed.variants[ImageVariantThumb]),
+		"thumb variant has invalid size")
+}
+
+func TestProcessImageSmall(t *testing.T) {
+	data := encodeTestPng(t, image.NewRGBA(image.Rect(0, 0, 40, 30)))
+
+	processed, err := processImage(data, GetImageLimits(FilePurposeBusinessIcon))
+	require.Nilf(t, err, "processImage returned an error")
+	for _, variant := range ImageVariants {
+		require.Equalf(t, image.Pt(40, 30), decodedSize(t, processed.variants[variant]),
+			"small images should not be scaled up")
+	}
+}
+
+func TestProcessImageExifOrientation(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	// Left half is white
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	data := encodeTestJpeg(t, img, 6)
+	require.Equalf(t, 6, jpegExifOrientation(data), "jpegExifOrientation should read the orientation")
+
+	processed, err := processImage(data, GetImageLimits(FilePurposeMenuImage))
+	require.Nilf(t, err, "processImage returned an error")
+	require.Equalf(t, "image/jpeg", processed.contentType, "JPEGs should stay JPEGs")
+	original := processed.variants[ImageVariantOriginal]
+	require.Equalf(t, image.Pt(20, 40), decodedSize(t, original), "image should be rotated")
+	require.Equalf(t, 1, jpegExifOrientation(original), "EXIF should be stripped")
+
+	// Rotated clockwise - the white half is on top
+	decoded, err := jpeg.Decode(bytes.NewReader(original))
+	require.Nilf(t, err, "jpeg.Decode returned an error")
+	top, _, _, _ := decoded.At(10, 5).RGBA()
+	bottom, _, _, _ := decoded.At(10, 35).RGBA()
+	require.Greaterf(t, top, uint32(0xf000), "top should be white")
+	require.Lessf(t, bottom, uint32(0x1000), "bottom should be black")
+}
+
+func TestProcessImageInvalid(t *testing.T) {
+	_, err := processImage([]byte("\x89PNG\x0D\x0A\x1A\x0A"), defaultImageLimits)
+	require.Equalf(t, ErrInvalidImage, err, "processImage should reject invalid images")
+}
+
+func TestResizeToFit(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	// Left half is white, right half is black
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	resized := resizeToFit(img, image.Pt(2, 2))
+	require.Equalf(t, image.Rect(0, 0, 2, 1), resized.Bounds(), "resizeToFit should keep the aspect ratio")
+	require.Equalf(t, color.RGBA{255, 255, 255, 255}, resized.RGBAAt(0, 0), "pixels should be averaged")
+	require.Equalf(t, color.RGBA{0, 0, 0, 0}, resized.RGBAAt(1, 0), "pixels should be averaged")
+}
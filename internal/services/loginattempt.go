@@ -0,0 +1,170 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	. "github.com/StampWallet/backend/internal/database"
+	. "github.com/StampWallet/backend/internal/utils"
+)
+
+// Failed login attempts of a single key
+type LoginAttempts struct {
+	Failures    uint      // failed attempts since the last reset or lockout
+	LastFailure time.Time // zero if there were no failed attempts
+	LockedUntil time.Time // zero if the key was never locked
+}
+
+// A LoginAttemptStore keeps track of failed login attempts. Keys are opaque to the store,
+// AuthManager uses emails and IP addresses of clients.
+type LoginAttemptStore interface {
+	// Returns failed login attempts of key. Returns zero LoginAttempts if key is not known.
+	Get(key string) (LoginAttempts, error)
+
+	// Records a failed login attempt of key and returns updated attempts. If the last failure
+	// of key happened more than forgetAfter ago, previous failures are forgotten.
+	RecordFailure(key string, forgetAfter time.Duration) (LoginAttempts, error)
+
+	// Locks key until the specified time and forgets its failures.
+	Lock(key string, until time.Time) error
+
+	// Forgets failures and lockout of key.
+	Reset(key string) error
+}
+
+// LoginAttemptStore backed by the login_attempts table. Attempts are shared by all instances
+// of the server.
+type PostgresLoginAttemptStore struct {
+	baseServices BaseServices
+}
+
+func CreatePostgresLoginAttemptStore(baseServices BaseServices) *PostgresLoginAttemptStore {
+	return &PostgresLoginAttemptStore{
+		baseServices: baseServices,
+	}
+}
+
+func convertLoginAttempt(attempt *LoginAttempt) LoginAttempts {
+	attempts := LoginAttempts{
+		Failures:    attempt.Failures,
+		LastFailure: attempt.LastFailure,
+	}
+	if attempt.LockedUntil.Valid {
+		attempts.LockedUntil = attempt.LockedUntil.Time
+	}
+	return attempts
+}
+
+func (store *PostgresLoginAttemptStore) Get(key string) (LoginAttempts, error) {
+	var attempt LoginAttempt
+	tx := store.baseServices.Database.First(&attempt, LoginAttempt{Key: key})
+	if err := tx.GetError(); err == gorm.ErrRecordNotFound {
+		return LoginAttempts{}, nil
+	} else if err != nil {
+		return LoginAttempts{}, fmt.Errorf("%s database failed to find login attempt: %+v", CallerFilename(), err)
+	}
+	return convertLoginAttempt(&attempt), nil
+}
+
+func (store *PostgresLoginAttemptStore) RecordFailure(key string, forgetAfter time.Duration) (LoginAttempts, error) {
+	now := time.Now()
+	var attempt LoginAttempt
+	// Single statement, so that concurrent attempts are all counted
+	tx := store.baseServices.Database.Raw(`INSERT INTO login_attempts
+			(created_at, updated_at, key, failures, last_failure)
+		VALUES (?, ?, ?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			updated_at = EXCLUDED.updated_at,
+			failures = CASE WHEN login_attempts.last_failure < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure = EXCLUDED.last_failure
+		RETURNING *`, now, now, key, now, now.Add(-forgetAfter)).
+		Scan(&attempt)
+	if err := tx.GetError(); err != nil {
+		return LoginAttempts{}, fmt.Errorf("%s database failed to record login attempt: %+v", CallerFilename(), err)
+	}
+	return convertLoginAttempt(&attempt), nil
+}
+
+func (store *PostgresLoginAttemptStore) Lock(key string, until time.Time) error {
+	tx := store.baseServices.Database.
+		Model(&LoginAttempt{}).
+		Where("key = ?", key).
+		Updates(map[string]interface{}{
+			"failures":     0,
+			"locked_until": sql.NullTime{Time: until, Valid: true},
+		})
+	if err := tx.GetError(); err != nil {
+		return fmt.Errorf("%s database failed to lock login attempts: %+v", CallerFilename(), err)
+	}
+	return nil
+}
+
+func (store *PostgresLoginAttemptStore) Reset(key string) error {
+	tx := store.baseServices.Database.
+		Model(&LoginAttempt{}).
+		Where("key = ?", key).
+		Updates(map[string]interface{}{
+			"failures":     0,
+			"locked_until": sql.NullTime{},
+		})
+	if err := tx.GetError(); err != nil {
+		return fmt.Errorf("%s database failed to reset login attempts: %+v", CallerFilename(), err)
+	}
+	return nil
+}
+
+// LoginAttemptStore that keeps attempts in memory of this process. Used in tests.
+type InMemoryLoginAttemptStore struct {
+	mutex    sync.Mutex
+	attempts map[string]LoginAttempts
+}
+
+func CreateInMemoryLoginAttemptStore() *InMemoryLoginAttemptStore {
+	return &InMemoryLoginAttemptStore{
+		attempts: map[string]LoginAttempts{},
+	}
+}
+
+func (store *InMemoryLoginAttemptStore) Get(key string) (LoginAttempts, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.attempts[key], nil
+}
+
+func (store *InMemoryLoginAttemptStore) RecordFailure(key string, forgetAfter time.Duration) (LoginAttempts, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	attempts := store.attempts[key]
+	if attempts.LastFailure.Before(now.Add(-forgetAfter)) {
+		attempts.Failures = 0
+	}
+	attempts.Failures += 1
+	attempts.LastFailure = now
+	store.attempts[key] = attempts
+	return attempts, nil
+}
+
+func (store *InMemoryLoginAttemptStore) Lock(key string, until time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	attempts := store.attempts[key]
+	attempts.Failures = 0
+	attempts.LockedUntil = until
+	store.attempts[key] = attempts
+	return nil
+}
+
+func (store *InMemoryLoginAttemptStore) Reset(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.attempts, key)
+	return nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/lithammer/shortuuid/v4"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/StampWallet/backend/internal/testutils"
+)
+
+// Runs the same checks against any LoginAttemptStore
+func testLoginAttemptStore(t *testing.T, store LoginAttemptStore) {
+	key := "email:" + shortuuid.New()
+
+	attempts, err := store.Get(key)
+	require.Nilf(t, err, "LoginAttemptStore.Get should return nil error")
+	require.Equalf(t, uint(0), attempts.Failures, "unknown key should have no failures")
+
+	for i := 1; i != 4; i++ {
+		attempts, err = store.RecordFailure(key, time.Hour)
+		require.Nilf(t, err, "LoginAttemptStore.RecordFailure should return nil error")
+		require.Equalf(t, uint(i), attempts.Failures, "LoginAttemptStore.RecordFailure should count failures")
+		require.Truef(t, TimeJustAroundNow(attempts.LastFailure), "LoginAttemptStore.RecordFailure should set LastFailure")
+	}
+
+	// Failures older than forgetAfter are forgotten
+	time.Sleep(10 * time.Millisecond)
+	attempts, err = store.RecordFailure(key, time.Millisecond)
+	require.Nilf(t, err, "LoginAttemptStore.RecordFailure should return nil error")
+	require.Equalf(t, uint(1), attempts.Failures, "LoginAttemptStore.RecordFailure should forget old failures")
+
+	until := time.Now().Add(time.Hour)
+	err = store.Lock(key, until)
+	require.Nilf(t, err, "LoginAttemptStore.Lock should return nil error")
+	attempts, err = store.Get(key)
+	require.Nilf(t, err, "LoginAttemptStore.Get should return nil error")
+	require.Equalf(t, uint(0), attempts.Failures, "LoginAttemptStore.Lock should forget failures")
+	require.WithinDurationf(t, until, attempts.LockedUntil, time.Millisecond, "LoginAttemptStore.Lock should set LockedUntil")
+
+	err = store.Reset(key)
+	require.Nilf(t, err, "LoginAttemptStore.Reset should return nil error")
+	attempts, err = store.Get(key)
+	require.Nilf(t, err, "LoginAttemptStore.Get should return nil error")
+	require.Truef(t, attempts.LockedUntil.IsZero(), "LoginAttemptStore.Reset should remove lockout")
+}
+
+func TestInMemoryLoginAttemptStore(t *testing.T) {
+	testLoginAttemptStore(t, CreateInMemoryLoginAttemptStore())
+}
+
+func TestPostgresLoginAttemptStore(t *testing.T) {
+	testLoginAttemptStore(t, CreatePostgresLoginAttemptStore(BaseServices{
+		Logger:   log.Default(),
+		Database: GetTestDatabase(),
+	}))
+}
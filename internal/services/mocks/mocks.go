@@ -6,7 +6,6 @@ package mock_services
 
 import (
 	io "io"
-	os "os"
 	reflect "reflect"
 	time "time"
 
@@ -69,6 +68,36 @@ func (mr *MockTokenServiceMockRecorder) Create(arg0, arg1, arg2 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockTokenService)(nil).Create), arg0, arg1, arg2)
 }
 
+// GetActive mocks base method.
+func (m *MockTokenService) GetActive(arg0 *database.User, arg1 database.TokenPurposeEnum) ([]database.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActive", arg0, arg1)
+	ret0, _ := ret[0].([]database.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActive indicates an expected call of GetActive.
+func (mr *MockTokenServiceMockRecorder) GetActive(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActive", reflect.TypeOf((*MockTokenService)(nil).GetActive), arg0, arg1)
+}
+
+// GetCreatedSince mocks base method.
+func (m *MockTokenService) GetCreatedSince(arg0 *database.User, arg1 database.TokenPurposeEnum, arg2 time.Time) ([]database.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCreatedSince", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]database.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCreatedSince indicates an expected call of GetCreatedSince.
+func (mr *MockTokenServiceMockRecorder) GetCreatedSince(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCreatedSince", reflect.TypeOf((*MockTokenService)(nil).GetCreatedSince), arg0, arg1, arg2)
+}
+
 // Invalidate mocks base method.
 func (m *MockTokenService) Invalidate(arg0 *database.Token) (*database.Token, error) {
 	m.ctrl.T.Helper()
@@ -84,6 +113,49 @@ func (mr *MockTokenServiceMockRecorder) Invalidate(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Invalidate", reflect.TypeOf((*MockTokenService)(nil).Invalidate), arg0)
 }
 
+// InvalidateAll mocks base method.
+func (m *MockTokenService) InvalidateAll(arg0 *database.User, arg1 database.TokenPurposeEnum) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InvalidateAll", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidateAll indicates an expected call of InvalidateAll.
+func (mr *MockTokenServiceMockRecorder) InvalidateAll(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidateAll", reflect.TypeOf((*MockTokenService)(nil).InvalidateAll), arg0, arg1)
+}
+
+// InvalidateOthers mocks base method.
+func (m *MockTokenService) InvalidateOthers(arg0 *database.Token) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InvalidateOthers", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidateOthers indicates an expected call of InvalidateOthers.
+func (mr *MockTokenServiceMockRecorder) InvalidateOthers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidateOthers", reflect.TypeOf((*MockTokenService)(nil).InvalidateOthers), arg0)
+}
+
+// SetSessionMetadata mocks base method.
+func (m *MockTokenService) SetSessionMetadata(arg0 *database.Token, arg1 services.SessionMetadata) (*database.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSessionMetadata", arg0, arg1)
+	ret0, _ := ret[0].(*database.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetSessionMetadata indicates an expected call of SetSessionMetadata.
+func (mr *MockTokenServiceMockRecorder) SetSessionMetadata(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSessionMetadata", reflect.TypeOf((*MockTokenService)(nil).SetSessionMetadata), arg0, arg1)
+}
+
 // WithTransaction mocks base method.
 func (m *MockTokenService) WithTransaction(arg0 database.GormDB) (services.TokenService, error) {
 	m.ctrl.T.Helper()
@@ -123,17 +195,69 @@ func (m *MockEmailService) EXPECT() *MockEmailServiceMockRecorder {
 }
 
 // Send mocks base method.
-func (m *MockEmailService) Send(arg0, arg1, arg2 string) error {
+func (m *MockEmailService) Send(arg0 string, arg1 services.EmailMessage) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Send", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "Send", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Send indicates an expected call of Send.
-func (mr *MockEmailServiceMockRecorder) Send(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockEmailServiceMockRecorder) Send(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockEmailService)(nil).Send), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockEmailService)(nil).Send), arg0, arg1)
+}
+
+// MockEmailOutbox is a mock of EmailOutbox interface.
+type MockEmailOutbox struct {
+	ctrl     *gomock.Controller
+	recorder *MockEmailOutboxMockRecorder
+}
+
+// MockEmailOutboxMockRecorder is the mock recorder for MockEmailOutbox.
+type MockEmailOutboxMockRecorder struct {
+	mock *MockEmailOutbox
+}
+
+// NewMockEmailOutbox creates a new mock instance.
+func NewMockEmailOutbox(ctrl *gomock.Controller) *MockEmailOutbox {
+	mock := &MockEmailOutbox{ctrl: ctrl}
+	mock.recorder = &MockEmailOutboxMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEmailOutbox) EXPECT() *MockEmailOutboxMockRecorder {
+	return m.recorder
+}
+
+// Enqueue mocks base method.
+func (m *MockEmailOutbox) Enqueue(arg0 string, arg1 services.EmailMessage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockEmailOutboxMockRecorder) Enqueue(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockEmailOutbox)(nil).Enqueue), arg0, arg1)
+}
+
+// WithTransaction mocks base method.
+func (m *MockEmailOutbox) WithTransaction(arg0 database.GormDB) (services.EmailOutbox, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTransaction", arg0)
+	ret0, _ := ret[0].(services.EmailOutbox)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WithTransaction indicates an expected call of WithTransaction.
+func (mr *MockEmailOutboxMockRecorder) WithTransaction(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTransaction", reflect.TypeOf((*MockEmailOutbox)(nil).WithTransaction), arg0)
 }
 
 // MockFileStorageService is a mock of FileStorageService interface.
@@ -160,34 +284,63 @@ func (m *MockFileStorageService) EXPECT() *MockFileStorageServiceMockRecorder {
 }
 
 // CreateStub mocks base method.
-func (m *MockFileStorageService) CreateStub(arg0 *database.User) (*database.FileMetadata, error) {
+func (m *MockFileStorageService) CreateStub(arg0 *database.User, arg1 *database.Business, arg2 database.FilePurposeEnum) (*database.FileMetadata, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateStub", arg0)
+	ret := m.ctrl.Call(m, "CreateStub", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*database.FileMetadata)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateStub indicates an expected call of CreateStub.
-func (mr *MockFileStorageServiceMockRecorder) CreateStub(arg0 interface{}) *gomock.Call {
+func (mr *MockFileStorageServiceMockRecorder) CreateStub(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateStub", reflect.TypeOf((*MockFileStorageService)(nil).CreateStub), arg0, arg1, arg2)
+}
+
+// GetBusinessUsage mocks base method.
+func (m *MockFileStorageService) GetBusinessUsage(arg0 *database.Business) (*services.FileUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBusinessUsage", arg0)
+	ret0, _ := ret[0].(*services.FileUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBusinessUsage indicates an expected call of GetBusinessUsage.
+func (mr *MockFileStorageServiceMockRecorder) GetBusinessUsage(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateStub", reflect.TypeOf((*MockFileStorageService)(nil).CreateStub), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBusinessUsage", reflect.TypeOf((*MockFileStorageService)(nil).GetBusinessUsage), arg0)
 }
 
 // GetData mocks base method.
-func (m *MockFileStorageService) GetData(arg0 string) (*os.File, string, error) {
+func (m *MockFileStorageService) GetData(arg0 string, arg1 services.ImageVariant) (*services.FileData, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetData", arg0)
-	ret0, _ := ret[0].(*os.File)
-	ret1, _ := ret[1].(string)
-	ret2, _ := ret[2].(error)
-	return ret0, ret1, ret2
+	ret := m.ctrl.Call(m, "GetData", arg0, arg1)
+	ret0, _ := ret[0].(*services.FileData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetData indicates an expected call of GetData.
-func (mr *MockFileStorageServiceMockRecorder) GetData(arg0 interface{}) *gomock.Call {
+func (mr *MockFileStorageServiceMockRecorder) GetData(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetData", reflect.TypeOf((*MockFileStorageService)(nil).GetData), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetData", reflect.TypeOf((*MockFileStorageService)(nil).GetData), arg0, arg1)
+}
+
+// ListStoredFiles mocks base method.
+func (m *MockFileStorageService) ListStoredFiles() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListStoredFiles")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListStoredFiles indicates an expected call of ListStoredFiles.
+func (mr *MockFileStorageServiceMockRecorder) ListStoredFiles() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStoredFiles", reflect.TypeOf((*MockFileStorageService)(nil).ListStoredFiles))
 }
 
 // RemoveFile mocks base method.
@@ -218,6 +371,20 @@ func (mr *MockFileStorageServiceMockRecorder) RemoveMetadata(arg0 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMetadata", reflect.TypeOf((*MockFileStorageService)(nil).RemoveMetadata), arg0)
 }
 
+// RemoveStoredFile mocks base method.
+func (m *MockFileStorageService) RemoveStoredFile(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveStoredFile", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveStoredFile indicates an expected call of RemoveStoredFile.
+func (mr *MockFileStorageServiceMockRecorder) RemoveStoredFile(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveStoredFile", reflect.TypeOf((*MockFileStorageService)(nil).RemoveStoredFile), arg0)
+}
+
 // Upload mocks base method.
 func (m *MockFileStorageService) Upload(arg0 database.FileMetadata, arg1 io.Reader, arg2 string) (*database.FileMetadata, error) {
 	m.ctrl.T.Helper()
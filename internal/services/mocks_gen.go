@@ -1,3 +1,3 @@
 package services
 
-//go:generate $GOPATH/bin/mockgen --destination mocks/mocks.go --build_flags=--mod=mod . TokenService,EmailService,FileStorageService
+//go:generate $GOPATH/bin/mockgen --destination mocks/mocks.go --build_flags=--mod=mod . TokenService,EmailService,EmailOutbox,FileStorageService
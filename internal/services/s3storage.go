@@ -0,0 +1,302 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/StampWallet/backend/internal/config"
+)
+
+var ErrInvalidS3Config = errors.New("invalid s3 config")
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3Service         = "s3"
+	s3DateFormat      = "20060102T150405Z"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// Stores files in a bucket of S3 or an S3-compatible server (like MinIO). Requests are signed with
+// AWS Signature Version 4.
+type S3StorageBackend struct {
+	endpoint   *url.URL
+	config     S3Config
+	httpClient *http.Client
+	// Returns current time, replaced in tests
+	now func() time.Time
+}
+
+func CreateS3StorageBackend(config S3Config) (*S3StorageBackend, error) {
+	if config.Endpoint == "" || config.Bucket == "" || config.Region == "" {
+		return nil, ErrInvalidS3Config
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %+v", ErrInvalidS3Config, err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, ErrInvalidS3Config
+	}
+
+	return &S3StorageBackend{
+		endpoint:   endpoint,
+		config:     config,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		now:        time.Now,
+	}, nil
+}
+
+// Returns URL of object under key
+func (backend *S3StorageBackend) objectUrl(key string) *url.URL {
+	objectUrl := *backend.endpoint
+	basePath := strings.TrimSuffix(objectUrl.Path, "/")
+	if backend.config.PathStyle {
+		objectUrl.Path = basePath + "/" + backend.config.Bucket + "/" + key
+	} else {
+		objectUrl.Host = backend.config.Bucket + "." + objectUrl.Host
+		objectUrl.Path = basePath + "/" + key
+	}
+	objectUrl.RawPath = ""
+	return &objectUrl
+}
+
+// URI encodes s as required by SigV4 - everything except unreserved characters is escaped
+func s3UriEncode(s string, encodeSlash bool) string {
+	var builder strings.Builder
+	for _, b := range []byte(s) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || (b == '/' && !encodeSlash) {
+			builder.WriteByte(b)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+	return builder.String()
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3UriEncode(key, true)+"="+s3UriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (backend *S3StorageBackend) credentialScope(now time.Time) string {
+	return now.Format("20060102") + "/" + backend.config.Region + "/" + s3Service + "/aws4_request"
+}
+
+// Returns SigV4 signature of canonicalRequest
+func (backend *S3StorageBackend) signature(now time.Time, canonicalRequest string) string {
+	stringToSign := s3Algorithm + "\n" +
+		now.Format(s3DateFormat) + "\n" +
+		backend.credentialScope(now) + "\n" +
+		sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSha256([]byte("AWS4"+backend.config.SecretAccessKey), now.Format("20060102"))
+	key = hmacSha256(key, backend.config.Region)
+	key = hmacSha256(key, s3Service)
+	key = hmacSha256(key, "aws4_request")
+	return hex.EncodeToString(hmacSha256(key, stringToSign))
+}
+
+// Signs req with the Authorization header. All headers already set on req are signed.
+func (backend *S3StorageBackend) sign(req *http.Request, payloadHash string, now time.Time) {
+	req.Header.Set("X-Amz-Date", now.Format(s3DateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := req.Method + "\n" +
+		s3UriEncode(req.URL.Path, false) + "\n" +
+		s3CanonicalQuery(req.URL.Query()) + "\n" +
+		canonicalHeaders.String() + "\n" +
+		signedHeaders + "\n" +
+		payloadHash
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, backend.config.AccessKeyId, backend.credentialScope(now), signedHeaders,
+		backend.signature(now, canonicalRequest)))
+}
+
+// Returns objectUrl with SigV4 query parameters, valid for ttl
+func (backend *S3StorageBackend) presign(method string, objectUrl *url.URL, ttl time.Duration,
+	now time.Time) string {
+	query := objectUrl.Query()
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", backend.config.AccessKeyId+"/"+backend.credentialScope(now))
+	query.Set("X-Amz-Date", now.Format(s3DateFormat))
+	query.Set("X-Amz-Expires", fmt.Sprint(int64(ttl/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := method + "\n" +
+		s3UriEncode(objectUrl.Path, false) + "\n" +
+		s3CanonicalQuery(query) + "\n" +
+		"host:" + objectUrl.Host + "\n" +
+		"\n" +
+		"host\n" +
+		s3UnsignedPayload
+
+	signedUrl := *objectUrl
+	signedUrl.RawQuery = s3CanonicalQuery(query) + "&X-Amz-Signature=" + backend.signature(now, canonicalRequest)
+	return signedUrl.String()
+}
+
+// Sends a signed request to object under key. Empty key sends the request to the bucket.
+func (backend *S3StorageBackend) do(method string, key string, query url.Values, body []byte,
+	header http.Header) (*http.Response, error) {
+	requestUrl := backend.objectUrl(key)
+	requestUrl.RawQuery = query.Encode()
+	req, err := http.NewRequest(method, requestUrl.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	backend.sign(req, sha256Hex(body), backend.now().UTC())
+	return backend.httpClient.Do(req)
+}
+
+// Returns an error describing an unexpected response
+func s3ResponseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s %s returned status %d: %s", resp.Request.Method, resp.Request.URL.Path,
+		resp.StatusCode, string(body))
+}
+
+func (backend *S3StorageBackend) Put(key string, data []byte, contentType string) error {
+	resp, err := backend.do("PUT", key, nil, data, http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3ResponseError(resp)
+	}
+	return nil
+}
+
+func (backend *S3StorageBackend) Get(key string) (io.ReadCloser, error) {
+	resp, err := backend.do("GET", key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrFileNotUploaded
+	} else if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, s3ResponseError(resp)
+	}
+	return resp.Body, nil
+}
+
+// S3 does not report if the deleted object existed, missing objects are not an error
+func (backend *S3StorageBackend) Delete(key string) error {
+	resp, err := backend.do("DELETE", key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3ResponseError(resp)
+	}
+	return nil
+}
+
+// Response of ListObjectsV2
+type s3ListBucketResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key string
+	}
+}
+
+func (backend *S3StorageBackend) List() ([]string, error) {
+	keys := []string{}
+	continuationToken := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+		resp, err := backend.do("GET", "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return nil, s3ResponseError(resp)
+		}
+
+		var result s3ListBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode ListObjectsV2 response: %w", err)
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+// Returns a presigned URL if S3Config.PresignTTL is set
+func (backend *S3StorageBackend) PresignGet(key string) (string, error) {
+	if backend.config.PresignTTL <= 0 {
+		return "", nil
+	}
+	return backend.presign("GET", backend.objectUrl(key), backend.config.PresignTTL, backend.now().UTC()), nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// A StorageBackend stores file contents under keys. FileStorageService keeps metadata of files in
+// the database, backends only store the bytes.
+type StorageBackend interface {
+	// Stores data under key, replacing the previous object
+	Put(key string, data []byte, contentType string) error
+	// Returns contents of object under key. Returns ErrFileNotUploaded if there is no such object.
+	Get(key string) (io.ReadCloser, error)
+	// Removes object under key. Backends that can't tell if the object existed may return nil for
+	// missing objects.
+	Delete(key string) error
+	// Returns keys of all stored objects
+	List() ([]string, error)
+	// Returns a temporary URL clients can download the object from directly.
+	// Returns an empty string if the object should be streamed from Get instead.
+	PresignGet(key string) (string, error)
+}
+
+// Stores files in a local directory. Works only if all instances of the server share the directory.
+type FilesystemStorageBackend struct {
+	basePath string
+}
+
+func addTrailingSlash(path string) string {
+	if path[len(path)-1] != '/' {
+		return path + "/"
+	}
+	return path
+}
+
+func CreateFilesystemStorageBackend(basePath string) (*FilesystemStorageBackend, error) {
+	fi, err := os.Stat(basePath)
+	if err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, ErrInvalidBasePath
+	}
+
+	return &FilesystemStorageBackend{
+		basePath: basePath,
+	}, nil
+}
+
+// Returns path of key, making sure that it's inside basePath
+func (backend *FilesystemStorageBackend) filePath(key string) (string, error) {
+	filePath := path.Join(backend.basePath, key)
+	if path.Dir(filePath) != path.Dir(addTrailingSlash(backend.basePath)) {
+		return "", errors.New("file base somehow changed")
+	}
+	return filePath, nil
+}
+
+func (backend *FilesystemStorageBackend) Put(key string, data []byte, contentType string) error {
+	filePath, err := backend.filePath(key)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, fs.FileMode(0660))
+}
+
+func (backend *FilesystemStorageBackend) Get(key string) (io.ReadCloser, error) {
+	filePath, err := backend.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, ErrFileNotUploaded
+	}
+	return file, nil
+}
+
+func (backend *FilesystemStorageBackend) Delete(key string) error {
+	filePath, err := backend.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil {
+		return ErrFileNotUploaded
+	}
+	return nil
+}
+
+func (backend *FilesystemStorageBackend) List() ([]string, error) {
+	entries, err := os.ReadDir(backend.basePath)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			keys = append(keys, entry.Name())
+		}
+	}
+	return keys, nil
+}
+
+func (backend *FilesystemStorageBackend) PresignGet(key string) (string, error) {
+	return "", nil
+}
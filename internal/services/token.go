@@ -1,6 +1,7 @@
 package services
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"time"
@@ -17,6 +18,12 @@ var ErrUnknownToken = errors.New("Invalid token")
 var ErrTokenExpired = errors.New("Token expired")
 var ErrTokenUsed = errors.New("Token used")
 
+// Details of the client that uses a session token
+type SessionMetadata struct {
+	UserAgent string
+	IpAddress string
+}
+
 // A TokenService is a service for managing tokens. Tokens are used for authorization instead of actual
 // user credentials in scenarios where temporary, disposable credentials are desirable.
 // Example scenarios: identifying user session after login, identifying the user from a verification email.
@@ -24,9 +31,11 @@ type TokenService interface {
 	// Creates a new token. Returns database.Token and token secret (hashed secret is stored in the database).
 	// Token secret is confidential and should not be stored on the backend.
 	// purpose controls Check behavior.
-	// If TokenPurpose is TokenPurposeEmail, token is invalidated after Check is called on the token.
+	// If TokenPurpose is TokenPurposeEmail, TokenPurposePasswordReset, TokenPurposeEmailChange or
+	// TokenPurposeEmailRevert, token is invalidated after Check is called on the token.
 	// If TokenPurpose is TokenPurposeSession, token expiration date is changed on each Check call
-	// (the date is moved exactly a week from call date, although that could change any time).
+	// (the date is moved exactly a week from call date, although that could change any time)
+	// and LastUsed is set to the call date.
 	Create(user *User, purpose TokenPurposeEnum, expiration time.Time) (*Token, string, error)
 
 	// Checks if token with tokenId exists in the database.
@@ -36,6 +45,23 @@ type TokenService interface {
 	// Invalidates the token - the token cannot be used after that, Check will return ErrUnknownToken.
 	Invalidate(token *Token) (*Token, error)
 
+	// Invalidates all tokens of user with purpose.
+	InvalidateAll(user *User, purpose TokenPurposeEnum) error
+
+	// Invalidates all tokens of token owner with the same purpose as token, except token itself.
+	InvalidateOthers(token *Token) error
+
+	// Returns tokens of user with purpose that can still be used (not expired, not recalled),
+	// most recently used first.
+	GetActive(user *User, purpose TokenPurposeEnum) ([]Token, error)
+
+	// Returns tokens of user with purpose created after since, including used, expired and recalled ones,
+	// oldest first. Meant for rate limiting of tokens sent by email.
+	GetCreatedSince(user *User, purpose TokenPurposeEnum, since time.Time) ([]Token, error)
+
+	// Replaces session metadata of token.
+	SetSessionMetadata(token *Token, metadata SessionMetadata) (*Token, error)
+
 	// Returns TokenService that will execute queries within transaction tx.
 	// NOTE This won't work as expected if TokenService is using a different database.
 	// Maybe returning a rollback func would be a good idea. On the other hand, currently
@@ -55,6 +81,15 @@ func CreateTokenServiceImpl(baseServices BaseServices) *TokenServiceImpl {
 	}
 }
 
+// Tokens with these purposes can be checked only once
+func isSingleUse(purpose TokenPurposeEnum) bool {
+	switch purpose {
+	case TokenPurposeEmail, TokenPurposePasswordReset, TokenPurposeEmailChange, TokenPurposeEmailRevert:
+		return true
+	}
+	return false
+}
+
 func (service *TokenServiceImpl) Create(user *User, purpose TokenPurposeEnum, expiration time.Time) (*Token, string, error) {
 	// Create token secret and hash it
 	secret := shortuuid.New()
@@ -112,10 +147,11 @@ func (service *TokenServiceImpl) Check(tokenId string, tokenSecret string) (*Tok
 	}
 
 	// Check if token is valid for it's purpose
-	if token.TokenPurpose == TokenPurposeEmail && token.Used {
+	if isSingleUse(token.TokenPurpose) && token.Used {
 		return nil, ErrTokenUsed
 	} else if token.TokenPurpose == TokenPurposeSession {
 		token.Expires = time.Now().Add(7 * 24 * time.Hour)
+		token.LastUsed = sql.NullTime{Time: time.Now(), Valid: true}
 	}
 
 	// Update the token in the database
@@ -139,6 +175,70 @@ func (service *TokenServiceImpl) Invalidate(token *Token) (*Token, error) {
 	return token, nil
 }
 
+func (service *TokenServiceImpl) InvalidateAll(user *User, purpose TokenPurposeEnum) error {
+	tx := service.baseServices.Database.
+		Model(&Token{}).
+		Where("owner_id = ? AND token_purpose = ? AND recalled = false", user.ID, purpose).
+		Update("recalled", true)
+	if err := tx.GetError(); err != nil {
+		return fmt.Errorf("%s database failed to update tokens: %+v", CallerFilename(), err)
+	}
+	return nil
+}
+
+func (service *TokenServiceImpl) InvalidateOthers(token *Token) error {
+	tx := service.baseServices.Database.
+		Model(&Token{}).
+		Where("owner_id = ? AND token_purpose = ? AND recalled = false AND id <> ?",
+			token.OwnerId, token.TokenPurpose, token.ID).
+		Update("recalled", true)
+	if err := tx.GetError(); err != nil {
+		return fmt.Errorf("%s database failed to update tokens: %+v", CallerFilename(), err)
+	}
+	return nil
+}
+
+func (service *TokenServiceImpl) GetActive(user *User, purpose TokenPurposeEnum) ([]Token, error) {
+	var tokens []Token
+	tx := service.baseServices.Database.
+		Where("owner_id = ? AND token_purpose = ? AND recalled = false AND expires > ?",
+			user.ID, purpose, time.Now()).
+		Order("last_used desc nulls last, created_at desc").
+		Find(&tokens)
+	if err := tx.GetError(); err != nil {
+		return nil, fmt.Errorf("%s database failed to find tokens: %+v", CallerFilename(), err)
+	}
+	return tokens, nil
+}
+
+func (service *TokenServiceImpl) GetCreatedSince(user *User, purpose TokenPurposeEnum, since time.Time) ([]Token, error) {
+	var tokens []Token
+	tx := service.baseServices.Database.
+		Where("owner_id = ? AND token_purpose = ? AND created_at > ?", user.ID, purpose, since).
+		Order("created_at").
+		Find(&tokens)
+	if err := tx.GetError(); err != nil {
+		return nil, fmt.Errorf("%s database failed to find tokens: %+v", CallerFilename(), err)
+	}
+	return tokens, nil
+}
+
+func (service *TokenServiceImpl) SetSessionMetadata(token *Token, metadata SessionMetadata) (*Token, error) {
+	token.UserAgent = metadata.UserAgent
+	token.IpAddress = metadata.IpAddress
+
+	tx := service.baseServices.Database.
+		Model(token).
+		Updates(map[string]interface{}{
+			"user_agent": token.UserAgent,
+			"ip_address": token.IpAddress,
+		})
+	if err := tx.GetError(); err != nil {
+		return nil, fmt.Errorf("%s database failed to update token: %+v", CallerFilename(), err)
+	}
+	return token, nil
+}
+
 func (service *TokenServiceImpl) WithTransaction(tx GormDB) (TokenService, error) {
 	// Get current database and tx database
 	txDb, err := tx.DB()
@@ -132,6 +132,8 @@ func TestTokenServiceCheckValidSessionToken(t *testing.T) {
 	require.Equalf(t, nToken.User.PublicId, user.PublicId, "TokenService.Check should return the expected user")
 	require.Truef(t, time.Now().Add((7*24*time.Hour)-time.Hour).Before(nToken.Expires), "session token expiration date should be updated")
 
+	require.Truef(t, nToken.LastUsed.Valid && TimeJustAroundNow(nToken.LastUsed.Time), "session token last used date should be updated")
+
 	_, err = service.Check(token.TokenId, secret)
 	require.Nilf(t, err, "TokenService.Check should not return ErrTokenUsed on used session token")
 }
@@ -184,3 +186,131 @@ func TestTokenServiceInvalidate(t *testing.T) {
 	require.Nilf(t, tx.GetError(), "Database.First should return a nil error")
 	require.Equalf(t, true, dbToken.Recalled, "Token in the database should be recalled")
 }
+
+func TestTokenServiceInvalidateAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetTokenService(ctrl)
+	user := GetTestUser(service.baseServices.Database)
+	sessionToken, sessionSecret := GetTestSessionToken(service.baseServices.Database, user, time.Now().Add(time.Hour))
+	emailToken, emailSecret := GetTestToken(service.baseServices.Database, user)
+	otherUser := GetTestUser(service.baseServices.Database)
+	otherToken, otherSecret := GetTestSessionToken(service.baseServices.Database, otherUser, time.Now().Add(time.Hour))
+
+	err := service.InvalidateAll(user, TokenPurposeSession)
+	require.Nilf(t, err, "TokenService.InvalidateAll should return nil error")
+
+	_, err = service.Check(sessionToken.TokenId, sessionSecret)
+	require.Equalf(t, ErrUnknownToken, err, "session token of user should be invalidated")
+	_, err = service.Check(emailToken.TokenId, emailSecret)
+	require.Nilf(t, err, "tokens with other purpose should not be invalidated")
+	_, err = service.Check(otherToken.TokenId, otherSecret)
+	require.Nilf(t, err, "tokens of other users should not be invalidated")
+}
+
+func TestTokenServiceCheckUsedPasswordResetToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetTokenService(ctrl)
+	user := GetTestUser(service.baseServices.Database)
+	token, secret, err := service.Create(user, TokenPurposePasswordReset, time.Now().Add(time.Hour))
+	require.Nilf(t, err, "TokenService.Create should return nil error")
+
+	_, err = service.Check(token.TokenId, secret)
+	require.Nilf(t, err, "TokenService.Check should return nil error")
+	_, err = service.Check(token.TokenId, secret)
+	require.Equalf(t, ErrTokenUsed, err, "password reset token should be single use")
+}
+
+func TestTokenServiceCheckUsedEmailChangeTokens(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetTokenService(ctrl)
+	user := GetTestUser(service.baseServices.Database)
+	for _, purpose := range []TokenPurposeEnum{TokenPurposeEmailChange, TokenPurposeEmailRevert} {
+		token, secret, err := service.Create(user, purpose, time.Now().Add(time.Hour))
+		require.Nilf(t, err, "TokenService.Create should return nil error")
+
+		_, err = service.Check(token.TokenId, secret)
+		require.Nilf(t, err, "TokenService.Check should return nil error")
+		_, err = service.Check(token.TokenId, secret)
+		require.Equalf(t, ErrTokenUsed, err, "%s token should be single use", purpose)
+	}
+}
+
+func TestTokenServiceInvalidateOthers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetTokenService(ctrl)
+	user := GetTestUser(service.baseServices.Database)
+	currentToken, currentSecret := GetTestSessionToken(service.baseServices.Database, user, time.Now().Add(time.Hour))
+	sessionToken, sessionSecret := GetTestSessionToken(service.baseServices.Database, user, time.Now().Add(time.Hour))
+	emailToken, emailSecret := GetTestToken(service.baseServices.Database, user)
+
+	err := service.InvalidateOthers(currentToken)
+	require.Nilf(t, err, "TokenService.InvalidateOthers should return nil error")
+
+	_, err = service.Check(currentToken.TokenId, currentSecret)
+	require.Nilf(t, err, "token passed to InvalidateOthers should not be invalidated")
+	_, err = service.Check(sessionToken.TokenId, sessionSecret)
+	require.Equalf(t, ErrUnknownToken, err, "other session token of user should be invalidated")
+	_, err = service.Check(emailToken.TokenId, emailSecret)
+	require.Nilf(t, err, "tokens with other purpose should not be invalidated")
+}
+
+func TestTokenServiceGetActive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetTokenService(ctrl)
+	user := GetTestUser(service.baseServices.Database)
+	oldToken, _ := GetTestSessionToken(service.baseServices.Database, user, time.Now().Add(time.Hour))
+	usedToken, usedSecret := GetTestSessionToken(service.baseServices.Database, user, time.Now().Add(time.Hour))
+	GetTestSessionToken(service.baseServices.Database, user, time.Now().Add(-time.Hour))
+	recalledToken, _ := GetTestSessionToken(service.baseServices.Database, user, time.Now().Add(time.Hour))
+	_, err := service.Invalidate(recalledToken)
+	require.Nilf(t, err, "TokenService.Invalidate should return nil error")
+	_, err = service.Check(usedToken.TokenId, usedSecret)
+	require.Nilf(t, err, "TokenService.Check should return nil error")
+
+	tokens, err := service.GetActive(user, TokenPurposeSession)
+	require.Nilf(t, err, "TokenService.GetActive should return nil error")
+	require.Lenf(t, tokens, 2, "TokenService.GetActive should not return expired and recalled tokens")
+	require.Equalf(t, usedToken.TokenId, tokens[0].TokenId, "TokenService.GetActive should return recently used tokens first")
+	require.Equalf(t, oldToken.TokenId, tokens[1].TokenId, "TokenService.GetActive returned unexpected token")
+}
+
+func TestTokenServiceGetCreatedSince(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetTokenService(ctrl)
+	user := GetTestUser(service.baseServices.Database)
+	since := time.Now()
+	firstToken, _, err := service.Create(user, TokenPurposeEmail, time.Now().Add(time.Hour))
+	require.Nilf(t, err, "TokenService.Create should return nil error")
+	secondToken, _, err := service.Create(user, TokenPurposeEmail, time.Now().Add(time.Hour))
+	require.Nilf(t, err, "TokenService.Create should return nil error")
+	_, err = service.Invalidate(secondToken)
+	require.Nilf(t, err, "TokenService.Invalidate should return nil error")
+	_, _, err = service.Create(user, TokenPurposePasswordReset, time.Now().Add(time.Hour))
+	require.Nilf(t, err, "TokenService.Create should return nil error")
+
+	tokens, err := service.GetCreatedSince(user, TokenPurposeEmail, since)
+	require.Nilf(t, err, "TokenService.GetCreatedSince should return nil error")
+	require.Lenf(t, tokens, 2, "TokenService.GetCreatedSince should return recalled tokens and no tokens with other purpose")
+	require.Equalf(t, firstToken.TokenId, tokens[0].TokenId, "TokenService.GetCreatedSince should return oldest tokens first")
+	require.Equalf(t, secondToken.TokenId, tokens[1].TokenId, "TokenService.GetCreatedSince returned unexpected token")
+
+	tokens, err = service.GetCreatedSince(user, TokenPurposeEmail, time.Now())
+	require.Nilf(t, err, "TokenService.GetCreatedSince should return nil error")
+	require.Lenf(t, tokens, 0, "TokenService.GetCreatedSince should not return older tokens")
+}
+
+func TestTokenServiceSetSessionMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := GetTokenService(ctrl)
+	user := GetTestUser(service.baseServices.Database)
+	token, _ := GetTestSessionToken(service.baseServices.Database, user, time.Now().Add(time.Hour))
+
+	_, err := service.SetSessionMetadata(token, SessionMetadata{UserAgent: "test agent", IpAddress: "192.0.2.1"})
+	require.Nilf(t, err, "TokenService.SetSessionMetadata should return nil error")
+
+	var dbToken Token
+	tx := service.baseServices.Database.First(&dbToken, Token{TokenId: token.TokenId})
+	require.Nilf(t, tx.GetError(), "Database.First should return a nil error")
+	require.Equalf(t, "test agent", dbToken.UserAgent, "Token in the database has invalid user agent")
+	require.Equalf(t, "192.0.2.1", dbToken.IpAddress, "Token in the database has invalid ip address")
+}
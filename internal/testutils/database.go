@@ -11,6 +11,7 @@ import (
 	"gorm.io/gorm/logger"
 
 	. "github.com/StampWallet/backend/internal/database"
+	"github.com/StampWallet/backend/internal/database/migrations"
 )
 
 // Wipes the database
@@ -35,7 +36,7 @@ func RecreateDatabase(db GormDB, databaseName string) error {
 	  select 'truncate ' || string_agg(format('%I.%I', schemaname, tablename), ',') || ' cascade'
 		into l_stmt
 	  from pg_tables
-	  where schemaname in ('public') and tablename != 'spatial_ref_sys';
+	  where schemaname in ('public') and tablename not in ('spatial_ref_sys', 'schema_migrations', 'categories');
 
 	  if l_stmt is not null then 
 		execute l_stmt;
@@ -55,8 +56,12 @@ func RecreateDatabase(db GormDB, databaseName string) error {
 		}
 	}
 
-	// Applies auto migration
-	if err := AutoMigrate(db); err != nil {
+	// Applies migrations
+	embedded, err := migrations.EmbeddedMigrations()
+	if err != nil {
+		return err
+	}
+	if _, err := migrations.CreateMigrator(db, embedded, log.Default()).Up(); err != nil {
 		return err
 	}
 	return nil
@@ -64,11 +64,35 @@ func GetTestBusiness(db GormDB, user *User) *Business {
 		User:           user,
 	}
 	Save(db, &business)
+	// Like BusinessManager.Create, the first location is at the registered address. Not attached to business
+	GetTestBusinessLocation(db, &business, business.GPSCoordinates)
 	user.Business = &business
 	business.User = user
 	return &business
 }
 
+func GetTestBusinessLocation(db GormDB, business *Business, coordinates GPSCoordinates) *BusinessLocation {
+	location := BusinessLocation{
+		PublicId:       shortuuid.New(),
+		BusinessId:     business.ID,
+		Name:           business.Name,
+		Address:        business.Address,
+		GPSCoordinates: coordinates,
+	}
+	Save(db, &location)
+	return &location
+}
+
+// Moves business and all of its locations to coordinates
+func MoveTestBusiness(db GormDB, business *Business, coordinates GPSCoordinates) {
+	business.GPSCoordinates = coordinates
+	Save(db, business)
+	tx := db.Model(&BusinessLocation{}).Where("business_id = ?", business.ID).Update("gps_coordinates", coordinates)
+	if err := tx.GetError(); err != nil {
+		panic(fmt.Errorf("failed to move locations of business: %w", err))
+	}
+}
+
 func GetDefaultBusiness(user *User) *Business {
 	return GetTestBusiness(nil, user)
 }
@@ -176,6 +200,18 @@ func GetTestOwnedItemUsed(db GormDB, itemDefinition *ItemDefinition, card *Virtu
 	return &ownedItem
 }
 
+// Creates a lot of points for virtualCard, created at created. Does not change points of virtualCard.
+func GetTestPointsLot(db GormDB, virtualCard *VirtualCard, points uint, created time.Time) *PointsLot {
+	lot := PointsLot{
+		Model:         gorm.Model{CreatedAt: created},
+		VirtualCardId: virtualCard.ID,
+		Points:        points,
+		Remaining:     points,
+	}
+	Save(db, &lot)
+	return &lot
+}
+
 func GetDefaultOwnedItem(itemDefinition *ItemDefinition, card *VirtualCard) *OwnedItem {
 	return GetTestOwnedItem(nil, itemDefinition, card)
 }
@@ -196,6 +232,7 @@ func GetTestTransaction(db GormDB, virtualCard *VirtualCard, items []OwnedItem)
 	transaction := Transaction{
 		PublicId:      shortuuid.New(),
 		VirtualCardId: virtualCard.ID,
+		BusinessId:    virtualCard.BusinessId,
 		Code:          strconv.Itoa(rand.Intn(math.MaxInt)),
 		State:         TransactionStateStarted,
 		AddedPoints:   0,
@@ -284,3 +321,28 @@ func GetTestMenuImage(db GormDB, business *Business) *MenuImage {
 	Save(db, &menuImage)
 	return &menuImage
 }
+
+func GetTestBusinessMember(db GormDB, business *Business, user *User, role BusinessMemberRoleEnum) *BusinessMember {
+	member := BusinessMember{
+		PublicId:   shortuuid.New(),
+		BusinessId: business.ID,
+		OwnerId:    user.ID,
+		Role:       role,
+	}
+	Save(db, &member)
+	member.Business = business
+	member.User = user
+	return &member
+}
+
+func GetTestBusinessInvitation(db GormDB, business *Business, email string, role BusinessMemberRoleEnum) *BusinessInvitation {
+	invitation := BusinessInvitation{
+		PublicId:   shortuuid.New(),
+		BusinessId: business.ID,
+		Email:      email,
+		Role:       role,
+	}
+	Save(db, &invitation)
+	invitation.Business = business
+	return &invitation
+}
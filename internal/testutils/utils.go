@@ -96,7 +96,7 @@ func (TimeJustBeforeNow) String() string {
 }
 
 type Copyable interface {
-	int32 | uint64 | uint | string | bool | time.Time | database.GPSCoordinates
+	int32 | uint64 | uint | float64 | string | bool | time.Time | database.GPSCoordinates
 }
 
 func Ptr[T Copyable](s T) *T {
@@ -154,6 +154,12 @@ func (tc *TestContextBuilder) SetUser(u *database.User) *TestContextBuilder {
 	return tc
 }
 
+// Inserts session token into the context, like AuthMiddleware does
+func (tc *TestContextBuilder) SetSession(t *database.Token) *TestContextBuilder {
+	tc.Context.Set("token", t)
+	return tc
+}
+
 func (tc *TestContextBuilder) SetDefaultUser() *TestContextBuilder {
 	return tc.SetUser(GetDefaultUser())
 }
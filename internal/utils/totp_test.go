@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 appendix B, SHA1 test vectors (last 6 digits)
+func TestTotpCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		code, err := TotpCode(secret, TotpStep(time.Unix(unix, 0)))
+		require.Nilf(t, err, "TotpCode should return nil error")
+		require.Equalf(t, expected, code, "TotpCode should match the RFC 6238 vector for %d", unix)
+	}
+}
+
+func TestCheckTotpCode(t *testing.T) {
+	secret, err := GenerateTotpSecret()
+	require.Nilf(t, err, "GenerateTotpSecret should return nil error")
+
+	now := time.Now()
+	code, err := TotpCode(secret, TotpStep(now))
+	require.Nilf(t, err, "TotpCode should return nil error")
+
+	step, ok := CheckTotpCode(secret, code, now)
+	require.Truef(t, ok, "CheckTotpCode should accept the current code")
+	require.Equalf(t, TotpStep(now), step, "CheckTotpCode should return the step of the code")
+
+	_, ok = CheckTotpCode(secret, code, now.Add(TotpPeriod))
+	require.Truef(t, ok, "CheckTotpCode should accept the previous code")
+
+	_, ok = CheckTotpCode(secret, code, now.Add(5*TotpPeriod))
+	require.Falsef(t, ok, "CheckTotpCode should not accept old codes")
+
+	_, ok = CheckTotpCode(secret, "12345", now)
+	require.Falsef(t, ok, "CheckTotpCode should not accept codes of wrong length")
+}
+
+func TestTotpUri(t *testing.T) {
+	uri := TotpUri("StampWallet", "user@example.com", "ABCDEF")
+	require.Truef(t, strings.HasPrefix(uri, "otpauth://totp/StampWallet:user@example.com?"),
+		"TotpUri should start with the label, got %s", uri)
+	require.Containsf(t, uri, "secret=ABCDEF", "TotpUri should contain the secret")
+	require.Containsf(t, uri, "issuer=StampWallet", "TotpUri should contain the issuer")
+}
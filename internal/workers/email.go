@@ -0,0 +1,55 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	. "github.com/StampWallet/backend/internal/managers"
+)
+
+// Max emails delivered in a single run
+const emailOutboxBatchSize = 100
+
+// An EmailOutboxJob periodically delivers emails queued by services.EmailOutbox.
+// See EmailOutboxManager.DeliverPending.
+type EmailOutboxJob struct {
+	emailOutboxManager EmailOutboxManager
+	interval           time.Duration
+	logger             *log.Logger
+}
+
+// Interval of EmailOutboxJob if the configured one is not positive. Unlike other jobs, it can't be
+// disabled, queued emails would never be delivered.
+const defaultEmailOutboxInterval = 10 * time.Second
+
+func CreateEmailOutboxJob(emailOutboxManager EmailOutboxManager, interval time.Duration,
+	logger *log.Logger) *EmailOutboxJob {
+	if interval <= 0 {
+		logger.Printf("email outbox interval %s is not positive, using %s", interval, defaultEmailOutboxInterval)
+		interval = defaultEmailOutboxInterval
+	}
+	return &EmailOutboxJob{
+		emailOutboxManager: emailOutboxManager,
+		interval:           interval,
+		logger:             logger,
+	}
+}
+
+// Delivers a batch of pending emails once
+func (job *EmailOutboxJob) Run() {
+	report, err := job.emailOutboxManager.DeliverPending(emailOutboxBatchSize)
+	if err != nil {
+		job.logger.Printf("emailOutboxManager.DeliverPending returned an error: %+v", err)
+		return
+	}
+	if report.Sent != 0 || report.Retried != 0 || report.DeadLettered != 0 {
+		job.logger.Printf("sent %d emails, %d will be retried, %d dead-lettered",
+			report.Sent, report.Retried, report.DeadLettered)
+	}
+}
+
+// Starts the job in background. The job stops when ctx is cancelled.
+func (job *EmailOutboxJob) Start(ctx context.Context) {
+	startPeriodically(ctx, job.logger, job.interval, job.Run)
+}
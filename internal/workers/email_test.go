@@ -0,0 +1,35 @@
+package workers
+
+import (
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/StampWallet/backend/internal/managers"
+	. "github.com/StampWallet/backend/internal/managers/mocks"
+)
+
+func TestEmailOutboxJobRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	emailOutboxManager := NewMockEmailOutboxManager(ctrl)
+	job := CreateEmailOutboxJob(emailOutboxManager, time.Minute, log.Default())
+
+	emailOutboxManager.EXPECT().DeliverPending(emailOutboxBatchSize).
+		Return(&EmailDeliveryReport{Sent: 2, Retried: 1}, nil)
+	job.Run()
+
+	// errors are only logged
+	emailOutboxManager.EXPECT().DeliverPending(emailOutboxBatchSize).Return(nil, errors.New("test error"))
+	job.Run()
+}
+
+func TestCreateEmailOutboxJobDefaultInterval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	job := CreateEmailOutboxJob(NewMockEmailOutboxManager(ctrl), 0, log.Default())
+	if job.interval != defaultEmailOutboxInterval {
+		t.Errorf("zero interval should be replaced with the default, got %s", job.interval)
+	}
+}
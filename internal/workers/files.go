@@ -0,0 +1,43 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	. "github.com/StampWallet/backend/internal/managers"
+)
+
+// A FileGCJob periodically removes files that nothing refers to. See FileGCManager.CollectGarbage.
+type FileGCJob struct {
+	fileGCManager FileGCManager
+	interval      time.Duration
+	logger        *log.Logger
+}
+
+func CreateFileGCJob(fileGCManager FileGCManager, interval time.Duration, logger *log.Logger) *FileGCJob {
+	return &FileGCJob{
+		fileGCManager: fileGCManager,
+		interval:      interval,
+		logger:        logger,
+	}
+}
+
+// Removes unreferenced files once
+func (job *FileGCJob) Run() {
+	report, err := job.fileGCManager.CollectGarbage(false)
+	if err != nil {
+		job.logger.Printf("fileGCManager.CollectGarbage returned an error: %+v", err)
+		return
+	}
+	removed := len(report.AbandonedStubs) + len(report.UnreferencedFiles) + len(report.OrphanedFiles)
+	if removed != 0 {
+		job.logger.Printf("removed %d abandoned stubs, %d unreferenced files, %d orphaned files, %d failed",
+			len(report.AbandonedStubs), len(report.UnreferencedFiles), len(report.OrphanedFiles), report.Failed)
+	}
+}
+
+// Starts the job in background. The job stops when ctx is cancelled.
+func (job *FileGCJob) Start(ctx context.Context) {
+	startPeriodically(ctx, job.logger, job.interval, job.Run)
+}
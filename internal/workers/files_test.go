@@ -0,0 +1,30 @@
+package workers
+
+import (
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/StampWallet/backend/internal/managers"
+	. "github.com/StampWallet/backend/internal/managers/mocks"
+)
+
+func TestFileGCJobRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	fileGCManager := NewMockFileGCManager(ctrl)
+	job := CreateFileGCJob(fileGCManager, time.Hour, log.Default())
+
+	// the job never runs in dry run mode
+	fileGCManager.EXPECT().CollectGarbage(false).Return(&FileGCReport{
+		AbandonedStubs: []string{"a"},
+		OrphanedFiles:  []string{"b", "c"},
+	}, nil)
+	job.Run()
+
+	// errors are only logged
+	fileGCManager.EXPECT().CollectGarbage(false).Return(nil, errors.New("test error"))
+	job.Run()
+}
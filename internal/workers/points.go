@@ -0,0 +1,43 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	. "github.com/StampWallet/backend/internal/managers"
+)
+
+// A PointsExpiryJob periodically expires points older than expiry policies of their businesses.
+// See PointsLedgerManager.ExpirePoints.
+type PointsExpiryJob struct {
+	pointsLedgerManager PointsLedgerManager
+	interval            time.Duration
+	logger              *log.Logger
+}
+
+func CreatePointsExpiryJob(pointsLedgerManager PointsLedgerManager, interval time.Duration,
+	logger *log.Logger) *PointsExpiryJob {
+	return &PointsExpiryJob{
+		pointsLedgerManager: pointsLedgerManager,
+		interval:            interval,
+		logger:              logger,
+	}
+}
+
+// Expires points once
+func (job *PointsExpiryJob) Run() {
+	expired, err := job.pointsLedgerManager.ExpirePoints()
+	if err != nil {
+		job.logger.Printf("pointsLedgerManager.ExpirePoints returned an error: %+v", err)
+		return
+	}
+	if expired != 0 {
+		job.logger.Printf("expired %d points", expired)
+	}
+}
+
+// Starts the job in background. The job stops when ctx is cancelled.
+func (job *PointsExpiryJob) Start(ctx context.Context) {
+	startPeriodically(ctx, job.logger, job.interval, job.Run)
+}
@@ -0,0 +1,25 @@
+package workers
+
+import (
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/StampWallet/backend/internal/managers/mocks"
+)
+
+func TestPointsExpiryJobRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	pointsLedgerManager := NewMockPointsLedgerManager(ctrl)
+	job := CreatePointsExpiryJob(pointsLedgerManager, time.Hour, log.Default())
+
+	pointsLedgerManager.EXPECT().ExpirePoints().Return(uint64(20), nil)
+	job.Run()
+
+	// errors are only logged
+	pointsLedgerManager.EXPECT().ExpirePoints().Return(uint64(0), errors.New("test error"))
+	job.Run()
+}
@@ -0,0 +1,43 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	. "github.com/StampWallet/backend/internal/managers"
+)
+
+// A TransactionReaper periodically moves transactions that were started, but not finalized in time,
+// to TransactionStateExpired. See TransactionManager.ExpireTransactions.
+type TransactionReaper struct {
+	transactionManager TransactionManager
+	interval           time.Duration
+	logger             *log.Logger
+}
+
+func CreateTransactionReaper(transactionManager TransactionManager, interval time.Duration,
+	logger *log.Logger) *TransactionReaper {
+	return &TransactionReaper{
+		transactionManager: transactionManager,
+		interval:           interval,
+		logger:             logger,
+	}
+}
+
+// Expires stale transactions once
+func (reaper *TransactionReaper) Run() {
+	expired, err := reaper.transactionManager.ExpireTransactions()
+	if err != nil {
+		reaper.logger.Printf("transactionManager.ExpireTransactions returned an error: %+v", err)
+		return
+	}
+	if expired != 0 {
+		reaper.logger.Printf("expired %d transactions", expired)
+	}
+}
+
+// Starts the reaper in background. The reaper stops when ctx is cancelled.
+func (reaper *TransactionReaper) Start(ctx context.Context) {
+	startPeriodically(ctx, reaper.logger, reaper.interval, reaper.Run)
+}
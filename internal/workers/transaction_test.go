@@ -0,0 +1,47 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/StampWallet/backend/internal/managers/mocks"
+)
+
+func TestTransactionReaperRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	transactionManager := NewMockTransactionManager(ctrl)
+	reaper := CreateTransactionReaper(transactionManager, time.Minute, log.Default())
+
+	transactionManager.EXPECT().ExpireTransactions().Return(int64(2), nil)
+	reaper.Run()
+
+	// errors are only logged
+	transactionManager.EXPECT().ExpireTransactions().Return(int64(0), errors.New("test error"))
+	reaper.Run()
+}
+
+func TestTransactionReaperStart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	transactionManager := NewMockTransactionManager(ctrl)
+	reaper := CreateTransactionReaper(transactionManager, 10*time.Millisecond, log.Default())
+
+	done := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	transactionManager.EXPECT().ExpireTransactions().Return(int64(0), nil).MinTimes(2).
+		Do(func() {
+			select {
+			case done <- struct{}{}:
+			default:
+			}
+		})
+
+	reaper.Start(ctx)
+	<-done
+	<-done
+	cancel()
+}
@@ -0,0 +1,34 @@
+// Package workers implements background jobs that run alongside the API server.
+// Workers are thin wrappers that periodically call managers - all business logic stays in managers.
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Calls job every interval until ctx is cancelled. job is called once immediately after start.
+func runPeriodically(ctx context.Context, interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	job()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job()
+		}
+	}
+}
+
+// Starts job in a new goroutine, see runPeriodically
+func startPeriodically(ctx context.Context, logger *log.Logger, interval time.Duration, job func()) {
+	if interval <= 0 {
+		logger.Printf("interval is not positive (%s), worker disabled", interval)
+		return
+	}
+	go runPeriodically(ctx, interval, job)
+}